@@ -3,9 +3,75 @@
 // It is used by the gitfs command line.
 package bin
 
-import "github.com/posener/gitfs/internal/binfs"
+import (
+	"net/http"
 
-// Register registers binary data of a given project.
-func Register(project string, version int, data string) {
-	binfs.Register(project, version, data)
+	"github.com/posener/gitfs/internal/binfs"
+)
+
+// VersionError is returned by gitfs.New for a project that was packed with
+// an encoding version that is not supported by the current gitfs version.
+type VersionError = binfs.VersionError
+
+// Register registers binary data of a given project. The data is given in
+// chunks, which are decoded in order without joining them.
+func Register(project string, version int, chunks ...string) {
+	binfs.Register(project, version, chunks...)
+}
+
+// Metadata describes the generation of packed projects.
+type Metadata = binfs.Metadata
+
+// Stats are the number of files of a packed project and their total size.
+type Stats = binfs.Stats
+
+// RegisterMetadata records the metadata of the generation of the given
+// projects. It is called by the generated files after the projects are
+// registered.
+func RegisterMetadata(meta Metadata, projects ...string) {
+	binfs.RegisterMetadata(meta, projects...)
+}
+
+// RegisterDecoder teaches gitfs to decode projects that were packed with the
+// given encoding version. It can be used by a side package to support formats
+// that were introduced in newer gitfs versions. It panics if a decoder for the
+// version was already registered.
+func RegisterDecoder(version int, decoder func(encoded string) (http.FileSystem, error)) {
+	binfs.RegisterDecoder(version, decoder)
+}
+
+// ProjectInfo describes a registered project.
+type ProjectInfo struct {
+	// Project is the project name, without the ref.
+	Project string
+	// Ref is the ref of the project. Empty for the default branch.
+	Ref string
+	// Files is the number of files in the project filesystem, as recorded
+	// when the project was packed. It is zero for projects that were packed
+	// by gitfs versions that did not record it.
+	Files int
+	// Size is the total size in bytes of the files in the project.
+	Size int64
+	// Metadata describes how and when the project was packed, such as the
+	// version and the arguments of the gitfs command. It is nil for projects
+	// that were packed by gitfs versions that did not record it.
+	Metadata *Metadata
+}
+
+// Projects lists all the projects that are registered in the binary.
+// It can be used to introspect the content that was packed into the binary,
+// and it does not decode the packed projects.
+func Projects() []ProjectInfo {
+	projects := binfs.Projects()
+	infos := make([]ProjectInfo, 0, len(projects))
+	for _, p := range projects {
+		infos = append(infos, ProjectInfo{
+			Project:  p.Project,
+			Ref:      p.Ref,
+			Files:    p.Files,
+			Size:     p.Size,
+			Metadata: p.Metadata,
+		})
+	}
+	return infos
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/posener/gitfs"
+)
+
+// apiCalls counts the Github API requests of the loaded filesystems.
+var apiCalls = &apiUsage{}
+
+// apiUsage is a gitfs.Observer that counts the requests that are sent to the
+// Github API.
+type apiUsage struct {
+	requests int64
+	failed   int64
+}
+
+func (u *apiUsage) Request(status int, d time.Duration) {
+	atomic.AddInt64(&u.requests, 1)
+	if status == 0 || status >= 400 {
+		atomic.AddInt64(&u.failed, 1)
+	}
+}
+
+func (*apiUsage) Downloaded(n int)                      {}
+func (*apiUsage) FileLoaded(d time.Duration, err error) {}
+func (*apiUsage) Cache(name string, hit bool)           {}
+
+// printAPIUsage prints the number of requests that were sent to the Github
+// API, and the remaining rate limit of the credentials, such that usage in CI
+// can be budgeted. The rate limit is checked only if requests were sent.
+func printAPIUsage() {
+	if *skipReport {
+		return
+	}
+	var h *gitfs.Health
+	if atomic.LoadInt64(&apiCalls.requests) > 0 {
+		var err error
+		h, err = gitfs.HealthCheck(context.Background())
+		if err != nil && h == nil {
+			log.Printf("Failed checking Github API rate limit: %s", err)
+		}
+	}
+	writeAPIUsage(os.Stdout, apiCalls, h)
+}
+
+// writeAPIUsage writes the API usage, and the rate limit of the given health if
+// it is not nil.
+func writeAPIUsage(w io.Writer, u *apiUsage, h *gitfs.Health) {
+	requests, failed := atomic.LoadInt64(&u.requests), atomic.LoadInt64(&u.failed)
+	fmt.Fprintf(w, "Github API requests: %d", requests)
+	if failed > 0 {
+		fmt.Fprintf(w, " (%d failed)", failed)
+	}
+	fmt.Fprintln(w)
+	if h == nil {
+		return
+	}
+	auth := "authenticated"
+	if !h.Authenticated {
+		auth = "unauthenticated"
+	}
+	fmt.Fprintf(w, "Github API rate limit: %d/%d remaining (%s), resets at %s\n",
+		h.Remaining, h.Limit, auth, h.Reset.Format(time.RFC3339))
+	if requests > 0 && int64(h.Remaining) < requests {
+		fmt.Fprintln(w, "Warning: the remaining rate limit is lower than the requests of this run")
+	}
+}
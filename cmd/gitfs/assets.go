@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/packages"
+)
+
+var assetsDir = flag.String("assets", "", "Generate a standalone package in the given directory, that should be imported with a blank import, instead of the -out file")
+
+// assetsDoc is the content of the doc.go file of a generated assets package.
+const assetsDoc = `// Package %[1]s contains filesystems that were packed by gitfs. It has no
+// API: importing it registers the packed filesystems, such that gitfs.New
+// loads them from the binary instead of the remote repositories. It should be
+// imported with a blank import from the main package.
+package %[1]s
+`
+
+// setupAssets prepares the given directory for a generated assets package. It
+// creates the directory and its doc.go file if they do not exist, and returns
+// the output file and the package name. The package name is the name of the
+// directory if pkg is empty.
+func setupAssets(dir, pkg string) (string, string, error) {
+	if pkg == "" {
+		var err error
+		if pkg, err = assetsPkgName(dir); err != nil {
+			return "", "", err
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", errors.Wrap(err, "creating assets directory")
+	}
+	doc := filepath.Join(dir, "doc.go")
+	if _, err := os.Stat(doc); os.IsNotExist(err) {
+		if err := ioutil.WriteFile(doc, []byte(fmt.Sprintf(assetsDoc, pkg)), 0644); err != nil {
+			return "", "", errors.Wrap(err, "writing doc.go")
+		}
+	}
+	return filepath.Join(dir, "gitfs.go"), pkg, nil
+}
+
+// assetsPkgName returns a package name for the given directory, which is its
+// name without characters that are not valid in package names.
+func assetsPkgName(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	name := strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, filepath.Base(abs))
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		return "", errors.Errorf("directory %q is not a valid package name, use -pkg to set it", filepath.Base(abs))
+	}
+	return name, nil
+}
+
+// printAssetsImport prints the import path of the generated assets package.
+func printAssetsImport(dir string) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName}, abs)
+	if err != nil || len(pkgs) == 0 || pkgs[0].PkgPath == "" {
+		return
+	}
+	log.Printf("Import the generated package with: import _ %q", pkgs[0].PkgPath)
+}
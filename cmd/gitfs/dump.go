@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/posener/gitfs"
+	"github.com/posener/gitfs/fsutil"
+)
+
+// dump runs the dump subcommand, which prints the structure of the
+// filesystem of a project, for debugging.
+func dump(args []string) {
+	flags := flag.NewFlagSet("dump", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Print the structure as JSON")
+	prefetch := flags.Bool("prefetch", false, "Prefetch the content of the files, such that they are all loaded")
+	var globs globFlag
+	flags.Var(&globs, "glob", "Dump only files that match the glob pattern. Can be given multiple times")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), dumpUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(reorderFlags(flags, args))
+	if flags.NArg() != 1 {
+		log.Fatal("Exactly one project should be provided.")
+	}
+
+	fs, err := gitfs.New(context.Background(), flags.Arg(0),
+		gitfs.OptPrefetch(*prefetch), gitfs.OptGlob(globs...))
+	if err != nil {
+		log.Fatalf("Failed loading %s: %s", flags.Arg(0), err)
+	}
+	if err := writeDump(os.Stdout, fs, *asJSON); err != nil {
+		log.Fatalf("Failed dumping %s: %s", flags.Arg(0), err)
+	}
+}
+
+// writeDump writes the structure of the filesystem as JSON or as a tree.
+func writeDump(w io.Writer, fs http.FileSystem, asJSON bool) error {
+	d, err := fsutil.Dump(fs)
+	if err != nil {
+		return err
+	}
+	if !asJSON {
+		_, err = io.WriteString(w, d.String())
+		return err
+	}
+	if d == nil {
+		d = fsutil.FileSystemDump{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+const dumpUsage = `gitfs dump prints the structure of the filesystem of a project.
+
+Usage:
+
+	gitfs dump <project>
+
+The paths and sizes of the files are printed as a tree, with whether their
+content was loaded, for debugging which files the filesystem contains and
+which of them are loaded by the given flags. With -json, the structure is
+printed as a JSON list of entries.
+
+Flags:
+
+`
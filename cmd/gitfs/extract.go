@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/posener/gitfs/fsutil"
+	"github.com/posener/gitfs/internal/binfs"
+)
+
+// extract runs the extract subcommand, which writes the filesystems that are
+// packed in generated files to disk.
+func extract(args []string) {
+	flags := flag.NewFlagSet("extract", flag.ExitOnError)
+	outDir := flags.String("o", ".", "Output directory")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), extractUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(reorderFlags(flags, args))
+	if flags.NArg() == 0 {
+		log.Fatal("At least one generated file should be provided.")
+	}
+
+	for _, path := range flags.Args() {
+		regs, err := readRegistrations(path)
+		if err != nil {
+			log.Fatalf("Failed reading %s: %s", path, err)
+		}
+		for _, r := range regs {
+			dir := filepath.Join(*outDir, filepath.FromSlash(r.Project))
+			if err := extractRegistration(r, dir); err != nil {
+				log.Fatalf("Failed extracting %s: %s", r.Project, err)
+			}
+			log.Printf("Extracted %s to %s", r.Project, dir)
+		}
+	}
+}
+
+// extractRegistration decodes a registered filesystem and writes it to the
+// given directory.
+func extractRegistration(r registration, dir string) error {
+	if r.Encoded == "" {
+		return errNotEncoded
+	}
+	fs, err := binfs.Decode(r.Project, r.Version, r.Encoded)
+	if err != nil {
+		return err
+	}
+	return writeFS(fs, dir)
+}
+
+// writeFS writes the files of the filesystem to the given directory, with
+// their permissions. Symlinks are written as symlinks to their targets.
+func writeFS(fs http.FileSystem, dir string) error {
+	walk := fsutil.Walk(fs, "/")
+	for walk.Step() {
+		if err := walk.Err(); err != nil {
+			return err
+		}
+		path := filepath.Join(dir, filepath.FromSlash(walk.Path()))
+		st := walk.Stat()
+		if st.IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := writeFile(fs, walk.Path(), path, st.Mode()); err != nil {
+			return errors.Wrap(err, walk.Path())
+		}
+	}
+	return nil
+}
+
+func writeFile(fs http.FileSystem, name, path string, mode os.FileMode) error {
+	f, err := fs.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if mode&os.ModeSymlink != 0 {
+		target, err := ioutil.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(string(target), path)
+	}
+	perm := mode.Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, f)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// reorderFlags moves the flags before the positional arguments, such that
+// flags can be given after them, as in `gitfs extract gitfs.go -o dir`.
+// Flags of the given flag set that are boolean do not take the next
+// argument as their value.
+func reorderFlags(flags *flag.FlagSet, args []string) []string {
+	var ordered, positional []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--":
+			return append(append(ordered, args[i:]...), positional...)
+		case len(args[i]) > 1 && args[i][0] == '-':
+			ordered = append(ordered, args[i])
+			if i+1 < len(args) && !strings.Contains(args[i], "=") && !isBoolFlag(flags, args[i]) {
+				i++
+				ordered = append(ordered, args[i])
+			}
+		default:
+			positional = append(positional, args[i])
+		}
+	}
+	return append(ordered, positional...)
+}
+
+// isBoolFlag returns true if the given argument is a boolean flag of the flag
+// set.
+func isBoolFlag(flags *flag.FlagSet, arg string) bool {
+	f := flags.Lookup(strings.TrimLeft(arg, "-"))
+	if f == nil {
+		return false
+	}
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+const extractUsage = `gitfs extract writes the filesystems that are packed in generated files to disk.
+
+Usage:
+
+	gitfs extract <generated files> -o <dir>
+
+Each packed project is written to a directory named after the project in the
+output directory, for example <dir>/github.com/x/y. It enables inspecting the
+packed content, or migrating away from packing, without running the code
+that uses it.
+
+Flags:
+
+`
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// registration is a call to bin.Register in a generated file.
+type registration struct {
+	Project string
+	Version int
+	// Encoded is the encoded filesystem. It is empty if it could not be read
+	// from the call.
+	Encoded string
+}
+
+// readRegistrations returns the calls to bin.Register in a generated file.
+func readRegistrations(path string) ([]registration, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	var regs []registration
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || !isPkgDot(call.Fun, "bin", "Register") || len(call.Args) < 3 {
+			return true
+		}
+		project, ok := stringLit(call.Args[0])
+		if !ok {
+			return true
+		}
+		r := registration{Project: project}
+		if lit, ok := call.Args[1].(*ast.BasicLit); ok && lit.Kind == token.INT {
+			r.Version, _ = strconv.Atoi(lit.Value)
+		}
+		r.Encoded = encodedArgs(call.Args[2:])
+		regs = append(regs, r)
+		return true
+	})
+	return regs, nil
+}
+
+// encodedArgs returns the encoded data of the chunks arguments of
+// bin.Register, which are string literals in the generated files.
+func encodedArgs(args []ast.Expr) string {
+	if len(args) == 1 {
+		return encodedExpr(args[0])
+	}
+	var b strings.Builder
+	for _, arg := range args {
+		s, ok := stringLit(arg)
+		if !ok {
+			return ""
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+// encodedExpr returns the string of a single encoded argument of
+// bin.Register, which is a string literal, or a strings.Join call of string
+// literals with an empty separator, as older generated files contain.
+func encodedExpr(expr ast.Expr) string {
+	if s, ok := stringLit(expr); ok {
+		return s
+	}
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || !isPkgDot(call.Fun, "strings", "Join") || len(call.Args) != 2 {
+		return ""
+	}
+	if sep, ok := stringLit(call.Args[1]); !ok || sep != "" {
+		return ""
+	}
+	list, ok := call.Args[0].(*ast.CompositeLit)
+	if !ok {
+		return ""
+	}
+	var b strings.Builder
+	for _, elt := range list.Elts {
+		s, ok := stringLit(elt)
+		if !ok {
+			return ""
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+// stringLit returns the value of a string literal expression.
+func stringLit(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// isPkgDot returns true if expr is a selector of the given name in the given
+// package.
+func isPkgDot(expr ast.Expr, pkg, name string) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	id, ok := sel.X.(*ast.Ident)
+	return ok && id.Name == pkg && sel.Sel.Name == name
+}
+
+// errNotEncoded is returned for registrations that their encoded filesystem
+// could not be read from the generated file.
+var errNotEncoded = errors.New("encoded filesystem is not a string literal")
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/posener/gitfs"
+	"github.com/posener/gitfs/internal/binfs"
+)
+
+var licensesOut = flag.String("licenses", "", "Output file for a notice of the licenses of the packed projects (not generated if empty)")
+
+// licensePrefixes are the prefixes of the names of license files, in lower
+// case.
+var licensePrefixes = []string{"license", "licence", "copying", "notice", "unlicense"}
+
+// licensePatterns are glob patterns of license files in the root directory of
+// a repository.
+var licensePatterns = []string{
+	"LICENSE*", "License*", "license*",
+	"LICENCE*", "Licence*", "licence*",
+	"COPYING*", "Copying*", "copying*",
+	"NOTICE*", "Notice*", "notice*",
+	"UNLICENSE*", "Unlicense*", "unlicense*",
+}
+
+// projectLicenses are the license files of a packed project.
+type projectLicenses struct {
+	Project string
+	Files   []licenseFile
+}
+
+// licenseFile is a license file of a project.
+type licenseFile struct {
+	Name    string
+	Content string
+}
+
+func createLicenses(calls binfs.Calls, fss map[string]http.FileSystem) {
+	licenses, err := collectLicenses(calls, fss, loadRootLicenses)
+	if err != nil {
+		log.Fatalf("Failed collecting licenses: %s", err)
+	}
+	for _, l := range licenses {
+		if len(l.Files) == 0 {
+			log.Printf("No license file was found for %s", l.Project)
+		}
+	}
+	f, err := os.Create(*licensesOut)
+	if err != nil {
+		log.Fatalf("Failed creating file %q: %s", *licensesOut, err)
+	}
+	defer f.Close()
+	writeLicenses(f, licenses)
+}
+
+// collectLicenses returns the license files of the packed projects, sorted by
+// the projects names. The license files are looked up in the root directory
+// of the packed filesystem, and if it has none, in the root directory of the
+// repository, which is loaded with loadRoot. Projects that failed loading are
+// omitted.
+func collectLicenses(calls binfs.Calls, fss map[string]http.FileSystem, loadRoot func(project string) (http.FileSystem, error)) ([]projectLicenses, error) {
+	var licenses []projectLicenses
+	for _, c := range calls {
+		fs, ok := fss[c.Project]
+		if !ok {
+			continue
+		}
+		files, err := findLicenses(fs)
+		if err != nil {
+			return nil, errors.Wrap(err, c.Project)
+		}
+		// License files that were not packed are looked up in the root of the
+		// repository.
+		if root := repoRoot(c.SourceProject()); len(files) == 0 && (root != c.SourceProject() || len(c.GlobPatterns()) > 0) {
+			rootFS, err := loadRoot(root)
+			if err != nil {
+				return nil, errors.Wrapf(err, "loading %s", root)
+			}
+			if files, err = findLicenses(rootFS); err != nil {
+				return nil, errors.Wrap(err, root)
+			}
+		}
+		licenses = append(licenses, projectLicenses{Project: c.Project, Files: files})
+	}
+	sort.Slice(licenses, func(i, j int) bool { return licenses[i].Project < licenses[j].Project })
+	return licenses, nil
+}
+
+// loadRootLicenses loads the license files of the root directory of the given
+// repository.
+func loadRootLicenses(project string) (http.FileSystem, error) {
+	return gitfs.New(context.Background(), project,
+		gitfs.OptLocal("."), gitfs.OptGlob(licensePatterns...),
+		gitfs.OptDiskCache(diskCacheDir()), gitfs.OptMetrics(apiCalls))
+}
+
+// findLicenses returns the license files in the root directory of the given
+// filesystem, sorted by their names.
+func findLicenses(fs http.FileSystem) ([]licenseFile, error) {
+	dir, err := fs.Open("/")
+	if err != nil {
+		return nil, err
+	}
+	infos, err := dir.Readdir(0)
+	dir.Close()
+	if err != nil {
+		return nil, err
+	}
+	var files []licenseFile
+	for _, info := range infos {
+		if info.IsDir() || !isLicense(info.Name()) {
+			continue
+		}
+		f, err := fs.Open(path.Join("/", info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		content, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, info.Name())
+		}
+		files = append(files, licenseFile{Name: info.Name(), Content: string(content)})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// isLicense returns true if the given file name is a name of a license file.
+func isLicense(name string) bool {
+	name = strings.ToLower(name)
+	for _, prefix := range licensePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// repoRoot returns the project of the root directory of the repository of the
+// given project, with the same ref.
+func repoRoot(project string) string {
+	name, ref := project, ""
+	if i := strings.Index(project, "@"); i >= 0 {
+		name, ref = project[:i], project[i:]
+	}
+	parts := strings.SplitN(name, "/", 4)
+	if len(parts) < 4 {
+		return project
+	}
+	return strings.Join(parts[:3], "/") + ref
+}
+
+// writeLicenses writes a notice of the license files of the given projects.
+func writeLicenses(w io.Writer, licenses []projectLicenses) {
+	const line = "================================================================================"
+	fmt.Fprintf(w, "Third-party notices\n\n")
+	fmt.Fprintf(w, "The following projects are packed into this program by gitfs.\n")
+	for _, l := range licenses {
+		fmt.Fprintf(w, "\n%s\n%s\n%s\n", line, l.Project, line)
+		if len(l.Files) == 0 {
+			fmt.Fprintf(w, "\nNo license file was found.\n")
+		}
+		for _, f := range l.Files {
+			fmt.Fprintf(w, "\n--- %s ---\n\n%s", f.Name, f.Content)
+			if !strings.HasSuffix(f.Content, "\n") {
+				fmt.Fprintln(w)
+			}
+		}
+	}
+}
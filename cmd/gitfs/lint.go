@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/posener/gitfs"
+	"github.com/posener/gitfs/internal/binfs"
+	"github.com/posener/gitfs/internal/glob"
+)
+
+// lint runs the lint subcommand, which validates the gitfs.New calls in the
+// given patterns without generating anything.
+func lint(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), lintUsage)
+		flags.PrintDefaults()
+	}
+	tests := flags.Bool("tests", false, "Also validate gitfs.New calls in _test.go files")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		log.Fatal("At least one file pattern should be provided.")
+	}
+
+	calls, err := loadCalls(*tests, flags.Args())
+	if err != nil {
+		log.Fatalf("Failed loading calls: %s", err)
+	}
+	problems := lintCalls(os.Stdout, calls, func(project string) error {
+		_, err := gitfs.ResolveCommit(context.Background(), project)
+		return err
+	})
+	if problems > 0 {
+		log.Fatalf("Found %d problems in %d projects", problems, len(calls))
+	}
+	log.Printf("Checked %d projects", len(calls))
+}
+
+// lintCalls writes a line for each problem in the given calls, prefixed with
+// the position of the call, and returns the number of problems. The glob
+// patterns of each project are checked, and its project string is checked
+// with resolve, which should fail if it is invalid, if its repository is not
+// reachable or if its ref does not exist.
+func lintCalls(w io.Writer, calls binfs.Calls, resolve func(project string) error) int {
+	keys := make([]string, 0, len(calls))
+	for k := range calls {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	problems := 0
+	for _, k := range keys {
+		c := calls[k]
+		var errs []error
+		if _, err := glob.New(c.GlobPatterns()...); err != nil {
+			errs = append(errs, fmt.Errorf("invalid glob patterns: %s", err))
+		}
+		if err := resolve(c.Project); err != nil {
+			errs = append(errs, err)
+		}
+		for _, err := range errs {
+			for _, pos := range c.Positions() {
+				fmt.Fprintf(w, "%s: %s: %s\n", pos, c.Project, err)
+			}
+			problems++
+		}
+	}
+	return problems
+}
+
+const lintUsage = `gitfs lint validates the 'gitfs.New' calls in the given patterns.
+
+Usage:
+
+	gitfs lint <patterns>
+
+For each project, the command checks that the project string is valid, that
+its repository is reachable and that its ref exists, and that its glob
+patterns are valid. Problems are printed with the positions of the calls, and
+the command fails if any problem was found. Nothing is generated.
+
+Flags:
+
+`
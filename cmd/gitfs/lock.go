@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/posener/gitfs"
+	"github.com/posener/gitfs/internal/binfs"
+	"github.com/posener/gitfs/internal/browserurl"
+	"github.com/posener/gitfs/internal/lockfile"
+)
+
+var (
+	lockPath   = flag.String("lock", "", "Lock file that pins the packed projects to commits. Projects that are not in the lock file are locked to the current commits of their refs, and added to it")
+	updateLock = flag.Bool("update-lock", false, "Lock all the packed projects to the current commits of their refs")
+)
+
+// readLock reads the lock file, or returns an empty lock file if it does not
+// exist.
+func readLock(path string) lockfile.File {
+	lf, err := lockfile.Read(path)
+	if os.IsNotExist(err) {
+		return make(lockfile.File)
+	}
+	if err != nil {
+		log.Fatalf("Failed reading lock file: %s", err)
+	}
+	return lf
+}
+
+// lockedProvider returns a provider that loads projects from the commits that
+// they are locked to, and locks projects that are not locked. The locked
+// commits and the hashes of the loaded content are recorded in the lock file.
+func lockedProvider(lf lockfile.File) func(binfs.Config) (http.FileSystem, error) {
+	return func(c binfs.Config) (http.FileSystem, error) {
+		project := browserurl.Project(c.SourceProject())
+		e, ok := lf[project]
+		if !ok || *updateLock {
+			commit, err := gitfs.ResolveCommit(context.Background(), project, gitfs.OptMetrics(apiCalls))
+			if err != nil {
+				return nil, err
+			}
+			if ok && e.Commit != commit {
+				log.Printf("Updating lock of %s: %s -> %s", project, e.Commit, commit)
+			}
+			e = lockfile.Entry{Commit: commit}
+		}
+		c.Source = lockfile.Pin(project, e.Commit)
+		fs, err := provider(c)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := lockfile.Hash(fs)
+		if err != nil {
+			return nil, err
+		}
+		if e.Hash != "" && e.Hash != hash {
+			log.Printf("Content of %s at the locked commit changed, were its glob patterns changed?", project)
+		}
+		e.Hash = hash
+		lf[project] = e
+		return fs, nil
+	}
+}
@@ -7,11 +7,15 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime/debug"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/pkg/errors"
 	"golang.org/x/tools/go/packages"
@@ -19,6 +23,7 @@ import (
 	"github.com/posener/gitfs"
 	"github.com/posener/gitfs/fsutil"
 	"github.com/posener/gitfs/internal/binfs"
+	"github.com/posener/gitfs/internal/lockfile"
 )
 
 var (
@@ -26,13 +31,42 @@ var (
 	pkg         = flag.String("pkg", "", "Package name for output file (default is the package name of current directory)")
 	skipTestGen = flag.Bool("skip-test-gen", false, "Skip test generation")
 	bootstrap   = flag.Bool("bootstrap", false, "Bootstrap mode. For package internal usage.")
+	diskCache   = flag.Bool("cache", false, "Cache remote content in the user cache directory, shared with other processes that use gitfs")
+	tests       = flag.Bool("tests", false, "Also look for gitfs.New calls in _test.go files")
+	precompress = flag.Bool("precompress", false, "Store gzip compressed copies of files that compress well, to be served compressed by fsutil.FileServer")
 )
 
+// chunkSize is the maximal length of a single string literal in the
+// generated binary file. Packed data is split to chunks of this size, since
+// huge string literals slow down editors and tools, and may hit compiler
+// limits.
+const chunkSize = 4096
+
 // templates are used for the generated files. They
 // are loaded with loadTemplate function call.
 var templates *template.Template
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "outdated":
+			outdated(os.Args[2:])
+			return
+		case "lint":
+			lint(os.Args[2:])
+			return
+		case "extract":
+			extract(os.Args[2:])
+			return
+		case "pack":
+			pack(os.Args[2:])
+			return
+		case "dump":
+			dump(os.Args[2:])
+			return
+		}
+	}
+
 	// Parse flags
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), usage)
@@ -42,7 +76,29 @@ func main() {
 	if len(flag.Args()) == 0 {
 		log.Fatal("At least one file pattern should be provided.")
 	}
+	setup()
 
+	calls, err := loadCalls(*tests, flag.Args())
+	if err != nil {
+		log.Fatalf("Failed loading binaries: %s", err)
+	}
+	if len(calls) == 0 {
+		log.Fatalf("Did not found any calls for gitfs.New")
+	}
+	packCalls(calls)
+}
+
+// loadCalls loads the gitfs.New calls in the given patterns, including the
+// calls in test files if tests is set.
+func loadCalls(tests bool, patterns []string) (binfs.Calls, error) {
+	if tests {
+		return binfs.LoadCallsWithTests(patterns...)
+	}
+	return binfs.LoadCalls(patterns...)
+}
+
+// setup prepares the generation of files according to the flags.
+func setup() {
 	gitfs.SetLogger(log.New(os.Stderr, "[gitfs] ", log.LstdFlags))
 	log.Printf("Starting binary packing...")
 	log.Printf("Encoding version: %d", binfs.EncodeVersion)
@@ -50,6 +106,12 @@ func main() {
 
 	// Fix flags.
 	var err error
+	if *assetsDir != "" {
+		*out, *pkg, err = setupAssets(*assetsDir, *pkg)
+		if err != nil {
+			log.Fatalf("Invalid assets flag: %s", err)
+		}
+	}
 	*out, err = getOut(*out)
 	if err != nil {
 		log.Fatalf("Invalid out flag: %s", err)
@@ -58,30 +120,60 @@ func main() {
 	if err != nil {
 		log.Fatalf("Invalid: pkg must be provided if output is not a Go package: %s", err)
 	}
+}
 
-	calls, err := binfs.LoadCalls(flag.Args()...)
-	if err != nil {
-		log.Fatalf("Failed loading binaries: %s", err)
+// packCalls packs the projects of the given calls and generates the output
+// files.
+func packCalls(calls binfs.Calls) {
+	if err := refs.apply(calls); err != nil {
+		log.Fatalf("Invalid ref flag: %s", err)
 	}
-	if len(calls) == 0 {
-		log.Fatalf("Did not found any calls for gitfs.New")
+	for _, c := range calls {
+		c.Precompress = *precompress
 	}
 
-	binaries := binfs.GenerateBinaries(calls, provider)
+	provide := provider
+	var lf lockfile.File
+	if *lockPath != "" {
+		lf = readLock(*lockPath)
+		provide = lockedProvider(lf)
+	}
+	fss := make(map[string]http.FileSystem)
+	provide = recordingProvider(provide, fss)
+	binaries := binfs.GenerateBinaries(calls, provide)
+	regs := previousRegistrations(*out)
+	if lf != nil {
+		pruneLock(lf, regs, calls)
+		if err := lf.Write(*lockPath); err != nil {
+			log.Fatalf("Failed writing lock file: %s", err)
+		}
+	}
 
 	// Generate output
-	createOut(binaries)
+	reportStale(*out, regs, calls)
+	createOut(binaries, projectStats(calls, fss))
 	createTest(calls)
+	if *pathsOut != "" {
+		createPaths(fss)
+	}
+	if *licensesOut != "" {
+		createLicenses(calls, fss)
+	}
+	printReport(calls, fss, binaries)
+	printAPIUsage()
+	if *assetsDir != "" {
+		printAssetsImport(*assetsDir)
+	}
 }
 
-func createOut(binaries map[string]string) {
+func createOut(binaries map[string]string, stats map[string]binfs.Stats) {
 	f, err := os.Create(*out)
 	if err != nil {
 		log.Fatalf("Failed creating file %q: %s", *out, err)
 	}
 	defer f.Close()
 
-	err = generate(f, binaries)
+	err = generate(f, binaries, stats)
 	if err != nil {
 		defer os.Remove(*out)
 		log.Fatalf("Failed generating filesystem: %s", err)
@@ -111,18 +203,74 @@ func createTest(calls binfs.Calls) {
 	defer goimports(testPath)
 }
 
-func generate(w io.Writer, binaries map[string]string) error {
+func generate(w io.Writer, binaries map[string]string, stats map[string]binfs.Stats) error {
+	chunks := make(map[string][]string, len(binaries))
+	for project, binary := range binaries {
+		chunks[project] = chunk(binary, chunkSize)
+	}
+	meta := generationMetadata(os.Getenv, os.Args[1:])
+	meta.Stats = stats
 	return templates.ExecuteTemplate(w, "binary.go.gotmpl", struct {
 		Package  string
-		Binaries map[string]string
+		Binaries map[string][]string
 		Version  int
+		Metadata binfs.Metadata
 	}{
 		Package:  *pkg,
-		Binaries: binaries,
+		Binaries: chunks,
 		Version:  binfs.EncodeVersion,
+		Metadata: meta,
 	})
 }
 
+// projectStats returns the number of files and the total size of the packed
+// projects, keyed as their binaries, such that they are recorded in the
+// generated file. Projects that failed loading are omitted.
+func projectStats(calls binfs.Calls, fss map[string]http.FileSystem) map[string]binfs.Stats {
+	stats := make(map[string]binfs.Stats, len(calls))
+	for key, c := range calls {
+		fs, ok := fss[c.Project]
+		if !ok {
+			continue
+		}
+		s, err := binfs.FSStats(fs)
+		if err != nil {
+			log.Fatalf("Failed listing files of %s: %s", c.Project, err)
+		}
+		stats[key] = s
+	}
+	return stats
+}
+
+// generationMetadata returns the metadata that is recorded in the generated
+// file. The time is taken from the SOURCE_DATE_EPOCH environment variable, if
+// it is set, for reproducible generation.
+func generationMetadata(getenv func(string) string, args []string) binfs.Metadata {
+	meta := binfs.Metadata{Tool: "(devel)", Time: time.Now().UTC(), Args: args}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		meta.Tool = info.Main.Version
+	}
+	if epoch := getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		if sec, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			meta.Time = time.Unix(sec, 0).UTC()
+		} else {
+			log.Printf("Invalid SOURCE_DATE_EPOCH %q: %s", epoch, err)
+		}
+	}
+	return meta
+}
+
+// chunk splits s to strings of the given size. The last chunk may
+// be shorter.
+func chunk(s string, size int) []string {
+	chunks := make([]string, 0, len(s)/size+1)
+	for len(s) > size {
+		chunks = append(chunks, s[:size])
+		s = s[size:]
+	}
+	return append(chunks, s)
+}
+
 func generateTest(w io.Writer, calls binfs.Calls, testName string) error {
 	return templates.ExecuteTemplate(w, "test.go.gotmpl", struct {
 		Package  string
@@ -234,6 +382,121 @@ The calls for 'gitfs.New' must contain an explicit string represented
 project name. With the current implementation, the project can't be
 inferred from a variable or a constant.
 
+Calls in _test.go files are not packed by default, since filesystems that
+are used only by tests are usually not needed in the binary. Use -tests to
+pack them too, such that tests do not access the network.
+
+
+Lock file:
+
+With the -lock flag, the projects are packed from the commits that they are
+locked to in the given lock file, such that the packed content is
+reproducible. Projects that are not locked yet are locked to the current
+commits of their refs, and -update-lock locks all the projects to the current
+commits of their refs. The lock file should be committed, such that updates
+of the packed commits are reviewed. The library can use the same lock file
+with gitfs.OptLockFile. A lock file can be shared by several output files:
+regenerating an output file removes only the locks of the projects that were
+packed into it and are not packed anymore.
+
+Running 'gitfs outdated' reports the locked projects that their refs point to
+newer commits.
+
+Lint:
+
+Running 'gitfs lint <patterns>' validates the 'gitfs.New' calls without
+generating anything, such that typos in project strings and glob patterns are
+caught before they fail at runtime.
+
+Ref overrides:
+
+The -ref flag packs a project from a different ref than the one in the code,
+for example '-ref github.com/x/y=v1.2.3' packs the v1.2.3 tag of all the
+usages of github.com/x/y, such as release artifacts can be built from the
+same source that uses the default branch. The packed filesystems are still
+loaded by the projects in the code.
+
+Path constants:
+
+With the -paths flag, a Go file with a constant for the path of every packed
+file is generated, such that the code can refer to the files with constants
+instead of string literals, and a removed or renamed file fails compilation
+instead of failing at runtime. For example, the path "templates/index.gotmpl"
+is the constant TemplatesIndexGotmpl.
+
+Size report:
+
+After generation, the raw and encoded sizes of every packed project and file
+are printed, with an estimate of the growth of the binary, such that glob
+patterns can be tuned to pack only the needed files. Files are compressed
+together, so their encoded sizes are estimates. Use -skip-report to skip it.
+
+API usage:
+
+After generation, the number of Github API requests that were sent and the
+remaining rate limit of the credentials are printed, such that runs in CI can
+be budgeted, and it is noticed when the rate limit is about to be exhausted.
+Without -cache, projects are downloaded as tarballs, which requires fewer
+requests. It is skipped with -skip-report as well.
+
+Licenses:
+
+With the -licenses flag, a notice file with the license files of every packed
+project is generated, since packing the content of other repositories into a
+binary may require to distribute their licenses with it. License files, such
+as LICENSE, COPYING and NOTICE, are taken from the root directory of the
+packed project, or from the root directory of its repository if the packed
+content does not have them. Projects without a license file are reported.
+
+Assets package:
+
+With the -assets flag, the packed filesystems are generated into a standalone
+package in the given directory, instead of into the -out file in the scanned
+package, such that large generated files are kept out of hand written
+packages. The package name is the name of the directory, unless -pkg is given.
+The package should be imported with a blank import from the main package,
+which registers the packed filesystems:
+
+	import _ "example.com/x/assets"
+
+Precompressed files:
+
+With the -precompress flag, files that compress well, such as HTML, CSS and
+JavaScript files, are packed also gzip compressed. fsutil.FileServer serves
+them compressed to clients that accept gzip, without compressing them at
+runtime. The packed data is larger, since these files are stored twice.
+
+Metadata:
+
+The generated file records the version of the gitfs command, the time of the
+generation and the command line arguments, which are available at runtime
+with bin.Projects. The time is taken from the SOURCE_DATE_EPOCH environment
+variable, if it is set, for reproducible generation.
+
+Pruning:
+
+When the output file is regenerated, projects that were packed into it but
+are not used by any 'gitfs.New' call anymore are dropped, and reported, such
+that unused packed data does not stay in the binary. Their entries are also
+removed from the lock file.
+
+Pack:
+
+Running 'gitfs pack <projects>' packs the given projects, without scanning
+any Go source, for code that builds project names dynamically. The flags of
+the pack subcommand are the flags of gitfs, in addition to the flags that are
+otherwise inferred from the 'gitfs.New' calls, such as -glob.
+
+Extract:
+
+Running 'gitfs extract <generated files> -o <dir>' writes the packed
+filesystems of generated files to disk.
+
+Dump:
+
+Running 'gitfs dump <project>' prints the files of the filesystem of a
+project, with their sizes and whether they were loaded, for debugging. Use
+-json for a machine readable output.
 
 Example:
 
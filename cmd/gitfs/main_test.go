@@ -1,13 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs"
+	"github.com/posener/gitfs/fsutil"
+	"github.com/posener/gitfs/internal/binfs"
+	"github.com/posener/gitfs/internal/lockfile"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -102,6 +112,64 @@ func TestGetPkg(t *testing.T) {
 	}
 }
 
+func TestSetupAssets(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assets := filepath.Join(dir, "my-assets")
+	out, pkg, err := setupAssets(assets, "")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(assets, "gitfs.go"), out)
+	assert.Equal(t, "myassets", pkg)
+	doc, err := ioutil.ReadFile(filepath.Join(assets, "doc.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(doc), "\npackage myassets\n")
+
+	// An existing doc.go is kept.
+	_, pkg, err = setupAssets(assets, "other")
+	require.NoError(t, err)
+	assert.Equal(t, "other", pkg)
+	doc2, err := ioutil.ReadFile(filepath.Join(assets, "doc.go"))
+	require.NoError(t, err)
+	assert.Equal(t, doc, doc2)
+
+	_, _, err = setupAssets(filepath.Join(dir, "1x"), "")
+	assert.Error(t, err)
+}
+
+func TestGenerationMetadata(t *testing.T) {
+	t.Parallel()
+	env := map[string]string{"SOURCE_DATE_EPOCH": "1600000000"}
+	meta := generationMetadata(func(name string) string { return env[name] }, []string{"-out", "x.go", "./..."})
+	assert.Equal(t, time.Unix(1600000000, 0).UTC(), meta.Time)
+	assert.Equal(t, []string{"-out", "x.go", "./..."}, meta.Args)
+	assert.NotEmpty(t, meta.Tool)
+
+	meta = generationMetadata(func(string) string { return "" }, nil)
+	assert.WithinDuration(t, time.Now(), meta.Time, time.Minute)
+}
+
+func TestChunk(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{s: "", want: []string{""}},
+		{s: "ab", want: []string{"ab"}},
+		{s: "abc", want: []string{"abc"}},
+		{s: "abcd", want: []string{"abc", "d"}},
+		{s: "abcdef", want: []string{"abc", "def"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			assert.Equal(t, tt.want, chunk(tt.s, 3))
+		})
+	}
+}
+
 func runGo(t *testing.T, args ...string) (stderr string, err error) {
 	cmd := exec.Command("go", args...)
 	stderrBuf, err := cmd.StderrPipe()
@@ -126,3 +194,389 @@ func clean() {
 		os.Remove(path)
 	}
 }
+
+func TestStaleProjects(t *testing.T) {
+	t.Parallel()
+	calls := make(binfs.Calls)
+	calls.Add("github.com/x/a", nil, false, false)
+	calls.Add("github.com/x/b@v1", nil, false, false)
+	regs := []registration{
+		{Project: "github.com/x/c"},
+		{Project: "github.com/x/a"},
+		{Project: "github.com/x/b@tags/v1"},
+		{Project: "github.com/x/b"},
+	}
+	assert.Equal(t, []string{"github.com/x/b", "github.com/x/c"}, staleProjects(regs, calls))
+}
+
+func TestPruneLock(t *testing.T) {
+	t.Parallel()
+	calls := make(binfs.Calls)
+	calls.Add("github.com/x/a", nil, false, false)
+	calls.Add("github.com/x/b@v1", nil, false, false)
+	calls["github.com/x/b@tags/v1"].Source = "github.com/x/b@v2"
+	regs := []registration{
+		{Project: "github.com/x/a"},
+		{Project: "github.com/x/b@v1"},
+		{Project: "github.com/x/c"},
+	}
+	lf := lockfile.File{
+		"github.com/x/a":    {Commit: "c1"},
+		"github.com/x/b@v1": {Commit: "c2"},
+		"github.com/x/b@v2": {Commit: "c3"},
+		"github.com/x/c":    {Commit: "c4"},
+		"github.com/x/d":    {Commit: "c5"},
+	}
+	pruneLock(lf, regs, calls)
+	// The lock of d, that was not packed into the output file, is kept.
+	assert.Equal(t, lockfile.File{
+		"github.com/x/a":    {Commit: "c1"},
+		"github.com/x/b@v2": {Commit: "c3"},
+		"github.com/x/d":    {Commit: "c5"},
+	}, lf)
+}
+
+func TestPruneLock_sharedLock(t *testing.T) {
+	t.Parallel()
+	lf := lockfile.File{
+		"github.com/x/a": {Commit: "c1"},
+		"github.com/x/b": {Commit: "c2"},
+	}
+
+	// Each output file packs one of the projects, with the same lock file.
+	outputs := []struct {
+		project string
+		regs    []registration
+	}{
+		{project: "github.com/x/a", regs: []registration{{Project: "github.com/x/a"}}},
+		{project: "github.com/x/b", regs: []registration{{Project: "github.com/x/b"}}},
+	}
+	for _, out := range outputs {
+		calls := make(binfs.Calls)
+		calls.Add(out.project, nil, false, false)
+		pruneLock(lf, out.regs, calls)
+	}
+	assert.Equal(t, lockfile.File{
+		"github.com/x/a": {Commit: "c1"},
+		"github.com/x/b": {Commit: "c2"},
+	}, lf)
+}
+
+func TestCheckOutdated(t *testing.T) {
+	t.Parallel()
+	lf := lockfile.File{
+		"github.com/x/a":    {Commit: "c1"},
+		"github.com/x/b@v1": {Commit: "c2"},
+	}
+	remote := map[string]string{"github.com/x/a": "c1", "github.com/x/b@v1": "c3"}
+	resolve := func(project string) (string, error) { return remote[project], nil }
+
+	var b bytes.Buffer
+	behind, err := checkOutdated(&b, lf, []string{"github.com/x/a", "github.com/x/b@v1", "github.com/x/c"}, resolve)
+	require.NoError(t, err)
+	assert.Equal(t, 1, behind)
+	assert.Equal(t, "github.com/x/a c1\ngithub.com/x/b@v1 c2 [c3]\ngithub.com/x/c (not locked)\n", b.String())
+}
+
+func TestRegisteredProjects(t *testing.T) {
+	t.Parallel()
+	f, err := ioutil.TempFile("", "gitfs*.go")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`package p
+
+func init() {
+	bin.Register("github.com/x/a", 2, "")
+	bin.Register("github.com/x/b@v1", 2, strings.Join([]string{""}, ""))
+	bin.Register("github.com/x/d", 2, "a", "b")
+	other.Register("github.com/x/c", 2, "")
+}
+`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	projects, err := registeredProjects(f.Name())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"github.com/x/a", "github.com/x/b@v1", "github.com/x/d"}, projects)
+}
+
+func TestLintCalls(t *testing.T) {
+	t.Parallel()
+	calls, err := binfs.LoadCalls("../../internal/binfs/testdata")
+	require.NoError(t, err)
+
+	resolve := func(project string) error {
+		if project == "github.com/a/b" {
+			return errors.New("ref not found")
+		}
+		return nil
+	}
+	var b bytes.Buffer
+	problems := lintCalls(&b, calls, resolve)
+	assert.Equal(t, 1, problems)
+	assert.Regexp(t, `testdata.go:12:\d+: github.com/a/b: ref not found\n$`, b.String())
+}
+
+func TestConstName(t *testing.T) {
+	t.Parallel()
+	tests := []struct{ path, want string }{
+		{path: "templates/index.gotmpl", want: "TemplatesIndexGotmpl"},
+		{path: "static/main-v2.min.js", want: "StaticMainV2MinJs"},
+		{path: "404.html", want: "Path404Html"},
+		{path: "_", want: "Path"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, constName(tt.path), tt.path)
+	}
+}
+
+func TestPathConsts(t *testing.T) {
+	t.Parallel()
+	fs1, err := gitfs.NewFromMap(map[string][]byte{"a/b.txt": nil, "a-b.txt": nil, "empty/": nil})
+	require.NoError(t, err)
+	fs2, err := gitfs.NewFromMap(map[string][]byte{"a/b.txt": nil, "c.txt": nil})
+	require.NoError(t, err)
+
+	consts, err := pathConsts(map[string]http.FileSystem{"github.com/x/y": fs1, "github.com/x/z": fs2})
+	require.NoError(t, err)
+	assert.Equal(t, []pathConst{
+		{Name: "ABTxt", Path: "a-b.txt"},
+		{Name: "ABTxt2", Path: "a/b.txt"},
+		{Name: "CTxt", Path: "c.txt"},
+	}, consts)
+
+	var b bytes.Buffer
+	loadTemplates()
+	*pkg = "assets"
+	require.NoError(t, generatePaths(&b, consts))
+	assert.Contains(t, b.String(), "package assets")
+	assert.Contains(t, b.String(), `ABTxt2 = "a/b.txt"`)
+}
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+	fs, err := gitfs.NewFromMap(map[string][]byte{"a.txt": []byte("a"), "d/b.txt": []byte("b")})
+	require.NoError(t, err)
+	binaries := binfs.GenerateBinaries(binfs.Calls{"github.com/x/y@v1": &binfs.Config{Project: "github.com/x/y@v1"}},
+		func(binfs.Config) (http.FileSystem, error) { return fs, nil })
+
+	dir, err := ioutil.TempDir("", "gitfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	generated := filepath.Join(dir, "gitfs.go")
+	f, err := os.Create(generated)
+	require.NoError(t, err)
+	loadTemplates()
+	chunks := map[string][]string{"github.com/x/y@v1": chunk(binaries["github.com/x/y@v1"], 10)}
+	require.NoError(t, templates.ExecuteTemplate(f, "binary.go.gotmpl", struct {
+		Package  string
+		Binaries map[string][]string
+		Version  int
+		Metadata binfs.Metadata
+	}{Package: "p", Binaries: chunks, Version: binfs.EncodeVersion, Metadata: binfs.Metadata{Args: []string{`"quoted"`}}}))
+	require.NoError(t, f.Close())
+
+	regs, err := readRegistrations(generated)
+	require.NoError(t, err)
+	require.Len(t, regs, 1)
+	assert.Equal(t, "github.com/x/y@v1", regs[0].Project)
+	assert.Equal(t, binfs.EncodeVersion, regs[0].Version)
+
+	out := filepath.Join(dir, "out")
+	require.NoError(t, extractRegistration(regs[0], out))
+	content, err := ioutil.ReadFile(filepath.Join(out, "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(out, "d", "b.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "b", string(content))
+}
+
+func TestReorderFlags(t *testing.T) {
+	t.Parallel()
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.String("o", "", "")
+	flags.Bool("b", false, "")
+	assert.Equal(t, []string{"-o", "dir", "a.go", "b.go"}, reorderFlags(flags, []string{"a.go", "-o", "dir", "b.go"}))
+	assert.Equal(t, []string{"-o=dir", "a.go"}, reorderFlags(flags, []string{"a.go", "-o=dir"}))
+	assert.Equal(t, []string{"--", "-a.go", "b.go"}, reorderFlags(flags, []string{"b.go", "--", "-a.go"}))
+	assert.Equal(t, []string{"-b", "--o", "dir", "a.go", "b.go"}, reorderFlags(flags, []string{"a.go", "-b", "b.go", "--o", "dir"}))
+}
+
+func TestRefOverrides(t *testing.T) {
+	t.Parallel()
+
+	r := make(refOverrides)
+	require.NoError(t, r.Set("github.com/x/y=v1.2.3"))
+	require.NoError(t, r.Set("https://github.com/x/z@master=dev"))
+	assert.Error(t, r.Set("github.com/x/y"))
+	assert.Error(t, r.Set("=v1"))
+	assert.Error(t, r.Set("github.com/x/y="))
+	assert.Equal(t, "github.com/x/y=v1.2.3,github.com/x/z=dev", r.String())
+
+	calls := binfs.Calls{
+		"github.com/x/y":        {Project: "github.com/x/y"},
+		"github.com/x/y@master": {Project: "github.com/x/y@master"},
+		"github.com/x/z":        {Project: "github.com/x/z"},
+		"github.com/x/w":        {Project: "github.com/x/w"},
+	}
+	require.NoError(t, r.apply(calls))
+	assert.Equal(t, "github.com/x/y@v1.2.3", calls["github.com/x/y"].Source)
+	assert.Equal(t, "github.com/x/y@v1.2.3", calls["github.com/x/y@master"].Source)
+	assert.Equal(t, "github.com/x/z@dev", calls["github.com/x/z"].Source)
+	assert.Equal(t, "", calls["github.com/x/w"].Source)
+
+	require.NoError(t, r.Set("github.com/x/unused=v1"))
+	assert.Error(t, r.apply(calls))
+}
+
+func TestProjectSizes(t *testing.T) {
+	t.Parallel()
+
+	fs := http.Dir("../../examples/templates")
+	calls := binfs.Calls{
+		"github.com/x/y": {Project: "github.com/x/y"},
+		"github.com/x/z": {Project: "github.com/x/z"},
+	}
+	fss := map[string]http.FileSystem{"github.com/x/y": fs, "github.com/x/z": fs}
+	binaries := map[string]string{"github.com/x/y": "encoded", "github.com/x/z": ""}
+
+	sizes, err := projectSizes(calls, fss, binaries)
+	require.NoError(t, err)
+	require.Len(t, sizes, 1)
+	s := sizes[0]
+	assert.Equal(t, "github.com/x/y", s.Project)
+	assert.Equal(t, int64(len("encoded")), s.Encoded)
+	require.NotEmpty(t, s.Files)
+	var raw int64
+	for i, f := range s.Files {
+		assert.True(t, f.Encoded > 0, f.Path)
+		if i > 0 {
+			assert.True(t, f.Encoded <= s.Files[i-1].Encoded, "not sorted by encoded size")
+		}
+		raw += f.Raw
+	}
+	assert.Equal(t, raw, s.Raw)
+
+	var out bytes.Buffer
+	writeReport(&out, sizes)
+	assert.Contains(t, out.String(), "github.com/x/y")
+	assert.Contains(t, out.String(), "Estimated binary growth: 7 B\n")
+}
+
+func TestFormatSize(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "0 B", formatSize(0))
+	assert.Equal(t, "1023 B", formatSize(1023))
+	assert.Equal(t, "1.0 KiB", formatSize(1024))
+	assert.Equal(t, "1.5 KiB", formatSize(1536))
+	assert.Equal(t, "2.0 MiB", formatSize(2<<20))
+}
+
+func TestWriteDump(t *testing.T) {
+	t.Parallel()
+
+	fs := http.Dir("../../examples/templates")
+
+	var out bytes.Buffer
+	require.NoError(t, writeDump(&out, fs, false))
+	assert.Contains(t, out.String(), "── tmpl1.gotmpl (13 bytes, loaded)\n")
+
+	out.Reset()
+	require.NoError(t, writeDump(&out, fs, true))
+	var entries []fsutil.DumpEntry
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entries))
+	assert.Contains(t, entries, fsutil.DumpEntry{Path: "/tmpl1.gotmpl", Size: 13, Loaded: true})
+}
+
+func TestWriteAPIUsage(t *testing.T) {
+	t.Parallel()
+
+	u := &apiUsage{}
+	u.Request(http.StatusOK, time.Second)
+	u.Request(http.StatusOK, time.Second)
+	u.Request(http.StatusNotFound, time.Second)
+
+	var out bytes.Buffer
+	writeAPIUsage(&out, u, nil)
+	assert.Equal(t, "Github API requests: 3 (1 failed)\n", out.String())
+
+	out.Reset()
+	reset := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	writeAPIUsage(&out, u, &gitfs.Health{Authenticated: true, Limit: 5000, Remaining: 2, Reset: reset})
+	want := `Github API requests: 3 (1 failed)
+Github API rate limit: 2/5000 remaining (authenticated), resets at 2020-01-01T00:00:00Z
+Warning: the remaining rate limit is lower than the requests of this run
+`
+	assert.Equal(t, want, out.String())
+}
+
+func TestCollectLicenses(t *testing.T) {
+	t.Parallel()
+
+	calls := binfs.Calls{
+		"github.com/x/y":        {Project: "github.com/x/y"},
+		"github.com/x/z/sub@v1": {Project: "github.com/x/z/sub@v1"},
+		"github.com/x/w":        {Project: "github.com/x/w"},
+	}
+	fss := map[string]http.FileSystem{
+		"github.com/x/y":        mapFS(t, map[string]string{"LICENSE": "MIT", "notice.txt": "notice\n", "a": "a"}),
+		"github.com/x/z/sub@v1": mapFS(t, map[string]string{"a": "a"}),
+		"github.com/x/w":        mapFS(t, map[string]string{"a": "a"}),
+	}
+	var loaded []string
+	loadRoot := func(project string) (http.FileSystem, error) {
+		loaded = append(loaded, project)
+		return mapFS(t, map[string]string{"COPYING": "GPL\n"}), nil
+	}
+
+	got, err := collectLicenses(calls, fss, loadRoot)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"github.com/x/z@v1"}, loaded)
+
+	var out bytes.Buffer
+	writeLicenses(&out, got)
+	want := `Third-party notices
+
+The following projects are packed into this program by gitfs.
+
+================================================================================
+github.com/x/w
+================================================================================
+
+No license file was found.
+
+================================================================================
+github.com/x/y
+================================================================================
+
+--- LICENSE ---
+
+MIT
+
+--- notice.txt ---
+
+notice
+
+================================================================================
+github.com/x/z/sub@v1
+================================================================================
+
+--- COPYING ---
+
+GPL
+`
+	assert.Equal(t, want, out.String())
+}
+
+func mapFS(t *testing.T, files map[string]string) http.FileSystem {
+	t.Helper()
+	m := make(map[string][]byte, len(files))
+	for name, content := range files {
+		m[name] = []byte(content)
+	}
+	fs, err := gitfs.NewFromMap(m)
+	require.NoError(t, err)
+	return fs
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/posener/gitfs"
+	"github.com/posener/gitfs/internal/browserurl"
+	"github.com/posener/gitfs/internal/lockfile"
+)
+
+// outdated runs the outdated subcommand, which reports the locked projects
+// that their refs point to newer commits, similar to `go list -u -m all`.
+func outdated(args []string) {
+	flags := flag.NewFlagSet("outdated", flag.ExitOnError)
+	lockPath := flags.String("lock", "gitfs.lock", "Lock file that pins the packed projects to commits")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), outdatedUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	lf, err := lockfile.Read(*lockPath)
+	if err != nil {
+		log.Fatalf("Failed reading lock file: %s", err)
+	}
+	projects := make([]string, 0, len(lf))
+	for project := range lf {
+		projects = append(projects, project)
+	}
+	if flags.NArg() > 0 {
+		projects, err = registeredProjects(flags.Args()...)
+		if err != nil {
+			log.Fatalf("Failed loading generated files: %s", err)
+		}
+	}
+	sort.Strings(projects)
+
+	behind, err := checkOutdated(os.Stdout, lf, projects, func(project string) (string, error) {
+		return gitfs.ResolveCommit(context.Background(), project)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%d of %d projects are behind their refs", behind, len(projects))
+}
+
+// checkOutdated writes a line for each of the given projects with the commit
+// that it is locked to, followed by the current commit of its ref in brackets
+// if it is different. Projects that are not locked are marked as such. It
+// returns the number of projects that are behind their refs.
+func checkOutdated(w io.Writer, lf lockfile.File, projects []string, resolve func(string) (string, error)) (int, error) {
+	behind := 0
+	for _, project := range projects {
+		e, ok := lf[project]
+		if !ok {
+			fmt.Fprintf(w, "%s (not locked)\n", project)
+			continue
+		}
+		commit, err := resolve(project)
+		if err != nil {
+			return 0, errors.Wrapf(err, "resolving %s", project)
+		}
+		if commit == e.Commit {
+			fmt.Fprintf(w, "%s %s\n", project, e.Commit)
+			continue
+		}
+		behind++
+		fmt.Fprintf(w, "%s %s [%s]\n", project, e.Commit, commit)
+	}
+	return behind, nil
+}
+
+// registeredProjects returns the projects that are registered in the given
+// generated files.
+func registeredProjects(paths ...string) ([]string, error) {
+	var projects []string
+	for _, path := range paths {
+		regs, err := readRegistrations(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range regs {
+			projects = append(projects, browserurl.Project(r.Project))
+		}
+	}
+	return projects, nil
+}
+
+const outdatedUsage = `gitfs outdated reports the packed projects that are behind their refs.
+
+Usage:
+
+	gitfs outdated <flags> [<generated files>]
+
+For each project in the lock file, the command prints the commit that it is
+locked to, and if the ref of the project points to a different commit, the
+current commit in brackets:
+
+	github.com/x/y@v1 0123... [4567...]
+
+When generated files are given, only the projects that are packed in them are
+reported, and projects that are not locked are marked as such.
+
+Flags:
+
+`
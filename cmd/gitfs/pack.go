@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/posener/gitfs/internal/binfs"
+)
+
+// pack runs the pack subcommand, which packs the projects that are given as
+// arguments instead of the projects of `gitfs.New` calls in Go source.
+func pack(args []string) {
+	flags := flag.NewFlagSet("pack", flag.ExitOnError)
+	// The pack subcommand generates the same files as gitfs, and accepts the
+	// same flags.
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		if f.Name != "bootstrap" {
+			flags.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	var globs globFlag
+	flags.Var(&globs, "glob", "Pack only files that match the glob pattern. Can be given multiple times")
+	keepEmptyDirs := flags.Bool("keep-empty-dirs", false, "Keep directories that none of their files match the glob patterns")
+	exportIgnore := flags.Bool("export-ignore", false, "Exclude paths with the export-ignore git attribute")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), packUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(reorderFlags(flags, args))
+	if flags.NArg() == 0 {
+		log.Fatal("At least one project should be provided.")
+	}
+	setup()
+
+	calls := make(binfs.Calls)
+	for _, project := range flags.Args() {
+		calls.Add(project, globs, *keepEmptyDirs, *exportIgnore)
+	}
+	packCalls(calls)
+}
+
+// globFlag is a flag of glob patterns, that can be given multiple times.
+type globFlag []string
+
+func (g *globFlag) String() string {
+	return strings.Join(*g, ",")
+}
+
+func (g *globFlag) Set(pattern string) error {
+	*g = append(*g, pattern)
+	return nil
+}
+
+const packUsage = `gitfs pack packs the given projects, without scanning any Go source.
+
+Usage:
+
+	gitfs pack <projects> -out <file>
+
+It is useful for code that builds project names dynamically, and therefore
+its 'gitfs.New' calls can't be found by gitfs. The packed projects are loaded
+by 'gitfs.New' calls with the same projects. For example:
+
+	gitfs pack github.com/x/y/static@v1.2 -glob '*.html' -out assets/gitfs.go
+
+Flags:
+`
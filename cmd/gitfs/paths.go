@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	"github.com/posener/gitfs/fsutil"
+	"github.com/posener/gitfs/internal/binfs"
+)
+
+var pathsOut = flag.String("paths", "", "Output file for constants of the paths of the packed files (not generated if empty)")
+
+// pathConst is a constant of the path of a packed file.
+type pathConst struct {
+	Name string
+	Path string
+}
+
+// recordingProvider returns a provider that records the filesystems that it
+// provides in the given map, by their projects.
+func recordingProvider(provide func(binfs.Config) (http.FileSystem, error), fss map[string]http.FileSystem) func(binfs.Config) (http.FileSystem, error) {
+	return func(c binfs.Config) (http.FileSystem, error) {
+		fs, err := provide(c)
+		if err == nil {
+			fss[c.Project] = fs
+		}
+		return fs, err
+	}
+}
+
+func createPaths(fss map[string]http.FileSystem) {
+	consts, err := pathConsts(fss)
+	if err != nil {
+		log.Fatalf("Failed listing packed files: %s", err)
+	}
+	f, err := os.Create(*pathsOut)
+	if err != nil {
+		log.Fatalf("Failed creating file %q: %s", *pathsOut, err)
+	}
+	defer f.Close()
+
+	err = generatePaths(f, consts)
+	if err != nil {
+		defer os.Remove(*pathsOut)
+		log.Fatalf("Failed generating paths: %s", err)
+	}
+	defer goimports(*pathsOut)
+}
+
+func generatePaths(w io.Writer, consts []pathConst) error {
+	return templates.ExecuteTemplate(w, "paths.go.gotmpl", struct {
+		Package string
+		Paths   []pathConst
+	}{
+		Package: *pkg,
+		Paths:   consts,
+	})
+}
+
+// pathConsts returns the constants of the paths of the files in the given
+// filesystems, sorted by their paths. A path that is in several filesystems
+// has a single constant. Paths that result in the same constant name are
+// distinguished by a numeric suffix.
+func pathConsts(fss map[string]http.FileSystem) ([]pathConst, error) {
+	paths := make(map[string]bool)
+	for project, fs := range fss {
+		walk := fsutil.Walk(fs, "/")
+		for walk.Step() {
+			if err := walk.Err(); err != nil {
+				return nil, errors.Wrap(err, project)
+			}
+			if !walk.Stat().IsDir() {
+				paths[strings.TrimPrefix(walk.Path(), "/")] = true
+			}
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	consts := make([]pathConst, 0, len(sorted))
+	names := make(map[string]bool)
+	for _, path := range sorted {
+		name := constName(path)
+		for i := 2; names[name]; i++ {
+			name = constName(path) + strconv.Itoa(i)
+		}
+		names[name] = true
+		consts = append(consts, pathConst{Name: name, Path: path})
+	}
+	return consts, nil
+}
+
+// constName returns an exported Go identifier for a path, from its
+// alphanumeric parts in title case. For example "templates/index.gotmpl" is
+// TemplatesIndexGotmpl.
+func constName(path string) string {
+	parts := strings.FieldsFunc(path, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, part := range parts {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	name := b.String()
+	if name == "" || !unicode.IsUpper([]rune(name)[0]) {
+		// Identifiers must start with a letter, and exported ones with an
+		// upper case letter.
+		name = "Path" + name
+	}
+	return name
+}
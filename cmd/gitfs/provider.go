@@ -9,6 +9,19 @@ import (
 )
 
 func provider(c binfs.Config) (http.FileSystem, error) {
-	return gitfs.New(context.Background(), c.Project,
-		gitfs.OptPrefetch(true), gitfs.OptLocal("."), gitfs.OptGlob(c.GlobPatterns()...))
+	// The disk cache does not apply to prefetching, which downloads the whole
+	// repository.
+	return gitfs.New(context.Background(), c.SourceProject(),
+		gitfs.OptPrefetch(!*diskCache), gitfs.OptLocal("."), gitfs.OptGlob(c.GlobPatterns()...),
+		gitfs.OptKeepEmptyDirs(c.KeepEmptyDirs()), gitfs.OptExportIgnore(c.ExportIgnore()),
+		gitfs.OptDiskCache(diskCacheDir()), gitfs.OptMetrics(apiCalls))
+}
+
+// diskCacheDir returns the directory of the disk cache, or an empty string if
+// it is disabled.
+func diskCacheDir() string {
+	if !*diskCache {
+		return ""
+	}
+	return gitfs.DefaultCacheDir()
 }
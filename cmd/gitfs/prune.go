@@ -0,0 +1,70 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sort"
+
+	"github.com/posener/gitfs/internal/binfs"
+	"github.com/posener/gitfs/internal/browserurl"
+	"github.com/posener/gitfs/internal/lockfile"
+)
+
+// previousRegistrations returns the projects that are registered in the
+// existing output file, or nil if it does not exist.
+func previousRegistrations(out string) []registration {
+	regs, err := readRegistrations(out)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("Failed reading existing output file %s: %s", out, err)
+		return nil
+	}
+	return regs
+}
+
+// reportStale reports the projects that are registered in the existing output
+// file, but are not packed again since no gitfs.New call uses them anymore.
+// They are dropped when the output file is regenerated.
+func reportStale(out string, regs []registration, calls binfs.Calls) {
+	stale := staleProjects(regs, calls)
+	for _, project := range stale {
+		log.Printf("Pruning project %s, which is not used by any gitfs.New call", project)
+	}
+	if len(stale) > 0 {
+		log.Printf("Pruned %d stale projects from %s", len(stale), out)
+	}
+}
+
+// staleProjects returns the registered projects that are not in the calls,
+// sorted.
+func staleProjects(regs []registration, calls binfs.Calls) []string {
+	var stale []string
+	for _, r := range regs {
+		if !calls.Has(r.Project) {
+			stale = append(stale, r.Project)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// pruneLock removes from the lock file the entries of projects that were
+// registered in the existing output file, and are not packed anymore, such
+// that it does not pin projects that are not used anymore. A lock file may be
+// shared by several output files, so entries of projects that were not packed
+// into this output file are kept.
+func pruneLock(lf lockfile.File, regs []registration, calls binfs.Calls) {
+	used := make(map[string]bool, len(calls))
+	for _, c := range calls {
+		used[browserurl.Project(c.SourceProject())] = true
+	}
+	for _, r := range regs {
+		project := browserurl.Project(r.Project)
+		if _, ok := lf[project]; ok && !used[project] {
+			log.Printf("Removing lock of %s, which is not packed anymore", project)
+			delete(lf, project)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/posener/gitfs/internal/binfs"
+	"github.com/posener/gitfs/internal/browserurl"
+)
+
+// refOverrides maps project names, without refs, to the refs that they are
+// loaded from. It is set with the -ref flag.
+type refOverrides map[string]string
+
+var refs = make(refOverrides)
+
+func init() {
+	flag.Var(refs, "ref", "Override the ref of a project, in the form <project>=<ref>. Can be given multiple times")
+}
+
+func (r refOverrides) String() string {
+	var overrides []string
+	for project, ref := range r {
+		overrides = append(overrides, project+"="+ref)
+	}
+	sort.Strings(overrides)
+	return strings.Join(overrides, ",")
+}
+
+func (r refOverrides) Set(value string) error {
+	i := strings.LastIndex(value, "=")
+	if i <= 0 || i == len(value)-1 {
+		return errors.Errorf("ref override %q should be of the form <project>=<ref>", value)
+	}
+	project, _ := splitRef(browserurl.Project(value[:i]))
+	r[project] = value[i+1:]
+	return nil
+}
+
+// apply sets the source of the calls for projects that their refs are
+// overridden, such that they are packed from the overriding refs under the
+// projects that the code uses. It fails if an override does not match any
+// call.
+func (r refOverrides) apply(calls binfs.Calls) error {
+	used := make(map[string]bool)
+	for _, c := range calls {
+		project, _ := splitRef(browserurl.Project(c.Project))
+		ref, ok := r[project]
+		if !ok {
+			continue
+		}
+		used[project] = true
+		c.Source = project + "@" + ref
+		log.Printf("Packing %s from ref %s", c.Project, ref)
+	}
+	for project := range r {
+		if !used[project] {
+			return errors.Errorf("ref override of %s does not match any gitfs.New call", project)
+		}
+	}
+	return nil
+}
+
+// splitRef splits a project to its name and ref.
+func splitRef(project string) (name, ref string) {
+	if i := strings.Index(project, "@"); i >= 0 {
+		return project[:i], project[i+1:]
+	}
+	return project, ""
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+
+	"github.com/posener/gitfs/fsutil"
+	"github.com/posener/gitfs/internal/binfs"
+)
+
+var skipReport = flag.Bool("skip-report", false, "Skip printing the size report of the packed projects and the Github API usage")
+
+// projectSize is the size of a packed project.
+type projectSize struct {
+	Project string
+	// Raw is the total size of the packed files.
+	Raw int64
+	// Encoded is the size of the encoded project in the generated file.
+	Encoded int64
+	Files   []fileSize
+}
+
+// fileSize is the size of a packed file.
+type fileSize struct {
+	Path string
+	Raw  int64
+	// Encoded is an estimate of the encoded size of the file, since files
+	// are compressed together.
+	Encoded int64
+}
+
+func printReport(calls binfs.Calls, fss map[string]http.FileSystem, binaries map[string]string) {
+	if *skipReport {
+		return
+	}
+	sizes, err := projectSizes(calls, fss, binaries)
+	if err != nil {
+		log.Fatalf("Failed calculating packed sizes: %s", err)
+	}
+	writeReport(os.Stdout, sizes)
+}
+
+// projectSizes returns the sizes of the packed projects and their files,
+// sorted by the projects names. The files of each project are sorted from
+// the largest encoded file to the smallest. Projects that failed loading are
+// omitted.
+func projectSizes(calls binfs.Calls, fss map[string]http.FileSystem, binaries map[string]string) ([]projectSize, error) {
+	var sizes []projectSize
+	for key, c := range calls {
+		fs, ok := fss[c.Project]
+		if !ok || binaries[key] == "" {
+			continue
+		}
+		s := projectSize{Project: c.Project, Encoded: int64(len(binaries[key]))}
+		walk := fsutil.Walk(fs, "")
+		for walk.Step() {
+			if walk.Stat().IsDir() {
+				continue
+			}
+			f, err := fileSizes(fs, walk.Path())
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s: %s", c.Project, walk.Path())
+			}
+			s.Raw += f.Raw
+			s.Files = append(s.Files, f)
+		}
+		if err := walk.Err(); err != nil {
+			return nil, errors.Wrap(err, c.Project)
+		}
+		sort.SliceStable(s.Files, func(i, j int) bool {
+			return s.Files[i].Encoded > s.Files[j].Encoded
+		})
+		sizes = append(sizes, s)
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i].Project < sizes[j].Project })
+	return sizes, nil
+}
+
+// fileSizes returns the raw size of a file and an estimate of its encoded
+// size, which is its compressed size in base64.
+func fileSizes(fs http.FileSystem, path string) (fileSize, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return fileSize{}, err
+	}
+	defer f.Close()
+	var compressed counter
+	w := gzip.NewWriter(&compressed)
+	raw, err := io.Copy(w, f)
+	if err != nil {
+		return fileSize{}, err
+	}
+	if err := w.Close(); err != nil {
+		return fileSize{}, err
+	}
+	return fileSize{
+		Path:    path,
+		Raw:     raw,
+		Encoded: int64(base64.StdEncoding.EncodedLen(int(compressed))),
+	}, nil
+}
+
+// writeReport writes a table of the sizes of the packed projects and their
+// files, followed by their totals. The total encoded size estimates the
+// growth of the binary, since the encoded projects are string constants in
+// the generated file.
+func writeReport(w io.Writer, sizes []projectSize) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "\tRaw\tEncoded\t\n")
+	var raw, encoded int64
+	for _, s := range sizes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t\n", s.Project, formatSize(s.Raw), formatSize(s.Encoded))
+		for _, f := range s.Files {
+			fmt.Fprintf(tw, "  %s\t%s\t~%s\t\n", f.Path, formatSize(f.Raw), formatSize(f.Encoded))
+		}
+		raw += s.Raw
+		encoded += s.Encoded
+	}
+	fmt.Fprintf(tw, "Total\t%s\t%s\t\n", formatSize(raw), formatSize(encoded))
+	tw.Flush()
+	fmt.Fprintf(w, "Estimated binary growth: %s\n", formatSize(encoded))
+}
+
+// formatSize returns a human readable size in bytes.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// counter is a writer that counts the bytes that are written to it.
+type counter int
+
+func (c *counter) Write(p []byte) (int, error) {
+	*c += counter(len(p))
+	return len(p), nil
+}
@@ -1,9 +1,22 @@
 // Code generated by gitfs; DO NOT EDIT
 package main
 
-import "github.com/posener/gitfs/bin"
+import (
+	"time"
 
-func init() {
-	bin.Register("github.com/posener/gitfs/cmd/gitfs/templates", 1, "H4sIAAAAAAAA/6xU3WobRxTedZ3CDqEtfYGeDg6sijy6LQ6+qOO4FIptEtMbx5jR7tnV1KuZ7cwRtln2oukP9CX6fn2JqJxZSVZJWlLIlbTnnPm+7/yOlz9/lKZZFV6S87rGdPk6SXfSRyemwZAuf03S3WPjQ7r8PUkSufxlN00/n+v2MpA3tr66vFoYS1/HwMepSJIvlr/tpumnWyFT5xp+nj5Od5Lkzcd/Ll+nO58QBlK1U7Wjedu8efTHZALPXIlQo0WvCUuY3kNtqApP4fgMTs8u4Pnxdxei1cWNrhG6Tp0Pf/teCDNvnSfIRSYZ2dhaikwWzhLekRQik7Wh2WKqCjeftC4wySSiy//wTaqwINNIMRJiMoELDNR1oPj3VM8R+h6KGRY3AWimCVga6zZW+3uYaypmyC6ExhW6gcJZtKQilINwY9p1tsbWQDMTgMWDX9ghc7g1NIN9jtxnz36NFqpG10pUC1u8S1BO8NWqAupiBJ3ICrqDg0NY1UId6eKm9m5hy3wksta7H7GgwBGXV4H8oqBOZGs7DD0UWVY3bjpExO+eg7oOvLY1wt71GPYKZytTM5B6ppsmwH7fc5DINnAHILtuHanOVxx9L8cc1HVgKlDfNm56ronQ2zVGZD/Y0EfILXb2jmHvOnK/63kWaWMKazJ+jrZcR/RrBWyLpmjZDupFVjkP12MgYqaBfFNAFkXqxcLmRGplHQO36e2WZNkwI2NA7xkrdlud4m1e0B0TPCAMrrOWODHG5jSUUqMR45gqQnx5CNY0A3RG6kSTbqpcnmjTYAmN0yVP2Gow17198tMBPAnyn3TofQSOVYlj+79Efs8vcqnk6IMqH/bn/YWXpqo2uoclVsemqvJVRkMl3l9Hi75yfs5SKj6M94FwDsyyUvIWu/oGDkFGOrmxHbFt4OabFMlLlhi9L+Nw56OnULIcKTdqnnvvPKt54L7VAeauNJXBEnRF6KHRgdY93lxRBecN6oDgcbChemW5FAevLCsvN7L7Ec94Lz4bIB6O8/KvD3Kb//3KTo2VYrhoxhrK4448rPemxXtTY+OOH7FCg6v9nhqrXmBtAqHP46a3W5cF2KB+QB+Ms9D34+EGMVTfy5HYWvpepEnydwAAAP//AB8qHREHAAA=")
+	"github.com/posener/gitfs/bin"
+)
 
+func init() {
+	bin.Register("github.com/posener/gitfs/cmd/gitfs/templates", 2,
+		"H4sIAAAAAAAA/6xW4W7bNhC22maFiGHbI9yEFJAHh95vFxnQJk1RYG2D1t2ftihoiZI5y6RKntekArH83Xv0mfYee4FkOEpy7CQoVqy/YpHH77777r5Djv68HUU7UqM9jS7OBtFOdOdY4Dz6dhDtPHGHyka3BtHdA6NRaozYILrz1OQy+mYQ3X38UdW1zCM2GAwu/rk4i76vBc4dLw0vDS7r6tbF3+MxHJhcQim1tAJlDrNTKBUW7j4cPodnz6fw6PDJlNUiW4hSQtPwY5EtRCm9Z2w8BiLjwBSAcwm1yBYyh0JV0o3Aykqg+kMCmnBrjcEuUlmorfldZug4y4x2CCmLmwas0KUE3qLueR8O+TOxlOA97EPTQG2VxgKSe++TNhC6MKnz8GTIovPo0+D89uuLs+iHmdLCnm4UfX77l/9XtVrWxgbCCaqlTBiLk1LhfDXjmVmOa+NIzHFQcTxTOmFDxoqVzkBphekQmo1SdzsdRrCbzVd64WCyD/whkVayk2CmNH8hS+VQ2jRpmvUj8D4ZkSa7/DdpnTIavB+xeAP+XQ9MuH2KgBoHpHAScFi8KWI83NJ0i8NTiSIXKFI67D8aFsdTY6rJtR71EZyuO4JTtZQTIPn4K61O0qbZjOuPKRh+HvJX04N0SHU9sKWbwOu3Dq3SZQNNs9cXqkawK2xJZV4iUTx43zSgCthVAa+ryvsrPMNr7y+Lhk7KPXp8ifkSBbowc3H4OYGlqF+3jN6SIuGU5Lixy26bYQjuGhJf7e0EmiPyUtB01/HwEYp4qT7K/pR+g2913epZvC6AjiiFv4nRu+2RI/irRDpU74fMd+7a+XRxFn2H0uGmt3b++nrekg6VLhMWJxmttxP8rNOSz7mwcCtUVTDieAxT6ZAGjv72qyWby2zhAOcC+zXWbg5YCszmkq4kVCYTFWRGS42c1t/UgFuouq9W6RJwrhyQLmBXuq0cPiicwx5F7tHNXik1FJUoebsXbiCUIvzUKcCnYWVkeEKN6rTgD0W2KK1Z6TwdsrjrVRiuYI9VhjSCfQ/b8WRxXFZmtjYQDcT1bWF0oVofHYiq6rcFofVwk3ZA2kh+fDkn/QiSYR5XZnYsEKXVPUbIvuFfFm+ZhG7X83jT89YgFLZOtj3w8ZYJNj1wGeRZXBgL70aASJna5F1ljpSOkb9Y6RSRd6cjoDZdb0lMa1HY0xFIawkrdJs/kx/SDE9GsInQXj2vkQojbCqDcz4cEo4qAsSP+6BV1ULHyI8EiqpIkyOhKplDZUROE9YNZgcN995P4J5LttNJawMwSRCHsf0ikr/SizThyfCrMg9EvoB4ropizbs1MT9URZF2FbVK/HcetbSFsUsSMfyTcupQLoGydEyuZecPYB+SkC5Znz2kszY37aQgQk79D7cvg7fS4X3ISZYkWbN5ZK2xxOYy9wfhYGlyVSiZgyhQWqiEw77H6y3K4biSwkmwsj2T/I0mKSZvNDHP17T9kMWeeRadR58G/w4ABgG2TD8KAAA=",
+	)
+	bin.RegisterMetadata(bin.Metadata{
+		Tool: "(devel)",
+		Time: time.Unix(1792205887, 0).UTC(),
+		Args: []string{"-bootstrap", "-skip-report", "-out", "templates.go", "main.go"},
+		Stats: map[string]bin.Stats{
+			"github.com/posener/gitfs/cmd/gitfs/templates": {Files: 3, Size: 2461},
+		},
+	}, "github.com/posener/gitfs/cmd/gitfs/templates")
 }
@@ -0,0 +1,41 @@
+package fsutil
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// All calls fn for every file and directory in the filesystem, except its
+// root, in lexicographic order of their paths. Paths are slash separated and
+// start with a slash, as the names that are given to the Open method of the
+// filesystem. The order does not depend on the order that the filesystem
+// lists directories, such that it is stable between runs, for example for
+// writing manifests. The filesystem is walked before fn is first called, and
+// the walk error, or the first error that fn returns, is returned.
+func All(fs http.FileSystem, fn func(path string, info os.FileInfo) error) error {
+	type entry struct {
+		path string
+		info os.FileInfo
+	}
+	var entries []entry
+	walk := Walk(fs, "/")
+	for walk.Step() {
+		if err := walk.Err(); err != nil {
+			return err
+		}
+		path := filepath.ToSlash(walk.Path())
+		if path == "/" {
+			continue
+		}
+		entries = append(entries, entry{path: path, info: walk.Stat()})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+	for _, e := range entries {
+		if err := fn(e.path, e.info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
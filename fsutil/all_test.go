@@ -0,0 +1,40 @@
+package fsutil
+
+import (
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/tree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAll(t *testing.T) {
+	t.Parallel()
+	tr := make(tree.Tree)
+	require.NoError(t, tr.AddFileContent("b.txt", []byte("b")))
+	require.NoError(t, tr.AddFileContent("a/b.txt", []byte("ab")))
+	require.NoError(t, tr.AddFileContent("a-c.txt", []byte("ac")))
+	require.NoError(t, tr.AddDir("a/d"))
+
+	var paths []string
+	var dirs []bool
+	err := All(tr, func(path string, info os.FileInfo) error {
+		paths = append(paths, path)
+		dirs = append(dirs, info.IsDir())
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/a", "/a-c.txt", "/a/b.txt", "/a/d", "/b.txt"}, paths)
+	assert.Equal(t, []bool{true, false, false, true, false}, dirs)
+
+	// An error stops the iteration.
+	paths = nil
+	err = All(tr, func(path string, info os.FileInfo) error {
+		paths = append(paths, path)
+		return errors.New("failed")
+	})
+	assert.EqualError(t, err, "failed")
+	assert.Equal(t, []string{"/a"}, paths)
+}
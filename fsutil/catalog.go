@@ -0,0 +1,152 @@
+package fsutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message/catalog"
+)
+
+// LoadCatalog loads the translations files in the given directory of the
+// filesystem into a message catalog. Each file contains the translations of
+// a single locale, and is named after its BCP 47 language tag, such as
+// "fr.json" or "pt-BR.toml". Files of other names or extensions are ignored.
+// The translations are looked up in the fallback language when they are
+// missing in the requested language.
+//
+// JSON files contain an object that maps message keys to translations:
+//
+//	{"Hello, %s!": "Bonjour, %s !"}
+//
+// TOML files contain string keys and values, and keys in tables are prefixed
+// with the table name and a dot. Arrays, inline tables and values other than
+// strings are not supported.
+//
+//	"Hello, %s!" = "Bonjour, %s !"
+//
+// The catalog is used for printing messages in a language:
+//
+//	cat, err := fsutil.LoadCatalog(fs, "locales", language.English)
+//	p := message.NewPrinter(language.French, message.Catalog(cat))
+//	p.Printf("Hello, %s!", name)
+func LoadCatalog(fs http.FileSystem, dir string, fallback language.Tag) (*catalog.Builder, error) {
+	d, err := fs.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	infos, err := d.Readdir(-1)
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing %s", dir)
+	}
+	b := catalog.NewBuilder(catalog.Fallback(fallback))
+	for _, info := range infos {
+		ext := path.Ext(info.Name())
+		if info.IsDir() || (ext != ".json" && ext != ".toml") {
+			continue
+		}
+		tag, err := language.Parse(strings.TrimSuffix(info.Name(), ext))
+		if err != nil {
+			continue
+		}
+		name := path.Join(dir, info.Name())
+		messages, err := readMessages(fs, name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "loading %s", name)
+		}
+		for key, msg := range messages {
+			if err := b.SetString(tag, key, msg); err != nil {
+				return nil, errors.Wrapf(err, "%s: %s", name, key)
+			}
+		}
+	}
+	return b, nil
+}
+
+// readMessages returns the messages in a translations file.
+func readMessages(fs http.FileSystem, name string) (map[string]string, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if path.Ext(name) == ".toml" {
+		return parseTOMLStrings(content)
+	}
+	var messages map[string]string
+	if err := json.Unmarshal(content, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// parseTOMLStrings parses the string keys and values of a TOML document. Keys
+// in tables are prefixed with the table name and a dot.
+func parseTOMLStrings(content []byte) (map[string]string, error) {
+	messages := make(map[string]string)
+	table := ""
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			table = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, errors.Errorf("line %d: expected key = value", n)
+		}
+		key, err := tomlString(strings.TrimSpace(line[:i]), true)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", n)
+		}
+		value, err := tomlString(strings.TrimSpace(line[i+1:]), false)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", n)
+		}
+		if table != "" {
+			key = table + "." + key
+		}
+		messages[key] = value
+	}
+	return messages, s.Err()
+}
+
+// tomlString returns the value of a TOML string, which is either quoted with
+// double quotes, or with single quotes for a literal string. Bare strings
+// are allowed only for keys. A comment after the string is ignored.
+func tomlString(s string, key bool) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		prefix, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", errors.Errorf("invalid string %s", s)
+		}
+		return strconv.Unquote(prefix)
+	case strings.HasPrefix(s, "'"):
+		end := strings.Index(s[1:], "'")
+		if end < 0 {
+			return "", errors.Errorf("invalid string %s", s)
+		}
+		return s[1 : end+1], nil
+	case key && s != "":
+		return s, nil
+	default:
+		return "", errors.Errorf("unsupported value %s", s)
+	}
+}
@@ -0,0 +1,48 @@
+package fsutil
+
+import (
+	"testing"
+
+	"github.com/posener/gitfs/internal/tree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+func TestLoadCatalog(t *testing.T) {
+	t.Parallel()
+	tr := make(tree.Tree)
+	require.NoError(t, tr.AddFileContent("locales/fr.json", []byte(`{"Hello, %s!": "Bonjour, %s !"}`)))
+	require.NoError(t, tr.AddFileContent("locales/pt-BR.toml", []byte(`
+# Greetings.
+"Hello, %s!" = "Olá, %s!" # Informal.
+[menu]
+quit = 'Sair'
+`)))
+	require.NoError(t, tr.AddFileContent("locales/README.md", []byte("Translations")))
+
+	cat, err := LoadCatalog(tr, "locales", language.English)
+	require.NoError(t, err)
+
+	p := message.NewPrinter(language.French, message.Catalog(cat))
+	assert.Equal(t, "Bonjour, gopher !", p.Sprintf("Hello, %s!", "gopher"))
+	p = message.NewPrinter(language.BrazilianPortuguese, message.Catalog(cat))
+	assert.Equal(t, "Olá, gopher!", p.Sprintf("Hello, %s!", "gopher"))
+	assert.Equal(t, "Sair", p.Sprintf("menu.quit"))
+	p = message.NewPrinter(language.German, message.Catalog(cat))
+	assert.Equal(t, "Hello, gopher!", p.Sprintf("Hello, %s!", "gopher"))
+}
+
+func TestLoadCatalog_invalid(t *testing.T) {
+	t.Parallel()
+	for _, file := range []string{"fr.json", "fr.toml"} {
+		tr := make(tree.Tree)
+		require.NoError(t, tr.AddFileContent("locales/"+file, []byte(`x = [1, 2]`)))
+		_, err := LoadCatalog(tr, "locales", language.English)
+		assert.Error(t, err, file)
+	}
+
+	_, err := LoadCatalog(make(tree.Tree), "locales", language.English)
+	assert.Error(t, err)
+}
@@ -4,14 +4,19 @@ import (
 	"bytes"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"sort"
 	"strings"
+	"sync"
 	"text/template"
 
 	"github.com/pkg/errors"
 	"github.com/posener/diff"
 )
 
+// diffWorkers is the number of paths that Diff compares concurrently.
+const diffWorkers = 8
+
 const (
 	msgOnlyInA     = "only in {{.A}}"
 	msgOnlyInB     = "only in {{.B}}"
@@ -77,6 +82,10 @@ func Diff(a, b http.FileSystem) (*FileSystemDiff, error) {
 	}
 
 	d := &FileSystemDiff{A: "a", B: "b"}
+	// Paths that exist in both filesystems are compared concurrently after
+	// the structure is compared, since their content might be loaded lazily.
+	// Their diffs are placeholders until then.
+	var common []int
 	// Compare two slices of ordered file names. Always compare first element
 	// in each slice and pop the elements from the slice accordingly.
 	for len(aFiles) > 0 || len(bFiles) > 0 {
@@ -93,29 +102,71 @@ func Diff(a, b http.FileSystem) (*FileSystemDiff, error) {
 			bFiles = bFiles[1:]
 		default:
 			// File exists both in a and in b.
-			path := aFiles[0]
-			diff, err := contentDiff(a, b, path)
+			common = append(common, len(d.Diffs))
+			d.Diffs = append(d.Diffs, PathDiff{Path: aFiles[0]})
+			aFiles = aFiles[1:]
+			bFiles = bFiles[1:]
+		}
+	}
+	if err := contentDiffs(a, b, d.Diffs, common); err != nil {
+		return nil, err
+	}
+
+	// Remove the placeholders of paths that are equal.
+	diffs := d.Diffs[:0]
+	for _, diff := range d.Diffs {
+		if diff.Diff != "" {
+			diffs = append(diffs, diff)
+		}
+	}
+	d.Diffs = diffs
+	return d, nil
+}
+
+// contentDiffs compares the paths of the diffs in the given indices
+// concurrently, and sets the diffs of the paths that are different. The error
+// of the first path that failed is returned.
+func contentDiffs(a, b http.FileSystem, diffs []PathDiff, indices []int) error {
+	errs := make([]error, len(indices))
+	sem := make(chan struct{}, diffWorkers)
+	var wg sync.WaitGroup
+	for i, index := range indices {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i, index int) {
+			defer func() { <-sem; wg.Done() }()
+			diff, err := contentDiff(a, b, diffs[index].Path)
 			if err != nil {
-				return nil, err
+				errs[i] = err
+				return
 			}
 			if diff != nil {
-				d.Diffs = append(d.Diffs, *diff)
+				diffs[index] = *diff
 			}
-			aFiles = aFiles[1:]
-			bFiles = bFiles[1:]
+		}(i, index)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
 		}
 	}
-	return d, nil
+	return nil
 }
 
 // lsR is ls -r. Sorted by name.
 func lsR(fs http.FileSystem) ([]string, error) {
-	w := Walk(fs, "")
-	var paths []string
-	for w.Step() {
-		paths = append(paths, w.Path())
-	}
-	if err := w.Err(); err != nil {
+	var (
+		paths []string
+		mu    sync.Mutex
+	)
+	err := WalkParallel(fs, "", diffWorkers, func(path string, _ os.FileInfo) error {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 	sort.Strings(paths)
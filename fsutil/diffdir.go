@@ -0,0 +1,73 @@
+package fsutil
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/pkg/errors"
+)
+
+// DiffDir returns the difference between a filesystem and a local directory,
+// after both are filtered by the given glob patterns. In the directory, the
+// .git directory and files that are ignored by the .gitignore files under it
+// are hidden, as they would be in a filesystem that was loaded from git. This
+// is useful in tests that verify that a packed filesystem matches the local
+// content:
+//
+//	diff, err := fsutil.DiffDir(fs, "static", "*.html")
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	if d := diff.String(); d != "" {
+//		t.Error(d)
+//	}
+//
+// In the returned diff, the directory is named by its path and the given
+// filesystem is named "filesystem".
+func DiffDir(fs http.FileSystem, dir string, globs ...string) (*FileSystemDiff, error) {
+	patterns, err := gitignore.ReadPatterns(osfs.New(dir), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading .gitignore files")
+	}
+	matcher := gitignore.NewMatcher(patterns)
+	local := Filter(http.Dir(dir), func(path string, isDir bool) bool {
+		return !gitIgnored(matcher, path, isDir)
+	})
+
+	local, err = Glob(local, globs...)
+	if err != nil {
+		return nil, err
+	}
+	fs, err = Glob(fs, globs...)
+	if err != nil {
+		return nil, err
+	}
+	d, err := Diff(local, fs)
+	if err != nil {
+		return nil, err
+	}
+	d.A = dir
+	d.B = "filesystem"
+	return d, nil
+}
+
+// gitIgnored returns true if the given slash separated path, or any of its
+// parent directories, is the .git directory or is ignored by the matcher.
+func gitIgnored(matcher gitignore.Matcher, path string, isDir bool) bool {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return false
+	}
+	parts := strings.Split(path, "/")
+	for i := range parts {
+		if parts[i] == ".git" {
+			return true
+		}
+		if matcher.Match(parts[:i+1], isDir || i < len(parts)-1) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package fsutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/posener/gitfs/internal/tree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffDir(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gitfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		".gitignore":     "ignored/\n*.log\n",
+		".git/HEAD":      "ref: refs/heads/master\n",
+		"a.txt":          "a\n",
+		"b.txt":          "b\n",
+		"c.go":           "package c\n",
+		"out.log":        "log\n",
+		"ignored/d.txt":  "d\n",
+		"dir/e.txt":      "e\n",
+		"dir/f.txt":      "f\n",
+		"dir/.gitignore": "f.txt\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	fs := make(tree.Tree)
+	fs.AddFileContent("a.txt", []byte("a\n"))
+	fs.AddFileContent("b.txt", []byte("B\n"))
+	fs.AddFileContent("dir/e.txt", []byte("e\n"))
+	fs.AddFileContent("dir/g.txt", []byte("g\n"))
+	fs.AddFileContent("c.go", []byte("package d\n"))
+
+	got, err := DiffDir(fs, dir, "*.txt", "dir/*.txt")
+	require.NoError(t, err)
+	want := `Diff between ` + dir + ` and filesystem:
+[b.txt]: content diff (-` + dir + `, +filesystem):
+1-b
+1+B
+[dir/g.txt]: only in filesystem
+`
+	assert.Equal(t, want, got.String())
+
+	_, err = DiffDir(fs, dir, "[")
+	assert.Error(t, err)
+}
@@ -0,0 +1,112 @@
+package fsutil
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// FileSystemDump is the structure of a filesystem, as returned by Dump. It
+// can be encoded to JSON, and its String method formats it as a tree.
+type FileSystemDump []DumpEntry
+
+// DumpEntry is a file or a directory in a filesystem dump.
+type DumpEntry struct {
+	// Path is the path of the entry in the filesystem.
+	Path string `json:"path"`
+	Dir  bool   `json:"dir,omitempty"`
+	// Size is the size of a file.
+	Size int64 `json:"size,omitempty"`
+	// Loaded is whether the content of a file was already loaded, such that
+	// reading it does not load it again. Files of filesystems that are not
+	// lazily loaded are always loaded.
+	Loaded bool `json:"loaded,omitempty"`
+}
+
+// Dump returns the structure of a filesystem, in walk order, without loading
+// the contents of its files. It is meant for debugging which files a gitfs
+// filesystem contains, and which of them were loaded.
+func Dump(fs http.FileSystem) (FileSystemDump, error) {
+	var d FileSystemDump
+	walk := Walk(fs, "/")
+	for walk.Step() {
+		if err := walk.Err(); err != nil {
+			return nil, err
+		}
+		if walk.Path() == "/" {
+			continue
+		}
+		st := walk.Stat()
+		e := DumpEntry{Path: walk.Path(), Dir: st.IsDir()}
+		if !e.Dir {
+			loaded, err := isLoaded(fs, e.Path)
+			if err != nil {
+				return nil, err
+			}
+			e.Size = st.Size()
+			e.Loaded = loaded
+		}
+		d = append(d, e)
+	}
+	return d, nil
+}
+
+// String formats the dump as a tree, similar to the output of the tree
+// command, with the size and the loaded state of every file.
+func (d FileSystemDump) String() string {
+	// last maps a directory to its last entry.
+	last := make(map[string]string)
+	for _, e := range d {
+		last[path.Dir(e.Path)] = e.Path
+	}
+	out := strings.Builder{}
+	out.WriteString("/\n")
+	for _, e := range d {
+		var prefix []string
+		for p := path.Dir(e.Path); p != "/"; p = path.Dir(p) {
+			if last[path.Dir(p)] == p {
+				prefix = append(prefix, "    ")
+			} else {
+				prefix = append(prefix, "│   ")
+			}
+		}
+		for i := len(prefix) - 1; i >= 0; i-- {
+			out.WriteString(prefix[i])
+		}
+		if last[path.Dir(e.Path)] == e.Path {
+			out.WriteString("└── ")
+		} else {
+			out.WriteString("├── ")
+		}
+		out.WriteString(path.Base(e.Path))
+		if e.Dir {
+			out.WriteString("/\n")
+			continue
+		}
+		state := "not loaded"
+		if e.Loaded {
+			state = "loaded"
+		}
+		fmt.Fprintf(&out, " (%d bytes, %s)\n", e.Size, state)
+	}
+	return out.String()
+}
+
+// isLoaded returns whether the content of a file was loaded.
+func isLoaded(fs http.FileSystem, name string) (bool, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if l, ok := f.(loader); ok {
+		return l.Loaded(), nil
+	}
+	return true, nil
+}
+
+// loader is a file that its content is lazily loaded.
+type loader interface {
+	Loaded() bool
+}
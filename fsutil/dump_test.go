@@ -0,0 +1,54 @@
+package fsutil
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/posener/gitfs/internal/tree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDump(t *testing.T) {
+	t.Parallel()
+	tr := make(tree.Tree)
+	require.NoError(t, tr.AddFileContent("a.txt", []byte("abc")))
+	require.NoError(t, tr.AddFileContent("d/b.txt", []byte("hello")))
+	require.NoError(t, tr.AddFileContent("d/e/c.txt", []byte("c")))
+	require.NoError(t, tr.AddFileContent("z.txt", []byte("zz")))
+
+	f, err := tr.Open("a.txt")
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(f)
+	require.NoError(t, err)
+
+	d, err := Dump(tr)
+	require.NoError(t, err)
+	assert.Equal(t, FileSystemDump{
+		{Path: "/a.txt", Size: 3, Loaded: true},
+		{Path: "/d", Dir: true},
+		{Path: "/d/b.txt", Size: 5},
+		{Path: "/d/e", Dir: true},
+		{Path: "/d/e/c.txt", Size: 1},
+		{Path: "/z.txt", Size: 2},
+	}, d)
+
+	want := `/
+├── a.txt (3 bytes, loaded)
+├── d/
+│   ├── b.txt (5 bytes, not loaded)
+│   └── e/
+│       └── c.txt (1 bytes, not loaded)
+└── z.txt (2 bytes, not loaded)
+`
+	assert.Equal(t, want, d.String())
+
+	b, err := json.Marshal(d[:3])
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"path": "/a.txt", "size": 3, "loaded": true},
+		{"path": "/d", "dir": true},
+		{"path": "/d/b.txt", "size": 5}
+	]`, string(b))
+}
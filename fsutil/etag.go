@@ -0,0 +1,84 @@
+package fsutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"path"
+)
+
+// ETagFileServer returns a handler that serves the files of the filesystem,
+// as http.FileServer, with an ETag header that identifies the content of the
+// served file. Requests with a matching If-None-Match header are answered
+// with 304 Not Modified, such that browsers and CDNs revalidate cached files
+// without downloading them again. The ETag is the git object SHA of the file
+// when the filesystem knows it, as remote gitfs filesystems do, and the
+// SHA-256 of the content of the file otherwise, which requires reading the
+// file for every request. As http.FileServer, directories without an
+// index.html file are listed, unless OptListDirs(false) is given.
+func ETagFileServer(fs http.FileSystem, opts ...ServeOption) http.Handler {
+	c := serveConfig{listDirs: true, dirStatus: http.StatusNotFound}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if !c.listDirs {
+		fs = NoListing(fs, c.dirStatus)
+	}
+	h := http.FileServer(fs)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		etag, err := ETag(fs, name)
+		if err == nil && etag != "" {
+			// http.FileServer answers conditional requests according to the
+			// ETag header.
+			w.Header().Set("ETag", etag)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// ETag returns the ETag of the file in the given path, as it is served by
+// ETagFileServer. A directory has the ETag of its index.html file, which is
+// served instead of the listing of the directory, or an empty string if it
+// does not have one.
+func ETag(fs http.FileSystem, name string) (string, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if st.IsDir() {
+		etag, err := ETag(fs, path.Join(name, "index.html"))
+		if err != nil {
+			return "", nil
+		}
+		return etag, nil
+	}
+	return fileETag(f)
+}
+
+// fileETag returns the ETag of a file. If the content of the file is read for
+// computing the ETag, the file is seeked back to its start.
+func fileETag(f http.File) (string, error) {
+	if h, ok := f.(hasher); ok && h.Hash() != "" {
+		return `"` + h.Hash() + `"`, nil
+	}
+	sum := sha256.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(sum.Sum(nil)) + `"`, nil
+}
+
+// hasher is a file that knows the git object SHA of its content.
+type hasher interface {
+	Hash() string
+}
@@ -0,0 +1,59 @@
+package fsutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/posener/gitfs/internal/tree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestETagFileServer(t *testing.T) {
+	t.Parallel()
+	tr := make(tree.Tree)
+	require.NoError(t, tr.AddFileContent("a.txt", []byte("a")))
+	require.NoError(t, tr.SetHash("a.txt", "2e65efe2a145dda7ee51d1741299f848e5bf752e"))
+	require.NoError(t, tr.AddFileContent("b.txt", []byte("b")))
+	require.NoError(t, tr.AddFileContent("d/index.html", []byte("index")))
+	require.NoError(t, tr.SetHash("d/index.html", "9015a7a32e7d2d3f1bdb2b0b1c4bb1d5e8b1b0a1"))
+	h := ETagFileServer(tr)
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// File with a known hash.
+	w := get("/a.txt", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "a", w.Body.String())
+	etag := w.Header().Get("ETag")
+	assert.Equal(t, `"2e65efe2a145dda7ee51d1741299f848e5bf752e"`, etag)
+	assert.Equal(t, http.StatusNotModified, get("/a.txt", etag).Code)
+	assert.Equal(t, http.StatusOK, get("/a.txt", `"other"`).Code)
+
+	// File without a known hash has the hash of its content.
+	w = get("/b.txt", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag = w.Header().Get("ETag")
+	assert.Equal(t, `"3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d"`, etag)
+	assert.Equal(t, http.StatusNotModified, get("/b.txt", etag).Code)
+
+	// Directory has the ETag of its index.
+	w = get("/d/", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "index", w.Body.String())
+	assert.Equal(t, `"9015a7a32e7d2d3f1bdb2b0b1c4bb1d5e8b1b0a1"`, w.Header().Get("ETag"))
+
+	// Directory without an index has no ETag.
+	w = get("/", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("ETag"))
+}
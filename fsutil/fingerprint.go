@@ -0,0 +1,67 @@
+package fsutil
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Fingerprint returns a digest of the structure and the content of a
+// filesystem, as a hex string. Filesystems with the same paths and the same
+// file contents have the same fingerprint, such that it can be used as a
+// version of assets for cache busting, or for checking that two deployments
+// serve the same content. Modification times and modes are not part of the
+// fingerprint.
+//
+// Files are identified by their git object SHAs. Files of remote filesystems
+// know their SHAs, and their content is not loaded. The SHAs of other files
+// are computed from their content, such that a local or packed filesystem has
+// the same fingerprint as the remote filesystem with the same content.
+func Fingerprint(fs http.FileSystem) (string, error) {
+	sum := sha256.New()
+	err := All(fs, func(path string, info os.FileInfo) error {
+		if info.IsDir() {
+			fmt.Fprintf(sum, "dir %s\x00", path)
+			return nil
+		}
+		hash, err := gitHash(fs, path)
+		if err != nil {
+			return errors.Wrapf(err, "hashing %s", path)
+		}
+		fmt.Fprintf(sum, "file %s\x00%s\x00", path, hash)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// gitHash returns the git object SHA of the file in the given path. It is
+// computed from the content, if the file does not know it.
+func gitHash(fs http.FileSystem, name string) (string, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if h, ok := f.(hasher); ok && h.Hash() != "" {
+		return h.Hash(), nil
+	}
+	st, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.New()
+	fmt.Fprintf(sum, "blob %d\x00", st.Size())
+	if _, err := io.Copy(sum, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
@@ -0,0 +1,36 @@
+package fsutil
+
+import (
+	"testing"
+
+	"github.com/posener/gitfs/internal/tree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+	fingerprint := func(files map[string]string, hashes map[string]string) string {
+		tr := make(tree.Tree)
+		for name, content := range files {
+			require.NoError(t, tr.AddFileContent(name, []byte(content)))
+		}
+		for name, hash := range hashes {
+			require.NoError(t, tr.SetHash(name, hash))
+		}
+		fp, err := Fingerprint(tr)
+		require.NoError(t, err)
+		return fp
+	}
+
+	base := fingerprint(map[string]string{"a": "hello\n", "d/b": "b"}, nil)
+	assert.Len(t, base, 64)
+	assert.Equal(t, base, fingerprint(map[string]string{"d/b": "b", "a": "hello\n"}, nil))
+	// The git object SHA of "hello\n" is the same as the computed one.
+	assert.Equal(t, base, fingerprint(map[string]string{"a": "hello\n", "d/b": "b"},
+		map[string]string{"a": "ce013625030ba8dba906f756967f9e9ca394464a"}))
+
+	assert.NotEqual(t, base, fingerprint(map[string]string{"a": "hello", "d/b": "b"}, nil))
+	assert.NotEqual(t, base, fingerprint(map[string]string{"a": "hello\n", "e/b": "b"}, nil))
+	assert.NotEqual(t, base, fingerprint(map[string]string{"a": "hello\n", "d/b": "b", "c": ""}, nil))
+}
@@ -3,7 +3,7 @@ package fsutil
 import (
 	"net/http"
 	"os"
-	"path/filepath"
+	"path"
 
 	globutil "github.com/posener/gitfs/internal/glob"
 )
@@ -22,6 +22,39 @@ func Glob(fs http.FileSystem, patterns ...string) (http.FileSystem, error) {
 	return &glob{FileSystem: fs, patterns: p}, nil
 }
 
+// GlobFiles is like Glob, but the patterns are applied only on files. All the
+// directories of the original filesystem are kept, also directories that none
+// of their files match any of the patterns.
+func GlobFiles(fs http.FileSystem, patterns ...string) (http.FileSystem, error) {
+	if len(patterns) == 0 {
+		return fs, nil
+	}
+	p, err := globutil.New(patterns...)
+	if err != nil {
+		return nil, err
+	}
+	return &glob{FileSystem: fs, patterns: p, keepDirs: true}, nil
+}
+
+// Filter returns a filesystem that contains only the files and directories
+// for which match returns true. The paths that are given to match are absolute
+// paths in the filesystem, such as "/dir/file". Since files can be opened
+// directly, match should not match files in directories that it does not
+// match.
+func Filter(fs http.FileSystem, match func(path string, isDir bool) bool) http.FileSystem {
+	return &glob{FileSystem: fs, filter: func(path string, info os.FileInfo) bool {
+		return match(path, info.IsDir())
+	}}
+}
+
+// FilterInfo is like Filter, but match is given the info of the files and
+// directories, such that they can be filtered also by their size or mode. A
+// directory that match does not match removes all its content, which can't
+// be opened even if match matches it.
+func FilterInfo(fs http.FileSystem, match func(path string, info os.FileInfo) bool) http.FileSystem {
+	return &glob{FileSystem: fs, filter: match, prune: true}
+}
+
 // glob is an object that play the role of an http.FileSystem and an http.File.
 // it wraps an existing underlying http.FileSystem, but applies glob pattern
 // matching on its files.
@@ -30,6 +63,13 @@ type glob struct {
 	http.File
 	root     string
 	patterns globutil.Patterns
+	// keepDirs indicates that all directories match.
+	keepDirs bool
+	// filter, if set, is used for matching instead of the patterns.
+	filter func(path string, info os.FileInfo) bool
+	// prune indicates that paths in directories that do not match, do not
+	// match as well.
+	prune bool
 }
 
 // Open a file, relative to root. If the file exists in the filesystem
@@ -37,8 +77,13 @@ type glob struct {
 // returned. If name is a directory, but it does not match the prefix
 // of any of the patterns, and os.ErrNotExist will be returned.
 func (g *glob) Open(name string) (http.File, error) {
-	path := filepath.Join(g.root, name)
-	f, err := g.FileSystem.Open(path)
+	name = path.Join(g.root, name)
+	if g.prune {
+		if err := g.matchDirs(path.Dir(name)); err != nil {
+			return nil, err
+		}
+	}
+	f, err := g.FileSystem.Open(name)
 	if err != nil {
 		return nil, err
 	}
@@ -48,14 +93,17 @@ func (g *glob) Open(name string) (http.File, error) {
 	}
 
 	// Regular file, match name.
-	if !g.patterns.Match(path, info.IsDir()) {
+	if !g.match(name, info) {
 		return nil, os.ErrNotExist
 	}
 	return &glob{
 		FileSystem: g.FileSystem,
 		File:       f,
-		root:       path,
+		root:       name,
 		patterns:   g.patterns,
+		keepDirs:   g.keepDirs,
+		filter:     g.filter,
+		prune:      g.prune,
 	}, nil
 }
 
@@ -67,10 +115,41 @@ func (g *glob) Readdir(count int) ([]os.FileInfo, error) {
 	}
 	ret := make([]os.FileInfo, 0, len(files))
 	for _, file := range files {
-		path := filepath.Join(g.root, file.Name())
-		if g.patterns.Match(path, file.IsDir()) {
+		name := path.Join(g.root, file.Name())
+		if g.match(name, file) {
 			ret = append(ret, file)
 		}
 	}
 	return ret, nil
 }
+
+// matchDirs returns os.ErrNotExist if the given directory, or any of its
+// parent directories, does not match.
+func (g *glob) matchDirs(dir string) error {
+	if dir == "/" || dir == "." || dir == "" {
+		return nil
+	}
+	if err := g.matchDirs(path.Dir(dir)); err != nil {
+		return err
+	}
+	f, err := g.FileSystem.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !g.match(dir, info) {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+func (g *glob) match(name string, info os.FileInfo) bool {
+	if g.filter != nil {
+		return g.filter(path.Join("/", name), info)
+	}
+	return (info.IsDir() && g.keepDirs) || g.patterns.Match(name, info.IsDir())
+}
@@ -2,9 +2,11 @@ package fsutil
 
 import (
 	"net/http"
+	"os"
 	"strings"
 	"testing"
 
+	"github.com/posener/gitfs/internal/tree"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -37,7 +39,7 @@ func TestGlobOpen(t *testing.T) {
 		},
 		{
 			patterns:   []string{"*/*1.gotmpl"},
-			matches:    []string{"testdata/tmpl1.gotmpl", "./testdata/tmpl1.gotmpl", "./testdata/tmpl1.gotmpl/"},
+			matches:    []string{"testdata/tmpl1.gotmpl", "./testdata/tmpl1.gotmpl", "./testdata/tmpl1.gotmpl/", "/testdata/tmpl1.gotmpl"},
 			notMatches: []string{"testdata/tmpl2.gotmpl", "./testdata/tmpl2.gotmpl", "./testdata/tmpl2.gotmpl/"},
 		},
 	}
@@ -161,6 +163,33 @@ func TestGlobReadDir_failure(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestGlobFiles(t *testing.T) {
+	t.Parallel()
+	g, err := GlobFiles(pwd, "*.go")
+	require.NoError(t, err)
+
+	// The directory is kept, although none of its files match.
+	dir, err := g.Open("testdata")
+	require.NoError(t, err)
+	files, err := dir.Readdir(0)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	root, err := g.Open(".")
+	require.NoError(t, err)
+	files, err = root.Readdir(0)
+	require.NoError(t, err)
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	assert.Contains(t, names, "testdata")
+	assert.Contains(t, names, "glob.go")
+
+	_, err = g.Open("testdata/tmpl1.gotmpl")
+	assert.Error(t, err)
+}
+
 func TestGlob_badPattern(t *testing.T) {
 	t.Parallel()
 	_, err := Glob(pwd, "[") // Missing closing bracket.
@@ -173,3 +202,32 @@ func TestGlob_noPattern(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, pwd, g)
 }
+
+func TestFilterInfo(t *testing.T) {
+	t.Parallel()
+	tr := make(tree.Tree)
+	require.NoError(t, tr.AddFileContent("d/small", []byte("a")))
+	require.NoError(t, tr.AddFileContent("d/large", []byte("abcdef")))
+	require.NoError(t, tr.AddFileContent("e/f/small", []byte("a")))
+	fs := FilterInfo(tr, func(path string, info os.FileInfo) bool {
+		return path != "/e" && (info.IsDir() || info.Size() < 5)
+	})
+
+	// The content of a directory that is filtered out is removed.
+	_, err := fs.Open("/e/f/small")
+	assert.True(t, os.IsNotExist(err))
+	_, err = fs.Open("e/f")
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = fs.Open("/d/small")
+	assert.NoError(t, err)
+	_, err = fs.Open("/d/large")
+	assert.True(t, os.IsNotExist(err))
+
+	d, err := fs.Open("/d")
+	require.NoError(t, err)
+	infos, err := d.Readdir(-1)
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, "small", infos[0].Name())
+}
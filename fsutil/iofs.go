@@ -0,0 +1,81 @@
+//go:build go1.16
+// +build go1.16
+
+package fsutil
+
+import (
+	htmltmpl "html/template"
+	iofs "io/fs"
+	"net/http"
+	txttmpl "text/template"
+
+	"github.com/kr/fs"
+)
+
+// FS returns an http.FileSystem of the given io/fs filesystem, such as an
+// embed.FS, such that it can be used with all the functions of this
+// package and with gitfs filesystems. Unlike http.FS, the root of the
+// returned filesystem can be opened as "" as well as "/", as the functions of
+// this package do.
+func FS(fsys iofs.FS) http.FileSystem {
+	return ioFS{http.FS(fsys)}
+}
+
+// WalkFS is like Walk, for an io/fs filesystem.
+func WalkFS(fsys iofs.FS, root string) *fs.Walker {
+	return Walk(FS(fsys), root)
+}
+
+// GlobFS is like Glob, for an io/fs filesystem.
+func GlobFS(fsys iofs.FS, patterns ...string) (http.FileSystem, error) {
+	return Glob(FS(fsys), patterns...)
+}
+
+// GlobFilesFS is like GlobFiles, for an io/fs filesystem.
+func GlobFilesFS(fsys iofs.FS, patterns ...string) (http.FileSystem, error) {
+	return GlobFiles(FS(fsys), patterns...)
+}
+
+// DiffFS is like Diff, for io/fs filesystems. To compare an io/fs filesystem
+// with an http.FileSystem, such as a gitfs filesystem, use Diff with FS.
+func DiffFS(a, b iofs.FS) (*FileSystemDiff, error) {
+	return Diff(FS(a), FS(b))
+}
+
+// TmplParseFS is like TmplParse, for an io/fs filesystem.
+func TmplParseFS(fsys iofs.FS, tmpl *txttmpl.Template, paths ...string) (*txttmpl.Template, error) {
+	return TmplParse(FS(fsys), tmpl, paths...)
+}
+
+// TmplParseGlobFS is like TmplParseGlob, for an io/fs filesystem.
+func TmplParseGlobFS(fsys iofs.FS, tmpl *txttmpl.Template, pattern string) (*txttmpl.Template, error) {
+	return TmplParseGlob(FS(fsys), tmpl, pattern)
+}
+
+// TmplParseHTMLFS is like TmplParseHTML, for an io/fs filesystem.
+func TmplParseHTMLFS(fsys iofs.FS, tmpl *htmltmpl.Template, paths ...string) (*htmltmpl.Template, error) {
+	return TmplParseHTML(FS(fsys), tmpl, paths...)
+}
+
+// TmplParseGlobHTMLFS is like TmplParseGlobHTML, for an io/fs filesystem.
+func TmplParseGlobHTMLFS(fsys iofs.FS, tmpl *htmltmpl.Template, pattern string) (*htmltmpl.Template, error) {
+	return TmplParseGlobHTML(FS(fsys), tmpl, pattern)
+}
+
+// TmplCheckFS is like TmplCheck, for an io/fs filesystem.
+func TmplCheckFS(fsys iofs.FS, patterns ...string) error {
+	return TmplCheck(FS(fsys), patterns...)
+}
+
+// ioFS opens the root of an http.FS filesystem also by an empty name, which
+// is not a valid io/fs path.
+type ioFS struct {
+	http.FileSystem
+}
+
+func (f ioFS) Open(name string) (http.File, error) {
+	if name == "" {
+		name = "/"
+	}
+	return f.FileSystem.Open(name)
+}
@@ -0,0 +1,57 @@
+//go:build go1.16
+// +build go1.16
+
+package fsutil
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	fsys := fstest.MapFS{
+		"a.txt":           {Data: []byte("a")},
+		"d/b.txt":         {Data: []byte("b")},
+		"tmpl/t1.gotmpl":  {Data: []byte("hello, {{.}}")},
+		"tmpl/bad.gotmpl": {Data: []byte("{{ .X ")},
+	}
+
+	var paths []string
+	walk := WalkFS(fsys, "")
+	for walk.Step() {
+		require.NoError(t, walk.Err())
+		paths = append(paths, walk.Path())
+	}
+	assert.Equal(t, []string{"", "a.txt", "d", "d/b.txt", "tmpl", "tmpl/bad.gotmpl", "tmpl/t1.gotmpl"}, paths)
+
+	globbed, err := GlobFS(fsys, "d/*")
+	require.NoError(t, err)
+	_, err = globbed.Open("a.txt")
+	assert.Error(t, err)
+	_, err = globbed.Open("d/b.txt")
+	assert.NoError(t, err)
+
+	diff, err := DiffFS(fsys, fstest.MapFS{"a.txt": {Data: []byte("a")}})
+	require.NoError(t, err)
+	assert.Len(t, diff.Diffs, 5)
+
+	diff, err = Diff(FS(fsys), http.Dir("testdata"))
+	require.NoError(t, err)
+	assert.NotEmpty(t, diff.Diffs)
+
+	tmpl, err := TmplParseFS(fsys, nil, "tmpl/t1.gotmpl")
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, tmpl.ExecuteTemplate(&buf, "t1.gotmpl", "foo"))
+	assert.Equal(t, "hello, foo", buf.String())
+
+	err = TmplCheckFS(fsys, "tmpl/*")
+	require.Error(t, err)
+	assert.Len(t, err.(TmplErrors), 1)
+}
@@ -0,0 +1,199 @@
+package fsutil
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Mount returns a filesystem that serves each of the given filesystems under
+// its path prefix, for example the "docs" prefix serves the "docs/a.md" file
+// from the "a.md" file of its filesystem. The directories above the prefixes
+// list the prefixes that they contain. An empty prefix mounts a filesystem
+// on the root directory. A prefix can't be inside another prefix, since the
+// filesystems are not merged.
+func Mount(mounts map[string]http.FileSystem) (http.FileSystem, error) {
+	m := &mount{
+		fss:  make(map[string]http.FileSystem, len(mounts)),
+		dirs: make(map[string][]string),
+	}
+	for prefix, fs := range mounts {
+		prefix = cleanPath(prefix)
+		if _, ok := m.fss[prefix]; ok {
+			return nil, fmt.Errorf("prefix %q is mounted multiple times", prefix)
+		}
+		m.fss[prefix] = fs
+	}
+	for prefix := range m.fss {
+		for other := range m.fss {
+			if other != prefix && isInside(other, prefix) {
+				return nil, fmt.Errorf("prefix %q is inside prefix %q", other, prefix)
+			}
+		}
+		// Add the prefix to the listing of all its parent directories.
+		for child := prefix; child != ""; {
+			parent := path.Dir(child)
+			if parent == "." {
+				parent = ""
+			}
+			if !contains(m.dirs[parent], child) {
+				m.dirs[parent] = append(m.dirs[parent], child)
+			}
+			child = parent
+		}
+	}
+	for _, children := range m.dirs {
+		sort.Strings(children)
+	}
+	if _, ok := m.fss[""]; !ok && m.dirs[""] == nil {
+		// The root directory exists also without mounts.
+		m.dirs[""] = []string{}
+	}
+	return m, nil
+}
+
+// mount is a filesystem that serves filesystems under path prefixes.
+type mount struct {
+	// fss are the filesystems by their prefixes, which are clean paths
+	// without leading or trailing slashes.
+	fss map[string]http.FileSystem
+	// dirs are the directories that contain prefixes, with their direct
+	// children, which are directories or prefixes.
+	dirs map[string][]string
+}
+
+func (m *mount) Open(name string) (http.File, error) {
+	name = cleanPath(name)
+	for prefix, fs := range m.fss {
+		if isInside(name, prefix) {
+			return fs.Open("/" + strings.TrimPrefix(strings.TrimPrefix(name, prefix), "/"))
+		}
+	}
+	if _, ok := m.dirs[name]; ok {
+		return &mountDir{mount: m, path: name}, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+// mountDir is a directory that contains prefixes.
+type mountDir struct {
+	mount *mount
+	path  string
+	// offset is the number of children that were returned by Readdir.
+	offset int
+}
+
+func (d *mountDir) Read([]byte) (int, error) {
+	return 0, fmt.Errorf("%s is a directory", d.path)
+}
+
+// Seek to the beginning of the directory resets the Readdir calls.
+func (d *mountDir) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekStart {
+		d.offset = 0
+		return 0, nil
+	}
+	return 0, fmt.Errorf("%s is a directory", d.path)
+}
+
+func (d *mountDir) Close() error {
+	return nil
+}
+
+func (d *mountDir) Stat() (os.FileInfo, error) {
+	return dirInfo(path.Base("/" + d.path)), nil
+}
+
+// Readdir returns the next count children of the directory, and io.EOF when
+// there are no more children. If count <= 0, it returns all the remaining
+// children. The root of a mounted filesystem is named after its prefix.
+func (d *mountDir) Readdir(count int) ([]os.FileInfo, error) {
+	children, err := d.next(d.mount.dirs[d.path], count)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(children))
+	for _, child := range children {
+		fs, ok := d.mount.fss[child]
+		if !ok {
+			infos = append(infos, dirInfo(path.Base(child)))
+			continue
+		}
+		f, err := fs.Open("/")
+		if err != nil {
+			return nil, err
+		}
+		info, err := f.Stat()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, renamedInfo{FileInfo: info, name: path.Base(child)})
+	}
+	return infos, nil
+}
+
+// next returns the next count of the given children of the directory, as
+// os.File.Readdir pages through the entries of a directory.
+func (d *mountDir) next(children []string, count int) ([]string, error) {
+	if d.offset > len(children) {
+		d.offset = len(children)
+	}
+	children = children[d.offset:]
+	if count <= 0 {
+		d.offset += len(children)
+		return children, nil
+	}
+	if len(children) == 0 {
+		return nil, io.EOF
+	}
+	if count < len(children) {
+		children = children[:count]
+	}
+	d.offset += len(children)
+	return children, nil
+}
+
+// dirInfo is the file info of a directory that contains prefixes.
+type dirInfo string
+
+func (d dirInfo) Name() string       { return string(d) }
+func (d dirInfo) Size() int64        { return 0 }
+func (d dirInfo) Mode() os.FileMode  { return os.ModeDir | 0755 }
+func (d dirInfo) ModTime() time.Time { return time.Time{} }
+func (d dirInfo) IsDir() bool        { return true }
+func (d dirInfo) Sys() interface{}   { return nil }
+
+// renamedInfo is a file info with a different name.
+type renamedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (r renamedInfo) Name() string { return r.name }
+
+// cleanPath returns a clean slash separated path without leading or trailing
+// slashes.
+func cleanPath(name string) string {
+	return strings.Trim(path.Clean("/"+name), "/")
+}
+
+// isInside returns true if the given clean path is the given prefix or is
+// inside it.
+func isInside(name, prefix string) bool {
+	return prefix == "" || name == prefix || strings.HasPrefix(name, prefix+"/")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
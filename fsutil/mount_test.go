@@ -0,0 +1,116 @@
+package fsutil
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMount(t *testing.T) {
+	t.Parallel()
+	fs, err := Mount(map[string]http.FileSystem{
+		"/a/":   http.Dir("../internal/testdata"),
+		"b/c/d": http.Dir("../internal/testdata/d1"),
+	})
+	require.NoError(t, err)
+
+	f, err := fs.Open("/a/d1/d11/f111")
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(f)
+	require.NoError(t, err)
+
+	_, err = fs.Open("/b/c/d/d11/f111")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"a", "b"}, readDirNames(t, fs, "/"))
+	assert.Equal(t, []string{"c"}, readDirNames(t, fs, "/b"))
+	assert.Equal(t, []string{"d"}, readDirNames(t, fs, "/b/c/"))
+
+	st, err := mustOpen(t, fs, "/b/c").Stat()
+	require.NoError(t, err)
+	assert.Equal(t, "c", st.Name())
+	assert.True(t, st.IsDir())
+
+	_, err = fs.Open("/c")
+	assert.True(t, os.IsNotExist(err))
+	_, err = fs.Open("/a/nosuchfile")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestMount_readdirPaging(t *testing.T) {
+	t.Parallel()
+	fs, err := Mount(map[string]http.FileSystem{
+		"a": http.Dir("../internal/testdata"),
+		"b": http.Dir("../internal/testdata"),
+		"c": http.Dir("../internal/testdata"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "b"}, {"c"}}, readDirPages(t, mustOpen(t, fs, "/"), 2))
+}
+
+// readDirPages reads the names of the entries of a directory in pages of
+// the given size, until io.EOF.
+func readDirPages(t *testing.T, d http.File, size int) [][]string {
+	t.Helper()
+	var pages [][]string
+	for {
+		infos, err := d.Readdir(size)
+		if err == io.EOF {
+			assert.Empty(t, infos)
+			return pages
+		}
+		require.NoError(t, err)
+		require.NotEmpty(t, infos)
+		var names []string
+		for _, info := range infos {
+			names = append(names, info.Name())
+		}
+		pages = append(pages, names)
+	}
+}
+
+func TestMount_root(t *testing.T) {
+	t.Parallel()
+	fs, err := Mount(map[string]http.FileSystem{"/": http.Dir("../internal/testdata/d1")})
+	require.NoError(t, err)
+	_, err = fs.Open("/d11/f111")
+	assert.NoError(t, err)
+
+	fs, err = Mount(nil)
+	require.NoError(t, err)
+	assert.Empty(t, readDirNames(t, fs, "/"))
+}
+
+func TestMount_nested(t *testing.T) {
+	t.Parallel()
+	_, err := Mount(map[string]http.FileSystem{"a": http.Dir("../internal/testdata"), "a/b": http.Dir("../internal/testdata")})
+	assert.Error(t, err)
+	_, err = Mount(map[string]http.FileSystem{"": http.Dir("../internal/testdata"), "a": http.Dir("../internal/testdata")})
+	assert.Error(t, err)
+	_, err = Mount(map[string]http.FileSystem{"a": http.Dir("../internal/testdata"), "/a/": http.Dir("../internal/testdata")})
+	assert.Error(t, err)
+}
+
+func mustOpen(t *testing.T, fs http.FileSystem, name string) http.File {
+	t.Helper()
+	f, err := fs.Open(name)
+	require.NoError(t, err)
+	return f
+}
+
+func readDirNames(t *testing.T, fs http.FileSystem, name string) []string {
+	t.Helper()
+	infos, err := mustOpen(t, fs, name).Readdir(0)
+	require.NoError(t, err)
+	names := []string{}
+	for _, info := range infos {
+		assert.True(t, info.IsDir())
+		names = append(names, info.Name())
+	}
+	return names
+}
@@ -0,0 +1,184 @@
+package fsutil
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Remap returns a filesystem that exposes the files of the given filesystem
+// under different paths, such that changes in the layout of a repository do
+// not require changes in the code that uses it. remap is called with the
+// absolute path of every file in the filesystem, such as "/docs/a.md", and
+// returns the path that it is exposed under, or an empty string to hide it.
+// The directories of the returned filesystem are the directories of the
+// exposed paths, such that empty directories are not exposed. The filesystem
+// is walked when Remap is called, and an error is returned if two files are
+// exposed under the same path, or a file is exposed under a directory path.
+func Remap(fs http.FileSystem, remap func(path string) string) (http.FileSystem, error) {
+	r := &remapFS{
+		fs:    fs,
+		files: make(map[string]string),
+		dirs:  map[string][]string{"": {}},
+	}
+	walk := Walk(fs, "/")
+	for walk.Step() {
+		if walk.Stat().IsDir() {
+			continue
+		}
+		src := path.Join("/", walk.Path())
+		dst := remap(src)
+		if dst == "" {
+			continue
+		}
+		if err := r.add(cleanPath(dst), src); err != nil {
+			return nil, err
+		}
+	}
+	if err := walk.Err(); err != nil {
+		return nil, err
+	}
+	for name, children := range r.dirs {
+		if _, ok := r.files[name]; ok {
+			return nil, fmt.Errorf("path %q is both a file and a directory", "/"+name)
+		}
+		sort.Strings(children)
+	}
+	return r, nil
+}
+
+// RemapPrefixes returns a filesystem that exposes the files of the given
+// filesystem with their path prefixes renamed. For example, the prefix
+// "static/css" renamed to "css" exposes the "static/css/main.css" file as
+// "css/main.css". The longest matching prefix of every file is renamed, and
+// files that do not match any prefix are exposed under their original path.
+// See Remap.
+func RemapPrefixes(fs http.FileSystem, prefixes map[string]string) (http.FileSystem, error) {
+	clean := make(map[string]string, len(prefixes))
+	for from, to := range prefixes {
+		clean[cleanPath(from)] = cleanPath(to)
+	}
+	return Remap(fs, func(name string) string {
+		name = cleanPath(name)
+		longest, ok := "", false
+		for from := range clean {
+			if isInside(name, from) && (!ok || len(from) > len(longest)) {
+				longest, ok = from, true
+			}
+		}
+		if !ok {
+			return name
+		}
+		return path.Join("/", clean[longest], strings.TrimPrefix(name, longest))
+	})
+}
+
+// remapFS is a filesystem that exposes files under different paths.
+type remapFS struct {
+	fs http.FileSystem
+	// files maps the exposed clean paths of the files to their absolute paths
+	// in fs.
+	files map[string]string
+	// dirs are the exposed directories, with the names of their direct
+	// children.
+	dirs map[string][]string
+}
+
+// add adds a file under the given exposed clean path, and adds it to the
+// listing of all its parent directories.
+func (r *remapFS) add(dst, src string) error {
+	if dst == "" {
+		return fmt.Errorf("file %q can't be exposed as the root directory", src)
+	}
+	if other, ok := r.files[dst]; ok {
+		return fmt.Errorf("files %q and %q are exposed under the same path %q", other, src, "/"+dst)
+	}
+	r.files[dst] = src
+	for child := dst; child != ""; {
+		parent := path.Dir(child)
+		if parent == "." {
+			parent = ""
+		}
+		_, exists := r.dirs[parent]
+		name := path.Base(child)
+		if !contains(r.dirs[parent], name) {
+			r.dirs[parent] = append(r.dirs[parent], name)
+		}
+		if exists {
+			break
+		}
+		child = parent
+	}
+	return nil
+}
+
+func (r *remapFS) Open(name string) (http.File, error) {
+	name = cleanPath(name)
+	if src, ok := r.files[name]; ok {
+		f, err := r.fs.Open(src)
+		if err != nil {
+			return nil, err
+		}
+		if path.Base(src) == path.Base("/"+name) {
+			return f, nil
+		}
+		return &remapFile{File: f, name: path.Base(name)}, nil
+	}
+	if _, ok := r.dirs[name]; ok {
+		return &remapDir{mountDir: mountDir{path: name}, remap: r}, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+// remapFile is a file that is exposed under a different name.
+type remapFile struct {
+	http.File
+	name string
+}
+
+func (f *remapFile) Stat() (os.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return renamedInfo{FileInfo: info, name: f.name}, nil
+}
+
+// remapDir is an exposed directory.
+type remapDir struct {
+	mountDir
+	remap *remapFS
+}
+
+// Readdir returns the next count children of the directory, and io.EOF when
+// there are no more children. If count <= 0, it returns all the remaining
+// children. The infos of files are the infos of their source files, under
+// their exposed names.
+func (d *remapDir) Readdir(count int) ([]os.FileInfo, error) {
+	children, err := d.next(d.remap.dirs[d.path], count)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(children))
+	for _, child := range children {
+		src, ok := d.remap.files[path.Join(d.path, child)]
+		if !ok {
+			infos = append(infos, dirInfo(child))
+			continue
+		}
+		f, err := d.remap.fs.Open(src)
+		if err != nil {
+			return nil, err
+		}
+		info, err := f.Stat()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, renamedInfo{FileInfo: info, name: child})
+	}
+	return infos, nil
+}
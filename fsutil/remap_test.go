@@ -0,0 +1,108 @@
+package fsutil
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/posener/gitfs/internal/tree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemap(t *testing.T) {
+	t.Parallel()
+	src := make(tree.Tree)
+	src.AddFileContent("static/css/main.css", []byte("css"))
+	src.AddFileContent("static/js/main.js", []byte("js"))
+	src.AddFileContent("README.md", []byte("readme"))
+	src.AddDir("empty")
+
+	fs, err := Remap(src, func(path string) string {
+		switch {
+		case path == "/README.md":
+			return ""
+		case path == "/static/js/main.js":
+			return "/app.js"
+		}
+		return strings.TrimPrefix(path, "/static")
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"app.js", "css"}, listNames(t, fs, "/"))
+	assert.Equal(t, []string{"main.css"}, listNames(t, fs, "/css"))
+
+	content, err := ioutil.ReadAll(mustOpen(t, fs, "/app.js"))
+	require.NoError(t, err)
+	assert.Equal(t, "js", string(content))
+	st, err := mustOpen(t, fs, "/app.js").Stat()
+	require.NoError(t, err)
+	assert.Equal(t, "app.js", st.Name())
+
+	for _, name := range []string{"/README.md", "/static/css/main.css", "/empty", "/js"} {
+		_, err = fs.Open(name)
+		assert.True(t, os.IsNotExist(err), name)
+	}
+}
+
+func TestRemap_conflict(t *testing.T) {
+	t.Parallel()
+	src := make(tree.Tree)
+	src.AddFileContent("a/f", []byte(""))
+	src.AddFileContent("b/f", []byte(""))
+	src.AddFileContent("c", []byte(""))
+
+	_, err := Remap(src, func(path string) string { return "/f" })
+	assert.Error(t, err)
+
+	_, err = Remap(src, func(path string) string {
+		if path == "/c" {
+			return "/a"
+		}
+		return path
+	})
+	assert.Error(t, err)
+}
+
+func TestRemapPrefixes(t *testing.T) {
+	t.Parallel()
+	src := make(tree.Tree)
+	src.AddFileContent("static/css/main.css", []byte("css"))
+	src.AddFileContent("static/index.html", []byte("html"))
+	src.AddFileContent("go.mod", []byte("mod"))
+
+	fs, err := RemapPrefixes(src, map[string]string{
+		"static":     "public",
+		"static/css": "/styles/",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"go.mod", "public", "styles"}, listNames(t, fs, "/"))
+	assert.Equal(t, []string{"index.html"}, listNames(t, fs, "/public"))
+	assert.Equal(t, []string{"main.css"}, listNames(t, fs, "/styles"))
+}
+
+func TestRemap_readdirPaging(t *testing.T) {
+	t.Parallel()
+	src := make(tree.Tree)
+	src.AddFileContent("a", []byte("a"))
+	src.AddFileContent("b", []byte("b"))
+	src.AddFileContent("c", []byte("c"))
+
+	fs, err := RemapPrefixes(src, map[string]string{"": "public"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "b"}, {"c"}}, readDirPages(t, mustOpen(t, fs, "/public"), 2))
+}
+
+func listNames(t *testing.T, fs http.FileSystem, name string) []string {
+	t.Helper()
+	infos, err := mustOpen(t, fs, name).Readdir(0)
+	require.NoError(t, err)
+	names := []string{}
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	return names
+}
@@ -0,0 +1,280 @@
+package fsutil
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// FileServer returns a handler that serves the files of the filesystem with
+// http.ServeContent. It is a replacement of http.FileServer for serving
+// assets from gitfs filesystems:
+//
+// * Responses have the ETag header of ETagFileServer and the Last-Modified
+// header of the modification time of the file, if it is known, and
+// conditional and range requests are answered accordingly.
+//
+// * Remote files are loaded with the context of the request, such that their
+// loading is canceled when the request is canceled.
+//
+// * A directory is served by its index.html file, and directories without one
+// are not listed, but answered with 404 Not Found, unless other options are
+// given.
+//
+// * Files that have a gzip compressed copy, such as files that were packed
+// with the -precompress flag of the gitfs command, are served compressed to
+// clients that accept gzip, without compressing them at runtime.
+func FileServer(fs http.FileSystem, opts ...ServeOption) http.Handler {
+	c := serveConfig{dirStatus: http.StatusNotFound}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		f, st, err := openFile(fs, name)
+		if err != nil {
+			serveError(w, err)
+			return
+		}
+		defer f.Close()
+		if st.IsDir() {
+			if !strings.HasSuffix(r.URL.Path, "/") {
+				redirect(w, r, path.Base(r.URL.Path)+"/")
+				return
+			}
+			index, indexSt, err := openFile(fs, path.Join(name, "index.html"))
+			if err != nil || indexSt.IsDir() {
+				if c.listDirs {
+					http.FileServer(fs).ServeHTTP(w, r)
+					return
+				}
+				serveStatus(w, c.dirStatus)
+				return
+			}
+			defer index.Close()
+			f, st = index, indexSt
+		}
+		if c, ok := f.(contexter); ok {
+			f = c.WithContext(r.Context())
+			defer f.Close()
+		}
+		etag, err := fileETag(f)
+		if err != nil {
+			serveError(w, err)
+			return
+		}
+		if g, ok := f.(gzipper); ok && g.Gzipped() != nil {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if acceptsGzip(r) {
+				serveGzipped(w, r, f, st, etag, g.Gzipped())
+				return
+			}
+		}
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, st.Name(), st.ModTime(), f)
+	})
+}
+
+// serveGzipped serves the gzip compressed copy of a file. The content type is
+// of the file, and the ETag is different from the ETag of the uncompressed
+// content, since the bytes of the response are different.
+func serveGzipped(w http.ResponseWriter, r *http.Request, f http.File, st os.FileInfo, etag string, gzipped []byte) {
+	ctype := mime.TypeByExtension(path.Ext(st.Name()))
+	if ctype == "" {
+		var buf [512]byte
+		n, _ := io.ReadFull(f, buf[:])
+		ctype = http.DetectContentType(buf[:n])
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Set("ETag", strings.TrimSuffix(etag, `"`)+`-gzip"`)
+	http.ServeContent(w, r, st.Name(), st.ModTime(), bytes.NewReader(gzipped))
+}
+
+// acceptsGzip returns whether the client of the request accepts gzip
+// encoded responses.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(enc, ";")
+		if strings.TrimSpace(parts[0]) != "gzip" {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if q := strings.TrimSpace(param); strings.HasPrefix(q, "q=") {
+				v, err := strconv.ParseFloat(q[2:], 64)
+				return err == nil && v > 0
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipper is a file that has a gzip compressed copy of its content.
+type gzipper interface {
+	Gzipped() []byte
+}
+
+// ServeOption is an option of FileServer and ETagFileServer.
+type ServeOption func(*serveConfig)
+
+type serveConfig struct {
+	listDirs  bool
+	dirStatus int
+}
+
+// OptListDirs sets whether directories without an index.html file are listed,
+// as http.FileServer lists them. By default, FileServer does not list
+// directories, and ETagFileServer lists them.
+func OptListDirs(list bool) ServeOption {
+	return func(c *serveConfig) {
+		c.listDirs = list
+	}
+}
+
+// OptDirStatus sets the status code of the responses for directories without
+// an index.html file when they are not listed. It should be
+// http.StatusNotFound, which is the default, or http.StatusForbidden.
+func OptDirStatus(code int) ServeOption {
+	return func(c *serveConfig) {
+		c.dirStatus = code
+	}
+}
+
+// NoListing returns a filesystem in which directories without an index.html
+// file can't be opened, such that http.FileServer does not list them. They
+// are answered with the given status code, which should be
+// http.StatusNotFound or http.StatusForbidden, since opening them fails with
+// os.ErrNotExist or os.ErrPermission. The returned filesystem is meant for
+// serving, since its directories without index files can't be walked.
+func NoListing(fs http.FileSystem, status int) http.FileSystem {
+	err := os.ErrNotExist
+	if status == http.StatusForbidden {
+		err = os.ErrPermission
+	}
+	return noListing{FileSystem: fs, err: err}
+}
+
+type noListing struct {
+	http.FileSystem
+	err error
+}
+
+func (n noListing) Open(name string) (http.File, error) {
+	f, st, err := openFile(n.FileSystem, name)
+	if err != nil || !st.IsDir() {
+		return f, err
+	}
+	index, indexSt, err := openFile(n.FileSystem, path.Join(name, "index.html"))
+	if err != nil || indexSt.IsDir() {
+		f.Close()
+		if err == nil {
+			index.Close()
+		}
+		return nil, n.err
+	}
+	index.Close()
+	return f, nil
+}
+
+// FileServerWithContext returns a handler that serves the files of the
+// filesystem as http.FileServer does, but loads remote files with the context
+// of the request. When the client disconnects, the request context is
+// canceled, and with it the loading of the served file, instead of letting it
+// run to completion. Files that do not implement the WithContext method are
+// served as they are.
+func FileServerWithContext(fs http.FileSystem) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.FileServer(contextFS{FileSystem: fs, ctx: r.Context()}).ServeHTTP(w, r)
+	})
+}
+
+// contextFS is a filesystem that opens files with a context.
+type contextFS struct {
+	http.FileSystem
+	ctx context.Context
+}
+
+func (c contextFS) Open(name string) (http.File, error) {
+	f, err := c.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fCtx, ok := f.(contexter)
+	if !ok {
+		return f, nil
+	}
+	return contextFile{File: fCtx.WithContext(c.ctx), orig: f}, nil
+}
+
+// contextFile is a file that was opened with a context. Closing it closes
+// also the original file.
+type contextFile struct {
+	http.File
+	orig http.File
+}
+
+func (f contextFile) Close() error {
+	err := f.File.Close()
+	if origErr := f.orig.Close(); err == nil {
+		err = origErr
+	}
+	return err
+}
+
+// contexter is a file that can be read with a context.
+type contexter interface {
+	WithContext(ctx context.Context) http.File
+}
+
+// openFile opens a file and returns it with its info.
+func openFile(fs http.FileSystem, name string) (http.File, os.FileInfo, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, st, nil
+}
+
+// serveError answers a request with the HTTP status of a filesystem error.
+func serveError(w http.ResponseWriter, err error) {
+	switch {
+	case os.IsNotExist(err):
+		serveStatus(w, http.StatusNotFound)
+	case os.IsPermission(err):
+		serveStatus(w, http.StatusForbidden)
+	default:
+		serveStatus(w, http.StatusInternalServerError)
+	}
+}
+
+// serveStatus answers a request with the given status code, with the same
+// messages as http.FileServer.
+func serveStatus(w http.ResponseWriter, code int) {
+	text := http.StatusText(code)
+	if code == http.StatusNotFound {
+		text = "page not found"
+	}
+	http.Error(w, strconv.Itoa(code)+" "+text, code)
+}
+
+// redirect redirects to a path relative to the request path, keeping its
+// query.
+func redirect(w http.ResponseWriter, r *http.Request, newPath string) {
+	if q := r.URL.RawQuery; q != "" {
+		newPath += "?" + q
+	}
+	w.Header().Set("Location", newPath)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
@@ -0,0 +1,194 @@
+package fsutil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/posener/gitfs/internal/tree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileServer(t *testing.T) {
+	t.Parallel()
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tr := make(tree.Tree)
+	require.NoError(t, tr.AddFileContent("a.txt", []byte("abcdef")))
+	require.NoError(t, tr.SetModTime("a.txt", func(context.Context) (time.Time, error) { return modTime, nil }))
+	require.NoError(t, tr.AddFileContent("d/index.html", []byte("index")))
+	require.NoError(t, tr.AddDir("empty"))
+	h := FileServer(tr)
+
+	get := func(path string, header ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/a.txt")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "abcdef", w.Body.String())
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, modTime.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	assert.Equal(t, http.StatusNotModified, get("/a.txt", "If-None-Match", etag).Code)
+	assert.Equal(t, http.StatusNotModified, get("/a.txt", "If-Modified-Since", modTime.Format(http.TimeFormat)).Code)
+
+	w = get("/a.txt", "Range", "bytes=1-3")
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "bcd", w.Body.String())
+
+	// Directories are served by their index.
+	w = get("/d/")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "index", w.Body.String())
+	w = get("/d?x=1")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "d/?x=1", w.Header().Get("Location"))
+
+	// Directories are not listed.
+	assert.Equal(t, http.StatusNotFound, get("/empty/").Code)
+	assert.Equal(t, http.StatusNotFound, get("/nosuchfile").Code)
+}
+
+func TestFileServer_dirOptions(t *testing.T) {
+	t.Parallel()
+	tr := make(tree.Tree)
+	require.NoError(t, tr.AddFileContent("d/a.txt", []byte("a")))
+	require.NoError(t, tr.AddFileContent("i/index.html", []byte("index")))
+
+	get := func(h http.Handler, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get(FileServer(tr, OptDirStatus(http.StatusForbidden)), "/d/")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = get(FileServer(tr, OptListDirs(true)), "/d/")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "a.txt")
+
+	w = get(ETagFileServer(tr, OptListDirs(false)), "/d/")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = get(ETagFileServer(tr, OptListDirs(false), OptDirStatus(http.StatusForbidden)), "/d/")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = get(ETagFileServer(tr, OptListDirs(false)), "/i/")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "index", w.Body.String())
+}
+
+func TestNoListing(t *testing.T) {
+	t.Parallel()
+	tr := make(tree.Tree)
+	require.NoError(t, tr.AddFileContent("d/a.txt", []byte("a")))
+	require.NoError(t, tr.AddFileContent("i/index.html", []byte("index")))
+	h := http.FileServer(NoListing(tr, http.StatusNotFound))
+
+	for path, code := range map[string]int{
+		"/":        http.StatusNotFound,
+		"/d/":      http.StatusNotFound,
+		"/d/a.txt": http.StatusOK,
+		"/i/":      http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, code, w.Code, path)
+	}
+
+	_, err := NoListing(tr, http.StatusForbidden).Open("d")
+	assert.True(t, os.IsPermission(err))
+}
+
+func TestFileServer_gzipped(t *testing.T) {
+	t.Parallel()
+	tr := make(tree.Tree)
+	require.NoError(t, tr.AddFileContent("a.css", []byte("body {}")))
+	require.NoError(t, tr.SetGzipped("a.css", []byte("gzipped")))
+	require.NoError(t, tr.SetHash("a.css", "abc"))
+	require.NoError(t, tr.AddFileContent("b", []byte("<html></html>")))
+	require.NoError(t, tr.SetGzipped("b", []byte("gzipped")))
+	h := FileServer(tr)
+
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/a.css", "deflate, gzip")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzipped", w.Body.String())
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "text/css; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `"abc-gzip"`, w.Header().Get("ETag"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+
+	w = get("/b", "gzip")
+	assert.Equal(t, "gzipped", w.Body.String())
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+
+	for _, acceptEncoding := range []string{"", "deflate", "gzip;q=0"} {
+		w = get("/a.css", acceptEncoding)
+		assert.Equal(t, "body {}", w.Body.String(), acceptEncoding)
+		assert.Empty(t, w.Header().Get("Content-Encoding"), acceptEncoding)
+		assert.Equal(t, `"abc"`, w.Header().Get("ETag"), acceptEncoding)
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"), acceptEncoding)
+	}
+}
+
+func TestFileServerWithContext(t *testing.T) {
+	t.Parallel()
+	type key struct{}
+	var loadValue interface{}
+	tr := make(tree.Tree)
+	require.NoError(t, tr.AddFile("a.txt", 3, func(ctx context.Context) ([]byte, error) {
+		loadValue = ctx.Value(key{})
+		return []byte("abc"), nil
+	}))
+	require.NoError(t, tr.AddDir("d"))
+	h := FileServerWithContext(tr)
+
+	r := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+	r = r.WithContext(context.WithValue(r.Context(), key{}, "request"))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "abc", w.Body.String())
+	assert.Equal(t, "request", loadValue)
+
+	// Directories are listed as in http.FileServer.
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "a.txt")
+}
+
+func TestFileServerWithContext_canceled(t *testing.T) {
+	t.Parallel()
+	tr := make(tree.Tree)
+	require.NoError(t, tr.AddFile("a.txt", 3, func(ctx context.Context) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}))
+	h := FileServerWithContext(tr)
+
+	// Without the request context, the load would block forever.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a.txt", nil).WithContext(ctx))
+	assert.Empty(t, w.Body.String())
+}
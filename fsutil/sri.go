@@ -0,0 +1,85 @@
+package fsutil
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SRIHashes maps paths of files to their subresource integrity hashes, as
+// they are used in the integrity attribute of HTML script and link elements.
+// Paths are slash separated, without a leading slash, such as "static/app.js".
+type SRIHashes map[string]string
+
+// SRI returns the subresource integrity hash of the file in the given path,
+// such as "sha384-oqVuAfXRKap7fdgcCY5uykM6+R9GqQ8K/uxy9rx7HNQlGYl1kPzQho1wx4JwY8wC".
+func SRI(fs http.FileSystem, name string) (string, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sum := sha512.New384()
+	if _, err := io.Copy(sum, f); err != nil {
+		return "", errors.Wrapf(err, "reading %s", name)
+	}
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum.Sum(nil)), nil
+}
+
+// SRIManifest returns the subresource integrity hashes of the files in the
+// filesystem that match any of the given glob patterns, such as "*.js" and
+// "*.css", or of all the files if no patterns are given. The hashes are
+// computed once, when the filesystem is loaded, and can be exposed to
+// templates with the FuncMap method:
+//
+//	hashes, err := fsutil.SRIManifest(fs, "static/*.js", "static/*.css")
+//	tmpl := template.New("index").Funcs(hashes.FuncMap())
+//
+// And in the template:
+//
+//	<script src="/static/app.js" integrity="{{ integrity "/static/app.js" }}"></script>
+func SRIManifest(fs http.FileSystem, patterns ...string) (SRIHashes, error) {
+	globbed, err := Glob(fs, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(SRIHashes)
+	walker := Walk(globbed, "")
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return nil, errors.Wrap(err, "walking filesystem")
+		}
+		if walker.Stat().IsDir() {
+			continue
+		}
+		hash, err := SRI(globbed, walker.Path())
+		if err != nil {
+			return nil, err
+		}
+		hashes[walker.Path()] = hash
+	}
+	return hashes, nil
+}
+
+// Integrity returns the hash of the given path. A leading slash is ignored,
+// such that the path can be the URL path of the file. It fails if the path
+// is not in the manifest.
+func (h SRIHashes) Integrity(path string) (string, error) {
+	hash, ok := h[strings.TrimPrefix(path, "/")]
+	if !ok {
+		return "", errors.Errorf("no integrity hash for %s", path)
+	}
+	return hash, nil
+}
+
+// FuncMap returns template functions that expose the hashes, which can be
+// given to the Funcs method of text and HTML templates. The "integrity"
+// function returns the hash of a path, as the Integrity method, such that a
+// template that refers to a missing path fails.
+func (h SRIHashes) FuncMap() map[string]interface{} {
+	return map[string]interface{}{"integrity": h.Integrity}
+}
@@ -0,0 +1,39 @@
+package fsutil
+
+import (
+	"bytes"
+	htmltmpl "html/template"
+	"testing"
+
+	"github.com/posener/gitfs/internal/tree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSRIManifest(t *testing.T) {
+	t.Parallel()
+	tr := make(tree.Tree)
+	require.NoError(t, tr.AddFileContent("static/app.js", []byte("alert('Hello, world.');")))
+	require.NoError(t, tr.AddFileContent("static/style.css", []byte("")))
+	require.NoError(t, tr.AddFileContent("index.html", []byte("<html></html>")))
+
+	hashes, err := SRIManifest(tr, "static/*.js", "static/*.css")
+	require.NoError(t, err)
+	assert.Equal(t, SRIHashes{
+		"static/app.js":    "sha384-H8BRh8j48O9oYatfu5AZzq6A9RINhZO5H16dQZngK7T62em8MUt1FLm52t+eX6xO",
+		"static/style.css": "sha384-OLBgp1GsljhM2TJ+sbHjaiH9txEUvgdDTAzHv2P24donTt6/529l+9Ua0vFImLlb",
+	}, hashes)
+
+	tmpl, err := htmltmpl.New("index").Funcs(hashes.FuncMap()).Parse(`<script src="/static/app.js" integrity="{{ integrity "/static/app.js" }}"></script>`)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, tmpl.Execute(&buf, nil))
+	// HTML templates escape the "+" in attributes, which browsers unescape.
+	assert.Equal(t, `<script src="/static/app.js" integrity="sha384-H8BRh8j48O9oYatfu5AZzq6A9RINhZO5H16dQZngK7T62em8MUt1FLm52t&#43;eX6xO"></script>`, buf.String())
+
+	_, err = hashes.Integrity("index.html")
+	assert.Error(t, err)
+
+	_, err = SRIManifest(tr, "[")
+	assert.Error(t, err)
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	htmltmpl "html/template"
 	"net/http"
+	"path"
 	"path/filepath"
 	"strings"
 	txttmpl "text/template"
+	"text/template/parse"
 
 	"github.com/pkg/errors"
 )
@@ -49,6 +51,83 @@ func TmplParseGlobHTML(fs http.FileSystem, tmpl *htmltmpl.Template, pattern stri
 	return t.Template, err
 }
 
+// TmplCheck parses all the templates in the given filesystem that match any
+// of the given glob patterns, or all the files if no patterns are given. It
+// returns the errors of all the templates that failed parsing, as
+// TmplErrors, such that programs can fail fast when they start instead of
+// when a template is first executed. Functions that templates call are not
+// checked, since they are only known when templates are parsed.
+func TmplCheck(fs http.FileSystem, patterns ...string) error {
+	var errs TmplErrors
+	buf := bytes.NewBuffer(nil)
+	walker := Walk(fs, "")
+	for walker.Step() {
+		if walker.Stat().IsDir() {
+			continue
+		}
+		name := walker.Path()
+		matched, err := matchAny(patterns, name)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+		f, err := fs.Open(name)
+		if err != nil {
+			return errors.Wrapf(err, "opening template %s", name)
+		}
+		buf.Reset()
+		_, err = buf.ReadFrom(f)
+		f.Close()
+		if err != nil {
+			return errors.Wrapf(err, "reading template %s", name)
+		}
+		t := parse.New(name)
+		t.Mode = parse.SkipFuncCheck
+		if _, err := t.Parse(buf.String(), "", "", make(map[string]*parse.Tree)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := walker.Err(); err != nil {
+		return errors.Wrap(err, "failed walking filesystem")
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// TmplErrors are errors of templates that failed parsing. Each error
+// contains the path of its template.
+type TmplErrors []error
+
+func (e TmplErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// matchAny returns true if name matches any of the patterns, or if there
+// are no patterns.
+func matchAny(patterns []string, name string) (bool, error) {
+	if len(patterns) == 0 {
+		return true, nil
+	}
+	for _, pattern := range patterns {
+		matched, err := path.Match(strings.TrimPrefix(pattern, "/"), name)
+		if err != nil {
+			return false, errors.Wrapf(err, "pattern %q", pattern)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 type tmplParser struct {
 	*txttmpl.Template
 }
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/posener/gitfs/internal/tree"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -129,3 +130,28 @@ func TestTmplParseHTML_emptyFileNames(t *testing.T) {
 	_, err := TmplParseHTML(fs, nil)
 	assert.Error(t, err)
 }
+
+func TestTmplCheck(t *testing.T) {
+	t.Parallel()
+	tr := make(tree.Tree)
+	require.NoError(t, tr.AddFileContent("ok.gotmpl", []byte(`{{ upper .Name }}`)))
+	require.NoError(t, tr.AddFileContent("tmpl/bad1.gotmpl", []byte(`{{ if .X }}`)))
+	require.NoError(t, tr.AddFileContent("tmpl/bad2.html", []byte(`{{ .X `)))
+	require.NoError(t, tr.AddFileContent("tmpl/ok.html", []byte(`<p>{{ .X }}</p>`)))
+
+	err := TmplCheck(tr)
+	require.Error(t, err)
+	errs, ok := err.(TmplErrors)
+	require.True(t, ok)
+	require.Len(t, errs, 2)
+	assert.Contains(t, errs[0].Error(), "tmpl/bad1.gotmpl")
+	assert.Contains(t, errs[1].Error(), "tmpl/bad2.html")
+
+	err = TmplCheck(tr, "tmpl/*.html")
+	require.Error(t, err)
+	assert.Len(t, err.(TmplErrors), 1)
+
+	assert.NoError(t, TmplCheck(tr, "*.gotmpl", "/tmpl/ok.html"))
+	assert.Error(t, TmplCheck(tr, "["))
+	assert.NoError(t, TmplCheck(http.Dir("."), "testdata/*.gotmpl"))
+}
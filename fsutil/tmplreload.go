@@ -0,0 +1,129 @@
+package fsutil
+
+import (
+	htmltmpl "html/template"
+	"io"
+	"net/http"
+	"sync"
+	txttmpl "text/template"
+
+	"github.com/posener/gitfs/internal/instrument"
+	"github.com/posener/gitfs/internal/log"
+)
+
+// TmplExecutor is a set of parsed templates, such as *template.Template of
+// the text/template and the html/template packages.
+type TmplExecutor interface {
+	ExecuteTemplate(w io.Writer, name string, data interface{}) error
+}
+
+// TmplReloader holds templates that are parsed from a filesystem, and parses
+// them again when the filesystem changes, such that rendered pages show the
+// current content without restarting the program. It is safe for concurrent
+// use. Its EventHook method should be given to gitfs.OptEventHook of the
+// filesystem, which reloads the templates after local files that are watched
+// with gitfs.OptLocalWatch changed, and after a gitfs.Refreshable filesystem
+// was refreshed:
+//
+//	var tmpl *fsutil.TmplReloader
+//	fs, err := gitfs.NewRefreshable(ctx, "github.com/x/y@heads/master",
+//		gitfs.OptEventHook(func(e gitfs.Event) { tmpl.EventHook(e) }))
+//	tmpl, err = fsutil.TmplReloadGlobHTML(fs, nil, "*.html")
+//	...
+//	err = tmpl.ExecuteTemplate(w, "index.html", data)
+type TmplReloader struct {
+	parse func() (TmplExecutor, error)
+	mu    sync.RWMutex
+	tmpl  TmplExecutor
+}
+
+// NewTmplReloader returns a reloader of the templates that are returned by
+// parse. The templates are parsed when it is created, and parsing errors are
+// returned.
+func NewTmplReloader(parse func() (TmplExecutor, error)) (*TmplReloader, error) {
+	r := &TmplReloader{parse: parse}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TmplReloadGlob returns a reloader of the text templates that are parsed as
+// with TmplParseGlob. If tmpl is not nil, every parse adds the templates to a
+// clone of it, such that it can be used for functions and common templates.
+func TmplReloadGlob(fs http.FileSystem, tmpl *txttmpl.Template, pattern string) (*TmplReloader, error) {
+	return NewTmplReloader(func() (TmplExecutor, error) {
+		base := tmpl
+		if base != nil {
+			var err error
+			if base, err = base.Clone(); err != nil {
+				return nil, err
+			}
+		}
+		t, err := TmplParseGlob(fs, base, pattern)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
+}
+
+// TmplReloadGlobHTML returns a reloader of the HTML templates that are parsed
+// as with TmplParseGlobHTML. If tmpl is not nil, every parse adds the
+// templates to a clone of it, such that it can be used for functions and
+// common templates. It should not be executed, since executed HTML templates
+// can't be cloned.
+func TmplReloadGlobHTML(fs http.FileSystem, tmpl *htmltmpl.Template, pattern string) (*TmplReloader, error) {
+	return NewTmplReloader(func() (TmplExecutor, error) {
+		base := tmpl
+		if base != nil {
+			var err error
+			if base, err = base.Clone(); err != nil {
+				return nil, err
+			}
+		}
+		t, err := TmplParseGlobHTML(fs, base, pattern)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
+}
+
+// Reload parses the templates again. If parsing fails, the previous templates
+// are kept, and the error is returned.
+func (r *TmplReloader) Reload() error {
+	tmpl, err := r.parse()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.tmpl = tmpl
+	r.mu.Unlock()
+	return nil
+}
+
+// EventHook reloads the templates on successful refresh events of gitfs
+// filesystems, and ignores other events. It can be given to
+// gitfs.OptEventHook. Reload failures are logged.
+func (r *TmplReloader) EventHook(e instrument.Event) {
+	if r == nil || e.Type != instrument.Refreshed || e.Err != nil {
+		return
+	}
+	if err := r.Reload(); err != nil {
+		log.Error("Failed reloading templates", "project", e.Project, "error", err)
+	}
+}
+
+// Templates returns the current templates.
+func (r *TmplReloader) Templates() TmplExecutor {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.tmpl
+}
+
+// ExecuteTemplate executes the template with the given name of the current
+// templates.
+func (r *TmplReloader) ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	return r.Templates().ExecuteTemplate(w, name, data)
+}
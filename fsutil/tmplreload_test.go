@@ -0,0 +1,51 @@
+package fsutil
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	txttmpl "text/template"
+
+	"github.com/posener/gitfs/internal/instrument"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTmplReloader(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	write := func(content string) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.tmpl"), []byte(content), 0644))
+	}
+	write(`{{ upper "a" }}1`)
+	base := txttmpl.New("").Funcs(txttmpl.FuncMap{"upper": func(s string) string { return s + s }})
+	r, err := TmplReloadGlob(http.Dir(dir), base, "*.tmpl")
+	require.NoError(t, err)
+
+	execute := func() string {
+		var b bytes.Buffer
+		require.NoError(t, r.ExecuteTemplate(&b, "a.tmpl", nil))
+		return b.String()
+	}
+	assert.Equal(t, "aa1", execute())
+
+	write(`{{ upper "a" }}2`)
+	// Failed refreshes and other events do not reload.
+	r.EventHook(instrument.Event{Type: instrument.Refreshed, Err: errors.New("failed")})
+	r.EventHook(instrument.Event{Type: instrument.TreeLoaded})
+	assert.Equal(t, "aa1", execute())
+
+	r.EventHook(instrument.Event{Type: instrument.Refreshed})
+	assert.Equal(t, "aa2", execute())
+
+	// A failed parse keeps the previous templates.
+	write(`{{ upper "a" `)
+	assert.Error(t, r.Reload())
+	assert.Equal(t, "aa2", execute())
+}
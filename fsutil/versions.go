@@ -0,0 +1,111 @@
+package fsutil
+
+import (
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// reSemver matches Semver versions, with an optional "v" prefix and optional
+// minor and patch numbers.
+var reSemver = regexp.MustCompile(`^v?\d+(\.\d+){0,2}$`)
+
+// VersionServer returns a handler that serves each of the given filesystems,
+// keyed by versions such as the filesystems of gitfs.NewAtRefs, with
+// FileServer under the version path prefix. For example, the "/v1.2.0/a.md"
+// path serves the "a.md" file of the "v1.2.0" filesystem. Versions that
+// contain slashes, such as "heads/dev", are served under all their path
+// components.
+//
+// Aliases are added for the Semver versions: "latest" serves the newest
+// version, and the major and minor versions, such as "v1" and "v1.2", serve
+// the newest version with the same major, or major and minor, numbers. A
+// given version is never replaced by an alias. The root path redirects to
+// "latest/", if it exists.
+func VersionServer(fss map[string]http.FileSystem, opts ...ServeOption) http.Handler {
+	servers := make(map[string]http.Handler, len(fss))
+	aliases := make(map[string]string)
+	for version, fs := range fss {
+		version = strings.Trim(version, "/")
+		servers[version] = FileServer(fs, opts...)
+		if !reSemver.MatchString(version) {
+			continue
+		}
+		n := semverNumbers(version)
+		v := ""
+		if strings.HasPrefix(version, "v") {
+			v = "v"
+		}
+		for _, alias := range []string{
+			"latest",
+			v + strconv.Itoa(n[0]),
+			v + strconv.Itoa(n[0]) + "." + strconv.Itoa(n[1]),
+		} {
+			if cur, ok := aliases[alias]; !ok || compareSemver(version, cur) > 0 {
+				aliases[alias] = version
+			}
+		}
+	}
+	for alias, version := range aliases {
+		if _, ok := servers[alias]; !ok {
+			servers[alias] = servers[version]
+		}
+	}
+	routes := make(map[string]http.Handler, len(servers))
+	for prefix, h := range servers {
+		routes[prefix] = http.StripPrefix("/"+prefix, h)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.Trim(path.Clean("/"+r.URL.Path), "/")
+		if name == "" {
+			if _, ok := routes["latest"]; ok {
+				redirect(w, r, "latest/")
+				return
+			}
+			serveStatus(w, http.StatusNotFound)
+			return
+		}
+		// Match the longest prefix of the path that is a version.
+		for prefix := name; prefix != "."; prefix = path.Dir(prefix) {
+			h, ok := routes[prefix]
+			if !ok {
+				continue
+			}
+			if prefix == name && !strings.HasSuffix(r.URL.Path, "/") {
+				redirect(w, r, path.Base(prefix)+"/")
+				return
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+		serveStatus(w, http.StatusNotFound)
+	})
+}
+
+// compareSemver compares two Semver versions. Missing minor and patch numbers
+// are zero. Equal versions, such as v1.2 and v1.2.0, are ordered by their
+// names, such that the choice between them is deterministic.
+func compareSemver(a, b string) int {
+	na, nb := semverNumbers(a), semverNumbers(b)
+	for i := range na {
+		switch {
+		case na[i] > nb[i]:
+			return 1
+		case na[i] < nb[i]:
+			return -1
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// semverNumbers returns the major, minor and patch numbers of a version that
+// matches reSemver.
+func semverNumbers(v string) [3]int {
+	var n [3]int
+	for i, part := range strings.Split(strings.TrimPrefix(v, "v"), ".") {
+		n[i], _ = strconv.Atoi(part)
+	}
+	return n
+}
@@ -0,0 +1,70 @@
+package fsutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/posener/gitfs/internal/tree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionServer(t *testing.T) {
+	t.Parallel()
+	fss := make(map[string]http.FileSystem)
+	for _, version := range []string{"v1.0.0", "v1.2.0", "v1.10.1", "v2.0.0", "heads/dev"} {
+		tr := make(tree.Tree)
+		require.NoError(t, tr.AddFileContent("d/a.txt", []byte(version)))
+		fss[version] = tr
+	}
+	h := VersionServer(fss)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/v1.0.0/d/a.txt", want: "v1.0.0"},
+		{path: "/v1.2.0/d/a.txt", want: "v1.2.0"},
+		{path: "/heads/dev/d/a.txt", want: "heads/dev"},
+		{path: "/latest/d/a.txt", want: "v2.0.0"},
+		{path: "/v1/d/a.txt", want: "v1.10.1"},
+		{path: "/v1.2/d/a.txt", want: "v1.2.0"},
+		{path: "/v2.0/d/a.txt", want: "v2.0.0"},
+	}
+	for _, tt := range tests {
+		w := get(tt.path)
+		assert.Equal(t, http.StatusOK, w.Code, tt.path)
+		assert.Equal(t, tt.want, w.Body.String(), tt.path)
+	}
+
+	w := get("/")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "latest/", w.Header().Get("Location"))
+
+	w = get("/v1")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "v1/", w.Header().Get("Location"))
+
+	w = get("/v1/d")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "d/", w.Header().Get("Location"))
+
+	assert.Equal(t, http.StatusNotFound, get("/v3/d/a.txt").Code)
+	assert.Equal(t, http.StatusNotFound, get("/v1/nope").Code)
+	assert.Equal(t, http.StatusNotFound, get("/heads/d/a.txt").Code)
+}
+
+func TestVersionServer_noLatest(t *testing.T) {
+	t.Parallel()
+	h := VersionServer(map[string]http.FileSystem{"heads/dev": make(tree.Tree)})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/kr/fs"
 )
@@ -17,6 +18,83 @@ func Walk(hfs http.FileSystem, root string) *fs.Walker {
 	return fs.WalkFS(root, fileSystem{hfs})
 }
 
+// WalkParallel calls visit for every path in the filesystem under root,
+// including root, with its stat. Up to workers directories are read and
+// paths are visited concurrently, such that walking a lazily loaded
+// filesystem, and loading its files in visit, is not bound by the latency of
+// every single load. visit is called from many goroutines, in no particular
+// order, and callers should aggregate its results by their paths. If visit
+// returns filepath.SkipDir for a directory, the directory contents are not
+// visited. All other paths are visited even if visit fails for some of them,
+// and the error of the lexically smallest failed path is returned, such that
+// the returned error does not depend on the scheduling of the goroutines.
+func WalkParallel(hfs http.FileSystem, root string, workers int, visit func(path string, info os.FileInfo) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	w := &parallelWalker{fs: fileSystem{hfs}, visit: visit, sem: make(chan struct{}, workers)}
+	info, err := w.fs.Lstat(root)
+	if err != nil {
+		w.fail(root, err)
+	} else {
+		w.wg.Add(1)
+		go w.walk(root, info)
+	}
+	w.wg.Wait()
+	return w.err
+}
+
+type parallelWalker struct {
+	fs    fileSystem
+	visit func(path string, info os.FileInfo) error
+	// sem bounds the number of goroutines that visit paths or read
+	// directories.
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	mu      sync.Mutex
+	err     error
+	errPath string
+}
+
+// walk visits path, and walks its contents concurrently if it is a
+// directory. The worker is released before the contents are walked, such
+// that a goroutine never holds a worker while it waits for others.
+func (w *parallelWalker) walk(path string, info os.FileInfo) {
+	defer w.wg.Done()
+	w.sem <- struct{}{}
+	entries, err := w.visitDir(path, info)
+	<-w.sem
+	if err != nil {
+		w.fail(path, err)
+		return
+	}
+	for _, entry := range entries {
+		w.wg.Add(1)
+		go w.walk(w.fs.Join(path, entry.Name()), entry)
+	}
+}
+
+// visitDir visits path and returns its contents if it is a directory.
+func (w *parallelWalker) visitDir(path string, info os.FileInfo) ([]os.FileInfo, error) {
+	err := w.visit(path, info)
+	if err == filepath.SkipDir {
+		return nil, nil
+	}
+	if err != nil || !info.IsDir() {
+		return nil, err
+	}
+	return w.fs.ReadDir(path)
+}
+
+func (w *parallelWalker) fail(path string, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil || path < w.errPath {
+		w.err, w.errPath = err, path
+	}
+}
+
 // FileSystem implements fs.FileSystem over http.FileSystem.
 //
 // See https://godoc.org/github.com/kr/fs#FileSystem for more details.
@@ -1,10 +1,15 @@
 package fsutil
 
 import (
+	"errors"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestWalk(t *testing.T) {
@@ -25,3 +30,48 @@ func TestWalk(t *testing.T) {
 	}
 	assert.ElementsMatch(t, want, got)
 }
+
+func TestWalkParallel(t *testing.T) {
+	t.Parallel()
+
+	var (
+		got []string
+		mu  sync.Mutex
+	)
+	err := WalkParallel(http.Dir("../internal"), "testdata", 3, func(path string, info os.FileInfo) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, path)
+		if info.Name() == "d1" {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	want := []string{
+		"testdata",
+		"testdata/f01",
+		"testdata/d2",
+		"testdata/d2/f21",
+		"testdata/d1",
+	}
+	assert.ElementsMatch(t, want, got)
+}
+
+func TestWalkParallel_error(t *testing.T) {
+	t.Parallel()
+
+	for i := 0; i < 10; i++ {
+		err := WalkParallel(http.Dir("../internal"), "testdata", 4, func(path string, info os.FileInfo) error {
+			if !info.IsDir() {
+				return errors.New(path)
+			}
+			return nil
+		})
+		require.Error(t, err)
+		assert.Equal(t, "testdata/d1/d11/f111", err.Error())
+	}
+
+	err := WalkParallel(http.Dir("../internal"), "nosuchdir", 4, func(string, os.FileInfo) error { return nil })
+	assert.Error(t, err)
+}
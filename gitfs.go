@@ -31,10 +31,17 @@
 //
 // * Files are loaded lazily by default or they can be preloaded if required.
 //
+// * Modification times of remote files are the times of the last commits that
+// modified them, and are loaded lazily when requested. The last commits
+// themselves are available using the `LastCommit` function.
+//
 // * Files can be packed to the Go binary using a command line tool.
 //
 // * This project is using the standard `http.FileSystem` interface.
 //
+// * In-memory filesystems, with the same semantics, can be created from a map
+// of file contents, for example in tests, or from tar and zip archives.
+//
 // * In ./fsutil there are some general useful tools around the
 // `http.FileSystem` interace.
 //
@@ -69,12 +76,14 @@
 // Private Repositories
 //
 // When used with private github repository, the Github API calls should be
-// instrumented with the appropriate credentials. The credentials can be
-// passed by providing an HTTP client.
+// instrumented with the appropriate credentials. By default, a Github token
+// is taken from the `GITHUB_TOKEN` or `GH_TOKEN` environment variables. This
+// can be disabled using `OptEnvToken(false)`.
 //
-// For example, to use a Github Token from environnement variable `GITHUB_TOKEN`:
+// The credentials can also be passed by providing an HTTP client. For example,
+// to use a Github Token from another environment variable:
 //
-// 	token := os.Getenv("GITHUB_TOKEN")
+// 	token := os.Getenv("MY_TOKEN")
 // 	client := oauth2.NewClient(
 // 		context.Background(),
 // 		oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
@@ -96,6 +105,13 @@
 // the remote files. (the value of the environment variable should point
 // to any directory within the github project).
 //
+// Local files can be watched with the `OptLocalWatch` option, which calls a
+// given function whenever they are modified. It can be used to reparse
+// templates, such that changes are visible without restarting the program:
+//
+// 	fs, err := gitfs.New(ctx, "github.com/x/y", gitfs.OptLocal(local),
+// 		gitfs.OptLocalWatch(func() { tmpl = parseTemplates(fs) }))
+//
 // Binary Packing
 //
 // Using gitfs does not mean that files are required to be remotely fetched.
@@ -109,6 +125,10 @@
 // This will cause all `gitfs.New` calls to automatically use the packed data,
 // insted of fetching the data on runtime.
 //
+// Packed data that was generated by a newer gitfs version, with an encoding
+// version that the used gitfs does not support, results in a `bin.VersionError`
+// from `New`. Support for such versions can be added with `bin.RegisterDecoder`.
+//
 // By default, a test will also be generated with the code. This test fails
 // when the local files are modified without updating the binary content.
 //
@@ -117,6 +137,15 @@
 // To generate only a specific filesystem add `//go:generate gitfs $GOFILE` in
 // the file it is being used.
 //
+// The projects can be locked to commits with the `-lock <file>` flag, such
+// that packing is reproducible and updates of the packed commits are visible
+// in the lock file. `gitfs.OptLockFile` loads remote projects from the same
+// locked commits.
+//
+// Production binaries that must never fetch files from the network can use
+// `OptRequireBinary(true)`, which makes `New` fail when the project was not
+// packed, instead of falling back to remote fetching.
+//
 // An interesting anecdote is that gitfs command is using itself for generating
 // its own templates.
 //
@@ -134,41 +163,219 @@ package gitfs
 
 import (
 	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
 	"github.com/posener/gitfs/fsutil"
+	"github.com/posener/gitfs/internal/archivefs"
 	"github.com/posener/gitfs/internal/binfs"
+	"github.com/posener/gitfs/internal/browserurl"
+	"github.com/posener/gitfs/internal/dirprefetch"
+	"github.com/posener/gitfs/internal/diskcache"
+	"github.com/posener/gitfs/internal/gitattr"
 	"github.com/posener/gitfs/internal/githubfs"
+	"github.com/posener/gitfs/internal/instrument"
 	"github.com/posener/gitfs/internal/localfs"
+	"github.com/posener/gitfs/internal/lockfile"
 	"github.com/posener/gitfs/internal/log"
+	"github.com/posener/gitfs/internal/netrc"
+	"github.com/posener/gitfs/internal/normfs"
+	"github.com/posener/gitfs/internal/stalefs"
+	"github.com/posener/gitfs/internal/strictfs"
+	"github.com/posener/gitfs/internal/tree"
+	"github.com/posener/gitfs/internal/webhook"
+	"golang.org/x/oauth2"
+	"golang.org/x/text/unicode/norm"
 )
 
 // OptClient sets up an HTTP client to perform request to the remote repository.
-// This client can be used for authorization credentials.
+// This client can be used for authorization credentials. If not set, a token
+// is taken from the environment, see OptEnvToken, or credentials are taken
+// from the netrc file, see OptNetrc.
 func OptClient(client *http.Client) option {
 	return func(c *config) {
 		c.client = client
 	}
 }
 
+// OptGithubClient sets up a pre-configured Github client to perform Github API
+// calls, for example, with a custom base URL or instrumentation. The client
+// that is given by OptClient, or taken from the environment, is still used
+// for downloading file contents, and should have the same credentials.
+func OptGithubClient(client *github.Client) option {
+	return func(c *config) {
+		c.githubClient = client
+	}
+}
+
+// OptEnvToken sets whether the Github token should be taken from the
+// GITHUB_TOKEN or GH_TOKEN environment variables, when no client is given
+// using OptClient. This is the default behavior.
+func OptEnvToken(use bool) option {
+	return func(c *config) {
+		c.noEnvToken = !use
+	}
+}
+
+// OptNetrc sets whether credentials should be taken from the netrc file of the
+// user, as go get does, when no client is given using OptClient and no token
+// is set in the environment. The file is taken from the NETRC environment
+// variable, or it is ~/.netrc. Credentials of github.com are used also for
+// api.github.com, and self-hosted hosts use their own credentials. This is the
+// default behavior.
+func OptNetrc(use bool) option {
+	return func(c *config) {
+		c.noNetrc = !use
+	}
+}
+
 // OptLocal result in looking for local git repository before accessing remote
 // repository. The given path should be contained in a git repository which
 // has a remote URL that matches the requested project.
+// If the project has a ref, the content is read from the local repository at
+// that ref, using a local or a remote branch, or a tag. Otherwise, the content
+// is read from the working directory, and files that are ignored by the
+// .gitignore files of the local repository are not included.
+// The path can also be a bare repository, which has no working directory. In
+// this case, the content is read from HEAD if the project has no ref.
 func OptLocal(path string) option {
 	return func(c *config) {
 		c.localPath = path
 	}
 }
 
+// OptLocalMatchRemote sets whether the local repository that is given by
+// OptLocal should have a remote URL that matches the project. Remote URLs are
+// matched in any of the URL forms that git supports, such as SSH URLs. When
+// false, the local repository is assumed to be a clone of the project, which
+// is useful for forks. This is the default behavior.
+func OptLocalMatchRemote(match bool) option {
+	return func(c *config) {
+		c.noMatchRemote = !match
+	}
+}
+
+// OptLocalDir serves the filesystem from the given local directory, instead of
+// the project. Unlike OptLocal, the directory is not required to be in a git
+// repository that matches the project. This is useful for scratch development,
+// or in tests that use a copy of the content. It has precedence over OptLocal.
+func OptLocalDir(path string) option {
+	return func(c *config) {
+		c.localDir = path
+	}
+}
+
+// OptLocalWatch calls onChange whenever a file is added, removed or modified
+// in a filesystem that is served from local files, with OptLocal or
+// OptLocalDir. The files are polled every second, by reading the listings of
+// the directories that are not ignored, until the context that was given to
+// New is done. onChange is called from a different goroutine, and it should not
+// block for long. It has no effect on remote and on binary packed
+// filesystems.
+func OptLocalWatch(onChange func()) option {
+	return func(c *config) {
+		c.onLocalChange = onChange
+	}
+}
+
+// OptRequireBinary fails New if the project was not registered by binary
+// packing, instead of fetching it from the remote repository. It guarantees
+// that the filesystem never uses the network, for example in production
+// binaries. Local files that are explicitly requested with OptLocal or
+// OptLocalDir are still used.
+func OptRequireBinary(require bool) option {
+	return func(c *config) {
+		c.requireBinary = require
+	}
+}
+
+// OptLockFile loads remote projects from the commits that they are locked to
+// in the given lock file, instead of the commits that their refs point to,
+// such that builds are reproducible. Projects that are not in the lock file
+// are loaded as usual. Lock files are created and updated by the gitfs
+// command line tool with its `-lock` flag, and should be committed, such
+// that changes of the locked commits are reviewed.
+//
+// 	fs, err := gitfs.New(ctx, "github.com/x/y", gitfs.OptLockFile("gitfs.lock"))
+func OptLockFile(path string) option {
+	return func(c *config) {
+		c.lockFile = path
+	}
+}
+
 // OptPrefetch sets prefetching all files in the filesystem when it is initially
-// loaded.
+// loaded. Remote repositories are downloaded as a single tarball. Files that
+// git archive excludes or rewrites, by the export-ignore and export-subst git
+// attributes, are loaded from Github when they are first read. The
+// modification times of the files are still loaded when they are first
+// needed, with a single Github API request, or a request per path with
+// OptPathModTimes.
 func OptPrefetch(prefetch bool) option {
 	return func(c *config) {
 		c.prefetch = prefetch
 	}
 }
 
+// OptPathModTimes sets the modification time of every file and directory of
+// remote repositories to the date of the last commit that modified it. This
+// costs a Github API request per path, when its modification time is first
+// requested, and http.FileServer requests the modification time of every
+// file that it serves. By default, the modification times of all the paths
+// are the date of the last commit of the project, which costs a single
+// request.
+func OptPathModTimes(pathModTimes bool) option {
+	return func(c *config) {
+		c.pathModTimes = pathModTimes
+	}
+}
+
+// OptGraphQL prefetches remote repositories using the Github GraphQL API,
+// which requires a single request per directory, but downloads only the
+// directory of the project, instead of the whole repository tarball. It
+// applies only with OptPrefetch, and requires an authenticated client,
+// which can be given using OptClient.
+func OptGraphQL(graphQL bool) option {
+	return func(c *config) {
+		c.graphQL = graphQL
+	}
+}
+
+// OptLazyDirs loads the listing of each directory of remote repositories
+// only when it is first accessed, instead of loading the structure of the
+// whole filesystem when it is created. This improves the creation time of
+// filesystems that are used sparsely. It does not apply with OptPrefetch or
+// BackendClone, and OptResolveSymlinks and OptMaxFiles have no effect with
+// this option.
+func OptLazyDirs(lazy bool) option {
+	return func(c *config) {
+		c.lazyDirs = lazy
+	}
+}
+
+// OptPrefetchDirs loads the files of a directory of a remote repository
+// concurrently, in the background, when the directory is first opened. It is
+// a middle ground between loading each file when it is read, which is the
+// default, and OptPrefetch, which loads the whole filesystem when it is
+// created, and it suits code that reads all the files of a directory, such as
+// parsing all the templates in a directory. Files in subdirectories are
+// loaded only when their directories are opened. It does not apply with
+// OptPrefetch.
+func OptPrefetchDirs(prefetch bool) option {
+	return func(c *config) {
+		c.prefetchDirs = prefetch
+	}
+}
+
 // OptGlob define glob patterns for which only matching files and directories
 // will be included in the filesystem.
 func OptGlob(patterns ...string) option {
@@ -177,6 +384,438 @@ func OptGlob(patterns ...string) option {
 	}
 }
 
+// OptFilter includes only the files and directories for which the given
+// function returns true, for inclusion rules that glob patterns can't express,
+// such as size limits. The paths that are given to the function are absolute
+// paths in the filesystem, such as "/dir/file". The filter applies to remote,
+// local and packed filesystems alike: a directory that is filtered out is
+// removed with all its content, that can't be opened by its full path. Remote
+// filesystems are filtered when their structure is loaded, such that files
+// that are filtered out are not downloaded, unless OptPrefetch downloads the
+// repository as a single archive, and the infos that are given to the
+// function have no modification time. The gitfs command can't evaluate the function, so it
+// packs the files that are filtered out, and they are filtered when the
+// packed filesystem is loaded.
+//
+// 	fs, err := gitfs.New(ctx, "github.com/x/y", gitfs.OptFilter(func(path string, info os.FileInfo) bool {
+// 		return info.IsDir() || info.Size() < 1<<20
+// 	}))
+func OptFilter(filter func(path string, info os.FileInfo) bool) option {
+	return func(c *config) {
+		c.filter = filter
+	}
+}
+
+// OptKeepEmptyDirs applies the glob patterns that are given by OptGlob only
+// on files, and keeps all the directories of the filesystem, also directories
+// that none of their files match the patterns. Git does not store empty
+// directories, so without glob patterns this option has no effect.
+func OptKeepEmptyDirs(keep bool) option {
+	return func(c *config) {
+		c.keepEmptyDirs = keep
+	}
+}
+
+// OptExportIgnore excludes the paths that have the export-ignore attribute in
+// the .gitattributes files of the filesystem, as `git archive` does, such that
+// test fixtures and CI configurations of repositories are not served or
+// packed. Only .gitattributes files in the project path, that match the glob
+// patterns, are used.
+func OptExportIgnore(exclude bool) option {
+	return func(c *config) {
+		c.exportIgnore = exclude
+	}
+}
+
+// Normalization is a Unicode normalization form of file names.
+type Normalization int
+
+const (
+	// NormalizeNone keeps the file names as they are. This is the default.
+	NormalizeNone Normalization = iota
+	// NormalizeNFC lists file names in their composed form, as they are
+	// usually reported by the Github API.
+	NormalizeNFC
+	// NormalizeNFD lists file names in their decomposed form, as they are
+	// stored by macOS.
+	NormalizeNFD
+)
+
+// OptNormalizeNames lists the file names of the filesystem in the given
+// Unicode normalization form, and opens files by names in any form. It
+// enables opening files that were authored on macOS, which stores names in
+// their decomposed form, with names in their composed form, and vice versa.
+func OptNormalizeNames(form Normalization) option {
+	return func(c *config) {
+		c.normalization = form
+	}
+}
+
+// OptStrictReaddir makes Readdir of regular files return an *os.PathError
+// with syscall.ENOTDIR, as it does for files of the os package, instead of
+// returning no files and no error. It enables code that distinguishes files
+// from directories by the error of Readdir to use the filesystem. It is off
+// by default, for compatibility with code that relies on the nil error.
+func OptStrictReaddir(strict bool) option {
+	return func(c *config) {
+		c.strictReaddir = strict
+	}
+}
+
+// OptResolveSymlinks replaces symbolic links in remote repositories with the
+// files or directories they point to. Without this option, or if the link
+// points outside of the filesystem, a symbolic link is a file with the
+// os.ModeSymlink mode bit, and its content is the path it points to. Links in
+// loops, such as a link to a directory that contains it, are removed.
+func OptResolveSymlinks(resolve bool) option {
+	return func(c *config) {
+		c.resolveSymlinks = resolve
+	}
+}
+
+// OptCacheSize bounds the total size in bytes of remote file contents that
+// are kept in memory. When the size is exceeded, the least recently used
+// contents are evicted, and loaded again from the remote repository when
+// needed. By default, all loaded contents are kept in memory.
+func OptCacheSize(size int64) option {
+	return func(c *config) {
+		c.cacheSize = size
+	}
+}
+
+// OptStreamThreshold sets a file size in bytes, such that remote files in that
+// size or larger are streamed from the remote repository when they are read,
+// instead of being loaded to memory. It does not apply to prefetched files.
+// By default, all files are loaded to memory.
+func OptStreamThreshold(size int64) option {
+	return func(c *config) {
+		c.streamThreshold = size
+	}
+}
+
+// ETagCache caches responses of the Github API. Requests for cached responses
+// are conditional, and if the remote content did not change, they do not count
+// against the Github API rate limit. A cache should be shared only between
+// filesystems that are created with the same client.
+type ETagCache = githubfs.ETagCache
+
+// NewETagCache returns an empty cache, to be used with OptETagCache, that
+// holds up to maxSize bytes of responses. When it is full, the least recently
+// used responses are evicted, and their requests are not conditional again.
+func NewETagCache(maxSize int64) *ETagCache {
+	return githubfs.NewETagCache(maxSize)
+}
+
+// OptETagCache uses the given cache for Github API requests. Using the same
+// cache when creating a filesystem of the same project again, only downloads
+// the content that changed.
+func OptETagCache(cache *ETagCache) option {
+	return func(c *config) {
+		c.etags = cache
+	}
+}
+
+// Backend is the method of loading remote repositories.
+type Backend int
+
+const (
+	// BackendAPI loads remote repositories using the Github API. This is the
+	// default backend.
+	BackendAPI Backend = iota
+	// BackendClone loads remote repositories using a shallow git clone that is
+	// stored in memory. It is not limited by the Github API rate limit, but
+	// the whole repository is downloaded when the filesystem is created.
+	// Commit information and modification times of files are not available.
+	BackendClone
+)
+
+// OptBackend sets the backend for loading remote repositories. Github tokens
+// of clients that were created with the oauth2 package, or that were taken
+// from the environment, are used as credentials for BackendClone.
+func OptBackend(backend Backend) option {
+	return func(c *config) {
+		c.backend = backend
+	}
+}
+
+// OptSparseClone keeps only the content of the requested path of the project
+// in memory when using BackendClone. The shallow clone is stored in a
+// temporary directory, and the files of the path that match the glob patterns
+// are loaded from it, after which it is removed. The memory usage is then
+// proportional to the content of the filesystem and not to the size of the
+// repository. Note that the whole tree of the commit is still downloaded.
+func OptSparseClone(sparse bool) option {
+	return func(c *config) {
+		c.sparseClone = sparse
+	}
+}
+
+// OptMaxFiles limits the number of files in remote filesystems. If a project
+// has more files, New returns an error. Prefetching stops as soon as the limit
+// is exceeded, such that a project of a large repository does not result in a
+// large number of API calls. By default, the number of files is not limited.
+func OptMaxFiles(n int) option {
+	return func(c *config) {
+		c.maxFiles = n
+	}
+}
+
+// OptMaxTotalSize limits the total size in bytes of the file contents that are
+// prefetched into memory with OptPrefetch. If the content of a project is
+// larger, New returns a *TotalSizeError, and OptPrefetch should not be used
+// for the project, such that files are loaded only when they are read.
+// Prefetching stops as soon as the limit is exceeded, which protects services
+// from running out of memory when a repository grows unexpectedly. By
+// default, the size is not limited.
+func OptMaxTotalSize(n int64) option {
+	return func(c *config) {
+		c.maxTotalSize = n
+	}
+}
+
+// TreeCache shares the structure and the file contents of a remote repository
+// between filesystems of different paths in the same repository and ref, such
+// that the structure is fetched only once. A cached structure is not fetched
+// again, such that a cache should be used only while the ref is not expected
+// to change.
+type TreeCache = githubfs.TreeCache
+
+// NewTreeCache returns an empty cache, to be used with OptTreeCache.
+func NewTreeCache() *TreeCache {
+	return githubfs.NewTreeCache()
+}
+
+// OptTreeCache uses the given cache for remote repositories. Filesystems of
+// different paths in the same repository should be created with the same
+// cache. It does not apply with OptPrefetch.
+func OptTreeCache(cache *TreeCache) option {
+	return func(c *config) {
+		c.trees = cache
+	}
+}
+
+// OptDiskCache stores the structure and the file contents of remote
+// repositories in the given directory on the local disk. The stored content
+// is shared between processes on the same host, such as services and the
+// gitfs command line tool, which do not fetch content that was already
+// fetched by another process. Concurrent fetches of the same content are
+// coordinated with lock files. Content is stored by git commit and object
+// SHAs, and the stored content is never expired. An empty directory disables
+// the cache. It does not apply with OptPrefetch and OptLazyDirs.
+//
+// 	fs, err := gitfs.New(ctx, "github.com/x/y", gitfs.OptDiskCache(gitfs.DefaultCacheDir()))
+func OptDiskCache(dir string) option {
+	return func(c *config) {
+		c.diskCacheDir = dir
+	}
+}
+
+// OptServeStale serves a previous version of the content when loading the
+// current content fails, for example during an outage of Github, instead of
+// failing. If loading a remote repository fails, it is loaded from the disk
+// cache of OptDiskCache, as with OptOffline, if the cache has it. In a
+// Refreshable filesystem, files that fail to load after a refresh are served
+// from the filesystem before the refresh. In this case, the info of a file,
+// which has its size, is returned only after its content is loaded. The
+// failures are logged, and emitted as EventServedStale events.
+//
+// 	fs, err := gitfs.NewRefreshable(ctx, "github.com/x/y@heads/master",
+// 		gitfs.OptDiskCache(gitfs.DefaultCacheDir()), gitfs.OptServeStale(true))
+func OptServeStale(serve bool) option {
+	return func(c *config) {
+		c.serveStale = serve
+	}
+}
+
+// DefaultCacheDir returns the default directory for OptDiskCache, which is the
+// gitfs directory in the user cache directory (~/.cache/gitfs on Linux). It
+// returns an empty string if the user cache directory is not available.
+func DefaultCacheDir() string {
+	dir, err := diskcache.DefaultDir()
+	if err != nil {
+		log.Warn("Failed getting cache directory", "error", err)
+		return ""
+	}
+	return dir
+}
+
+// OptOffline loads remote repositories only from the disk cache of
+// OptDiskCache, or from the default cache directory if it is not set, and
+// never sends any request. A branch or a tag, or the default branch if no ref
+// is given, is resolved to the commit that it pointed to when the repository
+// was last loaded with the disk cache. Content that is not stored results in
+// an *OfflineError, when the filesystem is created or when a file is read.
+// Commits and modification times of files are not available in offline mode.
+// Binary packed and local filesystems are used as usual. This is useful for
+// air-gapped environments and for unreliable networks.
+//
+// 	fs, err := gitfs.New(ctx, "github.com/x/y", gitfs.OptOffline(true))
+func OptOffline(offline bool) option {
+	return func(c *config) {
+		c.offline = offline
+	}
+}
+
+// OfflineError is returned in offline mode when content is not stored in the
+// disk cache. See OptOffline.
+type OfflineError = githubfs.OfflineError
+
+// OptRateLimit limits the rate of requests to remote repositories to the
+// given number of requests per second. This can be used to bound the usage
+// of a token that is shared between services. By default, the rate is not
+// limited.
+func OptRateLimit(requestsPerSecond float64) option {
+	return func(c *config) {
+		c.requestsPerSecond = requestsPerSecond
+	}
+}
+
+// Observer observes the operations of remote filesystems, for collecting
+// metrics. The metrics package provides an observer that exposes Prometheus
+// metrics. See OptMetrics.
+type Observer = instrument.Observer
+
+// OptMetrics reports the operations of remote filesystems to the given
+// observer: the requests that are sent and the bytes that are downloaded,
+// the loads of file contents, and the lookups in the caches.
+//
+// 	m := metrics.New()
+// 	prometheus.MustRegister(m)
+// 	fs, err := gitfs.New(ctx, "github.com/x/y", gitfs.OptMetrics(m))
+func OptMetrics(observer Observer) option {
+	return func(c *config) {
+		c.observer = observer
+	}
+}
+
+// Event is a lifecycle event of a filesystem, with the time that the operation
+// took. See OptEventHook.
+type Event = instrument.Event
+
+// EventType is the type of an Event.
+type EventType = instrument.EventType
+
+const (
+	// EventTreeLoaded is emitted when New returns, with its error if it
+	// failed.
+	EventTreeLoaded = instrument.TreeLoaded
+	// EventFileFetched is emitted when the content of a remote file was
+	// fetched.
+	EventFileFetched = instrument.FileFetched
+	// EventFetchFailed is emitted when fetching the content of a remote file
+	// failed.
+	EventFetchFailed = instrument.FetchFailed
+	// EventRefreshed is emitted when a change was detected in local files that
+	// are watched with OptLocalWatch, and when a Refreshable filesystem was
+	// refreshed, with its error if it failed.
+	EventRefreshed = instrument.Refreshed
+	// EventServedStale is emitted when loading the current content failed,
+	// and a previous version of the content is served instead, with the
+	// error of the failure. See OptServeStale.
+	EventServedStale = instrument.ServedStale
+)
+
+// OptEventHook calls hook with the lifecycle events of the filesystem, such
+// that applications can implement their own monitoring and alerting. The
+// events of file contents are emitted from the goroutines that read them, and
+// hook should not block for long.
+//
+// 	fs, err := gitfs.New(ctx, "github.com/x/y", gitfs.OptEventHook(func(e gitfs.Event) {
+// 		if e.Type == gitfs.EventFetchFailed {
+// 			alert(e.Path, e.Err)
+// 		}
+// 	}))
+func OptEventHook(hook func(Event)) option {
+	return func(c *config) {
+		c.onEvent = hook
+	}
+}
+
+// OptWaitRateLimit waits until the Github API rate limit resets when it is
+// exceeded, instead of failing, as long as the context of the request is not
+// done. This is useful when prefetching large repositories.
+func OptWaitRateLimit(wait bool) option {
+	return func(c *config) {
+		c.waitRateLimit = wait
+	}
+}
+
+// OptUserAgent sets the User-Agent header of the requests to the remote
+// repository, for proxies and servers that identify their clients. It does not
+// apply to the git requests of BackendClone, and to the requests of a client
+// that was given with OptGithubClient.
+func OptUserAgent(userAgent string) option {
+	return func(c *config) {
+		c.userAgent = userAgent
+	}
+}
+
+// OptBasicAuth authorizes the requests to the remote repository with basic
+// authentication, for self-hosted servers that support only a username and
+// password, or a token as the password. It also applies to the git requests
+// of BackendClone, and it does not apply to the requests of a client that was
+// given with OptGithubClient, and to the requests to mirrors of OptMirrors.
+// When it is set, credentials are not taken from the environment or from the
+// netrc file.
+func OptBasicAuth(username, password string) option {
+	return func(c *config) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// OptMirrors sets servers that serve the Github API, that are tried in order
+// until one of them does not fail with an error or a server error status, for
+// example an internal mirror, and then the public Github API. A mirror is
+// either a base URL of the Github API, such as "https://ghe.example.com/api/v3/",
+// or a host of a Github Enterprise server. The public Github API is
+// "github.com", and it is not used unless it is given. It does not apply to
+// the git requests of BackendClone, and to the requests of a client that was
+// given with OptGithubClient. The credentials of github.com are not sent to
+// the other mirrors, which are authorized only with OptMirrorToken. Since the
+// credentials that a custom transport adds can't be removed, it can't be used
+// with a client of OptClient that has a custom transport, other than an
+// OAuth2 client.
+//
+// 	fs, err := gitfs.New(ctx, "github.com/x/y", gitfs.OptMirrors("github.example.com", "github.com"))
+func OptMirrors(mirrors ...string) option {
+	return func(c *config) {
+		c.mirrors = mirrors
+	}
+}
+
+// OptMirrorToken authorizes the requests to a mirror of OptMirrors with the
+// given token. The mirror is given as it is given to OptMirrors. It can be
+// given several times for different mirrors.
+//
+// 	fs, err := gitfs.New(ctx, "github.com/x/y",
+// 		gitfs.OptMirrors("github.example.com", "github.com"),
+// 		gitfs.OptMirrorToken("github.example.com", os.Getenv("MIRROR_TOKEN")))
+func OptMirrorToken(mirror, token string) option {
+	return func(c *config) {
+		if c.mirrorTokens == nil {
+			c.mirrorTokens = make(map[string]string)
+		}
+		c.mirrorTokens[mirror] = token
+	}
+}
+
+// OptTLSConfig sets the TLS configuration of the connections to the remote
+// repository, for servers with certificates that are signed by a private CA,
+// or to skip the verification of certificates with InsecureSkipVerify. It is
+// applied on the transport of the client of OptClient, if it is an
+// *http.Transport, or the base transport of an OAuth2 client. The git requests
+// of BackendClone use only the InsecureSkipVerify field.
+//
+// 	pool := x509.NewCertPool()
+// 	pool.AppendCertsFromPEM(caPEM)
+// 	fs, err := gitfs.New(ctx, "github.com/x/y", gitfs.OptTLSConfig(&tls.Config{RootCAs: pool}))
+func OptTLSConfig(cfg *tls.Config) option {
+	return func(c *config) {
+		c.tlsConfig = cfg
+	}
+}
+
 // New returns a new git filesystem for the given project.
 //
 // Github:
@@ -186,32 +825,712 @@ func OptGlob(patterns ...string) option {
 //  * `heads/<branch name>` for a branch.
 //  * `tags/<tag>` for releases or git tags.
 //  * `<version>` for Semver compatible releases (e.g. v1.2.3).
-// If no ref is set, the default branch will be used.
+//  * `<commit>` for a full commit SHA.
+// If no ref is set, the default branch will be used. The ref is everything
+// after the first `@`, such that branch names may contain slashes, as in
+// `heads/release/1.x`. Characters that can't be used in the path or in the
+// ref, such as `@` and `#`, can be escaped with URL percent-encoding, for
+// example `heads/fix%231` for the `fix#1` branch.
+//
+// Revisions:
+// As in git, a ref can be followed by `~<n>` for the n-th first parent of its
+// commit, as in `main~3`, and by `@{<date>}` for the last commit before the
+// date, as in `heads/main@{2023-06-01}`. The date is of the form 2006-01-02,
+// for the start of the day in UTC, or an RFC3339 time. A ref of the form
+// `{<date>}` is the default branch at the date. Refs with revisions may omit
+// the `heads/` prefix of branches. Revisions are resolved to a commit using
+// the Github API when the filesystem is created, and are not supported by
+// local filesystems and by the clone backend.
+//
+// Modification times:
+// The modification times of the files of remote repositories are the date of
+// the last commit of the project, which is loaded with a single Github API
+// request when it is first needed. With OptPathModTimes, they are the date of
+// the last commit of each path, which costs a request per path.
+//
+// Github releases:
+// A project of the form github.com/<owner>/<repo>/releases@<tag> is the
+// filesystem of the assets that were uploaded to the release of the given tag.
+// The assets are files in the root directory of the filesystem.
+//
+// Browser URLs:
+// URLs of Github repositories, as they are shown in the browser, are also
+// accepted. For example, https://github.com/<owner>/<repo>/tree/<ref>/<path>
+// is the filesystem of the given path, and the URL of a file, with `blob`
+// instead of `tree`, is the filesystem of the directory that contains it.
+// Semver refs are tags and other refs are branches. Refs that contain a
+// slash can't be given in a URL.
 func New(ctx context.Context, project string, opts ...option) (http.FileSystem, error) {
+	project = browserurl.Project(project)
 	var c config
 	for _, opt := range opts {
 		opt(&c)
 	}
+	if c.onEvent == nil {
+		return c.new(ctx, project)
+	}
+	start := time.Now()
+	fs, err := c.new(ctx, project)
+	c.onEvent(Event{Type: EventTreeLoaded, Project: project, Duration: time.Since(start), Err: err})
+	return fs, err
+}
 
+// new returns the filesystem of the given project.
+func (c *config) new(ctx context.Context, project string) (http.FileSystem, error) {
+	fs, err := c.load(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	tagged, isTagged := fs.(tagger)
+	if c.prefetchDirs && !c.prefetch {
+		fs = dirprefetch.New(fs)
+	}
+	if c.exportIgnore {
+		ignore, err := gitattr.Load(fs)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading .gitattributes")
+		}
+		if len(ignore) > 0 {
+			fs = fsutil.Filter(fs, func(path string, isDir bool) bool {
+				return !ignore.Match(path, isDir)
+			})
+		}
+	}
+	switch c.normalization {
+	case NormalizeNFC:
+		fs = normfs.New(fs, norm.NFC)
+	case NormalizeNFD:
+		fs = normfs.New(fs, norm.NFD)
+	}
+	if c.strictReaddir {
+		fs = strictfs.New(fs)
+	}
+	if _, ok := fs.(tagger); isTagged && !ok {
+		// Keep the Tag method of the loaded filesystem.
+		fs = taggedFS{FileSystem: fs, load: tagged.Tag}
+	}
+	return fs, nil
+}
+
+// load returns the filesystem of the given project, from its source.
+func (c *config) load(ctx context.Context, project string) (http.FileSystem, error) {
 	switch {
+	case c.localDir != "":
+		log.Info("FileSystem from local directory", "project", project, "dir", c.localDir)
+		fs, err := localfs.NewDir(c.localDir)
+		if err != nil {
+			return nil, err
+		}
+		return c.localFS(ctx, project, fs)
 	case c.localPath != "":
-		log.Printf("FileSystem %q from local directory", project)
-		fs, err := localfs.New(project, c.localPath)
+		log.Info("FileSystem from local repository", "project", project, "path", c.localPath)
+		fs, err := localfs.New(project, c.localPath, !c.noMatchRemote)
 		if err != nil {
 			return nil, err
 		}
-		return fsutil.Glob(fs, c.patterns...)
+		return c.localFS(ctx, project, fs)
 	case binfs.Match(project):
-		log.Printf("FileSystem %q from binary", project)
-		return binfs.Get(project), nil
+		log.Info("FileSystem from binary", "project", project)
+		fs, err := binfs.Get(project)
+		if err != nil {
+			return nil, err
+		}
+		if c.filter != nil {
+			fs = fsutil.FilterInfo(fs, c.filter)
+		}
+		return fs, nil
+	case c.requireBinary:
+		return nil, errors.Errorf("project %q was not packed into the binary, and OptRequireBinary is set", project)
 	case githubfs.Match(project):
-		log.Printf("FileSystem %q from remote Github repository", project)
-		return githubfs.New(ctx, c.client, project, c.prefetch, c.patterns)
+		log.Info("FileSystem from remote Github repository", "project", project)
+		remote := project
+		if c.lockFile != "" {
+			var err error
+			if remote, err = c.pin(project); err != nil {
+				return nil, err
+			}
+		}
+		gc := c.github()
+		if c.onEvent != nil {
+			gc.OnFileLoad = func(path string, d time.Duration, err error) {
+				c.onEvent(instrument.FileEvent(project, path, d, err))
+			}
+		}
+		var fs http.FileSystem
+		var err error
+		if c.lazyDirs && !c.prefetch && c.backend != BackendClone {
+			fs, err = githubfs.NewLazy(ctx, remote, gc)
+		} else {
+			fs, err = githubfs.New(ctx, remote, gc)
+		}
+		if err != nil && c.serveStale {
+			fs, err = c.loadStale(ctx, project, remote, gc, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if load, ok := githubfs.TagLoader(remote, gc); ok {
+			fs = taggedFS{FileSystem: fs, load: load}
+		}
+		return fs, nil
 	default:
 		return nil, errors.Errorf("project %q not supported", project)
 	}
 }
 
+// loadStale loads a remote repository from the disk cache after loading it
+// failed with the given error. It returns the given error if the disk cache
+// does not have it.
+func (c *config) loadStale(ctx context.Context, project, remote string, gc githubfs.Config, err error) (http.FileSystem, error) {
+	if gc.DiskCache == nil || gc.Offline || ctx.Err() != nil {
+		return nil, err
+	}
+	gc.Offline = true
+	fs, staleErr := githubfs.New(ctx, remote, gc)
+	if staleErr != nil {
+		log.Debug("Stale content is not available", "project", project, "error", staleErr)
+		return nil, err
+	}
+	log.Warn("Failed loading project, serving stale content from disk cache", "project", project, "error", err)
+	if c.onEvent != nil {
+		c.onEvent(Event{Type: EventServedStale, Project: project, Err: err})
+	}
+	return fs, nil
+}
+
+// NewAll returns the filesystems of the given projects, keyed by the project
+// names, with the same options. The filesystems are loaded concurrently, and
+// share the HTTP client, with its rate limiting and caches. Git trees and
+// file contents are shared between the filesystems only with OptTreeCache,
+// since its contents are not bounded by OptCacheSize. If any of the
+// filesystems failed to load, an error of the first failed project in the
+// given order is returned, after all the loads are done.
+//
+// 	fss, err := gitfs.NewAll(ctx, []string{"github.com/x/y", "github.com/x/z"}, gitfs.OptRateLimit(10))
+func NewAll(ctx context.Context, projects []string, opts ...option) (map[string]http.FileSystem, error) {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	shared := githubfs.Share(c.github())
+	opts = append(opts[:len(opts):len(opts)], func(c *config) { c.shared = &shared })
+
+	var (
+		fss     = make(map[string]http.FileSystem, len(projects))
+		errs    = make(map[string]error)
+		started = make(map[string]bool)
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+	)
+	for _, project := range projects {
+		if started[project] {
+			continue
+		}
+		started[project] = true
+		wg.Add(1)
+		go func(project string) {
+			defer wg.Done()
+			fs, err := New(ctx, project, opts...)
+			mu.Lock()
+			defer mu.Unlock()
+			fss[project], errs[project] = fs, err
+		}(project)
+	}
+	wg.Wait()
+	for _, project := range projects {
+		if err := errs[project]; err != nil {
+			return nil, errors.Wrapf(err, "loading %s", project)
+		}
+	}
+	return fss, nil
+}
+
+// NewAtRefs returns the filesystems of a project at each of the given refs,
+// keyed by the refs, such as for serving several versions of documentation
+// side by side. The refs are in the format of the ref of a project, such as
+// "v1.2.3" or "heads/dev", and the given project should not have a ref. The
+// filesystems are loaded as with NewAll, and share the HTTP client and the
+// caches. They can be served side by side with fsutil.VersionServer.
+//
+// 	fss, err := gitfs.NewAtRefs(ctx, "github.com/x/y/docs", []string{"v1.0.0", "v2.0.0"})
+func NewAtRefs(ctx context.Context, project string, refs []string, opts ...option) (map[string]http.FileSystem, error) {
+	project = browserurl.Project(project)
+	if strings.Contains(project, "@") {
+		return nil, errors.Errorf("project %q should not have a ref", project)
+	}
+	projects := make([]string, len(refs))
+	for i, ref := range refs {
+		projects[i] = project + "@" + ref
+	}
+	fss, err := NewAll(ctx, projects, opts...)
+	if err != nil {
+		return nil, err
+	}
+	byRef := make(map[string]http.FileSystem, len(fss))
+	for _, ref := range refs {
+		byRef[ref] = fss[project+"@"+ref]
+	}
+	return byRef, nil
+}
+
+// NewMount returns a single filesystem that serves the filesystems of several
+// projects, each under its path prefix. The mounts map path prefixes to
+// projects, and the projects are loaded as with NewAll. The directories above
+// the prefixes list the prefixes that they contain. A prefix can't be inside
+// another prefix.
+//
+// 	fs, err := gitfs.NewMount(ctx, map[string]string{
+// 		"docs":   "github.com/x/y/docs",
+// 		"assets": "github.com/x/z/static@v1.2.3",
+// 	})
+func NewMount(ctx context.Context, mounts map[string]string, opts ...option) (http.FileSystem, error) {
+	projects := make([]string, 0, len(mounts))
+	for _, project := range mounts {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+	fss, err := NewAll(ctx, projects, opts...)
+	if err != nil {
+		return nil, err
+	}
+	mounted := make(map[string]http.FileSystem, len(mounts))
+	for prefix, project := range mounts {
+		mounted[prefix] = fss[project]
+	}
+	return fsutil.Mount(mounted)
+}
+
+// ResolveCommit returns the SHA of the commit that the ref of a remote project
+// points to, or that its default branch points to if it has no ref. It is
+// resolved using the Github API, with the given options.
+func ResolveCommit(ctx context.Context, project string, opts ...option) (string, error) {
+	project = browserurl.Project(project)
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if !githubfs.Match(project) {
+		return "", errors.Errorf("project %q not supported", project)
+	}
+	return githubfs.ResolveCommit(ctx, project, c.github())
+}
+
+// Open returns a single file of a project, that its path is the path of the
+// project, as in `github.com/x/y/path/file@ref`. Browser URLs of files are
+// also accepted. For remote repositories, only the content of the file is
+// fetched, using a single request, without loading the structure of the
+// repository. Otherwise, for example with OptLocal, with binary packing or
+// with OptLockFile, the filesystem of the directory of the file is created as
+// with New, and the file is opened from it.
+//
+// 	f, err := gitfs.Open(ctx, "github.com/x/y/config/app.yaml@v1.2.3")
+func Open(ctx context.Context, project string, opts ...option) (http.File, error) {
+	project = browserurl.File(project)
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	dir, name, err := splitFile(project)
+	if err != nil {
+		return nil, err
+	}
+	if c.isRemote(dir) && c.backend != BackendClone && c.lockFile == "" && githubfs.Versioned(dir) {
+		log.Info("File from remote Github repository", "project", project)
+		content, err := githubfs.ReadFile(ctx, project, c.github())
+		if err != nil {
+			return nil, err
+		}
+		t := make(tree.Tree)
+		if err := t.AddFileContent(name, content); err != nil {
+			return nil, err
+		}
+		return t.Open(name)
+	}
+	fs, err := New(ctx, dir, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Open(name)
+}
+
+// ReadFile returns the content of a single file of a project, that its path
+// is the path of the project, as with Open. For remote repositories, only the
+// content of the file is fetched, which is suitable for loading a single
+// configuration or schema file.
+//
+// 	schema, err := gitfs.ReadFile(ctx, "github.com/x/y/schema.json@v1.2.3")
+func ReadFile(ctx context.Context, project string, opts ...option) ([]byte, error) {
+	f, err := Open(ctx, project, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// splitFile splits a project name that its path is a file to the project of
+// the directory that contains the file, and the name of the file.
+func splitFile(project string) (dir, name string, err error) {
+	ref := ""
+	if i := strings.Index(project, "@"); i >= 0 {
+		project, ref = project[:i], project[i:]
+	}
+	project = strings.TrimSuffix(project, "/")
+	if strings.Count(project, "/") < 3 {
+		return "", "", errors.Errorf("project %q does not have a file path", project+ref)
+	}
+	return path.Dir(project) + ref, path.Base(project), nil
+}
+
+// ListVersions returns the tags of the repository of a remote project that
+// are Semver versions, such as v1.2.3, sorted from the newest version to the
+// oldest, using the Github API with the given options. The path and the ref
+// of the project are ignored. A version can be used as the ref of a project,
+// as in `github.com/x/y@v1.2.3`.
+//
+// 	versions, err := gitfs.ListVersions(ctx, "github.com/x/y")
+func ListVersions(ctx context.Context, project string, opts ...option) ([]string, error) {
+	project = browserurl.Project(project)
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if !githubfs.Match(project) {
+		return nil, errors.Errorf("project %q not supported", project)
+	}
+	return githubfs.ListVersions(ctx, project, c.github())
+}
+
+// Health is the state of the access to the Github API, as returned by
+// HealthCheck.
+type Health = githubfs.Health
+
+// HealthCheck verifies that the Github API can be accessed with the given
+// options, and returns the remaining rate limit. It can be called on startup,
+// such that invalid credentials fail there, and not on the first lazy read
+// from a filesystem. If no requests remain in the current rate limit window
+// it returns the health with a *RateLimitError. The check does not count
+// against the rate limit.
+//
+// 	h, err := gitfs.HealthCheck(ctx, gitfs.OptClient(client))
+func HealthCheck(ctx context.Context, opts ...option) (*Health, error) {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return githubfs.HealthCheck(ctx, c.github())
+}
+
+// ResolvedProject is the components of a project that was resolved by
+// Resolve.
+type ResolvedProject = githubfs.Resolved
+
+// Resolve parses the project string, and checks that its repository and its
+// ref exist and are accessible with the given options, using the Github API.
+// It returns the components of the project, with the commit that its ref
+// points to, such that applications can validate project strings that are
+// given by users before creating filesystems from them. Nothing is loaded
+// from the repository.
+//
+// 	p, err := gitfs.Resolve(ctx, "github.com/x/y/static@v1.2.3")
+func Resolve(ctx context.Context, project string, opts ...option) (*ResolvedProject, error) {
+	project = browserurl.Project(project)
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if !githubfs.Match(project) {
+		return nil, errors.Errorf("project %q not supported", project)
+	}
+	return githubfs.Resolve(ctx, project, c.github())
+}
+
+// Refreshable is a filesystem of a project that can be reloaded, such that it
+// serves the current content of the ref of the project. Files that were
+// opened before a refresh keep their content. It is returned by
+// NewRefreshable.
+type Refreshable struct {
+	project string
+	load    func(ctx context.Context) (http.FileSystem, error)
+	// resolve, if set, returns the commit that the ref of the project points
+	// to, such that refreshing an unchanged ref does not load the filesystem
+	// again.
+	resolve func(ctx context.Context) (string, error)
+	commit  string
+	onEvent func(Event)
+	// serveStale serves the files of the previous filesystem when they fail
+	// to load after a refresh.
+	serveStale bool
+	// refreshing serializes the refreshes, such that an older content never
+	// replaces a newer one.
+	refreshing sync.Mutex
+	mu         sync.RWMutex
+	fs         http.FileSystem
+}
+
+// NewRefreshable returns the filesystem of the given project, as New, that
+// can be refreshed to load the current content of its ref. The context is
+// also the parent of the contexts of the refreshed filesystems, and should
+// not be canceled as long as the filesystem is used. Refreshable filesystems
+// should not use OptTreeCache, since the trees in the cache are never fetched
+// again. Filesystems of remote repositories are loaded again only if the ref
+// of the project points to a different commit than the one that was loaded,
+// such that periodic refreshes of an unchanged ref cost a single request.
+func NewRefreshable(ctx context.Context, project string, opts ...option) (*Refreshable, error) {
+	project = browserurl.Project(project)
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	r := &Refreshable{
+		project:    project,
+		load:       refreshLoader(ctx, func(ctx context.Context) (http.FileSystem, error) { return c.new(ctx, project) }),
+		onEvent:    c.onEvent,
+		serveStale: c.serveStale,
+	}
+	if c.isRemote(project) && githubfs.Versioned(project) {
+		r.resolve = func(ctx context.Context) (string, error) { return c.resolveCommit(ctx, project) }
+		// The commit is resolved before the filesystem is loaded, such that
+		// a push between them results in loading it again in the next
+		// refresh, and not in missing the push.
+		commit, err := r.resolve(ctx)
+		if err != nil {
+			log.Warn("Failed resolving commit of refreshable filesystem", "project", project, "error", err)
+		}
+		r.commit = commit
+	}
+	fs, err := New(ctx, project, opts...)
+	if err != nil {
+		return nil, err
+	}
+	r.fs = fs
+	return r, nil
+}
+
+// refreshLoader returns a function that loads a refreshed filesystem with a
+// context that is derived from the given one, and is canceled if the context
+// of the refresh is done before the load is done. This way a refresh can be
+// canceled, while the refreshed filesystem, that may load content lazily or
+// watch files, outlives the refresh.
+func refreshLoader(ctx context.Context, load func(context.Context) (http.FileSystem, error)) func(context.Context) (http.FileSystem, error) {
+	return func(refreshCtx context.Context) (http.FileSystem, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-refreshCtx.Done():
+				cancel()
+			case <-done:
+			}
+		}()
+		fs, err := load(ctx)
+		close(done)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		if err := refreshCtx.Err(); err != nil {
+			// The refresh was canceled, but the load was already done.
+			cancel()
+			return nil, err
+		}
+		return fs, nil
+	}
+}
+
+// Open implements the http.FileSystem interface.
+func (r *Refreshable) Open(name string) (http.File, error) {
+	r.mu.RLock()
+	fs := r.fs
+	r.mu.RUnlock()
+	return fs.Open(name)
+}
+
+// Refresh loads the filesystem again. If the filesystem is of a remote
+// repository, and the ref of the project points to the commit that was
+// already loaded, it is not loaded again. If loading fails, the previous
+// content is kept. An EventRefreshed event is emitted when it is done, after
+// the refreshed content is served.
+func (r *Refreshable) Refresh(ctx context.Context) error {
+	r.refreshing.Lock()
+	defer r.refreshing.Unlock()
+	start := time.Now()
+	fs, commit, err := r.refresh(ctx)
+	switch {
+	case err != nil:
+	case fs == nil:
+		log.Debug("Filesystem is up to date", "project", r.project, "commit", commit)
+	default:
+		log.Info("Refreshed filesystem", "project", r.project)
+		r.commit = commit
+		r.mu.Lock()
+		if r.serveStale {
+			fs = stalefs.New(fs, r.fs, r.servedStale)
+		}
+		r.fs = fs
+		r.mu.Unlock()
+	}
+	if r.onEvent != nil {
+		r.onEvent(Event{Type: EventRefreshed, Project: r.project, Duration: time.Since(start), Err: err})
+	}
+	return err
+}
+
+// servedStale reports a file that is served from the filesystem before the
+// last refresh, since loading it failed with the given error.
+func (r *Refreshable) servedStale(name string, err error) {
+	log.Warn("Failed loading file, serving stale content", "project", r.project, "path", name, "error", err)
+	if r.onEvent != nil {
+		r.onEvent(Event{Type: EventServedStale, Project: r.project, Path: name, Err: err})
+	}
+}
+
+// refresh loads the filesystem and returns it with the commit that it was
+// loaded from, if it is known. It returns a nil filesystem if the commit was
+// already loaded.
+func (r *Refreshable) refresh(ctx context.Context) (http.FileSystem, string, error) {
+	var commit string
+	if r.resolve != nil {
+		var err error
+		commit, err = r.resolve(ctx)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "resolving commit")
+		}
+		if commit == r.commit {
+			return nil, commit, nil
+		}
+	}
+	fs, err := r.load(ctx)
+	return fs, commit, err
+}
+
+// WebhookRefresher returns a handler of Github webhooks that refreshes the
+// given filesystems when the refs of their projects are pushed. Projects
+// without a ref are refreshed when the default branch is pushed, and projects
+// that are pinned to a commit are never refreshed. The handler should be
+// registered as the webhook of the repositories, with the `push` event and
+// the `application/json` content type. If the secret is not empty, requests
+// must be signed with it, as Github does when the webhook has a secret. The
+// refreshes are done in the background after the handler responds, and their
+// failures are logged.
+//
+// 	fs, err := gitfs.NewRefreshable(ctx, "github.com/x/y@heads/master")
+// 	http.Handle("/webhook", gitfs.WebhookRefresher(fs, os.Getenv("WEBHOOK_SECRET")))
+func WebhookRefresher(fs *Refreshable, secret string, more ...*Refreshable) http.Handler {
+	fss := append([]*Refreshable{fs}, more...)
+	return webhook.Handler(secret, func(p webhook.Push) {
+		for _, fs := range fss {
+			if !githubfs.MatchPush(fs.project, p.Repository.FullName, p.Ref, p.Repository.DefaultBranch) {
+				continue
+			}
+			go func(fs *Refreshable) {
+				if err := fs.Refresh(context.Background()); err != nil {
+					log.Error("Failed refreshing filesystem", "project", fs.project, "error", err)
+				}
+			}(fs)
+		}
+	})
+}
+
+// NewLocalAuto returns a filesystem of a path in the Go module of the current
+// working directory, loaded from the local repository as with OptLocal. The
+// project is derived from the remote URL of the git repository, or from the
+// go.mod file if the repository has no remote URL, such that it is not
+// required to be repeated in the code. The given path is relative to the root
+// of the module, and may contain a ref (e.g. "static@v1.2.3").
+//
+// 	fs, err := gitfs.NewLocalAuto(ctx, "static")
+func NewLocalAuto(ctx context.Context, relPath string, opts ...option) (http.FileSystem, error) {
+	project, root, err := localfs.DetectProject(".")
+	if err != nil {
+		return nil, errors.Wrap(err, "detecting project")
+	}
+	if relPath = strings.Trim(relPath, "/"); relPath != "" && relPath[0] != '@' {
+		project += "/"
+	}
+	return New(ctx, project+relPath, append(opts[:len(opts):len(opts)], OptLocal(root))...)
+}
+
+// NewFromMap returns an in-memory filesystem with the given files. The map
+// keys are the file paths, and the values are the file contents. A key that
+// ends with "/" adds a directory, and its value is ignored. Parent directories
+// are added automatically. The returned filesystem has the same semantics as
+// the filesystems that are returned by New.
+func NewFromMap(files map[string][]byte) (http.FileSystem, error) {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	t := make(tree.Tree)
+	for _, path := range paths {
+		var err error
+		if strings.HasSuffix(path, "/") {
+			err = t.AddDir(path)
+		} else {
+			err = t.AddFileContent(path, files[path])
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "adding %s", path)
+		}
+	}
+	return t, nil
+}
+
+// NewFromTar returns an in-memory filesystem with the content of a tar
+// archive. The archive is read entirely when the filesystem is created.
+func NewFromTar(r io.Reader) (http.FileSystem, error) {
+	return archivefs.FromTar(r)
+}
+
+// NewFromZip returns a filesystem with the content of a zip archive of the
+// given size. Files are decompressed lazily when they are read, such that r
+// should be available as long as the filesystem is used.
+func NewFromZip(r io.ReaderAt, size int64) (http.FileSystem, error) {
+	return archivefs.FromZip(r, size)
+}
+
+// VerifyAgainstRemote compares the content that was packed into the binary
+// for the given project with the content of the remote repository at the
+// project's ref. It can be used as a health check to detect drift between
+// the packed content and the remote. The same options that were used to create
+// the filesystem should be given, such that the same files are compared.
+// In the returned diff, A is the binary and B is the remote content.
+func VerifyAgainstRemote(ctx context.Context, project string, opts ...option) (*fsutil.FileSystemDiff, error) {
+	project = browserurl.Project(project)
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if !binfs.Match(project) {
+		return nil, errors.Errorf("project %q is not packed in the binary", project)
+	}
+	if !githubfs.Match(project) {
+		return nil, errors.Errorf("project %q not supported", project)
+	}
+	packed, err := binfs.Get(project)
+	if err != nil {
+		return nil, err
+	}
+	packed, err = c.glob(packed)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := githubfs.New(ctx, project, c.github())
+	if err != nil {
+		return nil, errors.Wrap(err, "loading remote filesystem")
+	}
+	diff, err := fsutil.Diff(packed, remote)
+	if err != nil {
+		return nil, err
+	}
+	diff.A = "binary"
+	diff.B = "remote"
+	return diff, nil
+}
+
 // WithContext applies context to an http.File if it implements the
 // contexter interface.
 //
@@ -229,21 +1548,357 @@ func WithContext(f http.File, ctx context.Context) http.File {
 	return fCtx.WithContext(ctx)
 }
 
+// RateLimitError is returned when the Github API rate limit was exceeded. It
+// contains the time in which the rate limit resets. See OptWaitRateLimit.
+type RateLimitError = githubfs.RateLimitError
+
+// TotalSizeError is returned when the total size of the prefetched content of
+// a project exceeds the limit of OptMaxTotalSize.
+type TotalSizeError = githubfs.TotalSizeError
+
+// SizeError is returned when reading a remote file whose content does not
+// match the size in the repository tree. This happens if the repository
+// changed after the filesystem was created. In this case the filesystem
+// should be created again.
+type SizeError = tree.SizeError
+
+// Commit is information about the last commit that modified a file or a
+// directory. See LastCommit.
+type Commit = tree.Commit
+
+// LastCommit returns the last commit that modified an opened file or
+// directory. It is available for remote filesystems, and is loaded lazily
+// when requested. It returns an error if commit information is not available.
+//
+// Usage example:
+//
+// 	f, err := fs.Open("README.md")
+// 	// Handle err...
+// 	commit, err := gitfs.LastCommit(f)
+func LastCommit(f http.File) (*Commit, error) {
+	fCommit, ok := f.(committer)
+	if !ok {
+		return nil, errors.New("commit information is not available")
+	}
+	return fCommit.Commit()
+}
+
+// Tag is information about an annotated git tag. See TagInfo.
+type Tag = githubfs.Tag
+
+// TagInfo returns the annotated tag that is the ref of a remote filesystem,
+// with its message, its tagger and the tagged commit, such that release notes
+// can be rendered from the same source as the content. It is loaded lazily
+// when requested. It returns an error if the ref of the filesystem is not a
+// tag, or if it is a lightweight tag, which has no tag information.
+//
+// Usage example:
+//
+// 	fs, err := gitfs.New(ctx, "github.com/x/y@v1.2.3")
+// 	// Handle err...
+// 	tag, err := gitfs.TagInfo(ctx, fs)
+func TagInfo(ctx context.Context, fs http.FileSystem) (*Tag, error) {
+	t, ok := fs.(tagger)
+	if !ok {
+		return nil, errors.New("tag information is not available")
+	}
+	return t.Tag(ctx)
+}
+
+// taggedFS is a filesystem whose ref is a tag.
+type taggedFS struct {
+	http.FileSystem
+	load func(context.Context) (*Tag, error)
+}
+
+func (t taggedFS) Tag(ctx context.Context) (*Tag, error) {
+	return t.load(ctx)
+}
+
+// IsDirty returns true if the filesystem is loaded from the working directory
+// of a local repository with OptLocal, and the working directory had changes
+// that were not committed when the filesystem was created. These are modified,
+// added or removed files, and untracked files that are not ignored. Such
+// changes are not available in the remote repository, such that the remote
+// content differs from the local content. When the project has a ref, the
+// content is read from the ref, and it is not dirty.
+func IsDirty(fs http.FileSystem) bool {
+	d, ok := fs.(dirtier)
+	return ok && d.Dirty()
+}
+
 // SetLogger sets informative logging for gitfs. If nil, no logging
-// will be done.
+// will be done. Each message is logged in a single line, with its level and
+// its fields. Use SetLeveledLogger to integrate with leveled loggers.
 func SetLogger(logger log.Logger) {
+	if logger == nil {
+		log.Log = nil
+		return
+	}
+	log.Log = log.FromPrintf(logger)
+}
+
+// LeveledLogger is a logger with levels. Messages are constant strings, and
+// their fields are given as alternating keys and values. The standard library
+// *slog.Logger implements this interface.
+type LeveledLogger = log.Leveled
+
+// SetLeveledLogger sets leveled logging for gitfs. If nil, no logging will be
+// done. Loading of filesystems is logged in the info level, and the details
+// of fetching files are logged in the debug level.
+//
+// 	gitfs.SetLeveledLogger(slog.Default())
+func SetLeveledLogger(logger LeveledLogger) {
 	log.Log = logger
 }
 
+// SugaredLogger returns a leveled logger that uses the methods with the `w`
+// suffix of the given logger, such as zap's *SugaredLogger.
+//
+// 	gitfs.SetLeveledLogger(gitfs.SugaredLogger(zapLogger.Sugar()))
+func SugaredLogger(logger log.Sugared) LeveledLogger {
+	return log.FromSugared(logger)
+}
+
+// FormattedLogger returns a leveled logger that uses the methods with the `f`
+// suffix of the given logger, such as logrus' *Logger. Each message is logged
+// with its fields in a single line.
+//
+// 	gitfs.SetLeveledLogger(gitfs.FormattedLogger(logrus.StandardLogger()))
+func FormattedLogger(logger log.Formatted) LeveledLogger {
+	return log.FromFormatted(logger)
+}
+
+// localWatchInterval is the interval of polling local files for changes.
+const localWatchInterval = time.Second
+
 type config struct {
-	client    *http.Client
-	localPath string
-	prefetch  bool
-	patterns  []string
+	client            *http.Client
+	githubClient      *github.Client
+	noEnvToken        bool
+	noNetrc           bool
+	localPath         string
+	localDir          string
+	noMatchRemote     bool
+	onLocalChange     func()
+	requireBinary     bool
+	lockFile          string
+	prefetch          bool
+	graphQL           bool
+	lazyDirs          bool
+	prefetchDirs      bool
+	patterns          []string
+	filter            func(path string, info os.FileInfo) bool
+	keepEmptyDirs     bool
+	resolveSymlinks   bool
+	pathModTimes      bool
+	cacheSize         int64
+	streamThreshold   int64
+	etags             *ETagCache
+	trees             *TreeCache
+	diskCacheDir      string
+	offline           bool
+	serveStale        bool
+	backend           Backend
+	sparseClone       bool
+	maxFiles          int
+	maxTotalSize      int64
+	requestsPerSecond float64
+	observer          Observer
+	onEvent           func(Event)
+	// shared, if set, is the Github configuration of filesystems that are
+	// created together by NewAll.
+	shared            *githubfs.Config
+	waitRateLimit     bool
+	userAgent         string
+	username          string
+	password          string
+	tlsConfig         *tls.Config
+	mirrors           []string
+	mirrorTokens      map[string]string
+	exportIgnore      bool
+	normalization     Normalization
+	strictReaddir     bool
+}
+
+// github returns the configuration for a Github filesystem.
+func (c *config) github() githubfs.Config {
+	if c.shared != nil {
+		return *c.shared
+	}
+	return githubfs.Config{
+		Client:            c.httpClient(),
+		GithubClient:      c.githubClient,
+		Prefetch:          c.prefetch,
+		GraphQL:           c.graphQL,
+		Glob:              c.patterns,
+		KeepEmptyDirs:     c.keepEmptyDirs,
+		Filter:            c.filter,
+		ResolveSymlinks:   c.resolveSymlinks,
+		PathModTimes:      c.pathModTimes,
+		CacheSize:         c.cacheSize,
+		StreamThreshold:   c.streamThreshold,
+		ETags:             c.etags,
+		Trees:             c.trees,
+		DiskCache:         c.diskCache(),
+		Offline:           c.offline,
+		Clone:             c.backend == BackendClone,
+		SparseClone:       c.sparseClone,
+		MaxFiles:          c.maxFiles,
+		MaxTotalSize:      c.maxTotalSize,
+		RequestsPerSecond: c.requestsPerSecond,
+		Observer:          c.observer,
+		WaitRateLimit:     c.waitRateLimit,
+		UserAgent:         c.userAgent,
+		Username:          c.username,
+		Password:          c.password,
+		TLSConfig:         c.tlsConfig,
+		Mirrors:           c.mirrors,
+		MirrorTokens:      c.mirrorTokens,
+	}
+}
+
+// diskCache returns the disk cache for remote repositories, if it is enabled.
+func (c *config) diskCache() *diskcache.Cache {
+	dir := c.diskCacheDir
+	if dir == "" && c.offline {
+		dir = DefaultCacheDir()
+	}
+	if dir == "" {
+		return nil
+	}
+	return diskcache.New(dir)
+}
+
+// httpClient returns the client for remote repositories.
+func (c *config) httpClient() *http.Client {
+	if c.client != nil || c.username != "" || c.password != "" {
+		return c.client
+	}
+	if !c.noEnvToken {
+		if client := envTokenClient(os.Getenv); client != nil {
+			return client
+		}
+	}
+	if !c.noNetrc {
+		return netrcClient(os.Getenv)
+	}
+	return nil
+}
+
+// envTokenClient returns a client that is authorized with a Github token from
+// the environment. It returns nil if no token is set.
+func envTokenClient(getenv func(string) string) *http.Client {
+	for _, name := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if token := getenv(name); token != "" {
+			log.Debug("Using Github token from environment", "variable", name)
+			return oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
+		}
+	}
+	return nil
+}
+
+// netrcClient returns a client that is authorized with the credentials of the
+// netrc file. It returns nil if the file has no credentials.
+func netrcClient(getenv func(string) string) *http.Client {
+	machines, err := netrc.Load(getenv)
+	if err != nil {
+		log.Warn("Failed reading netrc file", "error", err)
+		return nil
+	}
+	if len(machines) == 0 {
+		return nil
+	}
+	log.Debug("Using credentials from netrc file", "path", netrc.Path(getenv))
+	return &http.Client{Transport: &netrc.Transport{Machines: machines}}
+}
+
+// isRemote returns true if the filesystem of the project is loaded from a
+// remote Github repository.
+func (c *config) isRemote(project string) bool {
+	return c.localDir == "" && c.localPath == "" && !c.offline && !c.requireBinary &&
+		!binfs.Match(project) && githubfs.Match(project)
+}
+
+// resolveCommit returns the commit that the ref of the project points to. If
+// the project is locked, it is the commit in the lock file.
+func (c *config) resolveCommit(ctx context.Context, project string) (string, error) {
+	remote := project
+	if c.lockFile != "" {
+		var err error
+		if remote, err = c.pin(project); err != nil {
+			return "", err
+		}
+	}
+	return githubfs.ResolveCommit(ctx, remote, c.github())
+}
+
+// pin returns the project name with the commit that it is locked to in the
+// lock file. Projects that are not locked are not changed.
+func (c *config) pin(project string) (string, error) {
+	lf, err := lockfile.Read(c.lockFile)
+	if err != nil {
+		return "", errors.Wrap(err, "reading lock file")
+	}
+	e, ok := lf[project]
+	if !ok {
+		log.Warn("Project is not locked", "project", project, "lockfile", c.lockFile)
+		return project, nil
+	}
+	log.Debug("Using locked commit", "project", project, "commit", e.Commit)
+	return lockfile.Pin(project, e.Commit), nil
+}
+
+// glob applies the glob patterns on a filesystem.
+func (c *config) glob(fs http.FileSystem) (http.FileSystem, error) {
+	if c.keepEmptyDirs {
+		return fsutil.GlobFiles(fs, c.patterns...)
+	}
+	return fsutil.Glob(fs, c.patterns...)
+}
+
+// localFS applies the glob patterns and the filter on a local filesystem, and
+// starts watching it if a watch function was given.
+func (c *config) localFS(ctx context.Context, project string, local http.FileSystem) (http.FileSystem, error) {
+	fs, err := c.glob(local)
+	if err != nil {
+		return nil, err
+	}
+	if c.filter != nil {
+		fs = fsutil.FilterInfo(fs, c.filter)
+	}
+	if d, ok := local.(dirtier); ok {
+		// Keep the Dirty method of the local filesystem.
+		fs = struct {
+			http.FileSystem
+			dirtier
+		}{fs, d}
+	}
+	if c.onLocalChange != nil {
+		localfs.Watch(ctx, fs, localWatchInterval, func(d time.Duration) {
+			if c.onEvent != nil {
+				c.onEvent(Event{Type: EventRefreshed, Project: project, Duration: d})
+			}
+			c.onLocalChange()
+		})
+	}
+	return fs, nil
 }
 
 type option func(*config)
 
+type committer interface {
+	Commit() (*Commit, error)
+}
+
+type tagger interface {
+	Tag(ctx context.Context) (*Tag, error)
+}
+
+type dirtier interface {
+	Dirty() bool
+}
+
 type contexter interface {
 	WithContext(ctx context.Context) http.File
 }
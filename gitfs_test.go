@@ -1,14 +1,27 @@
 package gitfs
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
+	"encoding/hex"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	git "github.com/go-git/go-git/v5"
+	"github.com/pkg/errors"
 	"github.com/posener/gitfs/fsutil"
+	"github.com/posener/gitfs/internal/binfs"
+	"github.com/posener/gitfs/internal/tree"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
@@ -83,6 +96,702 @@ func TestNew_local(t *testing.T) {
 	require.NoError(t, err)
 }
 
+// Tests loading of a local directory that is not in a matching repository.
+func TestNew_localDir(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	fs, err := New(ctx, "github.com/x/y", OptLocalDir("internal/testdata"), OptGlob("d2", "d2/*"))
+	require.NoError(t, err)
+	_, err = fs.Open("d2/f21")
+	assert.NoError(t, err)
+	_, err = fs.Open("f01")
+	assert.Error(t, err)
+
+	_, err = New(ctx, "github.com/x/y", OptLocalDir("nosuchdir"))
+	assert.Error(t, err)
+	_, err = New(ctx, "github.com/x/y", OptLocalDir("gitfs.go"))
+	assert.Error(t, err)
+}
+
+func TestOptExportIgnore(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "testdata"), 0755))
+	for name, content := range map[string]string{
+		".gitattributes": "testdata export-ignore\n",
+		"a.txt":          "a",
+		"testdata/b.txt": "b",
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	ctx := context.Background()
+	fs, err := New(ctx, "github.com/x/y", OptLocalDir(dir), OptExportIgnore(true))
+	require.NoError(t, err)
+	_, err = fs.Open("a.txt")
+	assert.NoError(t, err)
+	_, err = fs.Open("testdata/b.txt")
+	assert.True(t, os.IsNotExist(err))
+	_, err = fs.Open("testdata")
+	assert.True(t, os.IsNotExist(err))
+
+	fs, err = New(ctx, "github.com/x/y", OptLocalDir(dir))
+	require.NoError(t, err)
+	_, err = fs.Open("testdata/b.txt")
+	assert.NoError(t, err)
+}
+
+func TestIsDirty(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "gitfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	_, err = git.PlainInit(dir, false)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644))
+
+	fs, err := New(ctx, "github.com/x/y", OptLocal(dir), OptLocalMatchRemote(false), OptGlob("*.txt"))
+	require.NoError(t, err)
+	assert.True(t, IsDirty(fs))
+
+	fs, err = New(ctx, "github.com/x/y", OptLocalDir(dir))
+	require.NoError(t, err)
+	assert.False(t, IsDirty(fs))
+}
+
+func TestNew_localWatch(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan struct{}, 10)
+	_, err = New(ctx, "github.com/x/y", OptLocalDir(dir), OptLocalWatch(func() { changes <- struct{}{} }))
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0644))
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change was not detected")
+	}
+}
+
+func TestOptEventHook(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan Event, 10)
+	hook := OptEventHook(func(e Event) { events <- e })
+	_, err = New(ctx, "github.com/x/y", OptLocalDir(dir), OptLocalWatch(func() {}), hook)
+	require.NoError(t, err)
+	e := <-events
+	assert.Equal(t, EventTreeLoaded, e.Type)
+	assert.Equal(t, "github.com/x/y", e.Project)
+	assert.NoError(t, e.Err)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0644))
+	select {
+	case e := <-events:
+		assert.Equal(t, EventRefreshed, e.Type)
+	case <-time.After(5 * time.Second):
+		t.Fatal("refresh event was not emitted")
+	}
+
+	_, err = New(ctx, "github.com/x/y", OptLocalDir("nosuchdir"), hook)
+	require.Error(t, err)
+	e = <-events
+	assert.Equal(t, EventTreeLoaded, e.Type)
+	assert.Error(t, e.Err)
+}
+
+func TestNew_requireBinary(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, err := New(ctx, "github.com/nosuchusername/nosuchproject", OptRequireBinary(true))
+	assert.EqualError(t, err, `project "github.com/nosuchusername/nosuchproject" was not packed into the binary, and OptRequireBinary is set`)
+
+	_, err = New(ctx, "github.com/x/y", OptLocalDir("internal/testdata"), OptRequireBinary(true))
+	assert.NoError(t, err)
+}
+
+func TestNewAll(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	fss, err := NewAll(ctx, []string{"github.com/x/y/d1", "github.com/x/y/d2", "github.com/x/y/d1"}, OptLocalDir("internal/testdata"))
+	require.NoError(t, err)
+	assert.Len(t, fss, 2)
+	_, err = fss["github.com/x/y/d2"].Open("d2/f21")
+	assert.NoError(t, err)
+
+	_, err = NewAll(ctx, []string{"git.com/b", "git.com/a"})
+	assert.EqualError(t, err, `loading git.com/b: project "git.com/b" not supported`)
+}
+
+func TestNewAtRefs(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	fss, err := NewAtRefs(ctx, "github.com/x/y/d2", []string{"v1", "v2"}, OptLocalDir("internal/testdata"))
+	require.NoError(t, err)
+	assert.Len(t, fss, 2)
+	_, err = fss["v2"].Open("d2/f21")
+	assert.NoError(t, err)
+
+	_, err = NewAtRefs(ctx, "github.com/x/y@v1", []string{"v2"})
+	assert.EqualError(t, err, `project "github.com/x/y@v1" should not have a ref`)
+}
+
+func TestOptFilter(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	filter := OptFilter(func(path string, info os.FileInfo) bool {
+		return path != "/d1" && path != "/f01"
+	})
+
+	// The packed filesystem is decoded by a decoder of a test version.
+	packed, err := NewFromMap(map[string][]byte{"f01": nil, "d1/d11/f111": nil, "d2/f21": nil})
+	require.NoError(t, err)
+	binfs.RegisterDecoder(-1, func(string) (http.FileSystem, error) { return packed, nil })
+	binfs.Register("github.com/x/packed", -1, "")
+
+	remote := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, status := `{}`, http.StatusNotFound
+		switch req.URL.Path {
+		case "/repos/x/y":
+			body, status = `{"default_branch":"master"}`, http.StatusOK
+		case "/repos/x/y/git/trees/heads/master":
+			body, status = `{"tree":[
+				{"path":"d1","type":"tree"},
+				{"path":"d1/d11","type":"tree"},
+				{"path":"d1/d11/f111","type":"blob","mode":"100644","size":1},
+				{"path":"d2","type":"tree"},
+				{"path":"d2/f21","type":"blob","mode":"100644","size":1},
+				{"path":"f01","type":"blob","mode":"100644","size":1}
+			]}`, http.StatusOK
+		}
+		return &http.Response{
+			StatusCode: status,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})}
+
+	tests := []struct {
+		name    string
+		project string
+		opts    []option
+	}{
+		{name: "local", project: "github.com/x/y", opts: []option{OptLocalDir("internal/testdata")}},
+		{name: "remote", project: "github.com/x/y", opts: []option{OptClient(remote)}},
+		{name: "packed", project: "github.com/x/packed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs, err := New(ctx, tt.project, append(tt.opts, filter)...)
+			require.NoError(t, err)
+			_, err = fs.Open("f01")
+			assert.True(t, os.IsNotExist(err))
+			_, err = fs.Open("d2/f21")
+			assert.NoError(t, err)
+			// The content of a directory that is filtered out is removed.
+			_, err = fs.Open("d1/d11/f111")
+			assert.True(t, os.IsNotExist(err))
+			root, err := fs.Open("/")
+			require.NoError(t, err)
+			infos, err := root.Readdir(-1)
+			require.NoError(t, err)
+			require.Len(t, infos, 1)
+			assert.Equal(t, "d2", infos[0].Name())
+		})
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	f, err := Open(ctx, "github.com/x/y/f01", OptLocalDir("internal/testdata"))
+	require.NoError(t, err)
+	st, err := f.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, "f01", st.Name())
+
+	_, err = Open(ctx, "github.com/x/y@heads/master")
+	assert.EqualError(t, err, `project "github.com/x/y@heads/master" does not have a file path`)
+}
+
+func TestReadFile(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	content, err := ReadFile(ctx, "github.com/x/y/d2/f21", OptLocalDir("internal/testdata/d2"))
+	require.NoError(t, err)
+	want, err := ioutil.ReadFile("internal/testdata/d2/f21")
+	require.NoError(t, err)
+	assert.Equal(t, want, content)
+
+	_, err = ReadFile(ctx, "github.com/x/y/nope", OptLocalDir("internal/testdata"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestTagInfo(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	fs, err := NewFromMap(map[string][]byte{"f": []byte("v1")})
+	require.NoError(t, err)
+	_, err = TagInfo(ctx, fs)
+	assert.EqualError(t, err, "tag information is not available")
+
+	want := &Tag{Name: "v1.0.0", Message: "Release", Commit: "c1"}
+	tagged := taggedFS{FileSystem: fs, load: func(context.Context) (*Tag, error) { return want, nil }}
+	tag, err := TagInfo(ctx, tagged)
+	require.NoError(t, err)
+	assert.Equal(t, want, tag)
+}
+
+func TestSplitFile(t *testing.T) {
+	t.Parallel()
+	dir, name, err := splitFile("github.com/x/y/a/b.txt@heads/release/1.x")
+	require.NoError(t, err)
+	assert.Equal(t, "github.com/x/y/a@heads/release/1.x", dir)
+	assert.Equal(t, "b.txt", name)
+
+	dir, name, err = splitFile("github.com/x/y/b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "github.com/x/y", dir)
+	assert.Equal(t, "b.txt", name)
+}
+
+func TestResolve_notSupported(t *testing.T) {
+	t.Parallel()
+	_, err := Resolve(context.Background(), "git.com/a")
+	assert.EqualError(t, err, `project "git.com/a" not supported`)
+}
+
+func TestWebhookRefresher(t *testing.T) {
+	t.Parallel()
+	v1, err := NewFromMap(map[string][]byte{"f": []byte("v1")})
+	require.NoError(t, err)
+	v2, err := NewFromMap(map[string][]byte{"f": []byte("v2")})
+	require.NoError(t, err)
+
+	refreshed := make(chan Event, 1)
+	fs := &Refreshable{
+		project: "github.com/x/y@heads/dev",
+		load:    func(context.Context) (http.FileSystem, error) { return v2, nil },
+		onEvent: func(e Event) { refreshed <- e },
+		fs:      v1,
+	}
+	h := WebhookRefresher(fs, "")
+	push := func(ref string) int {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(
+			`{"ref":"`+ref+`","repository":{"full_name":"x/y","default_branch":"master"}}`))
+		r.Header.Set("X-GitHub-Event", "push")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// A push of another branch does not refresh the filesystem.
+	assert.Equal(t, http.StatusAccepted, push("refs/heads/master"))
+	assert.Equal(t, "v1", string(readFile(t, fs, "f")))
+
+	assert.Equal(t, http.StatusAccepted, push("refs/heads/dev"))
+	select {
+	case e := <-refreshed:
+		assert.Equal(t, EventRefreshed, e.Type)
+		assert.NoError(t, e.Err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("filesystem was not refreshed")
+	}
+	assert.Equal(t, "v2", string(readFile(t, fs, "f")))
+}
+
+func TestRefreshable_loadError(t *testing.T) {
+	t.Parallel()
+	v1, err := NewFromMap(map[string][]byte{"f": []byte("v1")})
+	require.NoError(t, err)
+	fs := &Refreshable{
+		project: "github.com/x/y",
+		load:    func(context.Context) (http.FileSystem, error) { return nil, errors.New("failed") },
+		fs:      v1,
+	}
+	assert.Error(t, fs.Refresh(context.Background()))
+	assert.Equal(t, "v1", string(readFile(t, fs, "f")))
+}
+
+func TestRefreshable_serveStale(t *testing.T) {
+	t.Parallel()
+	v1, err := NewFromMap(map[string][]byte{"f": []byte("v1")})
+	require.NoError(t, err)
+	v2 := make(tree.Tree)
+	require.NoError(t, v2.AddFile("f", 2, func(context.Context) ([]byte, error) { return nil, errors.New("failed") }))
+	var events []Event
+	fs := &Refreshable{
+		project:    "github.com/x/y",
+		load:       func(context.Context) (http.FileSystem, error) { return v2, nil },
+		onEvent:    func(e Event) { events = append(events, e) },
+		serveStale: true,
+		fs:         v1,
+	}
+	require.NoError(t, fs.Refresh(context.Background()))
+	assert.Equal(t, "v1", string(readFile(t, fs, "f")))
+	require.Len(t, events, 2)
+	assert.Equal(t, EventRefreshed, events[0].Type)
+	assert.Equal(t, EventServedStale, events[1].Type)
+	assert.Equal(t, "f", events[1].Path)
+}
+
+func TestRefreshable_unchangedCommit(t *testing.T) {
+	t.Parallel()
+	v1, err := NewFromMap(map[string][]byte{"f": []byte("v1")})
+	require.NoError(t, err)
+	v2, err := NewFromMap(map[string][]byte{"f": []byte("v2")})
+	require.NoError(t, err)
+	commit, loads := "c1", 0
+	fs := &Refreshable{
+		project: "github.com/x/y",
+		load: func(context.Context) (http.FileSystem, error) {
+			loads++
+			return v2, nil
+		},
+		resolve: func(context.Context) (string, error) { return commit, nil },
+		commit:  "c1",
+		fs:      v1,
+	}
+
+	// The ref was not changed.
+	require.NoError(t, fs.Refresh(context.Background()))
+	assert.Equal(t, 0, loads)
+	assert.Equal(t, "v1", string(readFile(t, fs, "f")))
+
+	commit = "c2"
+	require.NoError(t, fs.Refresh(context.Background()))
+	require.NoError(t, fs.Refresh(context.Background()))
+	assert.Equal(t, 1, loads)
+	assert.Equal(t, "v2", string(readFile(t, fs, "f")))
+}
+
+func TestRefreshable_canceled(t *testing.T) {
+	t.Parallel()
+	v1, err := NewFromMap(map[string][]byte{"f": []byte("v1")})
+	require.NoError(t, err)
+	v2, err := NewFromMap(map[string][]byte{"f": []byte("v2")})
+	require.NoError(t, err)
+	var loadCtx context.Context
+	block := true
+	fs := &Refreshable{
+		project: "github.com/x/y",
+		load: refreshLoader(context.Background(), func(ctx context.Context) (http.FileSystem, error) {
+			loadCtx = ctx
+			if block {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return v2, nil
+		}),
+		fs: v1,
+	}
+
+	// A canceled refresh cancels the load.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, fs.Refresh(ctx))
+	assert.Equal(t, "v1", string(readFile(t, fs, "f")))
+
+	// The refreshed filesystem outlives the context of the refresh.
+	block = false
+	ctx, cancel = context.WithCancel(context.Background())
+	require.NoError(t, fs.Refresh(ctx))
+	cancel()
+	assert.NoError(t, loadCtx.Err())
+	assert.Equal(t, "v2", string(readFile(t, fs, "f")))
+}
+
+func TestRefreshable_reloadTemplates(t *testing.T) {
+	t.Parallel()
+	v1, err := NewFromMap(map[string][]byte{"index.html": []byte("v1")})
+	require.NoError(t, err)
+	v2, err := NewFromMap(map[string][]byte{"index.html": []byte("v2")})
+	require.NoError(t, err)
+	var tmpl *fsutil.TmplReloader
+	fs := &Refreshable{
+		project: "github.com/x/y",
+		load:    func(context.Context) (http.FileSystem, error) { return v2, nil },
+		onEvent: func(e Event) { tmpl.EventHook(e) },
+		fs:      v1,
+	}
+	tmpl, err = fsutil.TmplReloadGlobHTML(fs, nil, "*.html")
+	require.NoError(t, err)
+
+	require.NoError(t, fs.Refresh(context.Background()))
+	var b bytes.Buffer
+	require.NoError(t, tmpl.ExecuteTemplate(&b, "index.html", nil))
+	assert.Equal(t, "v2", b.String())
+}
+
+func readFile(t *testing.T, fs http.FileSystem, name string) []byte {
+	t.Helper()
+	f, err := fs.Open(name)
+	require.NoError(t, err)
+	defer f.Close()
+	content, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	return content
+}
+
+func TestNewMount(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	fs, err := NewMount(ctx, map[string]string{
+		"a": "github.com/x/y",
+		"b": "github.com/x/y/d1",
+	}, OptLocalDir("internal/testdata"))
+	require.NoError(t, err)
+	_, err = fs.Open("a/d2/f21")
+	assert.NoError(t, err)
+	_, err = fs.Open("b/d1/d11/f111")
+	assert.NoError(t, err)
+}
+
+func TestConfigPin(t *testing.T) {
+	t.Parallel()
+	f, err := ioutil.TempFile("", "gitfs.lock")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("github.com/x/y/d@v1.0.0 0123456789abcdef0123456789abcdef01234567 h1:abc\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	c := config{lockFile: f.Name()}
+	pinned, err := c.pin("github.com/x/y/d@v1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "github.com/x/y/d@0123456789abcdef0123456789abcdef01234567", pinned)
+
+	pinned, err = c.pin("github.com/x/z")
+	require.NoError(t, err)
+	assert.Equal(t, "github.com/x/z", pinned)
+
+	c = config{lockFile: "nosuchfile"}
+	_, err = c.pin("github.com/x/y")
+	assert.Error(t, err)
+}
+
+func TestNewLocalAuto(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	fs, err := NewLocalAuto(ctx, "internal/testdata")
+	require.NoError(t, err)
+	_, err = fs.Open("d2/f21")
+	assert.NoError(t, err)
+}
+
+func TestNewFromMap(t *testing.T) {
+	t.Parallel()
+	fs, err := NewFromMap(map[string][]byte{
+		"a.txt":   []byte("a"),
+		"d/b.txt": []byte("b"),
+		"empty/":  nil,
+	})
+	require.NoError(t, err)
+
+	f, err := fs.Open("d/b.txt")
+	require.NoError(t, err)
+	content, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "b", string(content))
+
+	st, err := fs.Open("empty")
+	require.NoError(t, err)
+	files, err := st.Readdir(0)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestNewFromMap_conflict(t *testing.T) {
+	t.Parallel()
+	_, err := NewFromMap(map[string][]byte{
+		"a":   []byte("a"),
+		"a/b": []byte("b"),
+	})
+	assert.Error(t, err)
+}
+
+func TestNewFromTar(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	require.NoError(t, w.WriteHeader(&tar.Header{Name: "d/a.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 1}))
+	_, err := w.Write([]byte("a"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	fs, err := NewFromTar(&buf)
+	require.NoError(t, err)
+
+	f, err := fs.Open("d/a.txt")
+	require.NoError(t, err)
+	content, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(content))
+}
+
+func TestEnvTokenClient(t *testing.T) {
+	t.Parallel()
+	env := func(vars map[string]string) func(string) string {
+		return func(name string) string { return vars[name] }
+	}
+
+	assert.Nil(t, envTokenClient(env(nil)))
+	assert.NotNil(t, envTokenClient(env(map[string]string{"GITHUB_TOKEN": "token"})))
+	assert.NotNil(t, envTokenClient(env(map[string]string{"GH_TOKEN": "token"})))
+}
+
+func TestConfigHTTPClient(t *testing.T) {
+	t.Parallel()
+	client := &http.Client{}
+	c := config{client: client}
+	assert.Equal(t, client, c.httpClient())
+
+	c = config{noEnvToken: true, noNetrc: true}
+	assert.Nil(t, c.httpClient())
+
+	c = config{username: "user", password: "pass"}
+	assert.Nil(t, c.httpClient())
+}
+
+func TestNetrcClient(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "netrc")
+	require.NoError(t, ioutil.WriteFile(path, []byte("machine github.com login user password token"), 0600))
+	env := func(vars map[string]string) func(string) string {
+		return func(name string) string { return vars[name] }
+	}
+
+	assert.Nil(t, netrcClient(env(nil)))
+	assert.Nil(t, netrcClient(env(map[string]string{"NETRC": filepath.Join(dir, "missing")})))
+	assert.NotNil(t, netrcClient(env(map[string]string{"NETRC": path})))
+}
+
+func TestLastCommit_notAvailable(t *testing.T) {
+	t.Parallel()
+	fs, err := NewFromMap(map[string][]byte{"a": []byte("a")})
+	require.NoError(t, err)
+	f, err := fs.Open("a")
+	require.NoError(t, err)
+	_, err = LastCommit(f)
+	assert.Error(t, err)
+
+	_, err = LastCommit(nil)
+	assert.Error(t, err)
+}
+
+func TestVerifyAgainstRemote_notPacked(t *testing.T) {
+	t.Parallel()
+	_, err := VerifyAgainstRemote(context.Background(), "github.com/nosuchusername/nosuchproject")
+	assert.Error(t, err)
+}
+
+func TestVerifyAgainstRemote(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// The packed filesystem is decoded by a decoder of a test version.
+	packed, err := NewFromMap(map[string][]byte{
+		"same":     []byte("same\n"),
+		"modified": []byte("old\n"),
+		"removed":  []byte("removed\n"),
+	})
+	require.NoError(t, err)
+	binfs.RegisterDecoder(-2, func(string) (http.FileSystem, error) { return packed, nil })
+	binfs.Register("github.com/x/verify", -2, "")
+
+	// remote returns a client of a remote repository with the given files.
+	// The SHAs of the blobs are their hex encoded content.
+	remote := func(files map[string]string) *http.Client {
+		return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			body, status := `{}`, http.StatusNotFound
+			switch {
+			case req.URL.Path == "/repos/x/verify":
+				body, status = `{"default_branch":"master"}`, http.StatusOK
+			case req.URL.Path == "/repos/x/verify/git/trees/heads/master":
+				var entries []string
+				for path, content := range files {
+					entries = append(entries, fmt.Sprintf(`{"path":%q,"type":"blob","mode":"100644","size":%d,"sha":%q}`, path, len(content), hex.EncodeToString([]byte(content))))
+				}
+				body, status = `{"tree":[`+strings.Join(entries, ",")+`]}`, http.StatusOK
+			case strings.HasPrefix(req.URL.Path, "/repos/x/verify/git/blobs/"):
+				content, err := hex.DecodeString(strings.TrimPrefix(req.URL.Path, "/repos/x/verify/git/blobs/"))
+				if err != nil {
+					return nil, err
+				}
+				body, status = string(content), http.StatusOK
+			}
+			return &http.Response{
+				StatusCode: status,
+				Header:     make(http.Header),
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		})}
+	}
+
+	tests := []struct {
+		name   string
+		remote map[string]string
+		want   map[string]string
+	}{
+		{
+			name:   "equal",
+			remote: map[string]string{"same": "same\n", "modified": "old\n", "removed": "removed\n"},
+			want:   map[string]string{},
+		},
+		{
+			name:   "modified",
+			remote: map[string]string{"same": "same\n", "modified": "new\n", "removed": "removed\n"},
+			want:   map[string]string{"modified": "content diff (-{{.A}}, +{{.B}}):"},
+		},
+		{
+			name:   "added",
+			remote: map[string]string{"same": "same\n", "modified": "old\n", "removed": "removed\n", "added": "added\n"},
+			want:   map[string]string{"added": "only in {{.B}}"},
+		},
+		{
+			name:   "removed",
+			remote: map[string]string{"same": "same\n", "modified": "old\n"},
+			want:   map[string]string{"removed": "only in {{.A}}"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := VerifyAgainstRemote(ctx, "github.com/x/verify", OptClient(remote(tt.remote)))
+			require.NoError(t, err)
+			assert.Equal(t, "binary", diff.A)
+			assert.Equal(t, "remote", diff.B)
+			got := make(map[string]string)
+			for _, d := range diff.Diffs {
+				got[d.Path] = d.Diff
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func TestWithContext(t *testing.T) {
 	t.Parallel()
 	fs, err := New(context.Background(), "github.com/posener/gitfs")
@@ -10,8 +10,10 @@ require (
 	github.com/kr/fs v0.1.0
 	github.com/pkg/errors v0.9.1
 	github.com/posener/diff v0.0.1
+	github.com/prometheus/client_golang v1.9.0
 	github.com/stretchr/testify v1.9.0
 	golang.org/x/oauth2 v0.0.0-20190604053449-0f29369cfe45
+	golang.org/x/text v0.14.0
 	golang.org/x/tools v0.13.0
 	google.golang.org/appengine v1.6.1 // indirect
 )
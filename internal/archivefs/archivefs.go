@@ -0,0 +1,175 @@
+// Package archivefs is filesystem over tar and zip archives, and git trees.
+package archivefs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/tree"
+)
+
+// Filter maps the path of an archive entry to its path in the filesystem.
+// It returns false if the entry should not be added to the filesystem.
+type Filter func(name string, isDir bool) (string, bool)
+
+// FromTar returns a tree with the content of a tar archive. The archive is
+// read entirely, and the content of all files is kept in memory. Symlinks are
+// files whose content is the link target and their mode has the
+// os.ModeSymlink bit. Other special files are ignored.
+func FromTar(r io.Reader) (tree.Tree, error) {
+	return FromTarFilter(r, nil)
+}
+
+// FromTarFilter is like FromTar, but only entries that pass the filter are
+// added. If the filter is nil, all entries are added.
+func FromTarFilter(r io.Reader, filter Filter) (tree.Tree, error) {
+	return FromTarFilterSize(r, filter, nil)
+}
+
+// FromTarFilterSize is like FromTarFilter, but before the content of each
+// added file is read, addSize, if not nil, is called with the size of the
+// file. If it returns an error, reading the archive stops, and the error is
+// returned. It enables bounding the memory that the content takes.
+func FromTarFilterSize(r io.Reader, filter Filter, addSize func(int64) error) (tree.Tree, error) {
+	t := make(tree.Tree)
+	tr := tar.NewReader(r)
+	var buf []byte
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return t, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "reading tar")
+		}
+		name := cleanPath(h.Name)
+		if filter != nil && name != "" {
+			var ok bool
+			name, ok = filter(name, h.Typeflag == tar.TypeDir)
+			if !ok {
+				continue
+			}
+			name = cleanPath(name)
+		}
+		if name == "" {
+			continue
+		}
+		switch h.Typeflag {
+		case tar.TypeDir:
+			err = t.AddDir(name)
+		case tar.TypeReg, tar.TypeRegA:
+			if addSize != nil {
+				if err := addSize(h.Size); err != nil {
+					return nil, err
+				}
+			}
+			var content []byte
+			content, err = readContent(tr, h.Size, &buf)
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading %s", name)
+			}
+			err = addFile(t, name, content, os.FileMode(h.Mode).Perm())
+		case tar.TypeSymlink:
+			err = addFile(t, name, []byte(h.Linkname), os.ModeSymlink|os.FileMode(h.Mode).Perm())
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "adding %s", name)
+		}
+	}
+}
+
+// slabSize is the size of the buffers that the contents of small files in tar
+// archives are read into. Archives of many small files are read with a single
+// allocation per slab, instead of allocating and growing a buffer per file.
+const slabSize = 1 << 20
+
+// readContent reads the content of the current file of a tar archive, of the
+// given size. Files of up to a quarter of a slab are read into the free part
+// of buf, which is replaced with a new slab when it is full. Larger files are
+// read into their own buffer.
+func readContent(r io.Reader, size int64, buf *[]byte) ([]byte, error) {
+	if size > slabSize/4 {
+		return ioutil.ReadAll(r)
+	}
+	n := int(size)
+	if cap(*buf)-len(*buf) < n {
+		*buf = make([]byte, 0, slabSize)
+	}
+	start := len(*buf)
+	*buf = (*buf)[:start+n]
+	// The capacity is limited such that appending to the content does not
+	// override the content of the next file.
+	content := (*buf)[start : start+n : start+n]
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// FromZip returns a tree with the content of a zip archive. Files are
+// decompressed lazily from r, only when they are read, such that r should be
+// available as long as the tree is used. Symlinks are files whose content is
+// the link target and their mode has the os.ModeSymlink bit.
+func FromZip(r io.ReaderAt, size int64) (tree.Tree, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading zip")
+	}
+	t := make(tree.Tree, len(zr.File))
+	for _, f := range zr.File {
+		name := cleanPath(f.Name)
+		if name == "" {
+			continue
+		}
+		mode := f.Mode()
+		if mode.IsDir() {
+			err = t.AddDir(name)
+		} else {
+			err = t.AddFile(name, int(f.UncompressedSize64), zipLoader(f))
+			if err == nil {
+				err = t.SetMode(name, mode)
+			}
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "adding %s", name)
+		}
+	}
+	return t, nil
+}
+
+// zipLoader returns a loader of the content of a file in a zip archive.
+func zipLoader(f *zip.File) tree.Loader {
+	return func(ctx context.Context) ([]byte, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, errors.Wrapf(err, "opening %s", f.Name)
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+}
+
+func addFile(t tree.Tree, name string, content []byte, mode os.FileMode) error {
+	if err := t.AddFileContent(name, content); err != nil {
+		return err
+	}
+	return t.SetMode(name, mode)
+}
+
+// cleanPath returns the path of an archive entry, relative to the root of the
+// filesystem. Paths can't point outside of the root.
+func cleanPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
@@ -0,0 +1,159 @@
+package archivefs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromTar(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	writeTar(t, w, &tar.Header{Name: "./d/", Typeflag: tar.TypeDir, Mode: 0755}, "")
+	writeTar(t, w, &tar.Header{Name: "./d/a.sh", Typeflag: tar.TypeReg, Mode: 0755}, "#!/bin/sh")
+	writeTar(t, w, &tar.Header{Name: "b", Typeflag: tar.TypeReg, Mode: 0644}, "b")
+	writeTar(t, w, &tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "b", Mode: 0777}, "")
+	writeTar(t, w, &tar.Header{Name: "../outside", Typeflag: tar.TypeReg, Mode: 0644}, "outside")
+	require.NoError(t, w.Close())
+
+	fs, err := FromTar(&buf)
+	require.NoError(t, err)
+
+	assertFile(t, fs, "d/a.sh", "#!/bin/sh", 0755)
+	assertFile(t, fs, "b", "b", 0644)
+	assertFile(t, fs, "link", "b", os.ModeSymlink|0777)
+	assertFile(t, fs, "outside", "outside", 0644)
+}
+
+func TestFromTar_invalid(t *testing.T) {
+	t.Parallel()
+	_, err := FromTar(bytes.NewReader([]byte("not a tar")))
+	assert.Error(t, err)
+}
+
+func TestFromZip(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	writeZip(t, w, "d/", 0755|os.ModeDir, "")
+	writeZip(t, w, "d/a.sh", 0755, "#!/bin/sh")
+	writeZip(t, w, "b", 0644, "b")
+	require.NoError(t, w.Close())
+
+	fs, err := FromZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	assertFile(t, fs, "d/a.sh", "#!/bin/sh", 0755)
+	assertFile(t, fs, "b", "b", 0644)
+}
+
+func TestFromZip_invalid(t *testing.T) {
+	t.Parallel()
+	_, err := FromZip(bytes.NewReader([]byte("not a zip")), 9)
+	assert.Error(t, err)
+}
+
+func writeTar(t *testing.T, w *tar.Writer, h *tar.Header, content string) {
+	t.Helper()
+	h.Size = int64(len(content))
+	require.NoError(t, w.WriteHeader(h))
+	_, err := w.Write([]byte(content))
+	require.NoError(t, err)
+}
+
+func writeZip(t *testing.T, w *zip.Writer, name string, mode os.FileMode, content string) {
+	t.Helper()
+	h := &zip.FileHeader{Name: name, Method: zip.Deflate}
+	h.SetMode(mode)
+	f, err := w.CreateHeader(h)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(content))
+	require.NoError(t, err)
+}
+
+func assertFile(t *testing.T, fs http.FileSystem, path string, content string, mode os.FileMode) {
+	t.Helper()
+	f, err := fs.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	st, err := f.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, mode, st.Mode())
+	got, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(got))
+}
+
+func TestFromTarFilter(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	writeTar(t, w, &tar.Header{Name: "root/", Typeflag: tar.TypeDir, Mode: 0755}, "")
+	writeTar(t, w, &tar.Header{Name: "root/a", Typeflag: tar.TypeReg, Mode: 0644}, "a")
+	writeTar(t, w, &tar.Header{Name: "root/b", Typeflag: tar.TypeReg, Mode: 0644}, "b")
+	require.NoError(t, w.Close())
+
+	// Remove the root directory and the b file.
+	fs, err := FromTarFilter(&buf, func(name string, isDir bool) (string, bool) {
+		parts := strings.SplitN(name, "/", 2)
+		if len(parts) < 2 || name == "root/b" {
+			return "", false
+		}
+		return parts[1], true
+	})
+	require.NoError(t, err)
+
+	assertFile(t, fs, "a", "a", 0644)
+	_, err = fs.Open("b")
+	assert.Error(t, err)
+	_, err = fs.Open("root")
+	assert.Error(t, err)
+}
+
+func TestFromTar_manySmallFiles(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	large := strings.Repeat("x", slabSize/2)
+	writeTar(t, w, &tar.Header{Name: "large", Typeflag: tar.TypeReg, Mode: 0644}, large)
+	for i := 0; i < 5000; i++ {
+		writeTar(t, w, &tar.Header{Name: fmt.Sprintf("d/%d", i), Typeflag: tar.TypeReg, Mode: 0644}, strings.Repeat(fmt.Sprint(i), 200))
+	}
+	writeTar(t, w, &tar.Header{Name: "empty", Typeflag: tar.TypeReg, Mode: 0644}, "")
+	require.NoError(t, w.Close())
+
+	fs, err := FromTar(&buf)
+	require.NoError(t, err)
+
+	assertFile(t, fs, "large", large, 0644)
+	assertFile(t, fs, "empty", "", 0644)
+	for i := 0; i < 5000; i++ {
+		assertFile(t, fs, fmt.Sprintf("d/%d", i), strings.Repeat(fmt.Sprint(i), 200), 0644)
+	}
+}
+
+func TestFromTar_truncated(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	writeTar(t, w, &tar.Header{Name: "a", Typeflag: tar.TypeReg, Mode: 0644}, strings.Repeat("a", 1000))
+	require.NoError(t, w.Flush())
+
+	_, err := FromTar(bytes.NewReader(buf.Bytes()[:1024]))
+	assert.Error(t, err)
+}
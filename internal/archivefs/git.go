@@ -0,0 +1,113 @@
+package archivefs
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/tree"
+)
+
+// FromGitTree returns a tree with the content of a git tree object. Files are
+// read lazily from the git object storage, only when they are read, such that
+// the storage should be available as long as the tree is used. Only entries
+// that pass the filter are added. If the filter is nil, all entries are added.
+// Symlinks are files whose content is the link target and their mode has the
+// os.ModeSymlink bit. Submodules are ignored.
+func FromGitTree(gitTree *object.Tree, filter Filter) (tree.Tree, error) {
+	return fromGitTree(gitTree, filter, false)
+}
+
+// FromGitTreeContent is like FromGitTree, but the content of the files is read
+// when the tree is created, such that the git object storage is not needed
+// after it returns.
+func FromGitTreeContent(gitTree *object.Tree, filter Filter) (tree.Tree, error) {
+	return fromGitTree(gitTree, filter, true)
+}
+
+func fromGitTree(gitTree *object.Tree, filter Filter, eager bool) (tree.Tree, error) {
+	t := make(tree.Tree)
+	walker := object.NewTreeWalker(gitTree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			return t, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "walking git tree")
+		}
+		if entry.Mode == filemode.Submodule {
+			continue
+		}
+		name = cleanPath(name)
+		if filter != nil {
+			var ok bool
+			name, ok = filter(name, entry.Mode == filemode.Dir)
+			if !ok {
+				continue
+			}
+			name = cleanPath(name)
+		}
+		if name == "" {
+			continue
+		}
+		if err := addGitEntry(t, gitTree, name, entry, eager); err != nil {
+			return nil, errors.Wrapf(err, "adding %s", name)
+		}
+	}
+}
+
+func addGitEntry(t tree.Tree, gitTree *object.Tree, name string, entry object.TreeEntry, eager bool) error {
+	var mode os.FileMode
+	switch entry.Mode {
+	case filemode.Dir:
+		return t.AddDir(name)
+	case filemode.Symlink:
+		mode = os.ModeSymlink
+	case filemode.Executable:
+		mode = 0755
+	default:
+		mode = 0644
+	}
+	f, err := gitTree.TreeEntryFile(&entry)
+	if err != nil {
+		return errors.Wrap(err, "get blob")
+	}
+	load := blobLoader(&f.Blob)
+	if eager {
+		content, err := load(context.Background())
+		if err != nil {
+			return err
+		}
+		err = t.AddFileContent(name, content)
+	} else {
+		err = t.AddFile(name, int(f.Size), load)
+	}
+	if err != nil {
+		return err
+	}
+	if err := t.SetHash(name, f.Blob.Hash.String()); err != nil {
+		return err
+	}
+	return t.SetMode(name, mode)
+}
+
+// blobLoader returns a loader of the content of a git blob.
+func blobLoader(blob *object.Blob) tree.Loader {
+	return func(ctx context.Context) ([]byte, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r, err := blob.Reader()
+		if err != nil {
+			return nil, errors.Wrap(err, "reading blob")
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+}
@@ -0,0 +1,58 @@
+package archivefs
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromGitTree(t *testing.T) {
+	t.Parallel()
+
+	wfs := memfs.New()
+	r, err := git.Init(memory.NewStorage(), wfs)
+	require.NoError(t, err)
+	require.NoError(t, util.WriteFile(wfs, "d/a.sh", []byte("#!/bin/sh"), 0755))
+	require.NoError(t, util.WriteFile(wfs, "d/b", []byte("b"), 0644))
+	require.NoError(t, util.WriteFile(wfs, "c", []byte("c"), 0644))
+	require.NoError(t, wfs.Symlink("c", "link"))
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, w.AddGlob("."))
+	h, err := w.Commit("commit", &git.CommitOptions{Author: &object.Signature{Name: "Gopher", When: time.Now()}})
+	require.NoError(t, err)
+	commit, err := r.CommitObject(h)
+	require.NoError(t, err)
+	gitTree, err := commit.Tree()
+	require.NoError(t, err)
+
+	fs, err := FromGitTree(gitTree, nil)
+	require.NoError(t, err)
+	assertFile(t, fs, "d/a.sh", "#!/bin/sh", 0755)
+	assertFile(t, fs, "d/b", "b", 0644)
+	assertFile(t, fs, "c", "c", 0644)
+	assertFile(t, fs, "link", "c", os.ModeSymlink)
+
+	// Filter out the b file.
+	fs, err = FromGitTree(gitTree, func(name string, isDir bool) (string, bool) {
+		return name, name != "d/b"
+	})
+	require.NoError(t, err)
+	assertFile(t, fs, "d/a.sh", "#!/bin/sh", 0755)
+	_, err = fs.Open("d/b")
+	assert.Error(t, err)
+
+	// Content is read when the tree is created.
+	fs, err = FromGitTreeContent(gitTree, nil)
+	require.NoError(t, err)
+	assertFile(t, fs, "d/a.sh", "#!/bin/sh", 0755)
+	assertFile(t, fs, "link", "c", os.ModeSymlink)
+}
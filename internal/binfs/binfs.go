@@ -14,20 +14,96 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/posener/gitfs/fsutil"
+	"github.com/posener/gitfs/internal/browserurl"
 	"github.com/posener/gitfs/internal/tree"
 )
 
 // EncodeVersion is the current encoding version.
-const EncodeVersion = 1
+const EncodeVersion = 2
+
+// reSemver matches Semver refs, which are treated as tags.
+var reSemver = regexp.MustCompile(`^v?\d+(\.\d+){0,2}$`)
 
 // data maps registered projects (through `Register()` call)
-// to the corresponding filesystem that they represent.
-var data map[string]http.FileSystem
+// to the corresponding binary that they represent. The map
+// is keyed by the project name and ref, as returned by `key()`.
+var data map[string]*binary
+
+// Decoder decodes encoded data of a specific version to a filesystem.
+type Decoder func(encoded string) (http.FileSystem, error)
+
+// decoders maps encoding versions to their decoders.
+var decoders = map[int]Decoder{
+	1: func(encoded string) (http.FileSystem, error) { return decodeV1(strings.NewReader(encoded)) },
+	2: func(encoded string) (http.FileSystem, error) { return decodeV2(strings.NewReader(encoded)) },
+}
+
+// streamDecoders maps the encoding versions of gitfs to decoders that read
+// the encoded data from a stream, such that data that was registered in
+// chunks is decoded without joining the chunks to a single string.
+var streamDecoders = map[int]func(io.Reader) (tree.Tree, error){
+	1: decodeV1,
+	2: decodeV2,
+}
+
+// VersionError is returned for a project that was registered with
+// an encoding version that the current gitfs does not support.
+type VersionError struct {
+	Project string
+	Version int
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf(
+		"project %s was packed with encoding version %d, while gitfs supports versions up to %d. "+
+			"Please update github.com/posener/gitfs, or register a decoder for this version",
+		e.Project, e.Version, EncodeVersion)
+}
+
+// Metadata describes the generation of packed projects.
+type Metadata struct {
+	// Tool is the version of the gitfs command that generated the projects,
+	// or "(devel)" if it was not built from a released version.
+	Tool string
+	// Time is the time of the generation.
+	Time time.Time
+	// Args are the command line arguments of the gitfs command, such as its
+	// flags and patterns.
+	Args []string
+	// Stats maps the generated projects, as they are registered, to the
+	// number of their files and their total size.
+	Stats map[string]Stats
+}
+
+// Stats are the number of files in a filesystem and their total size.
+type Stats struct {
+	Files int
+	Size  int64
+}
+
+// binary is the data of a registered project.
+type binary struct {
+	project string
+	version int
+	chunks  []string
+	meta    *Metadata
+	stats   Stats
+
+	fs http.FileSystem
+	mu sync.Mutex
+}
 
 // fsStorage stores all filesystem structure and all file contents.
+// It is used by encoding version 1.
 type fsStorage struct {
 	// Files maps all file paths from root of the filesystem to
 	// their contents.
@@ -36,71 +112,263 @@ type fsStorage struct {
 	Dirs map[string]bool
 }
 
+// entry is a single filesystem entry. Encoding version 2 stores
+// the filesystem as a stream of entries.
+type entry struct {
+	// Path from root of the filesystem.
+	Path string
+	// IsDir is true if the entry is a directory.
+	IsDir bool
+	// Content of a file.
+	Content []byte
+	// Mode is the permission bits of a file.
+	Mode os.FileMode
+	// Gzipped is a gzip compressed copy of the content of a file, if it was
+	// precompressed. Decoders that do not know this field ignore it, such
+	// that it does not require a new encoding version.
+	Gzipped []byte
+}
+
+// minPrecompressSize is the minimal size of file content that is
+// precompressed. Smaller files are not worth the additional space.
+const minPrecompressSize = 256
+
 func init() {
-	data = make(map[string]http.FileSystem)
+	data = make(map[string]*binary)
 	gob.Register(fsStorage{})
 }
 
-// Register a filesystem under the project name.
-// It panics if anything goes wrong.
-func Register(project string, version int, encoded string) {
-	if data[project] != nil {
+// Register a filesystem under the project name. The encoded data is given in
+// chunks, which are the parts of the data in order. The chunks are not joined,
+// since they are usually string constants, and joining them would copy the
+// data of every project to the heap when the program starts.
+// The same project can be registered several times with different refs.
+// The data is decoded only when the filesystem is first requested, such
+// that projects that are not used do not consume memory for their decoded
+// content. Decoding errors, and unsupported versions, are returned when the
+// filesystem is requested. It panics if the project is already registered.
+func Register(project string, version int, chunks ...string) {
+	k := key(project)
+	if data[k] != nil {
 		panic(fmt.Sprintf("Project %s registered multiple times", project))
 	}
-	var (
-		fs  http.FileSystem
-		err error
-	)
-	switch version {
-	case 1:
-		fs, err = decodeV1(encoded)
-	default:
-		panic(fmt.Sprintf(`Registered filesystem is from future version %d.
-			The current gitfs suports versions up to %d.
-			Please update github.com/posener/gitfs.`, version, EncodeVersion))
+	if decoders[version] == nil {
+		log.Printf("Project %s can't be loaded: %s", project, &VersionError{Project: project, Version: version})
 	}
-	if err != nil {
-		panic(fmt.Sprintf("Failed decoding project %q: %s", project, err))
+	data[k] = &binary{project: project, version: version, chunks: chunks}
+}
+
+// RegisterMetadata records the metadata of the generation of the given
+// projects. It is called by generated files after the projects are
+// registered. Projects that are not registered are ignored.
+func RegisterMetadata(meta Metadata, projects ...string) {
+	for _, project := range projects {
+		if b := data[key(project)]; b != nil {
+			b.meta = &meta
+			b.stats = meta.Stats[project]
+		}
+	}
+}
+
+// RegisterDecoder registers a decoder for an encoding version. It enables
+// loading projects that were packed with newer gitfs versions. It panics
+// if a decoder for the given version is already registered.
+func RegisterDecoder(version int, decoder Decoder) {
+	if decoders[version] != nil {
+		panic(fmt.Sprintf("Decoder for version %d registered multiple times", version))
 	}
-	data[project] = fs
+	decoders[version] = decoder
 }
 
 // Match returns wether project exists in registered binaries.
 // The matching is done also over the project `ref`.
 func Match(project string) bool {
-	_, ok := data[project]
+	_, ok := data[key(project)]
 	return ok
 }
 
-// Get returns filesystem of a registered project.
-func Get(project string) http.FileSystem {
-	return data[project]
+// Get returns filesystem of a registered project. It returns a *VersionError
+// if the project was registered with a version that is not supported.
+func Get(project string) (http.FileSystem, error) {
+	b := data[key(project)]
+	if b == nil {
+		return nil, errors.Errorf("project %s not registered", project)
+	}
+	return b.get()
+}
+
+// get returns the filesystem of the binary. It decodes the data on the first
+// successful call, and returns the same filesystem on later calls. The
+// decoded filesystem holds a copy of the content, so the references to the
+// encoded chunks are dropped after they were decoded, such that chunks that
+// are not constants of the program are released, and the content is not
+// kept twice.
+func (b *binary) get() (http.FileSystem, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.fs != nil {
+		return b.fs, nil
+	}
+	fs, err := decode(b.project, b.version, b.chunks)
+	if _, ok := err.(*VersionError); ok {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "decoding project %s", b.project)
+	}
+	b.fs = fs
+	b.chunks = nil
+	return fs, nil
+}
+
+// Decode decodes the data of a project that was encoded with the given
+// version, as it is given to Register, without registering it. It returns a
+// *VersionError if the version is not supported.
+func Decode(project string, version int, encoded string) (http.FileSystem, error) {
+	return decode(project, version, []string{encoded})
+}
+
+// decode decodes the chunks of the encoded data of a project. The encoding
+// versions of gitfs read the chunks as a stream, and decoders that were
+// registered with RegisterDecoder get the joined chunks.
+func decode(project string, version int, chunks []string) (http.FileSystem, error) {
+	if decodeStream := streamDecoders[version]; decodeStream != nil {
+		readers := make([]io.Reader, 0, len(chunks))
+		for _, chunk := range chunks {
+			readers = append(readers, strings.NewReader(chunk))
+		}
+		return decodeStream(io.MultiReader(readers...))
+	}
+	decode := decoders[version]
+	if decode == nil {
+		return nil, &VersionError{Project: project, Version: version}
+	}
+	return decode(strings.Join(chunks, ""))
+}
+
+// ProjectInfo describes a project that was registered.
+type ProjectInfo struct {
+	// Project is the project name, without the ref.
+	Project string
+	// Ref is the ref of the project. Empty for the default branch.
+	Ref string
+	// Files is the number of files in the project filesystem. It is zero
+	// for projects that were generated without their stats.
+	Files int
+	// Size is the total size of the files in the project filesystem.
+	Size int64
+	// Metadata is the metadata of the generation of the project. It is nil
+	// for projects that were generated without metadata.
+	Metadata *Metadata
+}
+
+// Projects returns information about all the registered projects,
+// ordered by project name and ref. The projects are not decoded, the number
+// of files and their size are taken from the generation metadata.
+func Projects() []ProjectInfo {
+	projects := make([]ProjectInfo, 0, len(data))
+	for k, b := range data {
+		name, ref := splitKey(k)
+		projects = append(projects, ProjectInfo{
+			Project:  name,
+			Ref:      ref,
+			Files:    b.stats.Files,
+			Size:     b.stats.Size,
+			Metadata: b.meta,
+		})
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		if projects[i].Project != projects[j].Project {
+			return projects[i].Project < projects[j].Project
+		}
+		return projects[i].Ref < projects[j].Ref
+	})
+	return projects
+}
+
+// FSStats returns the number of files and their total size in a filesystem,
+// as they are recorded in the generation metadata.
+func FSStats(fs http.FileSystem) (Stats, error) {
+	var s Stats
+	walker := fsutil.Walk(fs, "")
+	for walker.Step() {
+		if st := walker.Stat(); st != nil && !st.IsDir() {
+			s.Files++
+			s.Size += st.Size()
+		}
+	}
+	return s, walker.Err()
+}
+
+// key returns the key under which a project is registered. Projects are
+// identified by their name and their ref, such that different refs of the
+// same project are registered separately. Refs that are written differently
+// but point to the same git ref, such as `v1.2.3` and `tags/v1.2.3`, result
+// in the same key. Browser URLs result in the key of their project name.
+func key(project string) string {
+	name, ref := splitKey(browserurl.Project(project))
+	name = strings.TrimRight(name, "/")
+	if ref == "" {
+		return name
+	}
+	if reSemver.MatchString(ref) {
+		ref = "tags/" + ref
+	}
+	return name + "@" + ref
+}
+
+// splitKey splits a project to its name and ref.
+func splitKey(project string) (name, ref string) {
+	if i := strings.Index(project, "@"); i >= 0 {
+		return project[:i], project[i+1:]
+	}
+	return project, ""
 }
 
 // encode converts a filesystem to an encoded string. All filesystem structure
 // and file content is stored.
 //
+// The filesystem is streamed while it is walked, such that only a single file
+// content is held in memory at a time, in addition to the encoded output.
+// Each filesystem entry is written as a gob value to the stream:
+// entries -> GOB -> gzip -> base64.
+//
+// If precompress is set, files that gzip compresses well are stored also
+// gzip compressed, such that they can be served compressed without
+// compressing them at runtime.
+//
 // Note: modifying this function should probably increase EncodeVersion const,
 // and should probably add a new `decode` function for the new version.
-func encode(fs http.FileSystem) (string, error) {
-	// storage is an object that contains all filesystem information.
-	storage := newFSStorage()
+func encode(fs http.FileSystem, precompress bool) (string, error) {
+	var out strings.Builder
+	b64 := base64.NewEncoder(base64.StdEncoding, &out)
+	w := gzip.NewWriter(b64)
+	enc := gob.NewEncoder(w)
+	var bufs buffers
 
-	// Walk the provided filesystem, and add all its content to storage.
+	// Walk the provided filesystem, and write all its content to the stream.
 	walker := fsutil.Walk(fs, "")
 	for walker.Step() {
 		path := walker.Path()
 		if path == "" {
 			continue
 		}
-		if walker.Stat().IsDir() {
-			storage.Dirs[path] = true
-		} else {
-			b, err := readFile(fs, path)
+		e := entry{Path: path, IsDir: walker.Stat().IsDir()}
+		if !e.IsDir {
+			e.Mode = gitPerm(walker.Stat().Mode())
+			var err error
+			e.Content, err = bufs.readFile(fs, path)
 			if err != nil {
 				return "", err
 			}
-			storage.Files[path] = b
+			if precompress {
+				if e.Gzipped, err = bufs.gzipped(e.Content); err != nil {
+					return "", errors.Wrapf(err, "compressing %s", path)
+				}
+			}
+		}
+		if err := enc.Encode(e); err != nil {
+			return "", errors.Wrapf(err, "encoding gob of %s", path)
 		}
 		log.Printf("Encoded path: %s", path)
 	}
@@ -108,27 +376,20 @@ func encode(fs http.FileSystem) (string, error) {
 		return "", errors.Wrap(err, "walking filesystem")
 	}
 
-	// Encode the storage object into a string.
-	// storage object -> GOB -> gzip -> base64.
-	var buf bytes.Buffer
-	w := gzip.NewWriter(&buf)
-	err := gob.NewEncoder(w).Encode(storage)
-	if err != nil {
-		return "", errors.Wrap(err, "encoding gob")
-	}
-	err = w.Close()
-	if err != nil {
+	if err := w.Close(); err != nil {
 		return "", errors.Wrap(err, "close gzip")
 	}
-	s := base64.StdEncoding.EncodeToString(buf.Bytes())
-	log.Printf("Encoded size: %d", len(s))
-	return s, err
+	if err := b64.Close(); err != nil {
+		return "", errors.Wrap(err, "close base64")
+	}
+	log.Printf("Encoded size: %d", out.Len())
+	return out.String(), nil
 }
 
 // decodeV1 returns a filesystem from data that was encoded in V1.
-func decodeV1(data string) (tree.Tree, error) {
+func decodeV1(data io.Reader) (tree.Tree, error) {
 	var storage fsStorage
-	b, err := base64.StdEncoding.DecodeString(data)
+	b, err := ioutil.ReadAll(base64.NewDecoder(base64.StdEncoding, data))
 	if err != nil {
 		return nil, errors.Wrap(err, "decoding base64")
 	}
@@ -156,24 +417,101 @@ func decodeV1(data string) (tree.Tree, error) {
 	return t, err
 }
 
-// readFile is a utility function that reads content of the file
-// denoted by path from the provided filesystem.
-func readFile(fs http.FileSystem, path string) ([]byte, error) {
+// decodeV2 returns a filesystem from data that was encoded in V2.
+func decodeV2(data io.Reader) (tree.Tree, error) {
+	r, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, data))
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding gzip")
+	}
+	defer r.Close()
+	dec := gob.NewDecoder(r)
+	t := make(tree.Tree)
+	for {
+		var e entry
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			return t, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding gob")
+		}
+		if e.IsDir {
+			err = t.AddDir(e.Path)
+		} else {
+			err = t.AddFileContent(e.Path, e.Content)
+			if err == nil && e.Mode != 0 {
+				err = t.SetMode(e.Path, e.Mode)
+			}
+			if err == nil && e.Gzipped != nil {
+				err = t.SetGzipped(e.Path, e.Gzipped)
+			}
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "adding %s", e.Path)
+		}
+	}
+}
+
+// buffers are reused by encode for every file of the filesystem. Since the
+// content of a file is encoded before the next file is read, the content and
+// its compression are read into the same buffers, and the same compressor is
+// used, instead of allocating them for each of many small files.
+type buffers struct {
+	content    bytes.Buffer
+	compressed bytes.Buffer
+	gz         *gzip.Writer
+}
+
+// gzipped returns the gzip compressed content, or nil if the content is too
+// small or does not compress well, such as images or archives that are
+// already compressed. The returned slice is valid until the next call.
+func (b *buffers) gzipped(content []byte) ([]byte, error) {
+	if len(content) < minPrecompressSize {
+		return nil, nil
+	}
+	b.compressed.Reset()
+	if b.gz == nil {
+		var err error
+		if b.gz, err = gzip.NewWriterLevel(&b.compressed, gzip.BestCompression); err != nil {
+			return nil, err
+		}
+	} else {
+		b.gz.Reset(&b.compressed)
+	}
+	if _, err := b.gz.Write(content); err != nil {
+		return nil, err
+	}
+	if err := b.gz.Close(); err != nil {
+		return nil, err
+	}
+	// Keep only compression that saves at least a tenth of the size.
+	if b.compressed.Len() > len(content)*9/10 {
+		return nil, nil
+	}
+	return b.compressed.Bytes(), nil
+}
+
+// gitPerm normalizes file permission bits as git does: a file is either
+// executable or not. This keeps the encoded data independent of the umask
+// of the machine that generated it.
+func gitPerm(mode os.FileMode) os.FileMode {
+	if mode&0111 != 0 {
+		return 0755
+	}
+	return 0644
+}
+
+// readFile reads content of the file denoted by path from the provided
+// filesystem. The returned slice is valid until the next call.
+func (b *buffers) readFile(fs http.FileSystem, path string) ([]byte, error) {
 	f, err := fs.Open(path)
 	if err != nil {
 		return nil, errors.Wrapf(err, "opening file %s", path)
 	}
 	defer f.Close()
-	b, err := ioutil.ReadAll(f)
-	if err != nil {
+	b.content.Reset()
+	if _, err := b.content.ReadFrom(f); err != nil {
 		return nil, errors.Wrapf(err, "reading file content %s", path)
 	}
-	return b, nil
-}
-
-func newFSStorage() fsStorage {
-	return fsStorage{
-		Files: make(map[string][]byte),
-		Dirs:  make(map[string]bool),
-	}
+	return b.content.Bytes(), nil
 }
@@ -1,12 +1,243 @@
 package binfs
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/posener/gitfs/internal/tree"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestRegister_illegalVersion(t *testing.T) {
+func TestRegister_futureVersion(t *testing.T) {
+	const version = EncodeVersion + 1
+	encoded, err := encode(testFS("content"), false)
+	require.NoError(t, err)
+
+	assert.NotPanics(t, func() { Register("github.com/x/y", version, encoded) })
+	require.True(t, Match("github.com/x/y"))
+
+	_, err = Get("github.com/x/y")
+	var versionErr *VersionError
+	require.True(t, errors.As(err, &versionErr))
+	assert.Equal(t, &VersionError{Project: "github.com/x/y", Version: version}, versionErr)
+
+	// After registering a decoder for the version, the project can be loaded.
+	RegisterDecoder(version, func(encoded string) (http.FileSystem, error) { return decodeV2(strings.NewReader(encoded)) })
+	assert.Panics(t, func() { RegisterDecoder(version, nil) })
+	fs, err := Get("github.com/x/y")
+	require.NoError(t, err)
+	assertContent(t, fs, "dir/file", "content")
+}
+
+func TestRegister_decodeFailure(t *testing.T) {
+	Register("github.com/x/z", EncodeVersion, "invalid")
+	assert.True(t, Match("github.com/x/z"))
+	_, err := Get("github.com/x/z")
+	assert.Error(t, err)
+}
+
+func TestGet_chunks(t *testing.T) {
+	encoded, err := encode(testFS("content"), false)
+	require.NoError(t, err)
+	Register("github.com/i/j", EncodeVersion, encoded[:10], encoded[10:20], encoded[20:])
+	b := data[key("github.com/i/j")]
+	assert.Equal(t, []string{encoded[:10], encoded[10:20], encoded[20:]}, b.chunks)
+
+	for i := 0; i < 2; i++ {
+		fs, err := Get("github.com/i/j")
+		require.NoError(t, err)
+		assertContent(t, fs, "dir/file", "content")
+		assert.Equal(t, fs, b.fs)
+		// The encoded chunks are not kept after they were decoded.
+		assert.Nil(t, b.chunks)
+	}
+}
+
+func TestRegister_multipleRefs(t *testing.T) {
+	v1, err := encode(testFS("v1"), false)
+	require.NoError(t, err)
+	v2, err := encode(testFS("v2"), false)
+	require.NoError(t, err)
+
+	Register("github.com/e/f@v1", EncodeVersion, v1)
+	Register("github.com/e/f@tags/v2", EncodeVersion, v2)
+
+	// Registering the same ref again should fail, even if spelled differently.
+	assert.Panics(t, func() { Register("github.com/e/f@tags/v1", EncodeVersion, v1) })
+
+	tests := []struct {
+		project string
+		want    string
+	}{
+		{project: "github.com/e/f@v1", want: "v1"},
+		{project: "github.com/e/f@tags/v1", want: "v1"},
+		{project: "github.com/e/f@v2", want: "v2"},
+		{project: "github.com/e/f@tags/v2", want: "v2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.project, func(t *testing.T) {
+			require.True(t, Match(tt.project))
+			fs, err := Get(tt.project)
+			require.NoError(t, err)
+			assertContent(t, fs, "dir/file", tt.want)
+		})
+	}
+
+	// Default branch and other refs were not registered.
+	assert.False(t, Match("github.com/e/f"))
+	assert.False(t, Match("github.com/e/f@v3"))
+}
+
+func TestKey(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		project string
+		want    string
+	}{
+		{project: "github.com/x/y", want: "github.com/x/y"},
+		{project: "github.com/x/y/", want: "github.com/x/y"},
+		{project: "github.com/x/y/path", want: "github.com/x/y/path"},
+		{project: "github.com/x/y@v1.2.3", want: "github.com/x/y@tags/v1.2.3"},
+		{project: "github.com/x/y@tags/v1.2.3", want: "github.com/x/y@tags/v1.2.3"},
+		{project: "github.com/x/y/path/@heads/master", want: "github.com/x/y/path@heads/master"},
+		{project: "https://github.com/x/y/tree/v1.2.3/path", want: "github.com/x/y/path@tags/v1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.project, func(t *testing.T) {
+			assert.Equal(t, tt.want, key(tt.project))
+		})
+	}
+}
+
+func TestProjects(t *testing.T) {
+	encoded, err := encode(testFS("content"), false)
+	require.NoError(t, err)
+	Register("github.com/g/h@v1", EncodeVersion, encoded)
+	stats, err := FSStats(testFS("content"))
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Files: 1, Size: 7}, stats)
+	meta := Metadata{
+		Tool:  "v1.0.0",
+		Time:  time.Unix(1600000000, 0),
+		Args:  []string{"./..."},
+		Stats: map[string]Stats{"github.com/g/h@tags/v1": stats},
+	}
+	RegisterMetadata(meta, "github.com/g/h@tags/v1", "github.com/g/notregistered")
+
+	for _, p := range Projects() {
+		if p.Project == "github.com/g/h" {
+			assert.Equal(t, ProjectInfo{Project: "github.com/g/h", Ref: "tags/v1", Files: 1, Size: 7, Metadata: &meta}, p)
+			// Listing the projects does not decode them.
+			assert.Nil(t, data[key("github.com/g/h@v1")].fs)
+			return
+		}
+	}
+	t.Error("Registered project was not found")
+}
+
+func TestDecodeV1(t *testing.T) {
+	t.Parallel()
+	storage := fsStorage{
+		Files: map[string][]byte{"dir/file": []byte("content")},
+		Dirs:  map[string]bool{"dir": true, "empty": true},
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	require.NoError(t, gob.NewEncoder(w).Encode(storage))
+	require.NoError(t, w.Close())
+
+	fs, err := decodeV1(strings.NewReader(base64.StdEncoding.EncodeToString(buf.Bytes())))
+	require.NoError(t, err)
+	assertFS(t, fs)
+}
+
+func TestEncodeDecodeV2(t *testing.T) {
+	t.Parallel()
+	src := make(tree.Tree)
+	require.NoError(t, src.AddFileContent("dir/file", []byte("content")))
+	require.NoError(t, src.AddFileContent("exec", []byte("#!/bin/sh")))
+	require.NoError(t, src.SetMode("exec", 0700))
+	require.NoError(t, src.AddDir("empty"))
+
+	encoded, err := encode(src, false)
+	require.NoError(t, err)
+	fs, err := decodeV2(strings.NewReader(encoded))
+	require.NoError(t, err)
+	assertFS(t, fs)
+
+	assertMode(t, fs, "dir/file", 0644)
+	assertMode(t, fs, "exec", 0755)
+}
+
+func assertMode(t *testing.T, fs http.FileSystem, path string, want os.FileMode) {
+	t.Helper()
+	f, err := fs.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	st, err := f.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, want, st.Mode())
+}
+
+func assertFS(t *testing.T, fs http.FileSystem) {
+	t.Helper()
+	assertContent(t, fs, "dir/file", "content")
+
+	d, err := fs.Open("empty")
+	require.NoError(t, err)
+	st, err := d.Stat()
+	require.NoError(t, err)
+	assert.True(t, st.IsDir())
+}
+
+func assertContent(t *testing.T, fs http.FileSystem, path string, want string) {
+	t.Helper()
+	f, err := fs.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(b))
+}
+
+func TestEncodeDecodePrecompress(t *testing.T) {
 	t.Parallel()
-	assert.Panics(t, func() { Register("github.com/x/y", EncodeVersion+1, "") })
+	compressible := strings.Repeat("compressible ", 100)
+	random := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(random)
+	src := make(tree.Tree)
+	require.NoError(t, src.AddFileContent("compressible", []byte(compressible)))
+	require.NoError(t, src.AddFileContent("random", random))
+	require.NoError(t, src.AddFileContent("small", []byte("small")))
+
+	encoded, err := encode(src, true)
+	require.NoError(t, err)
+	fs, err := decodeV2(strings.NewReader(encoded))
+	require.NoError(t, err)
+	assertContent(t, fs, "compressible", compressible)
+
+	gzipped := func(path string) []byte {
+		f, err := fs.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		return f.(interface{ Gzipped() []byte }).Gzipped()
+	}
+	r, err := gzip.NewReader(bytes.NewReader(gzipped("compressible")))
+	require.NoError(t, err)
+	content, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, compressible, string(content))
+	assert.Nil(t, gzipped("random"))
+	assert.Nil(t, gzipped("small"))
 }
@@ -11,12 +11,20 @@ import (
 	"golang.org/x/tools/go/packages"
 )
 
-// Calls is a map of project to load configuration.
+// Calls is a map of project to load configuration. Projects are keyed by
+// their name and ref, such that different spellings of the same ref are
+// loaded only once.
 type Calls map[string]*Config
 
 // Config is a configuration for generating a filesystem.
 type Config struct {
 	Project string
+	// Source, if set, is the project that is loaded for generating the
+	// filesystem instead of Project, for example with a different ref.
+	Source string
+	// Precompress stores gzip compressed copies of the files that compress
+	// well, in addition to their content.
+	Precompress bool
 	// globPatterns is a union of all globPatterns that found in all calls
 	// for this project.
 	globPatterns []string
@@ -24,6 +32,23 @@ type Config struct {
 	// a usage of pattern (this means that we should not have patterns applied
 	// in the binary creation).
 	noPatterns bool
+	// keepEmptyDirs is set if any of the calls for this project keeps empty
+	// directories.
+	keepEmptyDirs bool
+	// exportIgnoreCalls is the number of calls for this project that exclude
+	// paths with the export-ignore git attribute.
+	exportIgnoreCalls int
+	// positions of the calls for this project.
+	positions []token.Position
+}
+
+// SourceProject returns the project that should be loaded for generating the
+// filesystem.
+func (c *Config) SourceProject() string {
+	if c.Source != "" {
+		return c.Source
+	}
+	return c.Project
 }
 
 // GlobPatterns that should be used for this project.
@@ -34,13 +59,44 @@ func (c *Config) GlobPatterns() []string {
 	return c.globPatterns
 }
 
+// ExportIgnore returns true if paths with the export-ignore git attribute
+// should be excluded, which is when all the calls for this project exclude
+// them.
+func (c *Config) ExportIgnore() bool {
+	return c.exportIgnoreCalls > 0 && c.exportIgnoreCalls == len(c.positions)
+}
+
+// Positions returns the positions of the calls for this project in the
+// source files.
+func (c *Config) Positions() []token.Position {
+	return c.positions
+}
+
+// KeepEmptyDirs returns true if directories that none of their files match
+// the glob patterns should be kept.
+func (c *Config) KeepEmptyDirs() bool {
+	return c.keepEmptyDirs
+}
+
 // fsProviderFn is a function that given a project name it returns
 // its filesystem.
 type fsProviderFn func(c Config) (http.FileSystem, error)
 
 // LoadCalls load all calls to gitfs.New in the files according to the defined patterns.
+// Test files are not inspected.
 func LoadCalls(patterns ...string) (Calls, error) {
-	pkgs, err := packages.Load(&packages.Config{Mode: packages.LoadAllSyntax}, patterns...)
+	return loadCalls(false, patterns)
+}
+
+// LoadCallsWithTests is like LoadCalls, but also inspects the _test.go files
+// of the packages, such that filesystems that are used only by tests are
+// packed as well.
+func LoadCallsWithTests(patterns ...string) (Calls, error) {
+	return loadCalls(true, patterns)
+}
+
+func loadCalls(tests bool, patterns []string) (Calls, error) {
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.LoadAllSyntax, Tests: tests}, patterns...)
 	if err != nil {
 		return nil, errors.Wrap(err, "loading packages")
 	}
@@ -54,10 +110,17 @@ func LoadCalls(patterns ...string) (Calls, error) {
 		return nil, errors.New("no packages were loaded")
 	}
 
-	// Find all projects
+	// Find all projects. When tests are loaded, a package is loaded also as
+	// part of its test variant, and its files should be inspected once.
 	c := make(Calls)
+	seen := make(map[string]bool)
 	for _, pkg := range pkgs {
 		for _, file := range pkg.Syntax {
+			name := pkg.Fset.Position(file.Pos()).Filename
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
 			c.lookupAST(file, pkg.Fset)
 		}
 	}
@@ -76,6 +139,46 @@ func GenerateBinaries(c Calls, provider fsProviderFn) map[string]string {
 	return binaries
 }
 
+// Add adds a project to the calls, as if it was used in a `gitfs.New` call
+// with the given glob patterns, and with the OptKeepEmptyDirs and
+// OptExportIgnore options.
+func (c Calls) Add(project string, patterns []string, keepEmptyDirs, exportIgnore bool) {
+	c.add(project, token.Position{}, patterns, keepEmptyDirs, exportIgnore)
+}
+
+// Has returns whether the calls contain the given project, in any spelling of
+// its ref.
+func (c Calls) Has(project string) bool {
+	_, ok := c[key(project)]
+	return ok
+}
+
+// add adds a call for project in the given position.
+func (c Calls) add(project string, pos token.Position, patterns []string, keepEmptyDirs, exportIgnore bool) {
+	// Mark that project is used.
+	k := key(project)
+	if c[k] == nil {
+		c[k] = &Config{Project: project}
+	}
+	c[k].positions = append(c[k].positions, pos)
+
+	if len(patterns) == 0 {
+		// This call does not use pattern. Mark it so we will later load
+		// all files.
+		c[k].noPatterns = true
+	} else {
+		// Accumulate all the patterns that are used for all the places
+		// that the project was used.
+		c[k].globPatterns = append(c[k].globPatterns, patterns...)
+	}
+	if keepEmptyDirs {
+		c[k].keepEmptyDirs = true
+	}
+	if exportIgnore {
+		c[k].exportIgnoreCalls++
+	}
+}
+
 // lookupAST inspects a single AST and looks for `gitfs.New` calls.
 // If a call was found, it saves the project this call was called for
 // and options it was called with.
@@ -100,11 +203,6 @@ func (c Calls) lookupAST(file *ast.File, fset *token.FileSet) {
 						return false
 					}
 
-					// Mark that project is used.
-					if c[project] == nil {
-						c[project] = &Config{Project: project}
-					}
-
 					// Treat OptGlob call.
 					patterns, err := findOptGlob(call.Args[2:])
 					if err != nil {
@@ -113,15 +211,16 @@ func (c Calls) lookupAST(file *ast.File, fset *token.FileSet) {
 							pos, err)
 						patterns = nil
 					}
-					if len(patterns) == 0 {
-						// This call does not use pattern. Mark it so we will later load
-						// all files.
-						c[project].noPatterns = true
-					} else {
-						// Accumulate all the patterns that are used for all the places
-						// that the project was used.
-						c[project].globPatterns = append(c[project].globPatterns, patterns...)
+					if findOpt(call.Args[2:], "OptFilter") {
+						// The filter function can't be evaluated, it is
+						// applied when the packed filesystem is loaded.
+						log.Printf(
+							"gitfs.New call in %s uses OptFilter, packing all files, which are filtered when they are loaded.",
+							pos)
 					}
+					c.add(project, pos, patterns,
+						findOptTrue(call.Args[2:], "OptKeepEmptyDirs"),
+						findOptTrue(call.Args[2:], "OptExportIgnore"))
 				}
 			}
 		}
@@ -131,13 +230,17 @@ func (c Calls) lookupAST(file *ast.File, fset *token.FileSet) {
 
 // projectBinary retruns the binary encoded format of a single project.
 func loadBinary(provider fsProviderFn, c Config) string {
-	log.Printf("Encoding project: %s", c.Project)
+	if c.Source != "" {
+		log.Printf("Encoding project: %s from %s", c.Project, c.Source)
+	} else {
+		log.Printf("Encoding project: %s", c.Project)
+	}
 	fs, err := provider(c)
 	if err != nil {
 		log.Printf("Failed creating filesystem %q: %s", c.Project, err)
 		return ""
 	}
-	b, err := encode(fs)
+	b, err := encode(fs, c.Precompress)
 	if err != nil {
 		log.Printf("Failed encoding filesystem %q: %s", c.Project, err)
 		return ""
@@ -172,6 +275,30 @@ func findOptGlob(exprs []ast.Expr) ([]string, error) {
 	return nil, nil
 }
 
+// findOpt takes arguments of the gitfs.New and returns true if they contain
+// a `gitfs.<name>(...)` option.
+func findOpt(exprs []ast.Expr, name string) bool {
+	for _, expr := range exprs {
+		if call, ok := expr.(*ast.CallExpr); ok && isPkgDot(call.Fun, "gitfs", name) {
+			return true
+		}
+	}
+	return false
+}
+
+// findOptTrue takes arguments of the gitfs.New and returns true if they
+// contain a `gitfs.<name>(true)` option.
+func findOptTrue(exprs []ast.Expr, name string) bool {
+	for _, expr := range exprs {
+		call, ok := expr.(*ast.CallExpr)
+		if !ok || !isPkgDot(call.Fun, "gitfs", name) {
+			continue
+		}
+		return len(call.Args) == 1 && isIdent(call.Args[0], "true")
+	}
+	return false
+}
+
 // isPkgDot returns true if expr is `<pkg>.<name>`
 func isPkgDot(expr ast.Expr, pkg, name string) bool {
 	sel, ok := expr.(*ast.SelectorExpr)
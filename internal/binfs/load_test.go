@@ -13,6 +13,7 @@ import (
 const (
 	project1 = "github.com/a/b"
 	project2 = "github.com/c/d"
+	project3 = "github.com/e/f"
 )
 
 func TestLoadCalls(t *testing.T) {
@@ -20,14 +21,52 @@ func TestLoadCalls(t *testing.T) {
 	got, err := LoadCalls("./testdata")
 	require.NoError(t, err)
 
+	// Check and clear the positions of the calls.
+	for project, line := range map[string]int{project1: 12, project2: 13} {
+		require.Len(t, got[project].Positions(), 1)
+		assert.Equal(t, line, got[project].Positions()[0].Line)
+		got[project].positions = nil
+	}
+
 	want := Calls{
 		project1: &Config{Project: project1, noPatterns: true},
-		project2: &Config{Project: project2, globPatterns: []string{"foo", "*"}},
+		project2: &Config{Project: project2, globPatterns: []string{"foo", "*"}, keepEmptyDirs: true, exportIgnoreCalls: 1},
 	}
 
 	assert.Equal(t, want, got)
 }
 
+func TestLoadCallsWithTests(t *testing.T) {
+	t.Parallel()
+	got, err := LoadCallsWithTests("./testdata")
+	require.NoError(t, err)
+
+	for project, line := range map[string]int{project1: 12, project2: 13, project3: 12} {
+		require.Len(t, got[project].Positions(), 1, project)
+		assert.Equal(t, line, got[project].Positions()[0].Line)
+	}
+	assert.Len(t, got, 3)
+}
+
+func TestCallsAdd(t *testing.T) {
+	t.Parallel()
+	c := make(Calls)
+	c.Add(project1, nil, false, false)
+	c.Add(project2+"@v1.2.3", []string{"*.go"}, true, true)
+	c.Add(project2+"@tags/v1.2.3", []string{"*.md"}, false, true)
+
+	assert.Nil(t, c[project1].GlobPatterns())
+	p2 := c[project2+"@tags/v1.2.3"]
+	require.NotNil(t, p2)
+	assert.Equal(t, []string{"*.go", "*.md"}, p2.GlobPatterns())
+	assert.True(t, p2.KeepEmptyDirs())
+	assert.True(t, p2.ExportIgnore())
+
+	assert.True(t, c.Has(project2+"@tags/v1.2.3"))
+	assert.True(t, c.Has(project2+"@v1.2.3"))
+	assert.False(t, c.Has(project2))
+}
+
 func TestLoadCalls_patternNotFound(t *testing.T) {
 	t.Parallel()
 
@@ -56,8 +95,8 @@ func TestGenerateBinaries(t *testing.T) {
 	// Check the data that was registered:
 	for _, project := range []string{project1, project2} {
 		assert.True(t, Match(project))
-		fs := Get(project)
-		require.NotNil(t, fs)
+		fs, err := Get(project)
+		require.NoError(t, err)
 		f, err := fs.Open("dir/file")
 		assert.NoError(t, err)
 		b, err := ioutil.ReadAll(f)
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/posener/gitfs"
+)
+
+func TestFS(t *testing.T) {
+	ctx := context.Background()
+	gitfs.New(ctx, "github.com/e/f")
+}
@@ -0,0 +1,93 @@
+// Package browserurl converts URLs of Github repositories, as they are shown
+// in the browser, to gitfs project names.
+package browserurl
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// reSemver matches refs that the project grammar treats as tags.
+var reSemver = regexp.MustCompile(`^v?\d+(\.\d+){0,2}$`)
+
+// Project returns the project name of a browser URL of a Github repository.
+// Project names that are not URLs, and URLs that it does not recognize, are
+// returned as is. The supported URLs are:
+//
+//   - https://github.com/<owner>/<repo>, for the default branch.
+//
+//   - https://github.com/<owner>/<repo>/tree/<ref>/<path>, for a directory.
+//
+//   - https://github.com/<owner>/<repo>/blob/<ref>/<path>, for the directory
+//     that contains a file.
+//
+//   - https://github.com/<owner>/<repo>/releases/tag/<tag>, for the assets of
+//     a release.
+//
+// Refs that are Semver versions are tags, and other refs are branches. Since
+// the ref and the path are not separated in the URL, refs that contain a
+// slash are not supported.
+func Project(project string) string {
+	return convert(project, false)
+}
+
+// File returns the project name of a browser URL of a file in a Github
+// repository, that its path is the path of the file. It is the same as
+// Project, except that the URL of a file, with `blob`, is converted to the
+// path of the file instead of the directory that contains it.
+func File(project string) string {
+	return convert(project, true)
+}
+
+// convert converts a browser URL to a project name. If file is true, the path
+// of a file URL is kept.
+func convert(project string, file bool) string {
+	u, err := url.Parse(project)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return project
+	}
+	host := strings.TrimPrefix(u.Host, "www.")
+	if host != "github.com" {
+		return project
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return project
+	}
+	name := host + "/" + parts[0] + "/" + strings.TrimSuffix(parts[1], ".git")
+	parts = parts[2:]
+	if len(parts) == 0 {
+		return name
+	}
+	if len(parts) < 2 || parts[1] == "" {
+		return project
+	}
+	kind, ref, p := parts[0], parts[1], strings.Join(parts[2:], "/")
+	switch kind {
+	case "tree":
+	case "blob":
+		if file {
+			break
+		}
+		p = path.Dir(p)
+		if p == "." {
+			p = ""
+		}
+	case "releases":
+		if ref != "tag" || len(parts) != 3 {
+			return project
+		}
+		return name + "/releases@" + parts[2]
+	default:
+		return project
+	}
+	if p != "" {
+		name += "/" + p
+	}
+	if !reSemver.MatchString(ref) {
+		ref = "heads/" + ref
+	}
+	return name + "@" + ref
+}
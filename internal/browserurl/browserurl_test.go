@@ -0,0 +1,44 @@
+package browserurl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProject(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		project string
+		want    string
+	}{
+		{project: "https://github.com/x/y", want: "github.com/x/y"},
+		{project: "https://github.com/x/y/", want: "github.com/x/y"},
+		{project: "https://www.github.com/x/y.git", want: "github.com/x/y"},
+		{project: "https://github.com/x/y/tree/main", want: "github.com/x/y@heads/main"},
+		{project: "https://github.com/x/y/tree/main/docs", want: "github.com/x/y/docs@heads/main"},
+		{project: "https://github.com/x/y/tree/v1.2.3/a/b/", want: "github.com/x/y/a/b@v1.2.3"},
+		{project: "https://github.com/x/y/blob/v1.2.3/README.md", want: "github.com/x/y@v1.2.3"},
+		{project: "https://github.com/x/y/blob/main/docs/a.md#L10", want: "github.com/x/y/docs@heads/main"},
+		{project: "https://github.com/x/y/releases/tag/v1.0.0", want: "github.com/x/y/releases@v1.0.0"},
+		// Not converted.
+		{project: "github.com/x/y/tree/main", want: "github.com/x/y/tree/main"},
+		{project: "https://gitlab.com/x/y/tree/main", want: "https://gitlab.com/x/y/tree/main"},
+		{project: "https://github.com/x", want: "https://github.com/x"},
+		{project: "https://github.com/x/y/issues/1", want: "https://github.com/x/y/issues/1"},
+		{project: "https://github.com/x/y/tree", want: "https://github.com/x/y/tree"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.project, func(t *testing.T) {
+			assert.Equal(t, tt.want, Project(tt.project))
+		})
+	}
+}
+
+func TestFile(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "github.com/x/y/README.md@v1.2.3", File("https://github.com/x/y/blob/v1.2.3/README.md"))
+	assert.Equal(t, "github.com/x/y/docs/a.md@heads/main", File("https://github.com/x/y/blob/main/docs/a.md#L10"))
+	assert.Equal(t, "github.com/x/y/docs@heads/main", File("https://github.com/x/y/tree/main/docs"))
+	assert.Equal(t, "github.com/x/y/a.md", File("github.com/x/y/a.md"))
+}
@@ -0,0 +1,125 @@
+// Package dirprefetch loads the files of a directory concurrently when the
+// directory is first opened.
+package dirprefetch
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/posener/gitfs/internal/log"
+)
+
+// workers is the number of files of a directory that are loaded concurrently.
+const workers = 8
+
+// New returns a filesystem that, when a directory is first opened, loads the
+// content of the files in that directory in the background. Subdirectories
+// are not loaded. Reading a file whose load is in flight waits for it,
+// instead of loading it again. Only files that have a Load method, as the
+// files of lazily loaded gitfs filesystems, are loaded.
+func New(fs http.FileSystem) http.FileSystem {
+	return &prefetchFS{FileSystem: fs, started: make(map[string]bool)}
+}
+
+type prefetchFS struct {
+	http.FileSystem
+	started map[string]bool
+	mu      sync.Mutex
+	// wg waits for the loads that are in the background.
+	wg sync.WaitGroup
+}
+
+// loader is a file that can load its content.
+type loader interface {
+	Load(ctx context.Context) error
+}
+
+func (p *prefetchFS) Open(name string) (http.File, error) {
+	f, err := p.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil || !st.IsDir() {
+		return f, nil
+	}
+	dir := "/" + strings.Trim(name, "/")
+	p.mu.Lock()
+	started := p.started[dir]
+	p.started[dir] = true
+	p.mu.Unlock()
+	if !started {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.prefetch(dir)
+		}()
+	}
+	return f, nil
+}
+
+// prefetch loads the files of the given directory. Failures are only logged,
+// since the files are loaded again when they are read.
+func (p *prefetchFS) prefetch(dir string) {
+	d, err := p.FileSystem.Open(dir)
+	if err != nil {
+		log.Warn("Failed prefetching directory", "path", dir, "error", err)
+		return
+	}
+	infos, err := d.Readdir(-1)
+	d.Close()
+	if err != nil {
+		log.Warn("Failed prefetching directory", "path", dir, "error", err)
+		return
+	}
+	log.Debug("Prefetching directory", "path", dir, "entries", len(infos))
+
+	var names []string
+	for _, info := range infos {
+		if !info.IsDir() {
+			names = append(names, path.Join(dir, info.Name()))
+		}
+	}
+
+	// A fixed number of workers load the files, instead of a goroutine for
+	// each file, which matters for directories of many small files.
+	n := workers
+	if len(names) < n {
+		n = len(names)
+	}
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				if err := p.load(name); err != nil {
+					log.Warn("Failed prefetching file", "path", name, "error", err)
+				}
+			}
+		}()
+	}
+	for _, name := range names {
+		jobs <- name
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// load loads the content of a single file.
+func (p *prefetchFS) load(name string) error {
+	f, err := p.FileSystem.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	l, ok := f.(loader)
+	if !ok {
+		return nil
+	}
+	return l.Load(context.Background())
+}
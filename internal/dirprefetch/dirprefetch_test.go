@@ -0,0 +1,49 @@
+package dirprefetch
+
+import (
+	"context"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/posener/gitfs/internal/tree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefetchFS(t *testing.T) {
+	t.Parallel()
+	var (
+		loads = make(map[string]int)
+		mu    sync.Mutex
+	)
+	tr := make(tree.Tree)
+	for _, name := range []string{"d/a.txt", "d/b.txt", "d/e/c.txt", "f.txt"} {
+		name := name
+		require.NoError(t, tr.AddFile(name, 1, func(context.Context) ([]byte, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			loads[name]++
+			return []byte("x"), nil
+		}))
+	}
+	fs := New(tr)
+
+	d, err := fs.Open("d")
+	require.NoError(t, err)
+	d.Close()
+	_, err = fs.Open("/d/")
+	require.NoError(t, err)
+	fs.(*prefetchFS).wg.Wait()
+
+	// Only the files of the opened directory are loaded, once.
+	assert.Equal(t, map[string]int{"d/a.txt": 1, "d/b.txt": 1}, loads)
+
+	// Reading a prefetched file does not load it again.
+	f, err := fs.Open("d/a.txt")
+	require.NoError(t, err)
+	content, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(content))
+	assert.Equal(t, 1, loads["d/a.txt"])
+}
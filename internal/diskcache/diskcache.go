@@ -0,0 +1,162 @@
+// Package diskcache stores fetched content in a directory on the local disk,
+// such that it is shared between processes on the same host.
+package diskcache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/log"
+)
+
+const (
+	// lockPollInterval is the interval for retrying to acquire a lock that is
+	// held by another process.
+	lockPollInterval = 50 * time.Millisecond
+	// staleLockAge is the age of a lock file after which it is considered to
+	// be left behind by a process that did not release it.
+	staleLockAge = time.Minute
+)
+
+// Cache is a cache of immutable content on the local disk. Each entry is a
+// file in the cache directory. Entries are never modified once they are
+// stored, such that keys should identify content that does not change, such
+// as git objects by their SHA. A fetch of a missing entry is guarded by a
+// lock file, such that concurrent processes that need the same entry fetch it
+// only once.
+type Cache struct {
+	dir string
+}
+
+// New returns a cache that stores its entries in the given directory. The
+// directory is created when the first entry is stored.
+func New(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// DefaultDir returns the default cache directory, which is the gitfs
+// directory in the user cache directory (~/.cache/gitfs on Linux).
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gitfs"), nil
+}
+
+// Get returns the content of the given key, if it is stored.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	content, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return content, true
+}
+
+// Do returns the content of the given key. If it is not stored, it is fetched
+// using fetch and stored. While it is being fetched, other processes that
+// need the same key wait for it to be stored instead of fetching it as well.
+// Failing to store content is not an error, since the content is still
+// available to the caller.
+func (c *Cache) Do(ctx context.Context, key string, fetch func(context.Context) ([]byte, error)) ([]byte, error) {
+	if content, ok := c.Get(key); ok {
+		return content, nil
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Warn("Failed creating cache directory", "error", err)
+		return fetch(ctx)
+	}
+	unlock, err := lock(ctx, path+".lock")
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		log.Warn("Failed locking cache entry", "key", key, "error", err)
+		return fetch(ctx)
+	}
+	defer unlock()
+
+	// Another process might have stored the content while the lock was held.
+	if content, ok := c.Get(key); ok {
+		return content, nil
+	}
+	content, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := store(path, content); err != nil {
+		log.Warn("Failed storing cache entry", "key", key, "error", err)
+	}
+	return content, nil
+}
+
+// Set stores the content of the given key, replacing its stored content. It
+// can be used for entries that are not immutable, such as the commit that a
+// branch points to.
+func (c *Cache) Set(key string, content []byte) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(err, "creating cache directory")
+	}
+	return store(path, content)
+}
+
+// path returns the file path of a slash separated key.
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, filepath.FromSlash(strings.Trim(key, "/")))
+}
+
+// store writes content to a temporary file and renames it to the given path,
+// such that readers never see partially written content.
+func store(path string, content []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// lock acquires a lock by exclusively creating the given lock file. If the
+// lock is held, it waits until it is released, or until the context is done.
+// A lock file that is older than staleLockAge is removed. It returns a
+// function that releases the lock.
+func lock(ctx context.Context, path string) (func(), error) {
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, errors.Wrap(err, "creating lock file")
+		}
+		if st, err := os.Stat(path); err == nil && time.Since(st.ModTime()) > staleLockAge {
+			log.Warn("Removing stale cache lock", "path", path)
+			os.Remove(path)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package diskcache
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDo(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+
+	_, ok := New(dir).Get("a/b")
+	assert.False(t, ok)
+
+	var (
+		calls int
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+	)
+	fetch := func(context.Context) ([]byte, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		return []byte("content"), nil
+	}
+	// Concurrent caches of the same directory fetch the content once.
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			content, err := New(dir).Do(ctx, "a/b", fetch)
+			assert.NoError(t, err)
+			assert.Equal(t, "content", string(content))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, calls)
+
+	content, ok := New(dir).Get("a/b")
+	assert.True(t, ok)
+	assert.Equal(t, "content", string(content))
+
+	// No lock files or temporary files are left behind.
+	files, err := ioutil.ReadDir(filepath.Join(dir, "a"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "b", files[0].Name())
+}
+
+func TestDo_error(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	c := New(dir)
+
+	_, err = c.Do(context.Background(), "a", func(context.Context) ([]byte, error) {
+		return nil, errors.New("failed")
+	})
+	assert.Error(t, err)
+	// Failed fetches are not stored.
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+}
+
+func TestDo_lock(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	c := New(dir)
+	fetch := func(context.Context) ([]byte, error) { return []byte("content"), nil }
+
+	// The context is done while another process holds the lock.
+	lockPath := filepath.Join(dir, "a.lock")
+	require.NoError(t, ioutil.WriteFile(lockPath, nil, 0644))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = c.Do(ctx, "a", fetch)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// A stale lock is removed.
+	old := time.Now().Add(-2 * staleLockAge)
+	require.NoError(t, os.Chtimes(lockPath, old, old))
+	content, err := c.Do(context.Background(), "a", fetch)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+}
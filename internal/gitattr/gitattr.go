@@ -0,0 +1,167 @@
+// Package gitattr applies the export-ignore attribute of .gitattributes files,
+// which excludes paths from archives that are created with `git archive`.
+package gitattr
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/fsutil"
+)
+
+// fileName is the name of git attributes files.
+const fileName = ".gitattributes"
+
+// ExportIgnore matches the paths that have the export-ignore attribute.
+type ExportIgnore []rule
+
+// rule is a line of a .gitattributes file that sets or unsets the
+// export-ignore attribute.
+type rule struct {
+	// dir is the directory of the .gitattributes file of the rule.
+	dir     string
+	pattern string
+	ignore  bool
+}
+
+// Load returns the export-ignore attributes of the .gitattributes files in
+// the filesystem. Like in git, the attributes of a .gitattributes file apply
+// to the paths in its directory, and the attributes of files in deeper
+// directories override the attributes of files in their parent directories.
+func Load(fs http.FileSystem) (ExportIgnore, error) {
+	var e ExportIgnore
+	walk := fsutil.Walk(fs, "/")
+	for walk.Step() {
+		if err := walk.Err(); err != nil {
+			return nil, err
+		}
+		if walk.Stat().IsDir() || walk.Stat().Name() != fileName {
+			continue
+		}
+		f, err := fs.Open(walk.Path())
+		if err != nil {
+			return nil, err
+		}
+		rules, err := parse(f, cleanPath(path.Dir(walk.Path())))
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %s", walk.Path())
+		}
+		e = append(e, rules...)
+	}
+	// Rules of deeper directories are applied last, such that they take
+	// precedence.
+	sortByDepth(e)
+	return e, nil
+}
+
+// parse returns the export-ignore rules of a .gitattributes file in the given
+// directory.
+func parse(r io.Reader, dir string) ([]rule, error) {
+	var rules []rule
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, attr := range fields[1:] {
+			switch attr {
+			case "export-ignore":
+				rules = append(rules, rule{dir: dir, pattern: fields[0], ignore: true})
+			case "-export-ignore", "!export-ignore":
+				rules = append(rules, rule{dir: dir, pattern: fields[0]})
+			}
+		}
+	}
+	return rules, scanner.Err()
+}
+
+// Match returns true if the path, or any of its parent directories, has the
+// export-ignore attribute.
+func (e ExportIgnore) Match(name string, isDir bool) bool {
+	name = cleanPath(name)
+	if name == "" {
+		return false
+	}
+	if parent := cleanPath(path.Dir(name)); parent != "" && e.Match(parent, true) {
+		return true
+	}
+	ignore := false
+	for _, r := range e {
+		if r.match(name, isDir) {
+			ignore = r.ignore
+		}
+	}
+	return ignore
+}
+
+// match returns true if the rule applies to the given path. Patterns without
+// a slash match the name of a file in any directory under the directory of
+// the rule, and other patterns match the path relative to it. A pattern that
+// ends with a slash matches only directories.
+func (r rule) match(name string, isDir bool) bool {
+	if r.dir != "" {
+		if !strings.HasPrefix(name, r.dir+"/") {
+			return false
+		}
+		name = strings.TrimPrefix(name, r.dir+"/")
+	}
+	pattern := r.pattern
+	if strings.HasSuffix(pattern, "/") {
+		if !isDir {
+			return false
+		}
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches the segments of a path to the segments of a pattern,
+// in which a `**` segment matches any number of segments.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// sortByDepth stably sorts the rules by the depth of their directories.
+func sortByDepth(e ExportIgnore) {
+	depth := func(r rule) int {
+		if r.dir == "" {
+			return 0
+		}
+		return strings.Count(r.dir, "/") + 1
+	}
+	sort.SliceStable(e, func(i, j int) bool { return depth(e[i]) < depth(e[j]) })
+}
+
+// cleanPath returns a slash separated path without leading and trailing
+// slashes.
+func cleanPath(name string) string {
+	return strings.Trim(path.Clean("/"+name), "/")
+}
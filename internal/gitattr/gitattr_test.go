@@ -0,0 +1,66 @@
+package gitattr
+
+import (
+	"testing"
+
+	"github.com/posener/gitfs/internal/tree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	tr := make(tree.Tree)
+	require.NoError(t, tr.AddFileContent(".gitattributes", []byte(`# Comment
+*.go text
+.github export-ignore
+/testdata export-ignore
+*_test.go export-ignore
+docs/**/*.png export-ignore
+keep.txt export-ignore
+build/ export-ignore
+`)))
+	require.NoError(t, tr.AddFileContent("d/.gitattributes", []byte(`keep.txt -export-ignore
+local.txt export-ignore
+`)))
+	ignore, err := Load(tr)
+	require.NoError(t, err)
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{path: "main.go"},
+		{path: "main_test.go", want: true},
+		{path: "/pkg/pkg_test.go", want: true},
+		{path: ".github", isDir: true, want: true},
+		{path: ".github/workflows/ci.yml", want: true},
+		{path: "testdata", isDir: true, want: true},
+		{path: "testdata/f", want: true},
+		{path: "pkg/testdata", isDir: true},
+		{path: "docs/a/b/img.png", want: true},
+		{path: "docs/img.png", want: true},
+		{path: "img.png"},
+		{path: "keep.txt", want: true},
+		{path: "d/keep.txt"},
+		{path: "d/local.txt", want: true},
+		{path: "local.txt"},
+		{path: "build", isDir: true, want: true},
+		{path: "build"},
+		{path: "/"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ignore.Match(tt.path, tt.isDir), tt.path)
+	}
+}
+
+func TestLoad_noAttributes(t *testing.T) {
+	t.Parallel()
+	tr := make(tree.Tree)
+	require.NoError(t, tr.AddFileContent("a", []byte("a")))
+	ignore, err := Load(tr)
+	require.NoError(t, err)
+	assert.Empty(t, ignore)
+	assert.False(t, ignore.Match("a", false))
+}
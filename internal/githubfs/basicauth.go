@@ -0,0 +1,18 @@
+package githubfs
+
+import "net/http"
+
+// basicAuthTransport is an http.RoundTripper that authorizes the requests with
+// basic authentication.
+type basicAuthTransport struct {
+	base     http.RoundTripper
+	username string
+	password string
+}
+
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A round tripper should not modify the given request.
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.username, t.password)
+	return t.base.RoundTrip(req)
+}
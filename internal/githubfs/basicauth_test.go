@@ -0,0 +1,42 @@
+package githubfs
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasicAuth(t *testing.T) {
+	t.Parallel()
+	var got []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		user, pass, _ := req.BasicAuth()
+		got = append(got, user+":"+pass)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{}")), Header: make(http.Header), Request: req}, nil
+	})}
+	c := Config{Client: client, Username: "user", Password: "pass"}
+	httpClient, githubClient := c.clients()
+
+	_, err := httpClient.Get("https://example.com/")
+	require.NoError(t, err)
+	req, err := githubClient.NewRequest(http.MethodGet, "repos/x/y", nil)
+	require.NoError(t, err)
+	_, err = githubClient.Do(req.Context(), req, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"user:pass", "user:pass"}, got)
+}
+
+func TestGetClone_basicAuth(t *testing.T) {
+	t.Parallel()
+	fs := &getClone{Config: Config{Username: "user", Password: "pass"}}
+	assert.Equal(t, &githttp.BasicAuth{Username: "user", Password: "pass"}, fs.auth())
+
+	fs = &getClone{}
+	assert.Nil(t, fs.auth())
+}
@@ -0,0 +1,171 @@
+package githubfs
+
+import (
+	"bytes"
+	"container/list"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/posener/gitfs/internal/instrument"
+)
+
+// ETagCache caches responses of the Github API with their ETags. Requests
+// for cached responses are sent with the If-None-Match header, and if the
+// response did not change, the cached response is used. Such requests do
+// not count against the Github API rate limit.
+//
+// A cache can be shared between filesystems that use the same client, such
+// that creating the filesystem again only downloads the data that changed.
+//
+// The cache is bounded. When the total size of the cached response bodies
+// exceeds the maximal size, the least recently used responses are evicted.
+// Requests for evicted responses are sent again without the If-None-Match
+// header.
+type ETagCache struct {
+	maxSize int64
+	size    int64
+	// recent is a list of *etagEntry, ordered from the most recently used.
+	recent  *list.List
+	entries map[string]*list.Element
+	mu      sync.Mutex
+}
+
+type etagEntry struct {
+	key    string
+	etag   string
+	header http.Header
+	body   []byte
+}
+
+// NewETagCache returns an empty cache that holds up to maxSize bytes of
+// response bodies.
+func NewETagCache(maxSize int64) *ETagCache {
+	return &ETagCache{
+		maxSize: maxSize,
+		recent:  list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Size returns the total size of the cached response bodies.
+func (c *ETagCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+func (c *ETagCache) get(key string) *etagEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.recent.MoveToFront(e)
+	return e.Value.(*etagEntry)
+}
+
+// set caches a response, instead of the previous response of the same key.
+// Responses that are larger than the cache size are not cached.
+func (c *ETagCache) set(e *etagEntry) {
+	size := int64(len(e.body))
+	if size > c.maxSize {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.entries[e.key]; ok {
+		c.remove(old)
+	}
+	c.entries[e.key] = c.recent.PushFront(e)
+	c.size += size
+	for c.size > c.maxSize {
+		c.remove(c.recent.Back())
+	}
+}
+
+// remove removes an element of the recent list from the cache. It should be
+// called with mu locked.
+func (c *ETagCache) remove(e *list.Element) {
+	entry := e.Value.(*etagEntry)
+	c.recent.Remove(e)
+	delete(c.entries, entry.key)
+	c.size -= int64(len(entry.body))
+}
+
+// client returns a copy of the given client, that uses the cache. If an
+// observer is given, the cache lookups are reported to it.
+func (c *ETagCache) client(client *http.Client, observer instrument.Observer) *http.Client {
+	return withTransport(client, func(base http.RoundTripper) http.RoundTripper {
+		return &etagTransport{base: base, cache: c, observer: observer}
+	})
+}
+
+// etagTransport is an http.RoundTripper that caches responses in an ETagCache.
+type etagTransport struct {
+	base     http.RoundTripper
+	cache    *ETagCache
+	observer instrument.Observer
+}
+
+func (t *etagTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Raw content is streamed, and is not cached.
+	if req.Method != http.MethodGet || req.Header.Get("Accept") == mediaTypeRaw {
+		return t.base.RoundTrip(req)
+	}
+	key := req.URL.String() + " " + req.Header.Get("Accept")
+	cached := t.cache.get(key)
+	if cached != nil {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	hit := cached != nil && resp.StatusCode == http.StatusNotModified
+	if t.observer != nil {
+		t.observer.Cache("etag", hit)
+	}
+	if hit {
+		resp.Body.Close()
+		return cached.response(req, resp.Header), nil
+	}
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		return resp, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	t.cache.set(&etagEntry{key: key, etag: etag, header: resp.Header, body: body})
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// response returns the cached response. The headers of the not-modified
+// response, such as the rate limit headers, override the cached headers.
+func (e *etagEntry) response(req *http.Request, header http.Header) *http.Response {
+	h := make(http.Header, len(e.header))
+	for k, v := range e.header {
+		h[k] = v
+	}
+	for k, v := range header {
+		h[k] = v
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
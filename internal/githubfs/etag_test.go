@@ -0,0 +1,104 @@
+package githubfs
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestETagCache(t *testing.T) {
+	t.Parallel()
+
+	var (
+		content     = "content"
+		notModified int
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + content + `"`
+		w.Header().Set("X-RateLimit-Remaining", "10")
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(content))
+	}))
+	defer s.Close()
+
+	client := NewETagCache(1<<20).client(s.Client(), nil)
+	get := func() string {
+		t.Helper()
+		resp, err := client.Get(s.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "10", resp.Header.Get("X-RateLimit-Remaining"))
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	assert.Equal(t, "content", get())
+	assert.Equal(t, 0, notModified)
+
+	// Second request uses the cached response.
+	assert.Equal(t, "content", get())
+	assert.Equal(t, 1, notModified)
+
+	// Modified content is downloaded again.
+	content = "modified"
+	assert.Equal(t, "modified", get())
+	assert.Equal(t, 1, notModified)
+}
+
+func TestETagCache_evict(t *testing.T) {
+	t.Parallel()
+
+	// conditional counts the conditional requests of each path.
+	conditional := make(map[string]int)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + r.URL.Path + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			conditional[r.URL.Path]++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		// The body is of the size of the path.
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer s.Close()
+
+	c := NewETagCache(5)
+	client := c.client(s.Client(), nil)
+	get := func(path string) {
+		t.Helper()
+		resp, err := client.Get(s.URL + path)
+		require.NoError(t, err)
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, path, string(b))
+	}
+
+	get("/a")
+	get("/b")
+	get("/a")
+	assert.Equal(t, int64(4), c.Size())
+	// Exceeds the cache size, and evicts the least recently used response.
+	get("/c")
+	assert.Equal(t, int64(4), c.Size())
+	get("/a")
+	get("/b")
+	assert.Equal(t, map[string]int{"/a": 2}, conditional)
+
+	// Responses that are larger than the cache are not cached.
+	get("/large")
+	get("/large")
+	assert.Equal(t, 0, conditional["/large"])
+}
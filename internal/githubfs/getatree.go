@@ -2,10 +2,18 @@ package githubfs
 
 import (
 	"context"
-	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
 	"strings"
 
+	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/log"
 	"github.com/posener/gitfs/internal/tree"
 )
 
@@ -15,52 +23,278 @@ import (
 type getATree githubfs
 
 func (fs *getATree) get(ctx context.Context) (tree.Tree, error) {
-	gitTree, _, err := fs.client.Git.GetTree(ctx, fs.owner, fs.repo, fs.ref, true)
+	gitTree, prefix, err := fs.getTree(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "get git tree")
+		return nil, err
 	}
-	t := make(tree.Tree)
+	// Without a prefix, every entry is added, such that the tree is allocated
+	// once for repositories of many files.
+	var size int
+	if prefix == "" {
+		size = len(gitTree.Entries)
+	}
+	t := make(tree.Tree, size)
 	for _, entry := range gitTree.Entries {
 		path := entry.GetPath()
-		if fs.path != "" {
-			if !strings.HasPrefix(path, fs.path) {
+		if prefix != "" {
+			if !strings.HasPrefix(path, prefix) {
 				continue
 			}
-			path = strings.TrimPrefix(path, fs.path)
+			path = strings.TrimPrefix(path, prefix)
 		}
 
-		var err error
-		switch entry.GetType() {
-		case "tree": // A directory.
-			if !fs.glob.Match(path, true) {
-				continue
-			}
-			err = t.AddDir(path)
-		case "blob": // A file.
-			if !fs.glob.Match(path, false) {
-				continue
-			}
-			err = t.AddFile(path, entry.GetSize(), fs.contentLoader(entry.GetSHA()))
-		}
-		if err != nil {
+		if err := fs.add(t, path, entry); err != nil {
 			return nil, errors.Wrapf(err, "adding %s", path)
 		}
 	}
 	return t, nil
 }
 
+// getTree returns the recursive git tree of the project, with the prefix of
+// the paths of its entries that are in the project path. If the project has a
+// path, only the tree of the path is fetched, instead of the tree of the whole
+// repository, which may be much larger. If a TreeCache or a DiskCache is
+// configured, the tree of the whole repository is fetched once for all the
+// paths in the repository.
+func (fs *getATree) getTree(ctx context.Context) (*github.Tree, string, error) {
+	if fs.path != "" && fs.Trees == nil && fs.DiskCache == nil {
+		gitTree, ok, err := fs.getSubtree(ctx)
+		if err != nil || ok {
+			return gitTree, "", err
+		}
+	}
+	gitTree, err := fs.getRepoTree(ctx)
+	return gitTree, fs.path, err
+}
+
+// getSubtree returns the recursive git tree of the project path. If the path
+// is not a directory in the ref, it returns false, such that the tree of the
+// repository is used, which results in an empty filesystem if the ref exists,
+// and in an error if it does not.
+func (fs *getATree) getSubtree(ctx context.Context) (*github.Tree, bool, error) {
+	expr := fs.escapedRefName() + ":" + strings.TrimSuffix(fs.path, "/")
+	gitTree, resp, err := fs.client.Git.GetTree(ctx, fs.owner, fs.repo, expr, true)
+	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnprocessableEntity) {
+			log.Debug("Project path is not a directory, using the repository tree", "path", fs.path)
+			return nil, false, nil
+		}
+		return nil, false, errors.Wrap(rateLimit(err), "get git tree")
+	}
+	return gitTree, true, nil
+}
+
+// getRepoTree returns the recursive git tree of the repository. If a
+// TreeCache is configured, the tree is fetched once for all the paths in the
+// repository.
+func (fs *getATree) getRepoTree(ctx context.Context) (*github.Tree, error) {
+	get := func(ctx context.Context) (*github.Tree, error) {
+		if fs.DiskCache != nil {
+			return fs.getDiskTree(ctx)
+		}
+		gitTree, _, err := fs.client.Git.GetTree(ctx, fs.owner, fs.repo, fs.escapedRef(), true)
+		if err != nil {
+			return nil, errors.Wrap(rateLimit(err), "get git tree")
+		}
+		return gitTree, nil
+	}
+	if fs.Trees == nil {
+		return get(ctx)
+	}
+	return fs.Trees.tree(ctx, fs.owner+"/"+fs.repo+"@"+fs.ref, get)
+}
+
+// getDiskTree returns the recursive git tree of the commit of the ref from
+// the disk cache. If it is not stored, it is fetched and stored.
+func (fs *getATree) getDiskTree(ctx context.Context) (*github.Tree, error) {
+	sha, err := fs.commitSHA(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("trees/%s/%s/%s.json", fs.owner, fs.repo, sha)
+	hit := true
+	content, err := fs.DiskCache.Do(ctx, key, func(ctx context.Context) ([]byte, error) {
+		hit = false
+		if fs.Offline {
+			return nil, &OfflineError{What: fmt.Sprintf("git tree of commit %s", sha)}
+		}
+		gitTree, _, err := fs.client.Git.GetTree(ctx, fs.owner, fs.repo, sha, true)
+		if err != nil {
+			return nil, errors.Wrap(rateLimit(err), "get git tree")
+		}
+		return json.Marshal(gitTree)
+	})
+	if err != nil {
+		return nil, err
+	}
+	fs.observeCache("disk", hit)
+	var gitTree github.Tree
+	if err := json.Unmarshal(content, &gitTree); err != nil {
+		return nil, errors.Wrap(err, "decoding cached git tree")
+	}
+	return &gitTree, nil
+}
+
+// commitSHA resolves the ref to a commit. The resolved commit is stored in the
+// disk cache, such that the ref can be resolved in offline mode.
+func (fs *getATree) commitSHA(ctx context.Context) (string, error) {
+	if fs.isCommit() {
+		return fs.ref, nil
+	}
+	if fs.Offline {
+		return (*githubfs)(fs).loadRef(fs.ref)
+	}
+	sha, _, err := fs.client.Repositories.GetCommitSHA1(ctx, fs.owner, fs.repo, fs.escapedRefName(), "")
+	if err != nil {
+		return "", errors.Wrap(rateLimit(err), "get commit of ref")
+	}
+	(*githubfs)(fs).storeRef(fs.ref, sha)
+	return sha, nil
+}
+
+// add adds a git tree entry to the tree in the given path. Entries that do not
+// match the glob patterns are skipped.
+func (fs *getATree) add(t tree.Tree, path string, entry github.TreeEntry) error {
+	switch entry.GetType() {
+	case "tree": // A directory.
+		if !fs.KeepEmptyDirs && !fs.glob.Match(path, true) {
+			return nil
+		}
+		return t.AddDir(path)
+	case "blob": // A file.
+		if !fs.glob.Match(path, false) {
+			return nil
+		}
+		if err := t.AddFile(path, entry.GetSize(), fs.contentLoader(entry.GetSHA())); err != nil {
+			return err
+		}
+		if err := t.SetMode(path, fileMode(entry.GetMode())); err != nil {
+			return err
+		}
+		if err := t.SetHash(path, entry.GetSHA()); err != nil {
+			return err
+		}
+		if fs.StreamThreshold > 0 && int64(entry.GetSize()) >= fs.StreamThreshold && !fs.Offline {
+			return t.SetStreamer(path, fs.contentStreamer(entry.GetSHA()))
+		}
+	}
+	return nil
+}
+
+// mediaTypeRaw is the Github API media type for raw content.
+const mediaTypeRaw = "application/vnd.github.v3.raw"
+
+// gitModeSymlink is the git file mode of a symbolic link.
+const gitModeSymlink = "120000"
+
+// fileMode converts a git file mode, such as "100755", to os.FileMode.
+// It returns 0 for an invalid mode.
+func fileMode(gitMode string) os.FileMode {
+	if gitMode == gitModeSymlink {
+		return os.ModeSymlink
+	}
+	mode, err := strconv.ParseUint(gitMode, 8, 32)
+	if err != nil {
+		return 0
+	}
+	return os.FileMode(mode) & os.ModePerm
+}
+
+// contentStreamer streams the raw content of git blob according to git sha of
+// that blob.
+func (fs *getATree) contentStreamer(sha string) tree.Streamer {
+	return func(ctx context.Context) (io.ReadCloser, error) {
+		u := fmt.Sprintf("repos/%s/%s/git/blobs/%s", fs.owner, fs.repo, sha)
+		req, err := fs.client.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating blob request")
+		}
+		req.Header.Set("Accept", mediaTypeRaw)
+		resp, err := fs.httpClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed getting blob")
+		}
+		if err := github.CheckResponse(resp); err != nil {
+			resp.Body.Close()
+			return nil, errors.Wrap(rateLimit(err), "failed getting blob")
+		}
+		return resp.Body, nil
+	}
+}
+
 // contentLoader gets content of git blob according to git sha of that blob.
+// The blob is requested in its raw form, which avoids the overhead of the
+// base64 encoding. If a TreeCache is configured, the content is shared
+// between filesystems, and if a DiskCache is configured, it is shared between
+// processes.
 func (fs *getATree) contentLoader(sha string) func(context.Context) ([]byte, error) {
+	stream := fs.contentStreamer(sha)
+	load := func(ctx context.Context) ([]byte, error) {
+		r, err := stream(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		content, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed reading blob")
+		}
+		return content, nil
+	}
+	if fs.Offline {
+		load = func(context.Context) ([]byte, error) {
+			content, ok := fs.DiskCache.Get(blobKey(sha))
+			fs.observeCache("disk", ok)
+			if !ok {
+				return nil, &OfflineError{What: fmt.Sprintf("git blob %s", sha)}
+			}
+			return content, nil
+		}
+	} else if fs.DiskCache != nil {
+		fetch := load
+		load = func(ctx context.Context) ([]byte, error) {
+			hit := true
+			content, err := fs.DiskCache.Do(ctx, blobKey(sha), func(ctx context.Context) ([]byte, error) {
+				hit = false
+				return fetch(ctx)
+			})
+			if err == nil {
+				fs.observeCache("disk", hit)
+			}
+			return content, err
+		}
+	}
 	return func(ctx context.Context) ([]byte, error) {
-		blob, _, err := fs.client.Git.GetBlob(ctx, fs.owner, fs.repo, sha)
+		if fs.Trees != nil {
+			content, ok := fs.Trees.blob(sha)
+			fs.observeCache("tree", ok)
+			if ok {
+				return content, nil
+			}
+		}
+		content, err := load(ctx)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed getting blob")
+			return nil, err
 		}
-		switch encoding := blob.GetEncoding(); encoding {
-		case "base64":
-			return base64.StdEncoding.DecodeString(blob.GetContent())
-		default:
-			return nil, errors.Errorf("unexpected encoding: %s", encoding)
+		if fs.Trees != nil {
+			fs.Trees.addBlob(sha, content)
 		}
+		return content, nil
+	}
+}
+
+// observeCache reports a cache lookup to the observer, if it is set.
+func (fs *getATree) observeCache(name string, hit bool) {
+	if fs.Observer != nil {
+		fs.Observer.Cache(name, hit)
+	}
+}
+
+// blobKey returns the disk cache key of a git blob. Blobs are shared between
+// repositories, since they are identified by their content.
+func blobKey(sha string) string {
+	if len(sha) < 2 {
+		return "blobs/" + sha
 	}
+	return "blobs/" + sha[:2] + "/" + sha
 }
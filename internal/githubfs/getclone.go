@@ -0,0 +1,124 @@
+package githubfs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/archivefs"
+	"github.com/posener/gitfs/internal/log"
+	"github.com/posener/gitfs/internal/netrc"
+	"github.com/posener/gitfs/internal/tree"
+	"golang.org/x/oauth2"
+)
+
+// getClone gets github content using a shallow git clone of the repository,
+// with a depth of a single commit, which is stored in memory. It does not use
+// the Github API, such that it is not limited by the API rate limit, but the
+// whole tree of the commit is downloaded. With SparseClone, the clone is
+// stored in a temporary directory, and only the content of the project path is
+// kept in memory.
+type getClone githubfs
+
+func (fs *getClone) get(ctx context.Context) (tree.Tree, error) {
+	log.Debug("Cloning repository", "url", fs.cloneURL, "ref", fs.ref)
+	var storage storage.Storer = memory.NewStorage()
+	if fs.SparseClone {
+		dir, err := ioutil.TempDir("", "gitfs-clone")
+		if err != nil {
+			return nil, errors.Wrap(err, "creating clone directory")
+		}
+		defer os.RemoveAll(dir)
+		storage = filesystem.NewStorage(osfs.New(dir), cache.NewObjectLRUDefault())
+	}
+	opts := &git.CloneOptions{
+		URL:          fs.cloneURL,
+		Auth:         fs.auth(),
+		Depth:        1,
+		SingleBranch: true,
+		Tags:         git.NoTags,
+	}
+	if fs.TLSConfig != nil {
+		opts.InsecureSkipTLS = fs.TLSConfig.InsecureSkipVerify
+	}
+	if fs.ref != "" {
+		opts.ReferenceName = plumbing.ReferenceName("refs/" + fs.ref)
+	}
+	repo, err := git.CloneContext(ctx, storage, nil, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "git clone")
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, errors.Wrap(err, "get head")
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, errors.Wrap(err, "get head commit")
+	}
+	gitTree, err := commit.Tree()
+	if err != nil {
+		return nil, errors.Wrap(err, "get head tree")
+	}
+	if fs.path != "" {
+		gitTree, err = gitTree.Tree(strings.TrimSuffix(fs.path, "/"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "get tree of %s", fs.path)
+		}
+	}
+	if fs.SparseClone {
+		// The clone directory is removed when this function returns.
+		return archivefs.FromGitTreeContent(gitTree, fs.filter)
+	}
+	return archivefs.FromGitTree(gitTree, fs.filter)
+}
+
+// filter applies the glob patterns on the entries of the git tree.
+func (fs *getClone) filter(name string, isDir bool) (string, bool) {
+	if isDir && fs.KeepEmptyDirs {
+		return name, true
+	}
+	return name, fs.glob.Match(name, isDir)
+}
+
+// auth returns the credentials for the clone. Github tokens of an OAuth2
+// client, such as a client that was created from a token in the environment,
+// and credentials of a netrc client, are used for basic authentication. The
+// configured username and password have precedence over the client.
+func (fs *getClone) auth() transport.AuthMethod {
+	if fs.Username != "" || fs.Password != "" {
+		return &githttp.BasicAuth{Username: fs.Username, Password: fs.Password}
+	}
+	if fs.Client == nil {
+		return nil
+	}
+	if t, ok := fs.Client.Transport.(*netrc.Transport); ok {
+		m, ok := t.Machines.Lookup("github.com")
+		if !ok {
+			return nil
+		}
+		return &githttp.BasicAuth{Username: m.Login, Password: m.Password}
+	}
+	t, ok := fs.Client.Transport.(*oauth2.Transport)
+	if !ok {
+		return nil
+	}
+	token, err := t.Source.Token()
+	if err != nil {
+		log.Warn("Failed getting token for clone", "error", err)
+		return nil
+	}
+	// Github accepts any non-empty user name when using a token.
+	return &githttp.BasicAuth{Username: "gitfs", Password: token.AccessToken}
+}
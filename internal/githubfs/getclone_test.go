@@ -0,0 +1,72 @@
+package githubfs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetClone(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	mockRepo(t, dir, map[string]string{
+		"static/file":    "file",
+		"static/d/a.txt": "a",
+		"static/d/b.md":  "b",
+		"README.md":      "readme",
+	})
+
+	for _, sparse := range []bool{false, true} {
+		fs, err := newGithubFS(context.Background(), "github.com/x/y/static@heads/master", Config{
+			Clone:       true,
+			SparseClone: sparse,
+			Glob:        []string{"*", "d/*.txt"},
+		})
+		require.NoError(t, err)
+		fs.cloneURL = dir
+		g := getClone(*fs)
+		got, err := g.get(context.Background())
+		require.NoError(t, err)
+
+		for path, content := range map[string]string{"file": "file", "d/a.txt": "a"} {
+			f, err := got.Open(path)
+			require.NoError(t, err)
+			b, err := ioutil.ReadAll(f)
+			require.NoError(t, err)
+			assert.Equal(t, content, string(b))
+		}
+		assert.Nil(t, got["d/b.md"])
+		assert.Nil(t, got["README.md"])
+	}
+}
+
+// mockRepo creates a git repository in the given directory, with a single
+// commit on the master branch that contains the given files.
+func mockRepo(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	w, err := repo.Worktree()
+	require.NoError(t, err)
+	for path, content := range files {
+		full := filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, ioutil.WriteFile(full, []byte(content), 0644))
+		_, err = w.Add(path)
+		require.NoError(t, err)
+	}
+	_, err = w.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "Gopher", When: time.Now()},
+	})
+	require.NoError(t, err)
+}
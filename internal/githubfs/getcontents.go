@@ -4,6 +4,7 @@ import (
 	"context"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"strings"
 	"sync"
 
@@ -22,7 +23,6 @@ func (fs *getContents) get(ctx context.Context) (tree.Tree, error) {
 	downloader := recursiveGetContents{
 		getContents: fs,
 		tree:        make(tree.Tree),
-		errors:      make(chan error),
 	}
 
 	err := downloader.download(ctx)
@@ -32,46 +32,84 @@ func (fs *getContents) get(ctx context.Context) (tree.Tree, error) {
 	return downloader.tree, nil
 }
 
+// maxContentsEntries is the maximal number of directory entries that are
+// returned by the get-contents API. This API does not support pagination.
+const maxContentsEntries = 1000
+
+// contentWorkers is the number of file contents that are downloaded
+// concurrently by the get-contents API.
+const contentWorkers = 8
+
 // recursiveGetContents downloads an entire github tree using the Github get-contents API.
 // Since this API returns only a single-depth level of files, it runs recursively on each
-// directory. These recursive calls are done in parallel.
+// directory.
 type recursiveGetContents struct {
 	*getContents
-	tree   tree.Tree
-	mu     sync.Mutex
-	wg     sync.WaitGroup
-	errors chan error
+	tree  tree.Tree
+	count prefetchCount
+	// jobs are the downloads of file contents, which are done by a fixed
+	// number of workers.
+	jobs chan func(context.Context) error
+	// err is the first error, after which cancel cancels all other calls.
+	err    error
+	cancel context.CancelFunc
+	// mu guards tree and err.
+	mu sync.Mutex
 }
 
 // download an entire (sub)tree of a github project using the get-contents API.
 // The API returns an entire directory with all the files and download URL links.
 // The API is called recursively on all the directories, and download all the content of
 // all the files using the download URL.
-// Each recursive call is called in a goroutine, and each content download is called in
-// a goroutine.
-// The synchronization is done using mu, and waiting for all the goroutine to finish is
-// done using wg.
+// The directories are listed sequentially, and the contents of the files are
+// downloaded concurrently by contentWorkers workers, instead of a goroutine
+// for each file. The first error, including exceeding the MaxFiles or
+// MaxTotalSize limits, cancels the other calls and is returned.
 func (gc *recursiveGetContents) download(ctx context.Context) error {
-	gc.wg.Add(1)
-	gc.check(gc.recursive(ctx, gc.path))
-	gc.wg.Wait()
-
-	select {
-	case err := <-gc.errors:
-		return err
-	default:
-		return nil
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	gc.cancel = cancel
+	gc.jobs = make(chan func(context.Context) error)
+	var wg sync.WaitGroup
+	for i := 0; i < contentWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range gc.jobs {
+				if ctx.Err() == nil {
+					gc.check(job(ctx))
+				}
+			}
+		}()
 	}
+
+	gc.check(gc.recursive(ctx, gc.path))
+	close(gc.jobs)
+	wg.Wait()
+	return gc.err
 }
 
-// recursice is a single recursive get-contents call. Before a call to recursive,
-// wg.Add(1) should be called.
+// recursive is a single recursive get-contents call.
 func (gc *recursiveGetContents) recursive(ctx context.Context, root string) error {
-	defer gc.wg.Done()
-	log.Printf("Using Github get-content API for path %q", root)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := gc.addFile(nil); err != nil {
+		return err
+	}
+	log.Debug("Using Github get-content API", "path", root)
 	file, entries, _, err := gc.client.Repositories.GetContents(ctx, gc.owner, gc.repo, root, gc.opt())
 	if err != nil {
-		return errors.Wrap(err, "github get-contents")
+		return errors.Wrap(rateLimit(err), "github get-contents")
+	}
+	if len(entries) >= maxContentsEntries {
+		// The directory listing might be truncated, list it using the Git
+		// trees API instead.
+		log.Debug("Directory has too many entries, using Github get-a-tree API", "path", root, "entries", maxContentsEntries)
+		entries, err = gc.treeEntries(ctx, root)
+		if err != nil {
+			return err
+		}
 	}
 
 	// This API call may return entries or file, we check both cases.
@@ -81,23 +119,34 @@ func (gc *recursiveGetContents) recursive(ctx context.Context, root string) erro
 
 		switch entry.GetType() {
 		case "dir": // A directory.
-			if !gc.glob.Match(fsPath, true) {
+			if !gc.KeepEmptyDirs && !gc.glob.Match(fsPath, true) {
+				continue
+			}
+			if (*githubfs)(gc.getContents).excluded(fsPath, 0, os.ModeDir) {
 				continue
 			}
-			gc.mu.Lock()
-			err = gc.tree.AddDir(fsPath)
-			gc.mu.Unlock()
-			if err != nil {
+			if err := gc.addDir(fsPath); err != nil {
 				return errors.Wrapf(err, "adding %s", fsPath)
 			}
-			gc.wg.Add(1)
-			go gc.check(gc.recursive(ctx, fullPath))
-		case "file": // A file.
+			gc.check(gc.recursive(ctx, fullPath))
+		case "file", "symlink": // A file. The content of a symlink is its target.
 			if !gc.glob.Match(fsPath, false) {
 				continue
 			}
-			gc.wg.Add(1)
-			go gc.check(gc.downloadContent(ctx, fsPath, entry.GetSize(), entry.GetDownloadURL()))
+			var mode os.FileMode
+			if entry.GetType() == "symlink" {
+				mode = os.ModeSymlink
+			}
+			if (*githubfs)(gc.getContents).excluded(fsPath, int64(entry.GetSize()), mode) {
+				continue
+			}
+			if err := gc.addFile(entry); err != nil {
+				return err
+			}
+			entry := entry
+			gc.jobs <- func(ctx context.Context) error {
+				return gc.downloadContent(ctx, fsPath, mode, entry)
+			}
 		}
 	}
 
@@ -107,34 +156,114 @@ func (gc *recursiveGetContents) recursive(ctx context.Context, root string) erro
 		if !gc.glob.Match(path, false) {
 			return nil
 		}
-		content, err := file.GetContent()
+		content, err := gc.content(ctx, file)
 		if err != nil {
 			return errors.Wrapf(err, "get content of %s", path)
 		}
 		gc.mu.Lock()
-		err = gc.tree.AddFileContent(path, []byte(content))
-		gc.mu.Unlock()
-		if err != nil {
+		defer gc.mu.Unlock()
+		if err := gc.tree.AddFileContent(path, content); err != nil {
 			return errors.Wrapf(err, "adding %s", path)
 		}
 	}
 	return nil
 }
 
-// downloadContent downloads content of a single file. Before a call to recursive,
-// wg.Add(1) should be called.
-func (gc *recursiveGetContents) downloadContent(ctx context.Context, path string, size int, downloadURL string) error {
-	defer gc.wg.Done()
-	content, err := gc.downloadURL(ctx, downloadURL)
+// treeEntries lists a directory using the Git trees API, which is not limited
+// in the number of entries as the get-contents API. The entries are converted
+// to get-contents entries, without download URLs, such that the content of
+// files is downloaded using the Git blob API.
+func (gc *recursiveGetContents) treeEntries(ctx context.Context, root string) ([]*github.RepositoryContent, error) {
+	dir := strings.TrimSuffix(root, "/")
+	gitTree, _, err := gc.client.Git.GetTree(ctx, gc.owner, gc.repo, gc.escapedRefName()+":"+dir, false)
+	if err != nil {
+		return nil, errors.Wrapf(rateLimit(err), "get git tree of %s", root)
+	}
+	if gitTree.GetTruncated() {
+		return nil, errors.Errorf("git tree of %s is truncated", root)
+	}
+	entries := make([]*github.RepositoryContent, 0, len(gitTree.Entries))
+	for _, entry := range gitTree.Entries {
+		var typ string
+		switch {
+		case entry.GetType() == "tree":
+			typ = "dir"
+		case entry.GetMode() == gitModeSymlink:
+			typ = "symlink"
+		case entry.GetType() == "blob":
+			typ = "file"
+		default: // Submodules are skipped, as in the get-contents API.
+			continue
+		}
+		path := entry.GetPath()
+		if dir != "" {
+			path = dir + "/" + path
+		}
+		entries = append(entries, &github.RepositoryContent{
+			Type: github.String(typ),
+			Path: github.String(path),
+			SHA:  entry.SHA,
+			Size: entry.Size,
+		})
+	}
+	return entries, nil
+}
+
+// addFile adds a file entry to the files that were found, and returns an
+// error if the MaxFiles or MaxTotalSize limits were exceeded. If entry is
+// nil, the limits are only checked.
+func (gc *recursiveGetContents) addFile(entry *github.RepositoryContent) error {
+	if entry == nil {
+		return gc.count.check((*githubfs)(gc.getContents))
+	}
+	return gc.count.add((*githubfs)(gc.getContents), int64(entry.GetSize()))
+}
+
+// addDir adds a directory to the tree.
+func (gc *recursiveGetContents) addDir(path string) error {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	return gc.tree.AddDir(path)
+}
+
+// downloadContent downloads content of a single file.
+func (gc *recursiveGetContents) downloadContent(ctx context.Context, path string, mode os.FileMode, entry *github.RepositoryContent) error {
+	content, err := gc.content(ctx, entry)
 	if err != nil {
-		return errors.Wrapf(err, "get content from %s", downloadURL)
+		return errors.Wrapf(err, "get content of %s", path)
 	}
 	gc.mu.Lock()
 	defer gc.mu.Unlock()
-	return gc.tree.AddFileContent(path, content)
+	if err := gc.tree.AddFileContent(path, content); err != nil {
+		return err
+	}
+	if err := gc.tree.SetHash(path, entry.GetSHA()); err != nil {
+		return err
+	}
+	if mode != 0 {
+		return gc.tree.SetMode(path, mode)
+	}
+	return nil
 }
 
-// downloadContent downloads a given URL.
+// content returns the content of a file entry. The get-contents API omits the
+// content of large files, and in some cases also their download URL. In that
+// case, the content is downloaded using the Git blob API.
+func (gc *recursiveGetContents) content(ctx context.Context, entry *github.RepositoryContent) ([]byte, error) {
+	if entry.Content != nil && entry.GetEncoding() != "none" {
+		content, err := entry.GetContent()
+		return []byte(content), err
+	}
+	if downloadURL := entry.GetDownloadURL(); downloadURL != "" {
+		content, err := gc.downloadURL(ctx, downloadURL)
+		return content, errors.Wrapf(err, "get content from %s", downloadURL)
+	}
+	log.Debug("No content, using Git blob API", "path", entry.GetPath())
+	blobs := getATree(*gc.getContents)
+	return blobs.contentLoader(entry.GetSHA())(ctx)
+}
+
+// downloadURL downloads a given URL.
 func (gc *recursiveGetContents) downloadURL(ctx context.Context, downloadURL string) ([]byte, error) {
 	req, err := http.NewRequest(http.MethodGet, downloadURL, nil)
 	if err != nil {
@@ -151,14 +280,20 @@ func (gc *recursiveGetContents) downloadURL(ctx context.Context, downloadURL str
 	return ioutil.ReadAll(resp.Body)
 }
 
+// check keeps the first error and cancels the other calls. The following
+// errors are only logged, since they are usually caused by the cancellation.
 func (gc *recursiveGetContents) check(err error) {
-	if err != nil {
-		select {
-		case gc.errors <- err:
-		default:
-			log.Printf("Failed sending error in channel", err)
-		}
+	if err == nil {
+		return
+	}
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if gc.err != nil {
+		log.Debug("Ignoring error after the first error", "error", err)
+		return
 	}
+	gc.err = err
+	gc.cancel()
 }
 
 // opt returns Github GetContent options. The expected ref, unlike other APIs, should not
@@ -167,7 +302,5 @@ func (gc *recursiveGetContents) opt() *github.RepositoryContentGetOptions {
 	if gc.ref == "" {
 		return nil
 	}
-	ref := strings.TrimPrefix(gc.ref, "heads/")
-	ref = strings.TrimPrefix(ref, "tags/")
-	return &github.RepositoryContentGetOptions{Ref: ref}
+	return &github.RepositoryContentGetOptions{Ref: gc.refName()}
 }
@@ -0,0 +1,175 @@
+package githubfs
+
+import (
+	"context"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/log"
+	"github.com/posener/gitfs/internal/tree"
+)
+
+// getGraphQL gets github content using Github's GraphQL API:
+// (https://developer.github.com/v4/).
+// A single query returns the entries of a directory together with the
+// content of its text files, such that only one request is required per
+// directory. Content of binary or large files, that is not returned by the
+// query, is downloaded using the get-blob API.
+type getGraphQL githubfs
+
+// graphQLTreeQuery queries the entries of a directory and their content.
+const graphQLTreeQuery = `query($owner: String!, $name: String!, $expression: String!) {
+  repository(owner: $owner, name: $name) {
+    object(expression: $expression) {
+      ... on Tree {
+        entries {
+          name
+          type
+          mode
+          object {
+            ... on Blob {
+              oid
+              byteSize
+              isTruncated
+              text
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type graphQLResponse struct {
+	Data struct {
+		Repository struct {
+			Object *struct {
+				Entries []graphQLEntry `json:"entries"`
+			} `json:"object"`
+		} `json:"repository"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+type graphQLEntry struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Mode   int    `json:"mode"`
+	Object struct {
+		Oid         string  `json:"oid"`
+		ByteSize    int     `json:"byteSize"`
+		IsTruncated bool    `json:"isTruncated"`
+		Text        *string `json:"text"`
+	} `json:"object"`
+}
+
+func (fs *getGraphQL) get(ctx context.Context) (tree.Tree, error) {
+	t := make(tree.Tree)
+	var c prefetchCount
+	if err := fs.getDir(ctx, t, "", &c); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// getDir adds the content of a directory to the tree, and recursively the
+// content of its sub directories. The files that were added are counted in c.
+func (fs *getGraphQL) getDir(ctx context.Context, t tree.Tree, dir string, c *prefetchCount) error {
+	log.Debug("Using Github GraphQL API", "path", fs.path+dir)
+	entries, err := fs.query(ctx, strings.TrimSuffix(fs.path+dir, "/"))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		p := path.Join(dir, entry.Name)
+		var err error
+		switch entry.Type {
+		case "tree": // A directory.
+			if !fs.KeepEmptyDirs && !fs.glob.Match(p, true) {
+				continue
+			}
+			if (*githubfs)(fs).excluded(p, 0, os.ModeDir) {
+				continue
+			}
+			if err = t.AddDir(p); err == nil {
+				err = fs.getDir(ctx, t, p, c)
+			}
+		case "blob": // A file.
+			if !fs.glob.Match(p, false) {
+				continue
+			}
+			mode := fileMode(strconv.FormatInt(int64(entry.Mode), 8))
+			if (*githubfs)(fs).excluded(p, int64(entry.Object.ByteSize), mode) {
+				continue
+			}
+			if err := c.add((*githubfs)(fs), int64(entry.Object.ByteSize)); err != nil {
+				return err
+			}
+			err = fs.addFile(ctx, t, p, entry)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "adding %s", p)
+		}
+	}
+	return nil
+}
+
+// addFile adds a file to the tree. If the content of the file was not returned
+// by the query, it is downloaded.
+func (fs *getGraphQL) addFile(ctx context.Context, t tree.Tree, p string, entry graphQLEntry) error {
+	var content []byte
+	if entry.Object.Text != nil && !entry.Object.IsTruncated {
+		content = []byte(*entry.Object.Text)
+	} else {
+		blobs := getATree(*fs)
+		var err error
+		content, err = blobs.contentLoader(entry.Object.Oid)(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	if err := t.AddFileContent(p, content); err != nil {
+		return err
+	}
+	if err := t.SetHash(p, entry.Object.Oid); err != nil {
+		return err
+	}
+	return t.SetMode(p, fileMode(strconv.FormatInt(int64(entry.Mode), 8)))
+}
+
+// query returns the entries of the directory in the given path.
+func (fs *getGraphQL) query(ctx context.Context, dir string) ([]graphQLEntry, error) {
+	body := graphQLRequest{
+		Query: graphQLTreeQuery,
+		Variables: map[string]interface{}{
+			"owner":      fs.owner,
+			"name":       fs.repo,
+			"expression": fs.refExpression() + ":" + dir,
+		},
+	}
+	req, err := fs.client.NewRequest("POST", "graphql", body)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating graphql request")
+	}
+	var resp graphQLResponse
+	if _, err := fs.client.Do(ctx, req, &resp); err != nil {
+		return nil, errors.Wrap(rateLimit(err), "github graphql")
+	}
+	if len(resp.Errors) > 0 {
+		return nil, errors.Errorf("github graphql: %s", resp.Errors[0].Message)
+	}
+	if resp.Data.Repository.Object == nil {
+		return nil, errors.Wrapf(os.ErrNotExist, "path %q", dir)
+	}
+	return resp.Data.Repository.Object.Entries, nil
+}
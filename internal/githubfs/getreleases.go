@@ -0,0 +1,99 @@
+package githubfs
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/tree"
+)
+
+// releasesPath is the project path of the release assets filesystem.
+const releasesPath = "releases/"
+
+// getReleaseAssets gets the assets of a Github release using Github's
+// releases API: (https://developer.github.com/v3/repos/releases/).
+// The filesystem contains the assets of the release as files in its root
+// directory. The content of an asset is downloaded only when accessed, unless
+// Prefetch is set.
+type getReleaseAssets githubfs
+
+// isReleases returns true if the project is a release assets project, of the
+// form `github.com/<owner>/<repo>/releases@<tag>`.
+func (p *project) isReleases() bool {
+	return p.path == releasesPath && strings.HasPrefix(p.ref, "tags/")
+}
+
+func (fs *getReleaseAssets) get(ctx context.Context) (tree.Tree, error) {
+	release, _, err := fs.client.Repositories.GetReleaseByTag(ctx, fs.owner, fs.repo, fs.escapedRefName())
+	if err != nil {
+		return nil, errors.Wrap(rateLimit(err), "get release")
+	}
+	t := make(tree.Tree)
+	for _, asset := range release.Assets {
+		name := asset.GetName()
+		if !fs.glob.Match(name, false) {
+			continue
+		}
+		load := fs.assetLoader(asset.GetID())
+		if fs.Prefetch {
+			content, err := load(ctx)
+			if err != nil {
+				return nil, errors.Wrapf(err, "downloading %s", name)
+			}
+			err = t.AddFileContent(name, content)
+		} else {
+			err = t.AddFile(name, asset.GetSize(), load)
+		}
+		if err == nil {
+			updated := asset.GetUpdatedAt().Time
+			err = t.SetModTime(name, func(context.Context) (time.Time, error) { return updated, nil })
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "adding %s", name)
+		}
+	}
+	return t, nil
+}
+
+// assetLoader downloads the content of a release asset according to its id.
+func (fs *getReleaseAssets) assetLoader(id int64) tree.Loader {
+	return func(ctx context.Context) ([]byte, error) {
+		rc, redirectURL, err := fs.client.Repositories.DownloadReleaseAsset(ctx, fs.owner, fs.repo, id)
+		if err != nil {
+			return nil, errors.Wrap(rateLimit(err), "failed downloading asset")
+		}
+		if redirectURL != "" {
+			// The redirect location is signed, and should be downloaded without
+			// the credentials of the Github client.
+			rc, err = download(ctx, http.DefaultClient, redirectURL)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed downloading asset")
+			}
+		}
+		defer rc.Close()
+		return ioutil.ReadAll(rc)
+	}
+}
+
+// download returns the body of a GET request to the given URL.
+func download(ctx context.Context, client *http.Client, u string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "building request")
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "performing http request")
+	}
+	if err := github.CheckResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,135 @@
+package githubfs
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/archivefs"
+	"github.com/posener/gitfs/internal/log"
+	"github.com/posener/gitfs/internal/tree"
+)
+
+// getTarball gets github content using Github's get-archive API:
+// (https://developer.github.com/v3/repos/contents/#get-archive-link).
+// The whole repository is downloaded as a tarball in a single request. The
+// listing of the files, their modes and hashes are taken from the git tree,
+// as with getATree, and only their content is taken from the tarball. The
+// tarball is created by git archive, which applies the export-ignore and
+// export-subst git attributes, so files that are missing from the tarball,
+// or that their content there does not match their hash, are loaded from
+// their blobs when they are read.
+type getTarball githubfs
+
+func (fs *getTarball) get(ctx context.Context) (tree.Tree, error) {
+	listing := getATree(*fs)
+	t, err := listing.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := fs.download(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for path, o := range t {
+		f, ok := o.(hasher)
+		if !ok {
+			continue
+		}
+		content, ok := tarContent(contents, path)
+		if !ok || blobHash(content) != f.Hash() {
+			log.Debug("Content in tarball differs from the git blob, loading the blob", "path", path)
+			continue
+		}
+		if err := t.SetContent(path, content); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// download downloads the tarball and returns a tree of its content.
+func (fs *getTarball) download(ctx context.Context) (tree.Tree, error) {
+	log.Debug("Using Github tarball", "ref", fs.refName())
+	u := fmt.Sprintf("repos/%s/%s/tarball/%s", fs.owner, fs.repo, fs.escapedRefName())
+	req, err := fs.client.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating tarball request")
+	}
+	resp, err := fs.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "downloading tarball")
+	}
+	defer resp.Body.Close()
+	if err := github.CheckResponse(resp); err != nil {
+		return nil, errors.Wrap(rateLimit(err), "downloading tarball")
+	}
+	r, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding gzip")
+	}
+	defer r.Close()
+	var size int64
+	return archivefs.FromTarFilterSize(r, fs.filter, func(n int64) error {
+		size += n
+		return (*githubfs)(fs).checkTotalSize(size)
+	})
+}
+
+// hasher is a file that knows the git object SHA of its content.
+type hasher interface {
+	Hash() string
+}
+
+// tarContent returns the content of the file in the given path of the
+// tarball tree, if it is a file.
+func tarContent(contents tree.Tree, path string) ([]byte, bool) {
+	o := contents[path]
+	if o == nil {
+		return nil, false
+	}
+	f := o.Open()
+	defer f.Close()
+	if st, err := f.Stat(); err != nil || st.IsDir() {
+		return nil, false
+	}
+	content, err := ioutil.ReadAll(f)
+	return content, err == nil
+}
+
+// blobHash returns the git object SHA of a blob with the given content.
+func blobHash(content []byte) string {
+	sum := sha1.New()
+	fmt.Fprintf(sum, "blob %d\x00", len(content))
+	sum.Write(content)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// filter maps a path in the tarball to a path in the filesystem. The tarball
+// contains a single root directory, that is named after the repository and
+// the commit.
+func (fs *getTarball) filter(name string, isDir bool) (string, bool) {
+	i := strings.Index(name, "/")
+	if i < 0 {
+		return "", false
+	}
+	name = name[i+1:]
+	if !strings.HasPrefix(name, fs.path) {
+		return "", false
+	}
+	name = strings.TrimPrefix(name, fs.path)
+	if name == "" {
+		return "", false
+	}
+	if isDir && fs.KeepEmptyDirs {
+		return name, true
+	}
+	return name, fs.glob.Match(name, isDir)
+}
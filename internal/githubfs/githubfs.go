@@ -2,21 +2,194 @@ package githubfs
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
 	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/diskcache"
 	"github.com/posener/gitfs/internal/glob"
+	"github.com/posener/gitfs/internal/instrument"
 	"github.com/posener/gitfs/internal/log"
 	"github.com/posener/gitfs/internal/tree"
 )
 
+// Config is the configuration of a Github filesystem.
+type Config struct {
+	// Client is the HTTP client that is used for Github API calls. If not
+	// set, http.DefaultClient is used.
+	Client *http.Client
+	// GithubClient, if set, is used for Github API calls instead of a client
+	// that is created from Client. It can be used for custom base URLs or
+	// instrumentation. Client is still used for downloading file contents,
+	// and ETags, RequestsPerSecond and WaitRateLimit apply only to requests
+	// of Client.
+	GithubClient *github.Client
+	// Prefetch the content of all files when the filesystem is created. The
+	// repository is downloaded as a single tarball. Files that are missing
+	// from the tarball or differ from their git blobs, by the export-ignore
+	// and export-subst git attributes, are loaded when they are first read.
+	Prefetch bool
+	// GraphQL prefetches the content using the Github GraphQL API, which
+	// requires a single request per directory, but downloads only the project
+	// path. It applies only when Prefetch is set, and requires an
+	// authenticated client.
+	GraphQL bool
+	// Glob patterns. When set, only matching files and directories are
+	// included in the filesystem.
+	Glob []string
+	// KeepEmptyDirs applies the Glob patterns only on files, and keeps all
+	// the directories, also directories that none of their files match.
+	KeepEmptyDirs bool
+	// Filter, if set, includes only the files and directories for which it
+	// returns true. It is given absolute paths in the filesystem, such as
+	// "/dir/file". A directory that is filtered out is removed with all its
+	// content, and when prefetching, the content of files that are filtered
+	// out is not downloaded, where the API allows it. The modification times
+	// of the given infos are not set.
+	Filter func(path string, info os.FileInfo) bool
+	// PathModTimes sets the modification time of every file and directory to
+	// the date of the last commit that modified it, which is loaded with a
+	// Github API request per path, when the modification time of the path is
+	// first requested. Otherwise, the modification times of all the paths are
+	// the date of the last commit of the project, which is loaded with a
+	// single request.
+	PathModTimes bool
+	// ResolveSymlinks replaces symlinks with the files or directories they
+	// point to. Symlinks that point outside of the filesystem, and symlinks
+	// when this option is not set, are files whose content is the link target
+	// and their mode has the os.ModeSymlink bit. Symlinks in loops are
+	// removed.
+	ResolveSymlinks bool
+	// CacheSize bounds the total size in bytes of loaded file contents that
+	// are kept in memory. Least recently used contents are evicted and loaded
+	// again when needed. When zero, all loaded contents are kept.
+	CacheSize int64
+	// StreamThreshold is a file size in bytes. Files in that size or larger are
+	// streamed from the remote repository when they are read, instead of being
+	// loaded to memory. It does not apply when Prefetch is set. When zero, all
+	// files are loaded to memory.
+	StreamThreshold int64
+	// ETags, if set, caches the Github API responses, and sends conditional
+	// requests for cached responses.
+	ETags *ETagCache
+	// Trees, if set, shares the fetched git trees and file contents between
+	// filesystems of different paths in the same repository and ref.
+	Trees *TreeCache
+	// DiskCache, if set, stores the fetched git trees and file contents on
+	// the local disk, such that they are shared between processes. Trees are
+	// stored per commit, such that the ref is resolved to a commit with an
+	// additional API call. It applies when the filesystem is loaded using the
+	// get-a-tree API, which is the default.
+	DiskCache *diskcache.Cache
+	// Offline loads the filesystem only from DiskCache, without sending any
+	// request. The ref, or the default branch if no ref is given, is resolved
+	// to the commit that it was resolved to when the filesystem was last
+	// loaded with DiskCache. Content that is not stored results in an
+	// *OfflineError. Commits and modification times of files are not
+	// available, and release assets are not supported.
+	Offline bool
+	// Clone loads the project using a shallow git clone that is stored in
+	// memory, instead of using the Github API. This is not limited by the
+	// Github API rate limit, but the whole repository is downloaded. Commit
+	// information and modification times of files are not available.
+	Clone bool
+	// SparseClone stores the clone in a temporary directory instead of in
+	// memory, and loads to memory only the content of the project path that
+	// matches the Glob patterns, after which the directory is removed. It
+	// keeps the memory usage proportional to the content of the filesystem.
+	// The whole tree of the commit is still downloaded, since partial clone
+	// filters are not supported. It applies only when Clone is set.
+	SparseClone bool
+	// MaxFiles limits the number of files in the filesystem. If the project
+	// has more files, New returns an error. Prefetching stops as soon as the
+	// limit is exceeded, such that large repositories do not result in a large
+	// number of API calls. When zero, the number of files is not limited.
+	MaxFiles int
+	// MaxTotalSize limits the total size in bytes of the file contents that
+	// are prefetched into memory when Prefetch is set. If the content is
+	// larger, New returns a *TotalSizeError. Prefetching stops as soon as the
+	// limit is exceeded. When zero, the size is not limited.
+	MaxTotalSize int64
+	// RequestsPerSecond limits the rate of the requests that are sent using
+	// Client. When zero, the rate is not limited.
+	RequestsPerSecond float64
+	// Observer, if set, is reported with the requests that are sent using
+	// Client, the loads of file contents, and the lookups in the caches.
+	Observer instrument.Observer
+	// OnFileLoad, if set, is called whenever the content of a file is loaded,
+	// with the path of the file, the duration of the load and its error.
+	OnFileLoad func(path string, d time.Duration, err error)
+	// WaitRateLimit waits until the Github API rate limit resets when it is
+	// exceeded, as long as the context of the request is not done. Otherwise,
+	// a *RateLimitError is returned.
+	WaitRateLimit bool
+	// UserAgent, if set, is the User-Agent header of the requests that are
+	// sent using Client. It does not apply to git requests of Clone, and to
+	// requests of GithubClient.
+	UserAgent string
+	// Username and Password, if any of them is set, authorize the requests
+	// that are sent using Client with basic authentication, for servers that
+	// support only a username and password, or a token as the password. They
+	// also authorize the git requests of Clone, and do not apply to requests
+	// of GithubClient and to requests to Mirrors.
+	Username string
+	Password string
+	// TLSConfig, if set, is the TLS configuration of the connections of
+	// Client, for example for servers with certificates of a private CA. It
+	// is applied on the *http.Transport of Client, or on the default
+	// transport. Git requests of Clone use only its InsecureSkipVerify field.
+	TLSConfig *tls.Config
+	// Mirrors, if set, are the servers that the requests of the Github API
+	// are sent to, in order, until one of them does not fail with an error or
+	// a server error status. A mirror is either a base URL of the Github API,
+	// or a host of a Github Enterprise server. The public Github API is
+	// "github.com", and it is not used unless it is in the list. It applies
+	// to requests of Client, and not to git requests of Clone. The
+	// credentials of Client, and Username and Password, are not sent to the
+	// mirrors, other than the public Github API. Since the credentials of a
+	// custom transport can't be removed, Mirrors can be used only with a
+	// Client of an *http.Transport, an OAuth2 transport or a netrc transport.
+	Mirrors []string
+	// MirrorTokens are the tokens that authorize the requests to the
+	// mirrors, by the mirrors as they are given in Mirrors.
+	MirrorTokens map[string]string
+
+	// shared, if set, are the clients that are shared by all the filesystems
+	// of the configuration. See Share.
+	shared *sharedClients
+}
+
 type githubfs struct {
 	*project
+	Config
 	client     *github.Client
 	httpClient *http.Client
 	glob       glob.Patterns
+	// cloneURL is the git URL of the repository.
+	cloneURL string
+}
+
+// observeLoads reports the loads of the file contents in the given tree to
+// the observer and to OnFileLoad.
+func (fs *githubfs) observeLoads(t tree.Tree) {
+	if fs.Observer == nil && fs.OnFileLoad == nil {
+		return
+	}
+	t.SetLoadObserver(func(path string, d time.Duration, err error) {
+		if fs.Observer != nil {
+			fs.Observer.FileLoaded(d, err)
+		}
+		if fs.OnFileLoad != nil {
+			fs.OnFileLoad(path, d, err)
+		}
+	})
 }
 
 type treeGetter interface {
@@ -29,8 +202,14 @@ func Match(projectName string) bool {
 }
 
 // New returns a Tree for a given github project name.
-func New(ctx context.Context, client *http.Client, projectName string, prefetch bool, glob []string) (tree.Tree, error) {
-	fs, err := newGithubFS(ctx, client, projectName, glob)
+func New(ctx context.Context, projectName string, c Config) (tree.Tree, error) {
+	t, err := newTree(ctx, projectName, c)
+	// Errors of requests contain their URLs, which may be signed.
+	return t, log.RedactError(err)
+}
+
+func newTree(ctx context.Context, projectName string, c Config) (tree.Tree, error) {
+	fs, err := newGithubFS(ctx, projectName, c)
 	if err != nil {
 		return nil, err
 	}
@@ -38,47 +217,365 @@ func New(ctx context.Context, client *http.Client, projectName string, prefetch
 
 	// Log tree construction time.
 	defer func(start time.Time) {
-		log.Printf("Loaded project %q with %d files in %.1fs", projectName, len(t), time.Now().Sub(start).Seconds())
+		log.Info("Loaded project", "project", projectName, "files", len(t), "duration", time.Now().Sub(start))
 	}(time.Now())
 
 	var getter treeGetter
-	if prefetch {
-		g := getContents(*fs)
+	switch {
+	case fs.Offline:
+		g := getATree(*fs)
+		getter = &g
+	case fs.isReleases():
+		g := getReleaseAssets(*fs)
+		getter = &g
+	case fs.Clone:
+		g := getClone(*fs)
 		getter = &g
-	} else {
+	case fs.Prefetch && fs.GraphQL:
+		g := getGraphQL(*fs)
+		getter = &g
+	case fs.Prefetch:
+		g := getTarball(*fs)
+		getter = &g
+	default:
 		g := getATree(*fs)
 		getter = &g
 	}
-	return getter.get(ctx)
-}
-
-func newGithubFS(ctx context.Context, client *http.Client, projectName string, patterns []string) (*githubfs, error) {
-	g, err := glob.New(patterns...)
+	t, err = getter.get(ctx)
+	if _, ok := getter.(*getTarball); ok && err != nil && ctx.Err() == nil && !isTotalSizeError(err) {
+		// Fallback to downloading the files separately.
+		log.Warn("Failed prefetching tarball, using get-contents API instead", "error", err)
+		g := getContents(*fs)
+		t, err = g.get(ctx)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if fs.Filter != nil {
+		if err := t.Filter(fs.Filter); err != nil {
+			return nil, err
+		}
+	}
+	if err := fs.checkMaxFiles(countFiles(t)); err != nil {
+		return nil, err
+	}
+
+	if fs.ResolveSymlinks {
+		if err := t.ResolveSymlinks(ctx); err != nil {
+			return nil, errors.Wrap(err, "resolving symlinks")
+		}
+	}
+
+	if fs.CacheSize > 0 {
+		t.SetCache(tree.NewCache(fs.CacheSize))
+	}
+	fs.observeLoads(t)
+
+	// Commits and modification times are loaded lazily from the commits
+	// history. Release assets are not part of the history, and a clone and
+	// the offline mode do not use the Github API.
+	if fs.isReleases() || fs.Clone || fs.Offline {
+		return t, nil
+	}
+	project := fs.commitLoader("")
+	for path := range t {
+		if err := fs.setCommit(t, path, project); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// setCommit sets the loaders of the last commit and of the modification time
+// of the given path in the tree. The modification time is the date of the
+// given commit of the project, unless PathModTimes is set, such that serving
+// the files does not send a request per path.
+func (fs *githubfs) setCommit(t tree.Tree, path string, project tree.CommitLoader) error {
+	commit := project
+	if path != "" {
+		commit = fs.commitLoader(path)
+	}
+	if err := t.SetCommit(path, commit); err != nil {
+		return err
+	}
+	modTime := project
+	if fs.PathModTimes {
+		modTime = commit
+	}
+	return t.SetModTime(path, modTimeLoader(modTime))
+}
+
+// checkMaxFiles returns an error if the given number of files exceeds the
+// MaxFiles limit.
+func (fs *githubfs) checkMaxFiles(files int) error {
+	if fs.MaxFiles > 0 && files > fs.MaxFiles {
+		return errors.Errorf("project has more than %d files", fs.MaxFiles)
+	}
+	return nil
+}
+
+// excluded returns true if the Filter of the configuration filters out the
+// given path, with a file of the given size and mode.
+func (fs *githubfs) excluded(p string, size int64, mode os.FileMode) bool {
+	if fs.Filter == nil {
+		return false
+	}
+	return !fs.Filter(path.Join("/", p), entryInfo{name: path.Base(p), size: size, mode: mode})
+}
+
+// entryInfo is the info of an entry in a listing of the Github API, before
+// it is added to the tree.
+type entryInfo struct {
+	name string
+	size int64
+	mode os.FileMode
+}
+
+func (e entryInfo) Name() string       { return e.name }
+func (e entryInfo) Size() int64        { return e.size }
+func (e entryInfo) Mode() os.FileMode  { return e.mode }
+func (e entryInfo) ModTime() time.Time { return time.Time{} }
+func (e entryInfo) IsDir() bool        { return e.mode.IsDir() }
+func (e entryInfo) Sys() interface{}   { return nil }
+
+// prefetchCount counts the files that were prefetched and their total size.
+type prefetchCount struct {
+	files int
+	size  int64
+}
+
+// add adds a file of the given size, and returns an error if the MaxFiles or
+// MaxTotalSize limits of fs were exceeded.
+func (c *prefetchCount) add(fs *githubfs, size int64) error {
+	c.files++
+	c.size += size
+	return c.check(fs)
+}
+
+// check returns an error if the MaxFiles or MaxTotalSize limits of fs were
+// exceeded.
+func (c *prefetchCount) check(fs *githubfs) error {
+	if err := fs.checkMaxFiles(c.files); err != nil {
+		return err
+	}
+	return fs.checkTotalSize(c.size)
+}
+
+// TotalSizeError is returned when the total size of the prefetched content
+// exceeds the MaxTotalSize limit.
+type TotalSizeError struct {
+	// Limit is the MaxTotalSize limit in bytes.
+	Limit int64
+}
+
+func (e *TotalSizeError) Error() string {
+	return fmt.Sprintf("prefetched content is larger than %d bytes", e.Limit)
+}
+
+// checkTotalSize returns a *TotalSizeError if the given total size of
+// prefetched content exceeds the MaxTotalSize limit.
+func (fs *githubfs) checkTotalSize(size int64) error {
+	if fs.MaxTotalSize > 0 && size > fs.MaxTotalSize {
+		return &TotalSizeError{Limit: fs.MaxTotalSize}
+	}
+	return nil
+}
+
+func isTotalSizeError(err error) bool {
+	_, ok := errors.Cause(err).(*TotalSizeError)
+	return ok
+}
+
+// countFiles returns the number of files in a tree.
+func countFiles(t tree.Tree) int {
+	n := 0
+	for _, o := range t {
+		if st, err := o.Stat(); err == nil && !st.IsDir() {
+			n++
+		}
+	}
+	return n
+}
+
+// commitLoader returns a loader of the last commit that modified the given
+// path. A commit that was loaded successfully is not loaded again.
+func (fs *githubfs) commitLoader(path string) tree.CommitLoader {
+	var (
+		commit *tree.Commit
+		mu     sync.Mutex
+	)
+	return func(ctx context.Context) (*tree.Commit, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if commit == nil {
+			opt := &github.CommitsListOptions{
+				SHA:         fs.refName(),
+				Path:        strings.TrimSuffix(fs.path+path, "/"),
+				ListOptions: github.ListOptions{PerPage: 1},
+			}
+			commits, _, err := fs.client.Repositories.ListCommits(ctx, fs.owner, fs.repo, opt)
+			if err != nil {
+				return nil, errors.Wrapf(rateLimit(err), "list commits of %s", path)
+			}
+			if len(commits) == 0 {
+				return nil, errors.Errorf("no commits for %s", path)
+			}
+			c := commits[0]
+			commit = &tree.Commit{
+				SHA:     c.GetSHA(),
+				Author:  c.GetCommit().GetAuthor().GetName(),
+				Date:    c.GetCommit().GetCommitter().GetDate(),
+				Message: c.GetCommit().GetMessage(),
+			}
+		}
+		cp := *commit
+		return &cp, nil
+	}
+}
+
+// modTimeLoader returns a loader of the date of the given commit.
+func modTimeLoader(commit tree.CommitLoader) tree.TimeLoader {
+	return func(ctx context.Context) (time.Time, error) {
+		c, err := commit(ctx)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return c.Date, nil
+	}
+}
+
+// Share returns a configuration with the same settings, that its filesystems
+// share the HTTP client, with its rate limiting, retries and caching, and the
+// Github API client. A TreeCache is shared only if the configuration has one,
+// since the contents that it keeps are not bounded.
+func Share(c Config) Config {
+	client, githubClient := c.clients()
+	c.shared = &sharedClients{http: client, github: githubClient}
+	return c
+}
+
+// sharedClients are the clients of filesystems that share them.
+type sharedClients struct {
+	http   *http.Client
+	github *github.Client
+}
+
+// clients returns the HTTP client, with the configured transports, and the
+// Github API client that uses it, unless other clients are configured.
+func (c Config) clients() (*http.Client, *github.Client) {
+	if c.shared != nil {
+		return c.shared.http, c.shared.github
+	}
+	client := c.Client
 	if client == nil {
 		client = http.DefaultClient
 	}
+	if c.TLSConfig != nil {
+		client = withTLSConfig(client, c.TLSConfig)
+	}
+	if len(c.Mirrors) > 0 {
+		client = withoutMirrorCredentials(client, c.Mirrors)
+	}
+	if c.Observer != nil {
+		// Applied first, such that every sent request is observed.
+		client = withTransport(client, func(base http.RoundTripper) http.RoundTripper {
+			return &instrument.Transport{Base: base, Observer: c.Observer}
+		})
+	}
+	if c.RequestsPerSecond > 0 {
+		// Applied before the other transports, such that retries are limited
+		// as well.
+		interval := time.Duration(float64(time.Second) / c.RequestsPerSecond)
+		client = withTransport(client, func(base http.RoundTripper) http.RoundTripper {
+			return &throttleTransport{base: base, interval: interval}
+		})
+	}
+	if len(c.Mirrors) > 0 {
+		// Applied after the throttling, such that requests to each mirror
+		// are limited.
+		client = withTransport(client, func(base http.RoundTripper) http.RoundTripper {
+			return newMirrorTransport(base, c.Mirrors, c.MirrorTokens)
+		})
+	}
+	if c.UserAgent != "" {
+		client = withTransport(client, func(base http.RoundTripper) http.RoundTripper {
+			return &userAgentTransport{base: base, userAgent: c.UserAgent}
+		})
+	}
+	if c.Username != "" || c.Password != "" {
+		client = withTransport(client, func(base http.RoundTripper) http.RoundTripper {
+			return &basicAuthTransport{base: base, username: c.Username, password: c.Password}
+		})
+	}
+	client = withTransport(client, func(base http.RoundTripper) http.RoundTripper {
+		return &secondaryRateLimitTransport{base: base}
+	})
+	if c.ETags != nil {
+		client = c.ETags.client(client, c.Observer)
+	}
+	if c.WaitRateLimit {
+		client = withTransport(client, func(base http.RoundTripper) http.RoundTripper {
+			return &rateLimitTransport{base: base}
+		})
+	}
+	githubClient := c.GithubClient
+	if githubClient == nil {
+		githubClient = github.NewClient(client)
+	}
+	return client, githubClient
+}
+
+func newGithubFS(ctx context.Context, projectName string, c Config) (*githubfs, error) {
+	if err := c.checkMirrorCredentials(); err != nil {
+		return nil, err
+	}
+	g, err := glob.New(c.Glob...)
+	if err != nil {
+		return nil, err
+	}
 	project, err := newProject(projectName)
 	if err != nil {
 		return nil, err
 	}
 
+	client, githubClient := c.clients()
 	fs := &githubfs{
 		project:    project,
-		client:     github.NewClient(client),
+		Config:     c,
+		client:     githubClient,
 		httpClient: client,
 		glob:       g,
+		cloneURL:   fmt.Sprintf("https://github.com/%s/%s.git", project.owner, project.repo),
 	}
 
-	// Set ref to default branch in case it is empty.
-	if fs.ref == "" {
+	if c.Offline {
+		if err := fs.checkOffline(); err != nil {
+			return nil, err
+		}
+		return fs, nil
+	}
+
+	// Set ref to default branch in case it is empty. A clone uses the default
+	// branch of the remote repository.
+	if fs.ref == "" && !c.Clone {
 		repo, _, err := fs.client.Repositories.Get(ctx, fs.owner, fs.repo)
 		if err != nil {
-			return nil, errors.Wrap(err, "get git repository")
+			return nil, errors.Wrap(rateLimit(err), "get git repository")
 		}
 		fs.ref = "heads/" + repo.GetDefaultBranch()
+		if c.DiskCache != nil {
+			// Stored for resolving the default branch in offline mode.
+			fs.storeRef("HEAD", fs.ref)
+		}
+	}
+
+	if c.Clone && (fs.hasRevision() || fs.isCommit()) {
+		return nil, errors.New("revisions and commit refs are not supported with a clone")
+	}
+	if fs.hasRevision() {
+		if err := fs.resolveRevision(ctx); err != nil {
+			return nil, err
+		}
 	}
 	return fs, nil
 }
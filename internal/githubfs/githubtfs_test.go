@@ -1,14 +1,23 @@
 package githubfs
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
 	"github.com/posener/gitfs/internal/testfs"
+	"github.com/posener/gitfs/internal/tree"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
@@ -44,6 +53,468 @@ func TestNewWithGlob(t *testing.T) {
 	}
 }
 
+func TestNewKeepEmptyDirs(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		keepEmptyDirs bool
+		wantD1        bool
+	}{
+		{keepEmptyDirs: false, wantD1: false},
+		{keepEmptyDirs: true, wantD1: true},
+	}
+	for _, tt := range tests {
+		fs, err := New(context.Background(), "github.com/x/y", Config{
+			Client:        mockClient(),
+			Glob:          []string{"d2/*.txt"},
+			KeepEmptyDirs: tt.keepEmptyDirs,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, tt.wantD1, fs["d1"] != nil)
+		assert.Nil(t, fs["d1/f"])
+		assert.NotNil(t, fs["d2/f.txt"])
+	}
+}
+
+func TestNewGithubClient(t *testing.T) {
+	t.Parallel()
+	fs, err := New(context.Background(), "github.com/x/y", Config{
+		GithubClient: github.NewClient(mockClient()),
+	})
+	require.NoError(t, err)
+	assert.NotNil(t, fs["d2/f.txt"])
+}
+
+func TestNewMaxFiles(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		graphQL  bool
+		maxFiles int
+		wantErr  bool
+	}{
+		{name: "tree", maxFiles: 1, wantErr: true},
+		{name: "tree", maxFiles: 2},
+		{name: "graphql", graphQL: true, maxFiles: 2, wantErr: true},
+		{name: "graphql", graphQL: true, maxFiles: 3},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.name, tt.maxFiles), func(t *testing.T) {
+			_, err := New(context.Background(), "github.com/x/y", Config{
+				Client:   mockClient(),
+				Prefetch: tt.graphQL,
+				GraphQL:  tt.graphQL,
+				MaxFiles: tt.maxFiles,
+			})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewMaxTotalSize(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		graphQL      bool
+		maxTotalSize int64
+		wantErr      bool
+	}{
+		{name: "tarball", maxTotalSize: 33, wantErr: true},
+		{name: "tarball", maxTotalSize: 34},
+		{name: "graphql", graphQL: true, maxTotalSize: 1, wantErr: true},
+		{name: "graphql", graphQL: true, maxTotalSize: 100},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.name, tt.maxTotalSize), func(t *testing.T) {
+			_, err := New(context.Background(), "github.com/x/y", Config{
+				Client:       mockClient(),
+				Prefetch:     true,
+				GraphQL:      tt.graphQL,
+				MaxTotalSize: tt.maxTotalSize,
+			})
+			if tt.wantErr {
+				require.Error(t, err)
+				sizeErr, ok := errors.Cause(err).(*TotalSizeError)
+				require.True(t, ok, "got %T: %s", err, err)
+				assert.Equal(t, tt.maxTotalSize, sizeErr.Limit)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewLazy(t *testing.T) {
+	t.Parallel()
+	fs, err := NewLazy(context.Background(), "github.com/x/y", Config{Client: mockClient()})
+	require.NoError(t, err)
+	l := fs.(*lazyDirs)
+	assert.Empty(t, l.loaded)
+
+	f, err := fs.Open("d2/f.txt")
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(got))
+	// Only the directories in the path of the file were loaded.
+	assert.Equal(t, map[string]bool{"": true, "d2": true}, l.loaded)
+
+	d, err := fs.Open("/")
+	require.NoError(t, err)
+	files, err := d.Readdir(0)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	_, err = fs.Open("d2/nope")
+	assert.True(t, os.IsNotExist(err))
+	_, err = fs.Open("d3/f.txt")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestNewSubtree(t *testing.T) {
+	t.Parallel()
+
+	counter := &countingTransport{base: &mockTransport{}, counts: make(map[string]int)}
+	c := Config{Client: &http.Client{Transport: counter}}
+
+	// Only the tree of the project path is fetched.
+	fs, err := New(context.Background(), "github.com/x/y/d2", c)
+	require.NoError(t, err)
+	f, err := fs.Open("f.txt")
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(got))
+	assert.Equal(t, 1, counter.count("/repos/x/y/git/trees/master:d2"))
+	assert.Equal(t, 0, counter.count("/repos/x/y/git/trees/heads/master"))
+
+	// A path that is not a directory falls back to the tree of the
+	// repository.
+	fs, err = New(context.Background(), "github.com/x/y/nope", c)
+	require.NoError(t, err)
+	d, err := fs.Open("/")
+	require.NoError(t, err)
+	files, err := d.Readdir(0)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	assert.Equal(t, 1, counter.count("/repos/x/y/git/trees/heads/master"))
+}
+
+func TestNewStreamThreshold(t *testing.T) {
+	t.Parallel()
+	for _, threshold := range []int64{0, 1, 2} {
+		fs, err := New(context.Background(), "github.com/x/y", Config{
+			Client:          mockClient(),
+			StreamThreshold: threshold,
+		})
+		require.NoError(t, err)
+		f, err := fs.Open("d2/f.txt")
+		require.NoError(t, err)
+		got, err := ioutil.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, "x", string(got))
+	}
+}
+
+func TestNewGraphQL(t *testing.T) {
+	t.Parallel()
+	fs, err := New(context.Background(), "github.com/x/y", Config{
+		Client:   mockClient(),
+		Prefetch: true,
+		GraphQL:  true,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		path    string
+		content string
+		mode    os.FileMode
+	}{
+		{path: "link", content: "d1/f", mode: os.ModeSymlink},
+		{path: "d1/f", content: "f", mode: 0755},
+		{path: "d1/bin", content: "x", mode: 0644},
+	}
+	for _, tt := range tests {
+		f, err := fs.Open(tt.path)
+		require.NoError(t, err)
+		st, err := f.Stat()
+		require.NoError(t, err)
+		assert.Equal(t, tt.mode, st.Mode())
+		got, err := ioutil.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, tt.content, string(got))
+	}
+}
+
+func TestNewGraphQL_notFound(t *testing.T) {
+	t.Parallel()
+	_, err := New(context.Background(), "github.com/x/y/nosuchdir", Config{
+		Client:   mockClient(),
+		Prefetch: true,
+		GraphQL:  true,
+	})
+	assert.Error(t, err)
+}
+
+func TestNewFilter(t *testing.T) {
+	t.Parallel()
+	filter := func(path string, info os.FileInfo) bool { return path != "/d1" }
+	tests := []struct {
+		name string
+		new  func(context.Context, string, Config) (http.FileSystem, error)
+	}{
+		{name: "tree", new: func(ctx context.Context, project string, c Config) (http.FileSystem, error) {
+			return New(ctx, project, c)
+		}},
+		{name: "lazy", new: NewLazy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs, err := tt.new(context.Background(), "github.com/x/y", Config{Client: mockClient(), Filter: filter})
+			require.NoError(t, err)
+			// The content of a directory that is filtered out is removed.
+			_, err = fs.Open("d1/f")
+			assert.True(t, os.IsNotExist(err))
+			_, err = fs.Open("d1")
+			assert.True(t, os.IsNotExist(err))
+			_, err = fs.Open("d2/f.txt")
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Run("graphql", func(t *testing.T) {
+		counter := &countingTransport{base: &mockTransport{}, counts: make(map[string]int)}
+		fs, err := New(context.Background(), "github.com/x/y", Config{
+			Client:   &http.Client{Transport: counter},
+			Prefetch: true,
+			GraphQL:  true,
+			Filter:   func(path string, info os.FileInfo) bool { return path != "/d1/bin" },
+		})
+		require.NoError(t, err)
+		_, err = fs.Open("d1/bin")
+		assert.True(t, os.IsNotExist(err))
+		_, err = fs.Open("d1/f")
+		assert.NoError(t, err)
+		// The content of the file that is filtered out was not downloaded.
+		assert.Equal(t, 0, counter.count("/repos/x/y/git/blobs/s1"))
+	})
+}
+
+func TestNewTarball(t *testing.T) {
+	t.Parallel()
+	counter := &countingTransport{base: &mockTransport{}, counts: make(map[string]int)}
+	fs, err := New(context.Background(), "github.com/x/y/static", Config{
+		Client:   &http.Client{Transport: counter},
+		Prefetch: true,
+		Glob:     []string{"*", "d/*.txt"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		path    string
+		content string
+		mode    os.FileMode
+	}{
+		{path: "file", content: "file", mode: 0644},
+		{path: "run.sh", content: "#!/bin/sh", mode: 0755},
+		{path: "link", content: "file", mode: os.ModeSymlink},
+		{path: "d/a.txt", content: "a", mode: 0644},
+		// An export-ignore file, which is missing from the tarball.
+		{path: "ignored", content: "ignored", mode: 0644},
+		// An export-subst file, which is rewritten in the tarball.
+		{path: "version", content: "version: $Format:%H$", mode: 0644},
+	}
+	for _, tt := range tests {
+		f, err := fs.Open(tt.path)
+		require.NoError(t, err)
+		st, err := f.Stat()
+		require.NoError(t, err)
+		assert.Equal(t, tt.mode, st.Mode())
+		got, err := ioutil.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, tt.content, string(got))
+		assert.Equal(t, hash(tt.content), fs[tt.path].(interface{ Hash() string }).Hash())
+	}
+	assert.Nil(t, fs["d/b.md"])
+	assert.Nil(t, fs["README.md"])
+
+	// Only the files that are missing or differ in the tarball were loaded
+	// from their blobs.
+	assert.Equal(t, 0, counter.count("/repos/x/y/git/blobs/"+hash("file")))
+	assert.Equal(t, 1, counter.count("/repos/x/y/git/blobs/"+hash("ignored")))
+	assert.Equal(t, 1, counter.count("/repos/x/y/git/blobs/"+hash("version: $Format:%H$")))
+}
+
+func hash(content string) string {
+	return blobHash([]byte(content))
+}
+
+func TestGetContents(t *testing.T) {
+	t.Parallel()
+	fs, err := newGithubFS(context.Background(), "github.com/x/y", Config{Client: mockClient()})
+	require.NoError(t, err)
+	g := getContents(*fs)
+	got, err := g.get(context.Background())
+	require.NoError(t, err)
+
+	tests := []struct {
+		path    string
+		content string
+	}{
+		{path: "small", content: "small"},
+		// Download URL is missing, content is fetched using the blob API.
+		{path: "large", content: "x"},
+	}
+	for _, tt := range tests {
+		f, err := got.Open(tt.path)
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, tt.content, string(content))
+	}
+}
+
+func TestGetContents_manyEntries(t *testing.T) {
+	t.Parallel()
+	fs, err := newGithubFS(context.Background(), "github.com/x/y/many", Config{Client: mockClient()})
+	require.NoError(t, err)
+	g := getContents(*fs)
+	got, err := g.get(context.Background())
+	require.NoError(t, err)
+
+	d, err := got.Open("")
+	require.NoError(t, err)
+	files, err := d.Readdir(0)
+	require.NoError(t, err)
+	assert.Len(t, files, maxContentsEntries+1)
+
+	f, err := got.Open(fmt.Sprintf("f%d", maxContentsEntries))
+	require.NoError(t, err)
+	content, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(content))
+}
+
+func TestGetContents_maxFiles(t *testing.T) {
+	t.Parallel()
+	fs, err := newGithubFS(context.Background(), "github.com/x/y/many", Config{Client: mockClient(), MaxFiles: 10})
+	require.NoError(t, err)
+	g := getContents(*fs)
+	_, err = g.get(context.Background())
+	assert.Error(t, err)
+}
+
+func TestGetContents_cancelOnError(t *testing.T) {
+	t.Parallel()
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case "/repos/x/y/contents/d/":
+			body := `[
+				{"type":"file","path":"d/slow","size":1,"sha":"s1","download_url":"https://raw.example.com/slow"},
+				{"type":"file","path":"d/fail","size":1,"sha":"s1","download_url":"https://raw.example.com/fail"}
+			]`
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     make(http.Header),
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		case "/slow":
+			// Blocks until the download is canceled by the failure of the
+			// other file, which is downloaded concurrently.
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}
+		return (&mockTransport{}).RoundTrip(req)
+	})}
+	fs, err := newGithubFS(context.Background(), "github.com/x/y/d", Config{Client: client})
+	require.NoError(t, err)
+	g := getContents(*fs)
+	_, err = g.get(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "fail")
+}
+
+// mockTarball returns a gzipped tarball of a repository, in the structure
+// that Github returns.
+func mockTarball() []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gz)
+	write := func(h *tar.Header, content string) {
+		h.Size = int64(len(content))
+		w.WriteHeader(h)
+		w.Write([]byte(content))
+	}
+	write(&tar.Header{Name: "pax_global_header", Typeflag: tar.TypeXGlobalHeader, PAXRecords: map[string]string{"comment": "sha"}}, "")
+	write(&tar.Header{Name: "x-y-sha/", Typeflag: tar.TypeDir, Mode: 0775}, "")
+	write(&tar.Header{Name: "x-y-sha/README.md", Typeflag: tar.TypeReg, Mode: 0664}, "readme")
+	write(&tar.Header{Name: "x-y-sha/static/", Typeflag: tar.TypeDir, Mode: 0775}, "")
+	write(&tar.Header{Name: "x-y-sha/static/file", Typeflag: tar.TypeReg, Mode: 0664}, "file")
+	write(&tar.Header{Name: "x-y-sha/static/run.sh", Typeflag: tar.TypeReg, Mode: 0775}, "#!/bin/sh")
+	write(&tar.Header{Name: "x-y-sha/static/link", Typeflag: tar.TypeSymlink, Linkname: "file", Mode: 0777}, "")
+	write(&tar.Header{Name: "x-y-sha/static/d/", Typeflag: tar.TypeDir, Mode: 0775}, "")
+	write(&tar.Header{Name: "x-y-sha/static/d/a.txt", Typeflag: tar.TypeReg, Mode: 0664}, "a")
+	write(&tar.Header{Name: "x-y-sha/static/d/b.md", Typeflag: tar.TypeReg, Mode: 0664}, "b")
+	write(&tar.Header{Name: "x-y-sha/static/version", Typeflag: tar.TypeReg, Mode: 0664}, "version: 1a2b")
+	w.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestNewReleaseAssets(t *testing.T) {
+	t.Parallel()
+	for _, prefetch := range []bool{false, true} {
+		fs, err := New(context.Background(), "github.com/x/y/releases@v1.0.0", Config{
+			Client:   mockClient(),
+			Prefetch: prefetch,
+			Glob:     []string{"*.tar.gz"},
+		})
+		require.NoError(t, err)
+
+		f, err := fs.Open("app.tar.gz")
+		require.NoError(t, err)
+		st, err := f.Stat()
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC), st.ModTime())
+		got, err := ioutil.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, "app", string(got))
+
+		_, err = fs.Open("app.zip")
+		assert.Error(t, err)
+	}
+}
+
+func TestNewReleaseAssets_noRelease(t *testing.T) {
+	t.Parallel()
+	_, err := New(context.Background(), "github.com/x/y/releases@v2.0.0", Config{Client: mockClient()})
+	assert.Error(t, err)
+}
+
+func TestProjectIsReleases(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		project string
+		want    bool
+	}{
+		{project: "github.com/x/y/releases@v1.0.0", want: true},
+		{project: "github.com/x/y/releases@tags/latest", want: true},
+		{project: "github.com/x/y/releases", want: false},
+		{project: "github.com/x/y/releases@heads/master", want: false},
+		{project: "github.com/x/y/releases/a@v1.0.0", want: false},
+		{project: "github.com/x/y@v1.0.0", want: false},
+	}
+	for _, tt := range tests {
+		p, err := newProject(tt.project)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, p.isReleases(), tt.project)
+	}
+}
+
 type contexter interface {
 	WithContext(context.Context) http.File
 }
@@ -68,11 +539,81 @@ func TestOpen_cancelledContext(t *testing.T) {
 
 func TestNewGithubProject(t *testing.T) {
 	t.Parallel()
-	p, err := newGithubFS(context.Background(), mockClient(), "github.com/x/y", nil)
+	p, err := newGithubFS(context.Background(), "github.com/x/y", Config{Client: mockClient()})
 	require.NoError(t, err)
 	assert.Equal(t, "heads/master", p.ref)
 }
 
+func TestCommitLoader(t *testing.T) {
+	t.Parallel()
+	fs, err := newGithubFS(context.Background(), "github.com/x/y/static", Config{Client: mockClient()})
+	require.NoError(t, err)
+
+	date := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	commit := fs.commitLoader("file")
+	got, err := commit(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &tree.Commit{SHA: "abc", Author: "Gopher", Date: date, Message: "Add file"}, got)
+
+	gotTime, err := modTimeLoader(commit)(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, date, gotTime)
+
+	_, err = fs.commitLoader("nosuchfile")(context.Background())
+	assert.Error(t, err)
+	_, err = modTimeLoader(fs.commitLoader("nosuchfile"))(context.Background())
+	assert.Error(t, err)
+}
+
+func TestSetCommit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		pathModTimes bool
+		want         time.Time
+		requests     int
+	}{
+		// The modification times of all the paths are of the project.
+		{want: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), requests: 1},
+		{pathModTimes: true, want: time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC), requests: 2},
+	}
+	for _, tt := range tests {
+		counter := &countingTransport{base: &mockTransport{}, counts: make(map[string]int)}
+		fs, err := newGithubFS(context.Background(), "github.com/x/y/static", Config{
+			Client:       &http.Client{Transport: counter},
+			PathModTimes: tt.pathModTimes,
+		})
+		require.NoError(t, err)
+		tr := make(tree.Tree)
+		require.NoError(t, tr.AddFileContent("file", []byte("a")))
+		project := fs.commitLoader("")
+		require.NoError(t, fs.setCommit(tr, "", project))
+		require.NoError(t, fs.setCommit(tr, "file", project))
+
+		assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), modTime(t, tr, "/"))
+		assert.Equal(t, tt.want, modTime(t, tr, "file"))
+		assert.Equal(t, tt.requests, counter.count("/repos/x/y/commits"))
+	}
+}
+
+func modTime(t *testing.T, fs http.FileSystem, path string) time.Time {
+	t.Helper()
+	f, err := fs.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	st, err := f.Stat()
+	require.NoError(t, err)
+	return st.ModTime()
+}
+
+func TestFileMode(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, os.FileMode(0644), fileMode("100644"))
+	assert.Equal(t, os.FileMode(0755), fileMode("100755"))
+	assert.Equal(t, os.ModeSymlink, fileMode("120000"))
+	assert.Equal(t, os.FileMode(0), fileMode(""))
+	assert.Equal(t, os.FileMode(0), fileMode("x"))
+}
+
 func testFileSystemNoPrefetch(t *testing.T, project string) (http.FileSystem, error) {
 	return testFilesystem(t, project, false, nil)
 }
@@ -87,7 +628,7 @@ func testFilesystem(t *testing.T, project string, prefetch bool, glob []string)
 		t.Skip("no github token provided")
 	}
 	c := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
-	return New(context.Background(), c, project, prefetch, glob)
+	return New(context.Background(), project, Config{Client: c, Prefetch: prefetch, Glob: glob})
 }
 
 func mockClient() *http.Client {
@@ -105,6 +646,194 @@ func (*mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 			Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"default_branch":"master"}`))),
 			Request:    req,
 		}, nil
+	case req.Method == http.MethodGet && req.URL.Path == "/repos/x/y/commits":
+		body := `[]`
+		switch {
+		case req.URL.Query().Get("sha") == "master" && req.URL.Query().Get("path") == "static/file":
+			body = `[{"sha":"abc","commit":{"author":{"name":"Gopher"},"committer":{"date":"2019-01-02T03:04:05Z"},"message":"Add file"}}]`
+		case req.URL.Query().Get("sha") == "master" && req.URL.Query().Get("path") == "static":
+			body = `[{"sha":"def","commit":{"author":{"name":"Gopher"},"committer":{"date":"2020-01-02T03:04:05Z"},"message":"Update"}}]`
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+			Request:    req,
+		}, nil
+	case req.Method == http.MethodGet && req.URL.Path == "/repos/x/y/commits/master":
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte("c1"))),
+			Request:    req,
+		}, nil
+	case req.Method == http.MethodGet && (req.URL.Path == "/repos/x/y/git/trees/heads/master" || req.URL.Path == "/repos/x/y/git/trees/c1"):
+		body := `{"tree":[
+			{"path":"d1","type":"tree"},
+			{"path":"d1/f","type":"blob","mode":"100644","size":1},
+			{"path":"d2","type":"tree"},
+			{"path":"d2/f.txt","type":"blob","mode":"100644","size":1,"sha":"s1"}
+		]}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+			Request:    req,
+		}, nil
+	case req.Method == http.MethodGet && req.URL.Path == "/repos/x/y/git/trees/master:":
+		body := `{"tree":[
+			{"path":"d1","type":"tree"},
+			{"path":"d2","type":"tree"}
+		]}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+			Request:    req,
+		}, nil
+	case req.Method == http.MethodGet && req.URL.Path == "/repos/x/y/git/trees/master:d2":
+		body := `{"tree":[
+			{"path":"f.txt","type":"blob","mode":"100644","size":1,"sha":"s1"}
+		]}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+			Request:    req,
+		}, nil
+	case req.Method == http.MethodGet && req.URL.Path == "/repos/x/y/git/trees/master:static":
+		// The tree of the tarball, in which "ignored" has the export-ignore
+		// attribute and "version" has the export-subst attribute.
+		var entries []string
+		for _, e := range []struct{ path, mode, content string }{
+			{"file", "100644", "file"},
+			{"run.sh", "100755", "#!/bin/sh"},
+			{"link", "120000", "file"},
+			{"d/a.txt", "100644", "a"},
+			{"d/b.md", "100644", "b"},
+			{"ignored", "100644", "ignored"},
+			{"version", "100644", "version: $Format:%H$"},
+		} {
+			entries = append(entries, fmt.Sprintf(`{"path":%q,"type":"blob","mode":%q,"size":%d,"sha":%q}`, e.path, e.mode, len(e.content), hash(e.content)))
+		}
+		entries = append(entries, `{"path":"d","type":"tree"}`)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(strings.NewReader(`{"tree":[` + strings.Join(entries, ",") + "]}")),
+			Request:    req,
+		}, nil
+	case req.Method == http.MethodGet && (req.URL.Path == "/repos/x/y/git/blobs/"+hash("ignored") || req.URL.Path == "/repos/x/y/git/blobs/"+hash("version: $Format:%H$")):
+		content := "ignored"
+		if strings.HasSuffix(req.URL.Path, hash("version: $Format:%H$")) {
+			content = "version: $Format:%H$"
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(strings.NewReader(content)),
+			Request:    req,
+		}, nil
+	case req.Method == http.MethodGet && req.URL.Path == "/repos/x/y/git/blobs/s1":
+		if req.Header.Get("Accept") != mediaTypeRaw {
+			return nil, fmt.Errorf("blob should be requested in raw media type")
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte("x"))),
+			Request:    req,
+		}, nil
+	case req.Method == http.MethodGet && req.URL.Path == "/repos/x/y/contents/":
+		body := `[
+			{"type":"file","path":"small","size":5,"sha":"s4","download_url":"https://raw.example.com/x/y/master/small"},
+			{"type":"file","path":"large","size":1,"sha":"s1","download_url":null}
+		]`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+			Request:    req,
+		}, nil
+	case req.Method == http.MethodGet && req.URL.Path == "/repos/x/y/contents/many/":
+		// The get-contents API truncates the listing.
+		entries := make([]string, maxContentsEntries)
+		for i := range entries {
+			entries[i] = fmt.Sprintf(`{"type":"file","path":"many/f%d","size":1,"sha":"s1"}`, i)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(strings.NewReader("[" + strings.Join(entries, ",") + "]")),
+			Request:    req,
+		}, nil
+	case req.Method == http.MethodGet && req.URL.Path == "/repos/x/y/git/trees/master:many":
+		entries := make([]string, maxContentsEntries+1)
+		for i := range entries {
+			entries[i] = fmt.Sprintf(`{"path":"f%d","type":"blob","mode":"100644","size":1,"sha":"s1"}`, i)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(strings.NewReader(`{"tree":[` + strings.Join(entries, ",") + "]}")),
+			Request:    req,
+		}, nil
+	case req.Method == http.MethodGet && req.URL.Host == "raw.example.com" && req.URL.Path == "/x/y/master/small":
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte("small"))),
+			Request:    req,
+		}, nil
+	case req.Method == http.MethodPost && req.URL.Path == "/graphql":
+		var q graphQLRequest
+		if err := json.NewDecoder(req.Body).Decode(&q); err != nil {
+			return nil, err
+		}
+		body := `{"data":{"repository":{"object":null}}}`
+		switch q.Variables["expression"] {
+		case "refs/heads/master:":
+			body = `{"data":{"repository":{"object":{"entries":[
+				{"name":"d1","type":"tree","mode":16384,"object":{}},
+				{"name":"link","type":"blob","mode":40960,"object":{"oid":"s2","byteSize":4,"text":"d1/f"}}
+			]}}}}`
+		case "refs/heads/master:d1":
+			body = `{"data":{"repository":{"object":{"entries":[
+				{"name":"f","type":"blob","mode":33261,"object":{"oid":"s3","byteSize":1,"text":"f"}},
+				{"name":"bin","type":"blob","mode":33188,"object":{"oid":"s1","byteSize":1,"text":null}}
+			]}}}}`
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+			Request:    req,
+		}, nil
+	case req.Method == http.MethodGet && req.URL.Path == "/repos/x/y/tarball/master":
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(bytes.NewReader(mockTarball())),
+			Request:    req,
+		}, nil
+	case req.Method == http.MethodGet && req.URL.Path == "/repos/x/y/releases/tags/v1.0.0":
+		body := `{"assets":[
+			{"id":1,"name":"app.tar.gz","size":3,"updated_at":"2019-01-02T03:04:05Z"},
+			{"id":2,"name":"app.zip","size":3,"updated_at":"2019-01-02T03:04:05Z"}
+		]}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+			Request:    req,
+		}, nil
+	case req.Method == http.MethodGet && req.URL.Path == "/repos/x/y/releases/assets/1":
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte("app"))),
+			Request:    req,
+		}, nil
 	default:
 		return &http.Response{
 			StatusCode: http.StatusNotFound,
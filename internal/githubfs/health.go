@@ -0,0 +1,56 @@
+package githubfs
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// unauthenticatedLimit is the hourly rate limit of the Github API for
+// requests that are not authenticated.
+const unauthenticatedLimit = 60
+
+// Health is the state of the access to the Github API with a configuration.
+type Health struct {
+	// Authenticated is true if the requests are authenticated, which is
+	// deduced from a rate limit that is higher than the rate limit of
+	// unauthenticated requests.
+	Authenticated bool
+	// Limit is the number of requests that are allowed per hour.
+	Limit int
+	// Remaining is the number of requests that remain in the current rate
+	// limit window.
+	Remaining int
+	// Reset is the time in which the current rate limit window resets.
+	Reset time.Time
+}
+
+// HealthCheck checks the access to the Github API using the rate limit API,
+// which does not count against the rate limit. It fails if the credentials
+// of the client are invalid, and returns a *RateLimitError, with the health,
+// if no requests remain. In offline mode it returns an *OfflineError.
+func HealthCheck(ctx context.Context, c Config) (*Health, error) {
+	if c.Offline {
+		return nil, &OfflineError{What: "rate limit"}
+	}
+	if err := c.checkMirrorCredentials(); err != nil {
+		return nil, err
+	}
+	_, client := c.clients()
+	limits, _, err := client.RateLimits(ctx)
+	if err != nil {
+		return nil, errors.Wrap(rateLimit(err), "get rate limit")
+	}
+	core := limits.GetCore()
+	h := &Health{
+		Authenticated: core.Limit > unauthenticatedLimit,
+		Limit:         core.Limit,
+		Remaining:     core.Remaining,
+		Reset:         core.Reset.Time,
+	}
+	if h.Remaining == 0 {
+		return h, &RateLimitError{Limit: h.Limit, Remaining: h.Remaining, Reset: h.Reset}
+	}
+	return h, nil
+}
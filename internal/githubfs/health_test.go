@@ -0,0 +1,58 @@
+package githubfs
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthCheck(t *testing.T) {
+	t.Parallel()
+	reset := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	client := func(status int, body string) *http.Client {
+		return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path != "/rate_limit" {
+				status, body = http.StatusNotFound, `{}`
+			}
+			return &http.Response{
+				StatusCode: status,
+				Header:     make(http.Header),
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		})}
+	}
+	ctx := context.Background()
+
+	h, err := HealthCheck(ctx, Config{Client: client(http.StatusOK,
+		`{"resources":{"core":{"limit":5000,"remaining":4999,"reset":1577934245}}}`)})
+	require.NoError(t, err)
+	assert.True(t, h.Authenticated)
+	assert.Equal(t, 5000, h.Limit)
+	assert.Equal(t, 4999, h.Remaining)
+	assert.True(t, reset.Equal(h.Reset), "got: %v", h.Reset)
+
+	h, err = HealthCheck(ctx, Config{Client: client(http.StatusOK,
+		`{"resources":{"core":{"limit":60,"remaining":0,"reset":1577934245}}}`)})
+	var rl *RateLimitError
+	require.True(t, errors.As(err, &rl), "got: %v", err)
+	assert.Equal(t, 60, rl.Limit)
+	assert.False(t, h.Authenticated)
+
+	_, err = HealthCheck(ctx, Config{Client: client(http.StatusUnauthorized, `{"message":"Bad credentials"}`)})
+	assert.Error(t, err)
+}
+
+func TestHealthCheck_offline(t *testing.T) {
+	t.Parallel()
+	_, err := HealthCheck(context.Background(), Config{Offline: true})
+	var offline *OfflineError
+	assert.True(t, errors.As(err, &offline), "got: %v", err)
+}
@@ -0,0 +1,143 @@
+package githubfs
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/log"
+	"github.com/posener/gitfs/internal/tree"
+)
+
+// lazyDirs is a filesystem of a Github project, in which the listing of each
+// directory is loaded only when the directory, or a path in it, is first
+// opened. The listing is loaded using Github's get-a-tree API, without
+// recursion. It implements http.FileSystem.
+type lazyDirs struct {
+	fs *githubfs
+	// project loads the last commit of the project, see githubfs.setCommit.
+	project tree.CommitLoader
+	tree    tree.Tree
+	cache   *tree.Cache
+	loaded  map[string]bool
+	mu      sync.Mutex
+}
+
+// NewLazy returns a filesystem for a given github project name, that loads
+// directory listings lazily, when they are first accessed. It does not load
+// the structure of the project when it is created, which is faster for
+// filesystems that are used sparsely. The Prefetch, GraphQL, ResolveSymlinks
+// and MaxFiles configurations do not apply.
+func NewLazy(ctx context.Context, projectName string, c Config) (http.FileSystem, error) {
+	p, err := newProject(projectName)
+	if err != nil {
+		return nil, err
+	}
+	if p.isReleases() || c.Offline {
+		// Release assets are listed in a single request, and the offline mode
+		// loads the stored tree of the whole repository.
+		return New(ctx, projectName, c)
+	}
+	fs, err := newGithubFS(ctx, projectName, c)
+	if err != nil {
+		return nil, err
+	}
+	l := &lazyDirs{
+		fs:      fs,
+		project: fs.commitLoader(""),
+		tree:    make(tree.Tree),
+		loaded:  make(map[string]bool),
+	}
+	if c.CacheSize > 0 {
+		l.cache = tree.NewCache(c.CacheSize)
+	}
+	if err := l.tree.AddDir(""); err != nil {
+		return nil, err
+	}
+	if err := fs.setCommit(l.tree, "", l.project); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Open is the implementation of http.FileSystem. It loads the listings of all
+// the directories in the given path, and of the path itself if it is a
+// directory, before opening it.
+func (l *lazyDirs) Open(name string) (http.File, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	dir := ""
+	if err := l.load(dir); err != nil {
+		return nil, err
+	}
+	p := strings.Trim(name, "/")
+	if p != "" {
+		for _, part := range strings.Split(p, "/") {
+			dir = path.Join(dir, part)
+			o := l.tree[dir]
+			if o == nil {
+				break
+			}
+			if st, err := o.Stat(); err != nil || !st.IsDir() {
+				break
+			}
+			if err := l.load(dir); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return l.tree.Open(name)
+}
+
+// load adds the entries of the given directory to the tree, if they were not
+// added yet.
+func (l *lazyDirs) load(dir string) error {
+	if l.loaded[dir] {
+		return nil
+	}
+	log.Debug("Using Github get-a-tree API", "path", l.fs.path+dir)
+	ctx := context.Background()
+	expr := l.fs.escapedRefName() + ":" + strings.TrimSuffix(l.fs.path+dir, "/")
+	gitTree, _, err := l.fs.client.Git.GetTree(ctx, l.fs.owner, l.fs.repo, expr, false)
+	if err != nil {
+		return errors.Wrapf(rateLimit(err), "get git tree of %s", dir)
+	}
+
+	g := getATree(*l.fs)
+	for _, entry := range gitTree.Entries {
+		p := path.Join(dir, entry.GetPath())
+		if err := g.add(l.tree, p, entry); err != nil {
+			return errors.Wrapf(err, "adding %s", p)
+		}
+		if l.tree[p] == nil {
+			// Entry did not match the glob patterns.
+			continue
+		}
+		if l.fs.Filter != nil {
+			info, err := l.tree[p].Stat()
+			if err != nil {
+				return errors.Wrapf(err, "stat %s", p)
+			}
+			if !l.fs.Filter("/"+p, info) {
+				// A directory that is filtered out is never loaded.
+				if err := l.tree.Remove(p); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err := l.fs.setCommit(l.tree, p, l.project); err != nil {
+			return err
+		}
+	}
+	if l.cache != nil {
+		l.tree.SetCache(l.cache)
+	}
+	l.fs.observeLoads(l.tree)
+	l.loaded[dir] = true
+	return nil
+}
@@ -0,0 +1,213 @@
+package githubfs
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/log"
+	"github.com/posener/gitfs/internal/netrc"
+	"golang.org/x/oauth2"
+)
+
+// githubAPI is the base URL of the Github API, that the requests to it are
+// sent to the mirrors instead.
+const githubAPI = "https://api.github.com/"
+
+// githubAPIHost is the host of the Github API.
+const githubAPIHost = "api.github.com"
+
+// mirrorURL returns the base URL of the Github API of a mirror. The mirror is
+// either a base URL, or a host of a Github Enterprise server, that serves the
+// API under /api/v3/. The host github.com is the public Github API.
+func mirrorURL(mirror string) (*url.URL, error) {
+	switch {
+	case mirror == "github.com" || mirror == "api.github.com":
+		mirror = githubAPI
+	case !strings.Contains(mirror, "://"):
+		mirror = "https://" + mirror + "/api/v3/"
+	case !strings.HasSuffix(mirror, "/"):
+		mirror += "/"
+	}
+	return url.Parse(mirror)
+}
+
+// mirrorTransport is an http.RoundTripper that sends the requests of the
+// Github API to the mirrors in order, until one of them does not fail. A
+// request fails if it returns an error or a server error status. Requests to
+// other URLs, such as the download URLs that the mirror returned, are sent as
+// is. The Authorization header of requests to the hosts of mirrors, other than
+// the public Github API, is replaced with the token of the mirror, if it has
+// one, such that the credentials of github.com are never sent to mirrors.
+type mirrorTransport struct {
+	base    http.RoundTripper
+	mirrors []*url.URL
+	// tokens are the tokens of the mirrors, by their hosts.
+	tokens map[string]string
+}
+
+// newMirrorTransport returns a mirror transport of the given mirrors, with the
+// tokens of the mirrors by the mirrors as they are given. Invalid mirrors are
+// skipped.
+func newMirrorTransport(base http.RoundTripper, mirrors []string, tokens map[string]string) *mirrorTransport {
+	t := &mirrorTransport{base: base, tokens: make(map[string]string)}
+	for _, mirror := range mirrors {
+		u, err := mirrorURL(mirror)
+		if err != nil {
+			log.Warn("Skipping invalid mirror", "mirror", mirror, "error", err)
+			continue
+		}
+		t.mirrors = append(t.mirrors, u)
+		if token := tokens[mirror]; token != "" {
+			t.tokens[u.Host] = token
+		}
+	}
+	return t
+}
+
+func (t *mirrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.mirrors) == 0 {
+		return t.base.RoundTrip(req)
+	}
+	if !strings.HasPrefix(req.URL.String(), githubAPI) {
+		if !t.isMirror(req.URL.Host) {
+			return t.base.RoundTrip(req)
+		}
+		// A round tripper should not modify the given request.
+		r := req.Clone(req.Context())
+		t.authorize(r)
+		return t.base.RoundTrip(r)
+	}
+	path := strings.TrimPrefix(req.URL.Path, "/")
+	var (
+		resp *http.Response
+		err  error
+	)
+	for i, mirror := range t.mirrors {
+		if i > 0 {
+			if req.Context().Err() != nil || (req.Body != nil && req.GetBody == nil) {
+				break
+			}
+			log.Warn("Request failed, trying next mirror", "mirror", mirror.Host, "path", path, "error", mirrorErr(resp, err))
+			if resp != nil {
+				resp.Body.Close()
+			}
+		}
+		// A round tripper should not modify the given request.
+		r := req.Clone(req.Context())
+		if i > 0 && req.GetBody != nil {
+			if r.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		u := *mirror
+		u.Path += path
+		if req.URL.RawPath != "" {
+			u.RawPath = mirror.EscapedPath() + strings.TrimPrefix(req.URL.RawPath, "/")
+		}
+		u.RawQuery = req.URL.RawQuery
+		r.URL = &u
+		r.Host = u.Host
+		t.authorize(r)
+		resp, err = t.base.RoundTrip(r)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			return resp, nil
+		}
+	}
+	return resp, err
+}
+
+// isMirror returns true if the given host is a host of a mirror, other than
+// the public Github API.
+func (t *mirrorTransport) isMirror(host string) bool {
+	if host == githubAPIHost {
+		return false
+	}
+	for _, mirror := range t.mirrors {
+		if mirror.Host == host {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize replaces the Authorization header of a request to a mirror, other
+// than the public Github API, with the token of the mirror.
+func (t *mirrorTransport) authorize(r *http.Request) {
+	if r.URL.Host == githubAPIHost {
+		return
+	}
+	r.Header.Del("Authorization")
+	if token := t.tokens[r.URL.Host]; token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// withoutMirrorCredentials returns a client that does not add the token of an
+// OAuth2 client to the requests to the hosts of the given mirrors. An OAuth2
+// transport adds the token after the mirror transport removed the
+// Authorization header, so the requests to the mirrors bypass it.
+func withoutMirrorCredentials(client *http.Client, mirrors []string) *http.Client {
+	t, ok := client.Transport.(*oauth2.Transport)
+	if !ok {
+		return client
+	}
+	cp := *client
+	cp.Transport = &anonymousMirrorsTransport{oauth: t, mirrors: newMirrorTransport(nil, mirrors, nil)}
+	return &cp
+}
+
+// checkMirrorCredentials returns an error if mirrors are configured with a
+// client of a custom transport. Such a transport may add the credentials of
+// github.com to requests after the mirror transport removed them, so they
+// would be sent to the mirrors.
+func (c Config) checkMirrorCredentials() error {
+	if len(c.Mirrors) == 0 || c.Client == nil || knownTransport(c.Client.Transport) {
+		return nil
+	}
+	return errors.Errorf("mirrors can't be used with a client of transport %T, that may send the credentials of github.com to them", c.Client.Transport)
+}
+
+// knownTransport returns true if the credentials that the transport adds to
+// requests are not sent to mirrors: a transport without credentials, an
+// OAuth2 transport, that withoutMirrorCredentials bypasses, or a netrc
+// transport, that adds only the credentials of the host of the request.
+func knownTransport(rt http.RoundTripper) bool {
+	switch t := rt.(type) {
+	case nil, *http.Transport:
+		return true
+	case *oauth2.Transport:
+		return knownTransport(t.Base)
+	case *netrc.Transport:
+		return knownTransport(t.Base)
+	}
+	return false
+}
+
+// anonymousMirrorsTransport is an http.RoundTripper that sends the requests to
+// the hosts of mirrors using the base transport of an OAuth2 transport, and
+// other requests using the OAuth2 transport.
+type anonymousMirrorsTransport struct {
+	oauth   *oauth2.Transport
+	mirrors *mirrorTransport
+}
+
+func (t *anonymousMirrorsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.mirrors.isMirror(req.URL.Host) {
+		return t.oauth.RoundTrip(req)
+	}
+	base := t.oauth.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// mirrorErr describes the failure of a request to a mirror.
+func mirrorErr(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return resp.Status
+}
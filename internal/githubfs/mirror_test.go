@@ -0,0 +1,186 @@
+package githubfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/posener/gitfs/internal/netrc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestMirrorURL(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		mirror string
+		want   string
+	}{
+		{mirror: "github.com", want: "https://api.github.com/"},
+		{mirror: "ghe.example.com", want: "https://ghe.example.com/api/v3/"},
+		{mirror: "http://mirror.example.com/github", want: "http://mirror.example.com/github/"},
+		{mirror: "https://mirror.example.com/", want: "https://mirror.example.com/"},
+	}
+	for _, tt := range tests {
+		got, err := mirrorURL(tt.mirror)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got.String())
+	}
+}
+
+func TestMirrorTransport(t *testing.T) {
+	t.Parallel()
+	var got []string
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var body []byte
+		if req.Body != nil {
+			body, _ = ioutil.ReadAll(req.Body)
+		}
+		got = append(got, req.Method+" "+req.URL.String()+" "+string(body))
+		switch req.URL.Host {
+		case "down.example.com":
+			return nil, errors.New("connection refused")
+		case "error.example.com":
+			return &http.Response{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway", Body: ioutil.NopCloser(strings.NewReader("")), Request: req}, nil
+		}
+		return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}, nil
+	})
+	client := &http.Client{Transport: newMirrorTransport(base, []string{"down.example.com", "error.example.com", "github.com"}, nil)}
+
+	resp, err := client.Get("https://api.github.com/repos/x/y?ref=master")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = client.Post("https://api.github.com/graphql", "application/json", bytes.NewBufferString("query"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Requests to other URLs are not sent to the mirrors.
+	_, err = client.Get("https://raw.githubusercontent.com/x/y/master/a")
+	require.NoError(t, err)
+
+	want := []string{
+		"GET https://down.example.com/api/v3/repos/x/y?ref=master ",
+		"GET https://error.example.com/api/v3/repos/x/y?ref=master ",
+		"GET https://api.github.com/repos/x/y?ref=master ",
+		"POST https://down.example.com/api/v3/graphql query",
+		"POST https://error.example.com/api/v3/graphql query",
+		"POST https://api.github.com/graphql query",
+		"GET https://raw.githubusercontent.com/x/y/master/a ",
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestMirrorTransport_allFail(t *testing.T) {
+	t.Parallel()
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+	client := &http.Client{Transport: newMirrorTransport(base, []string{"a.example.com", "b.example.com"}, nil)}
+	_, err := client.Get("https://api.github.com/repos/x/y")
+	assert.Error(t, err)
+}
+
+func TestMirrorTransport_credentials(t *testing.T) {
+	t.Parallel()
+	var (
+		mu   sync.Mutex
+		auth = make(map[string][]string)
+	)
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		auth[req.URL.Host] = append(auth[req.URL.Host], req.Header.Get("Authorization"))
+		mu.Unlock()
+		status := http.StatusBadGateway
+		if req.URL.Host == "api.github.com" {
+			status = http.StatusNotFound
+		}
+		return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}, nil
+	})
+	oauthClient := oauth2.NewClient(
+		context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: base}),
+		oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "secret"}))
+
+	tests := []struct {
+		name   string
+		config Config
+		github string
+	}{
+		{name: "oauth2", config: Config{Client: oauthClient}, github: "Bearer secret"},
+		{name: "basic", config: Config{Client: &http.Client{Transport: base}, Username: "u", Password: "secret"}, github: "Basic dTpzZWNyZXQ="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			auth = make(map[string][]string)
+			mu.Unlock()
+			c := tt.config
+			c.Mirrors = []string{"mirror.example.com", "token.example.com", "github.com"}
+			c.MirrorTokens = map[string]string{"token.example.com": "mirror-token"}
+			client, _ := c.clients()
+
+			resp, err := client.Get("https://api.github.com/repos/x/y")
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+			// Download URLs on the host of a mirror are not authorized either.
+			_, err = client.Get("https://mirror.example.com/download/a")
+			require.NoError(t, err)
+
+			// The mirrors never see the credentials of github.com.
+			assert.Equal(t, map[string][]string{
+				"mirror.example.com": {"", ""},
+				"token.example.com":  {"Bearer mirror-token"},
+				"api.github.com":     {tt.github},
+			}, auth)
+		})
+	}
+}
+
+func TestCheckMirrorCredentials(t *testing.T) {
+	t.Parallel()
+	// A custom transport that adds credentials, which can't be removed from
+	// the requests to the mirrors.
+	custom := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer secret")
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	oauthClient := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "secret"}))
+
+	tests := []struct {
+		name    string
+		client  *http.Client
+		wantErr bool
+	}{
+		{name: "default"},
+		{name: "transport", client: &http.Client{Transport: &http.Transport{}}},
+		{name: "oauth2", client: oauthClient},
+		{name: "netrc", client: &http.Client{Transport: &netrc.Transport{}}},
+		{name: "custom", client: &http.Client{Transport: custom}, wantErr: true},
+		{name: "oauth2 custom", client: oauth2.NewClient(
+			context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: custom}),
+			oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "secret"})), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Config{Client: tt.client, Mirrors: []string{"mirror.example.com"}}
+			err := c.checkMirrorCredentials()
+			if tt.wantErr {
+				assert.Error(t, err)
+				_, err = New(context.Background(), "github.com/x/y", c)
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			// Without mirrors, any client can be used.
+			c.Mirrors = nil
+			assert.NoError(t, c.checkMirrorCredentials())
+		})
+	}
+}
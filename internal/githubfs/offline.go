@@ -0,0 +1,70 @@
+package githubfs
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/log"
+)
+
+// OfflineError is returned in offline mode when content that is required for
+// loading the filesystem or a file is not stored in the disk cache.
+type OfflineError struct {
+	// What describes the missing content.
+	What string
+}
+
+func (e *OfflineError) Error() string {
+	return fmt.Sprintf("%s is not available offline", e.What)
+}
+
+// checkOffline checks that the filesystem can be loaded in offline mode, and
+// resolves the default branch if no ref was given.
+func (fs *githubfs) checkOffline() error {
+	if fs.DiskCache == nil {
+		return errors.New("offline mode requires a disk cache")
+	}
+	if fs.isReleases() {
+		return &OfflineError{What: "release assets"}
+	}
+	if fs.hasRevision() {
+		return &OfflineError{What: "revision of a ref"}
+	}
+	if fs.ref == "" {
+		ref, err := fs.loadRef("HEAD")
+		if err != nil {
+			return err
+		}
+		fs.ref = ref
+	}
+	return nil
+}
+
+// refKey returns the disk cache key of the value of a ref. The value of a
+// branch or a tag is the commit that it points to, and the value of "HEAD" is
+// the default branch.
+func (fs *githubfs) refKey(ref string) string {
+	return fmt.Sprintf("refs/%s/%s/%s", fs.owner, fs.repo, ref)
+}
+
+// storeRef stores the value of a ref in the disk cache, if it is set.
+func (fs *githubfs) storeRef(ref, value string) {
+	if fs.DiskCache == nil {
+		return
+	}
+	if err := fs.DiskCache.Set(fs.refKey(ref), []byte(value)); err != nil {
+		log.Warn("Failed storing ref in cache", "ref", ref, "error", err)
+	}
+}
+
+// loadRef returns the value of a ref from the disk cache.
+func (fs *githubfs) loadRef(ref string) (string, error) {
+	value, ok := fs.DiskCache.Get(fs.refKey(ref))
+	if !ok {
+		if ref == "HEAD" {
+			return "", &OfflineError{What: fmt.Sprintf("default branch of %s/%s", fs.owner, fs.repo)}
+		}
+		return "", &OfflineError{What: fmt.Sprintf("ref %s of %s/%s", ref, fs.owner, fs.repo)}
+	}
+	return string(value), nil
+}
@@ -3,13 +3,17 @@ package githubfs
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var (
 	reGithubProject = regexp.MustCompile(`^github\.com/([^@/]+)/([^@/]+)(/([^@]*))?(@([^#]+))?$`)
 	reSemver        = regexp.MustCompile(`^v?\d+(\.\d+){0,2}$`)
+	reCommit        = regexp.MustCompile(`^[0-9a-f]{40}$`)
 )
 
 type project struct {
@@ -17,10 +21,18 @@ type project struct {
 	repo  string
 	ref   string
 	path  string
+	// date, if set, selects the last commit of the ref before this time.
+	date time.Time
+	// ancestors is the number of first parents of the selected commit to go
+	// back in the history.
+	ancestors int
 }
 
 // newProject parses project name into the different components
-// it is composed of.
+// it is composed of. The ref is everything after the first "@", such that it
+// may contain slashes, as in `heads/release/1.x`. Characters that can't be
+// used in the path or in the ref, such as "@" and "#", can be escaped with
+// URL percent-encoding, for example `heads/fix%231` for the `fix#1` branch.
 func newProject(projectName string) (p *project, err error) {
 	matches := reGithubProject.FindStringSubmatch(projectName)
 	if len(matches) < 2 {
@@ -31,8 +43,14 @@ func newProject(projectName string) (p *project, err error) {
 	p = &project{
 		owner: matches[1],
 		repo:  matches[2],
-		path:  matches[4],
-		ref:   matches[6],
+	}
+	if p.path, err = url.PathUnescape(matches[4]); err != nil {
+		err = fmt.Errorf("bad path in project name %s: %s", projectName, err)
+		return
+	}
+	if p.ref, err = url.PathUnescape(matches[6]); err != nil {
+		err = fmt.Errorf("bad ref in project name %s: %s", projectName, err)
+		return
 	}
 
 	// Add "/" suffix to path.
@@ -40,18 +58,130 @@ func newProject(projectName string) (p *project, err error) {
 		p.path = p.path + "/"
 	}
 
+	if p.ref, err = p.parseRevision(p.ref); err != nil {
+		err = fmt.Errorf("bad revision in project name %s: %s", projectName, err)
+		return
+	}
+
 	// If ref is Semver, add 'tags/' prefix to make it a valid ref.
 	if reSemver.MatchString(p.ref) {
 		p.ref = "tags/" + p.ref
 	}
 
+	// A ref with a revision may be a branch name without a prefix.
+	if p.hasRevision() && p.ref != "" && !strings.HasPrefix(p.ref, "heads/") && !strings.HasPrefix(p.ref, "tags/") && !reCommit.MatchString(p.ref) {
+		p.ref = "heads/" + p.ref
+	}
+
 	err = verifyRef(p.ref)
 	return
 }
 
+// parseRevision parses the revision suffixes of a ref, as in git: `~<n>` for
+// the n-th first parent of the commit, and `@{<date>}` for the last commit
+// before the date. The date is of the form 2006-01-02, which is the start of
+// the day in UTC, or an RFC3339 time. A ref that is only a date, such as
+// `{2006-01-02}`, selects the default branch. It returns the ref without the
+// revision suffixes.
+func (p *project) parseRevision(ref string) (string, error) {
+	if i := strings.LastIndex(ref, "~"); i >= 0 {
+		n := ref[i+1:]
+		ref = ref[:i]
+		p.ancestors = 1
+		if n != "" {
+			var err error
+			if p.ancestors, err = strconv.Atoi(n); err != nil || p.ancestors < 0 {
+				return "", errors.New("number of ancestors must be a non-negative integer")
+			}
+		}
+	}
+	if !strings.HasSuffix(ref, "}") {
+		return ref, nil
+	}
+	i := strings.LastIndex(ref, "{")
+	if i < 0 || (i > 0 && !strings.HasSuffix(ref[:i], "@")) {
+		return "", errors.New("date must be of the form @{<date>}")
+	}
+	date := ref[i+1 : len(ref)-1]
+	ref = strings.TrimSuffix(ref[:i], "@")
+	var err error
+	if p.date, err = time.Parse("2006-01-02", date); err != nil {
+		if p.date, err = time.Parse(time.RFC3339, date); err != nil {
+			return "", fmt.Errorf("date %q must be of the form 2006-01-02 or RFC3339", date)
+		}
+	}
+	return ref, nil
+}
+
+// hasRevision returns true if the project selects a commit relative to its
+// ref, which is resolved using the Github API.
+func (p *project) hasRevision() bool {
+	return !p.date.IsZero() || p.ancestors > 0
+}
+
+// refName returns the ref without the 'heads/' or 'tags/' prefix, as expected
+// by APIs that accept a branch name, a tag name or a commit SHA.
+func (p *project) refName() string {
+	ref := strings.TrimPrefix(p.ref, "heads/")
+	return strings.TrimPrefix(ref, "tags/")
+}
+
+// escapedRefName returns the ref name for using in a URL path of the Github
+// API. Slashes in branch and tag names are escaped, such that they are not
+// treated as path separators.
+func (p *project) escapedRefName() string {
+	return url.PathEscape(p.refName())
+}
+
+// escapedRef returns the ref, with its prefix, for using in a URL path of the
+// Github API.
+func (p *project) escapedRef() string {
+	return strings.TrimSuffix(p.ref, p.refName()) + p.escapedRefName()
+}
+
+// refExpression returns the ref as a git revision expression: the full name
+// of a branch or a tag, or a commit SHA of a resolved revision.
+func (p *project) refExpression() string {
+	if strings.HasPrefix(p.ref, "heads/") || strings.HasPrefix(p.ref, "tags/") {
+		return "refs/" + p.ref
+	}
+	return p.ref
+}
+
+// isCommit returns true if the ref is a commit SHA.
+func (p *project) isCommit() bool {
+	return reCommit.MatchString(p.ref)
+}
+
+// MatchPush returns true if a push of the given ref, such as refs/heads/master,
+// to the repository of the form owner/repo, updates the ref of the project.
+// Projects without a ref are updated by pushes of the default branch of the
+// repository, and projects that their ref is a commit SHA are never updated.
+func MatchPush(projectName, repository, ref, defaultBranch string) bool {
+	p, err := newProject(projectName)
+	if err != nil || p.isCommit() {
+		return false
+	}
+	if !strings.EqualFold(p.owner+"/"+p.repo, repository) {
+		return false
+	}
+	if p.ref == "" {
+		return ref == "refs/heads/"+defaultBranch
+	}
+	return ref == "refs/"+p.ref
+}
+
+// Versioned returns true if the content of the project is determined by the
+// commit that its ref points to. Release assets are uploaded separately from
+// the commits, and are not versioned.
+func Versioned(projectName string) bool {
+	p, err := newProject(projectName)
+	return err == nil && !p.isReleases()
+}
+
 func verifyRef(ref string) error {
-	if ref != "" && !strings.HasPrefix(ref, "heads/") && !strings.HasPrefix(ref, "tags/") {
-		return errors.New("ref must have a 'heads/' or 'tags/' prefix")
+	if ref != "" && !strings.HasPrefix(ref, "heads/") && !strings.HasPrefix(ref, "tags/") && !reCommit.MatchString(ref) {
+		return errors.New("ref must have a 'heads/' or 'tags/' prefix, or be a full commit SHA")
 	}
 	return nil
 }
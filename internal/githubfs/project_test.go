@@ -1,6 +1,9 @@
 package githubfs
 
 import (
+	"context"
+	"errors"
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -57,6 +60,18 @@ func TestGithubNewProject(t *testing.T) {
 			path: "github.com/x/y/static@v1.2.3",
 			want: project{owner: "x", repo: "y", ref: "tags/v1.2.3", path: "static/"},
 		},
+		{
+			path: "github.com/x/y@0123456789abcdef0123456789abcdef01234567",
+			want: project{owner: "x", repo: "y", ref: "0123456789abcdef0123456789abcdef01234567"},
+		},
+		{
+			path: "github.com/x/y/static@heads/release/1.x",
+			want: project{owner: "x", repo: "y", ref: "heads/release/1.x", path: "static/"},
+		},
+		{
+			path: "github.com/x/y/a%40b@heads/fix%231",
+			want: project{owner: "x", repo: "y", ref: "heads/fix#1", path: "a@b/"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -86,6 +101,8 @@ func TestGithubProjectProperties_error(t *testing.T) {
 		"github.com/x/y@v1.2.3.4",
 		"github.com/x/y@1.",
 		"github.com/x/y@1.2.3.4",
+		// Invalid escaping
+		"github.com/x/y@heads/a%2",
 	}
 
 	for _, path := range paths {
@@ -95,3 +112,51 @@ func TestGithubProjectProperties_error(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchPush(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		project string
+		repo    string
+		ref     string
+		want    bool
+	}{
+		{project: "github.com/x/y", repo: "x/y", ref: "refs/heads/master", want: true},
+		{project: "github.com/x/y", repo: "x/y", ref: "refs/heads/dev"},
+		{project: "github.com/X/y/static", repo: "x/Y", ref: "refs/heads/master", want: true},
+		{project: "github.com/x/y", repo: "x/z", ref: "refs/heads/master"},
+		{project: "github.com/x/y@heads/release/1.x", repo: "x/y", ref: "refs/heads/release/1.x", want: true},
+		{project: "github.com/x/y@v1.0.0", repo: "x/y", ref: "refs/tags/v1.0.0", want: true},
+		{project: "github.com/x/y@v1.0.0", repo: "x/y", ref: "refs/heads/v1.0.0"},
+		{project: "github.com/x/y@dev~1", repo: "x/y", ref: "refs/heads/dev", want: true},
+		{project: "github.com/x/y@0123456789abcdef0123456789abcdef01234567", repo: "x/y", ref: "refs/heads/master"},
+		{project: "bad", repo: "x/y", ref: "refs/heads/master"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MatchPush(tt.project, tt.repo, tt.ref, "master"), tt.project+" "+tt.ref)
+	}
+}
+
+func TestEscapedRef(t *testing.T) {
+	t.Parallel()
+	p, err := newProject("github.com/x/y@heads/release/1.x")
+	require.NoError(t, err)
+	assert.Equal(t, "heads/release%2F1.x", p.escapedRef())
+	assert.Equal(t, "release%2F1.x", p.escapedRefName())
+}
+
+func TestEscapedRef_request(t *testing.T) {
+	t.Parallel()
+	var paths []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		paths = append(paths, req.URL.EscapedPath())
+		return nil, errors.New("failed")
+	})}
+	_, err := New(context.Background(), "github.com/x/y@heads/release/1.x", Config{Client: client})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"/repos/x/y/git/trees/heads/release%2F1.x"}, paths)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
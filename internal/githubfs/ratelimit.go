@@ -0,0 +1,242 @@
+package githubfs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/log"
+)
+
+// RateLimitError is returned when the Github API rate limit was exceeded.
+type RateLimitError struct {
+	// Limit is the number of requests that are allowed per hour.
+	Limit int
+	// Remaining is the number of requests that remained in the current
+	// rate limit window.
+	Remaining int
+	// Reset is the time in which the current rate limit window resets.
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("github rate limit of %d requests exceeded, resets at %s", e.Limit, e.Reset)
+}
+
+// rateLimit converts a rate limit error of the Github client to a
+// *RateLimitError. Other errors are returned as is.
+func rateLimit(err error) error {
+	var rl *github.RateLimitError
+	if !errors.As(err, &rl) {
+		return err
+	}
+	return &RateLimitError{
+		Limit:     rl.Rate.Limit,
+		Remaining: rl.Rate.Remaining,
+		Reset:     rl.Rate.Reset.Time,
+	}
+}
+
+// rateLimitTransport is an http.RoundTripper that waits until the rate limit
+// resets when it is exceeded, and then retries the request. If the request
+// context is done before the reset, the rate limited response is returned.
+type rateLimitTransport struct {
+	base http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || req.Method != http.MethodGet {
+			return resp, err
+		}
+		reset, ok := rateLimitReset(resp)
+		if !ok {
+			return resp, nil
+		}
+		log.Warn("Github rate limit exceeded, waiting", "until", reset)
+		timer := time.NewTimer(time.Until(reset))
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return resp, nil
+		case <-timer.C:
+			resp.Body.Close()
+		}
+	}
+}
+
+// maxSecondaryRetries is the number of times a request is retried after it
+// was rejected due to a secondary rate limit.
+const maxSecondaryRetries = 5
+
+// secondaryRateLimitTransport is an http.RoundTripper that handles Github
+// secondary rate limits, which may be exceeded by concurrent requests. A
+// rejected request is retried after the time in the Retry-After header. Until
+// then, all the other requests that are sent through the transport are delayed
+// as well, which throttles concurrent requests.
+type secondaryRateLimitTransport struct {
+	base  http.RoundTripper
+	until time.Time
+	mu    sync.Mutex
+}
+
+func (t *secondaryRateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		if err := t.wait(req.Context()); err != nil {
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || retry == maxSecondaryRetries {
+			return resp, err
+		}
+		after, ok := retryAfter(resp)
+		if !ok {
+			return resp, nil
+		}
+		next, ok := rewind(req)
+		if !ok {
+			return resp, nil
+		}
+		resp.Body.Close()
+		log.Warn("Github secondary rate limit exceeded, retrying", "after", after)
+		t.delay(after)
+		req = next
+	}
+}
+
+// delay delays all requests by the given duration.
+func (t *secondaryRateLimitTransport) delay(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until := time.Now().Add(d); until.After(t.until) {
+		t.until = until
+	}
+}
+
+// wait until requests are not delayed, or until the context is done.
+func (t *secondaryRateLimitTransport) wait(ctx context.Context) error {
+	t.mu.Lock()
+	d := time.Until(t.until)
+	t.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttleTransport is an http.RoundTripper that limits the rate of requests
+// that are sent through it, by sending them in fixed intervals.
+type throttleTransport struct {
+	base     http.RoundTripper
+	interval time.Duration
+	next     time.Time
+	mu       sync.Mutex
+}
+
+func (t *throttleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// wait reserves the next free interval for a request, and waits until it
+// begins, or until the context is done.
+func (t *throttleTransport) wait(ctx context.Context) error {
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	d := t.next.Sub(now)
+	t.next = t.next.Add(t.interval)
+	t.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryAfter returns the duration in the Retry-After header of a response
+// that was rejected due to a secondary rate limit.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t), true
+	}
+	return 0, false
+}
+
+// rewind returns a copy of the request that can be sent again.
+func rewind(req *http.Request) (*http.Request, bool) {
+	next := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return next, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	next.Body = body
+	return next, true
+}
+
+// rateLimitReset returns the reset time of a response that was rejected due
+// to an exceeded rate limit.
+func rateLimitReset(resp *http.Response) (time.Time, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return time.Time{}, false
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return time.Time{}, false
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(reset, 0), true
+}
+
+// withTransport returns a copy of the client, that its transport is wrapped
+// with the given function.
+func withTransport(client *http.Client, wrap func(http.RoundTripper) http.RoundTripper) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	cp := *client
+	cp.Transport = wrap(base)
+	return &cp
+}
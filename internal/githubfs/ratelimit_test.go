@@ -0,0 +1,191 @@
+package githubfs
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rateLimitServer returns a server that rejects the first limited requests
+// due to an exceeded rate limit, that resets at the given time.
+func rateLimitServer(limited int, reset time.Time) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limited > 0 {
+			limited--
+			w.Header().Set("X-RateLimit-Limit", "60")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message":"API rate limit exceeded for 127.0.0.1."}`))
+			return
+		}
+		w.Write([]byte(`{"default_branch":"master"}`))
+	}))
+}
+
+func TestRateLimit_error(t *testing.T) {
+	t.Parallel()
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	s := rateLimitServer(1, reset)
+	defer s.Close()
+
+	_, err := newGithubFS(context.Background(), "github.com/x/y", Config{Client: redirect(s)})
+	var rl *RateLimitError
+	require.True(t, errors.As(err, &rl), "got: %v", err)
+	assert.Equal(t, 60, rl.Limit)
+	assert.Equal(t, 0, rl.Remaining)
+	assert.True(t, reset.Equal(rl.Reset))
+}
+
+func TestRateLimit_wait(t *testing.T) {
+	t.Parallel()
+	// Reset time already passed, so waiting does not block.
+	s := rateLimitServer(2, time.Now().Add(-time.Second))
+	defer s.Close()
+
+	fs, err := newGithubFS(context.Background(), "github.com/x/y", Config{Client: redirect(s), WaitRateLimit: true})
+	require.NoError(t, err)
+	assert.Equal(t, "heads/master", fs.ref)
+}
+
+func TestRateLimit_waitCancelled(t *testing.T) {
+	t.Parallel()
+	s := rateLimitServer(1, time.Now().Add(time.Hour))
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := newGithubFS(ctx, "github.com/x/y", Config{Client: redirect(s), WaitRateLimit: true})
+	var rl *RateLimitError
+	assert.True(t, errors.As(err, &rl), "got: %v", err)
+}
+
+// redirect returns a client that sends all requests to the given server.
+func redirect(s *httptest.Server) *http.Client {
+	return &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		u := *req.URL
+		u.Scheme = "http"
+		u.Host = s.Listener.Addr().String()
+		req = req.Clone(req.Context())
+		req.URL = &u
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestSecondaryRateLimit_retry(t *testing.T) {
+	t.Parallel()
+	var (
+		limited = 2
+		bodies  []string
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if limited > 0 {
+			limited--
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer s.Close()
+
+	client := withTransport(s.Client(), func(base http.RoundTripper) http.RoundTripper {
+		return &secondaryRateLimitTransport{base: base}
+	})
+	resp, err := client.Post(s.URL, "text/plain", strings.NewReader("body"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"body", "body", "body"}, bodies)
+}
+
+func TestSecondaryRateLimit_giveUp(t *testing.T) {
+	t.Parallel()
+	requests := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer s.Close()
+
+	client := withTransport(s.Client(), func(base http.RoundTripper) http.RoundTripper {
+		return &secondaryRateLimitTransport{base: base}
+	})
+	resp, err := client.Get(s.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, maxSecondaryRetries+1, requests)
+}
+
+func TestSecondaryRateLimit_throttle(t *testing.T) {
+	t.Parallel()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	tr := &secondaryRateLimitTransport{base: http.DefaultTransport}
+	client := &http.Client{Transport: tr}
+
+	// Other requests are delayed.
+	const delay = 50 * time.Millisecond
+	tr.delay(delay)
+	start := time.Now()
+	resp, err := client.Get(s.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.True(t, time.Since(start) >= delay)
+
+	// Delayed requests stop when the context is done.
+	tr.delay(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	require.NoError(t, err)
+	_, err = client.Do(req.WithContext(ctx))
+	assert.Error(t, err)
+}
+
+func TestThrottle(t *testing.T) {
+	t.Parallel()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	const interval = 20 * time.Millisecond
+	client := &http.Client{Transport: &throttleTransport{base: http.DefaultTransport, interval: interval}}
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get(s.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.True(t, time.Since(start) >= 3*interval)
+
+	// Throttled requests stop when the context is done.
+	client = &http.Client{Transport: &throttleTransport{base: http.DefaultTransport, interval: time.Hour}}
+	resp, err := client.Get(s.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	require.NoError(t, err)
+	_, err = client.Do(req.WithContext(ctx))
+	assert.Error(t, err)
+}
@@ -0,0 +1,78 @@
+package githubfs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/log"
+)
+
+// ReadFile returns the content of a single file, that its path is the path of
+// the project, using Github's get-contents API with the raw media type:
+// (https://developer.github.com/v3/repos/contents/#get-contents).
+// The tree of the repository is not loaded, and unless the ref of the project
+// has a revision, the content is fetched in a single request. Projects
+// without a ref use the default branch.
+func ReadFile(ctx context.Context, projectName string, c Config) ([]byte, error) {
+	p, err := newProject(projectName)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(p.path, "/")
+	if name == "" {
+		return nil, errors.Errorf("project %s does not have a file path", projectName)
+	}
+	if p.isReleases() {
+		return nil, errors.New("release assets can't be read as a single file")
+	}
+	if err := c.checkMirrorCredentials(); err != nil {
+		return nil, err
+	}
+	if p.hasRevision() {
+		fs, err := newGithubFS(ctx, projectName, c)
+		if err != nil {
+			return nil, err
+		}
+		p = fs.project
+	}
+	httpClient, client := c.clients()
+
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		parts[i] = url.PathEscape(parts[i])
+	}
+	u := fmt.Sprintf("repos/%s/%s/contents/%s", p.owner, p.repo, strings.Join(parts, "/"))
+	if p.ref != "" {
+		u += "?ref=" + url.QueryEscape(p.refName())
+	}
+	log.Debug("Using Github get-contents API for a single file", "path", name)
+	req, err := client.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating contents request")
+	}
+	req.Header.Set("Accept", mediaTypeRaw)
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed getting contents")
+	}
+	defer resp.Body.Close()
+	if err := github.CheckResponse(resp); err != nil {
+		return nil, errors.Wrap(rateLimit(err), "failed getting contents")
+	}
+	// The raw media type applies only to files, and the listing of a
+	// directory is returned as JSON.
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		return nil, errors.Errorf("%s is not a file", name)
+	}
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading contents")
+	}
+	return content, nil
+}
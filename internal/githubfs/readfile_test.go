@@ -0,0 +1,55 @@
+package githubfs
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFile(t *testing.T) {
+	t.Parallel()
+	var requests []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req.URL.RequestURI())
+		resp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Request: req}
+		switch req.URL.Path {
+		case "/repos/x/y/contents/d/f.txt":
+			if req.Header.Get("Accept") != mediaTypeRaw {
+				resp.StatusCode = http.StatusBadRequest
+			}
+			resp.Body = ioutil.NopCloser(strings.NewReader("content"))
+		case "/repos/x/y/contents/d":
+			resp.Header.Set("Content-Type", "application/json; charset=utf-8")
+			resp.Body = ioutil.NopCloser(strings.NewReader(`[]`))
+		default:
+			resp.StatusCode = http.StatusNotFound
+			resp.Body = ioutil.NopCloser(strings.NewReader(`{}`))
+		}
+		return resp, nil
+	})}
+	c := Config{Client: client}
+	ctx := context.Background()
+
+	content, err := ReadFile(ctx, "github.com/x/y/d/f.txt", c)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+	content, err = ReadFile(ctx, "github.com/x/y/d/f.txt@heads/release/1.x", c)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+	assert.Equal(t, []string{
+		"/repos/x/y/contents/d/f.txt",
+		"/repos/x/y/contents/d/f.txt?ref=release%2F1.x",
+	}, requests)
+
+	_, err = ReadFile(ctx, "github.com/x/y/d", c)
+	assert.EqualError(t, err, "d is not a file")
+	_, err = ReadFile(ctx, "github.com/x/y/nope", c)
+	assert.Error(t, err)
+	_, err = ReadFile(ctx, "github.com/x/y", c)
+	assert.Error(t, err)
+}
@@ -0,0 +1,143 @@
+package githubfs
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/log"
+)
+
+// ResolveCommit returns the SHA of the commit that the ref of a project,
+// with its revision, or the default branch if it has no ref, points to.
+func ResolveCommit(ctx context.Context, projectName string, c Config) (string, error) {
+	r, err := Resolve(ctx, projectName, c)
+	if err != nil {
+		return "", err
+	}
+	return r.Commit, nil
+}
+
+// Resolved is a project that was resolved with the Github API.
+type Resolved struct {
+	Owner string
+	Repo  string
+	// Path is the path of the project in the repository, or an empty string
+	// for the root of the repository.
+	Path string
+	// Ref is the ref of the project, with its 'heads/' or 'tags/' prefix. It
+	// is the default branch of the repository if the project has no ref, and
+	// the commit SHA if the project has a revision.
+	Ref string
+	// Commit is the SHA of the commit that the ref points to.
+	Commit string
+}
+
+// Resolve parses the project name, and resolves its ref to a commit. It fails
+// if the repository or the ref do not exist, or if the client can't access
+// them.
+func Resolve(ctx context.Context, projectName string, c Config) (*Resolved, error) {
+	fs, err := newGithubFS(ctx, projectName, c)
+	if err != nil {
+		return nil, err
+	}
+	g := getATree(*fs)
+	sha, err := g.commitSHA(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Resolved{
+		Owner:  fs.owner,
+		Repo:   fs.repo,
+		Path:   strings.TrimSuffix(fs.path, "/"),
+		Ref:    fs.ref,
+		Commit: sha,
+	}, nil
+}
+
+// resolveRevision resolves the revision of the project to a commit, using the
+// commits API, and replaces the ref with the SHA of the commit.
+func (fs *githubfs) resolveRevision(ctx context.Context) error {
+	sha := fs.refName()
+	if !fs.date.IsZero() {
+		opt := &github.CommitsListOptions{
+			SHA:         fs.refName(),
+			Until:       fs.date,
+			ListOptions: github.ListOptions{PerPage: 1},
+		}
+		commits, _, err := fs.client.Repositories.ListCommits(ctx, fs.owner, fs.repo, opt)
+		if err != nil {
+			return errors.Wrap(rateLimit(err), "list commits before date")
+		}
+		if len(commits) == 0 {
+			return errors.Errorf("no commits of %s before %s", fs.refName(), fs.date)
+		}
+		sha = commits[0].GetSHA()
+	}
+	sha, err := fs.ancestor(ctx, sha, fs.ancestors)
+	if err != nil {
+		return err
+	}
+	log.Debug("Resolved revision", "ref", fs.ref, "commit", sha)
+	fs.ref = sha
+	return nil
+}
+
+// ancestorsPerPage is the number of commits that are listed in each request
+// when looking up ancestors, which is the maximum of the commits API.
+const ancestorsPerPage = 100
+
+// ancestor returns the SHA of the commit that is n first parents before the
+// given commit or ref, as `<rev>~<n>` in git. Instead of getting the commits
+// one by one, the history is listed in pages with the commits API, and the
+// first parents are followed through the listed commits, such that a deep
+// ancestor takes a request for every page of history.
+func (fs *githubfs) ancestor(ctx context.Context, rev string, n int) (string, error) {
+	if n == 0 {
+		return rev, nil
+	}
+	opt := &github.CommitsListOptions{
+		SHA:         rev,
+		ListOptions: github.ListOptions{PerPage: ancestorsPerPage},
+	}
+	parents := make(map[string]string)
+	var sha string
+	for {
+		commits, resp, err := fs.client.Repositories.ListCommits(ctx, fs.owner, fs.repo, opt)
+		if err != nil {
+			return "", errors.Wrap(rateLimit(err), "list commits")
+		}
+		if sha == "" {
+			// The history starts with the commit of rev.
+			if len(commits) == 0 {
+				return "", errors.Errorf("no commits of %s", rev)
+			}
+			sha = commits[0].GetSHA()
+		}
+		for _, commit := range commits {
+			var parent string
+			if len(commit.Parents) > 0 {
+				parent = commit.Parents[0].GetSHA()
+			}
+			parents[commit.GetSHA()] = parent
+		}
+		for n > 0 {
+			parent, ok := parents[sha]
+			if !ok {
+				break
+			}
+			if parent == "" {
+				return "", errors.Errorf("commit %s has no parents", sha)
+			}
+			sha, n = parent, n-1
+		}
+		if n == 0 {
+			return sha, nil
+		}
+		if resp.NextPage == 0 {
+			return "", errors.Errorf("commit %s was not found in the history of %s", sha, rev)
+		}
+		opt.Page = resp.NextPage
+	}
+}
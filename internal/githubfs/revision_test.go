@@ -0,0 +1,133 @@
+package githubfs
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProject_revision(t *testing.T) {
+	t.Parallel()
+	date := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		path string
+		want project
+	}{
+		{
+			path: "github.com/x/y@{2023-06-01}",
+			want: project{owner: "x", repo: "y", date: date},
+		},
+		{
+			path: "github.com/x/y@heads/main@{2023-06-01T00:00:00Z}",
+			want: project{owner: "x", repo: "y", ref: "heads/main", date: date},
+		},
+		{
+			path: "github.com/x/y@main~3",
+			want: project{owner: "x", repo: "y", ref: "heads/main", ancestors: 3},
+		},
+		{
+			path: "github.com/x/y@v1.2.3~",
+			want: project{owner: "x", repo: "y", ref: "tags/v1.2.3", ancestors: 1},
+		},
+		{
+			path: "github.com/x/y/static@heads/release/1.x@{2023-06-01}~2",
+			want: project{owner: "x", repo: "y", ref: "heads/release/1.x", path: "static/", date: date, ancestors: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := newProject(tt.path)
+			require.NoError(t, err)
+			assert.Equal(t, &tt.want, got)
+		})
+	}
+}
+
+func TestNewProject_revisionError(t *testing.T) {
+	t.Parallel()
+	paths := []string{
+		"github.com/x/y@main~x",
+		"github.com/x/y@main~-1",
+		"github.com/x/y@{yesterday}",
+		"github.com/x/y@main{2023-06-01}",
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			p, err := newProject(path)
+			assert.Error(t, err, "Got project=%+v", p)
+		})
+	}
+}
+
+func TestResolveRevision(t *testing.T) {
+	t.Parallel()
+	var queries []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		header := make(http.Header)
+		var body string
+		switch {
+		case req.URL.Path == "/repos/x/y":
+			body = `{"default_branch":"main"}`
+		case req.URL.Path == "/repos/x/y/commits" && req.URL.Query().Get("until") != "":
+			queries = append(queries, req.URL.RawQuery)
+			body = `[{"sha":"c3"}]`
+		case req.URL.Path == "/repos/x/y/commits" && req.URL.Query().Get("page") == "":
+			// The history of c3 includes a merged branch.
+			queries = append(queries, req.URL.RawQuery)
+			body = `[{"sha":"c3","parents":[{"sha":"c2"},{"sha":"b1"}]},{"sha":"b1","parents":[{"sha":"c1"}]},{"sha":"c2","parents":[{"sha":"c1"}]}]`
+			header.Set("Link", `<https://api.github.com/repos/x/y/commits?page=2>; rel="next"`)
+		case req.URL.Path == "/repos/x/y/commits" && req.URL.Query().Get("page") == "2":
+			queries = append(queries, req.URL.RawQuery)
+			body = `[{"sha":"c1"}]`
+		default:
+			return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(bytes.NewReader(nil)), Request: req}, nil
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     header,
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+			Request:    req,
+		}, nil
+	})}
+
+	fs, err := newGithubFS(context.Background(), "github.com/x/y@{2023-06-01}~2", Config{Client: client})
+	require.NoError(t, err)
+	assert.Equal(t, "c1", fs.ref)
+	assert.Equal(t, "c1", fs.refName())
+	assert.Equal(t, "c1", fs.refExpression())
+	// The ancestors are found in a single page of history.
+	assert.Equal(t, []string{
+		"per_page=1&sha=main&until=2023-06-01T00%3A00%3A00Z",
+		"per_page=100&sha=c3",
+	}, queries)
+
+	// The root commit has no parents.
+	queries = nil
+	_, err = newGithubFS(context.Background(), "github.com/x/y@{2023-06-01}~3", Config{Client: client})
+	assert.Error(t, err)
+	assert.Len(t, queries, 3)
+}
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+	c := Config{Client: mockClient()}
+
+	r, err := Resolve(context.Background(), "github.com/x/y/d2", c)
+	require.NoError(t, err)
+	assert.Equal(t, &Resolved{Owner: "x", Repo: "y", Path: "d2", Ref: "heads/master", Commit: "c1"}, r)
+
+	// The repository does not exist.
+	_, err = Resolve(context.Background(), "github.com/x/z", c)
+	assert.Error(t, err)
+	// The ref does not exist.
+	_, err = Resolve(context.Background(), "github.com/x/y@heads/nope", c)
+	assert.Error(t, err)
+	_, err = Resolve(context.Background(), "github.com/x/y@nope", c)
+	assert.Error(t, err)
+}
@@ -0,0 +1,88 @@
+package githubfs
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxTagDepth is the maximal number of annotated tags that point to other
+// annotated tags, that are followed to the tagged commit.
+const maxTagDepth = 8
+
+// Tag is information about an annotated git tag.
+type Tag struct {
+	// Name is the name of the tag, without the 'tags/' prefix.
+	Name string
+	// Message is the message of the tag.
+	Message string
+	// Tagger is the name of the person who created the tag.
+	Tagger string
+	// TaggerEmail is the email of the person who created the tag.
+	TaggerEmail string
+	// Date is the time in which the tag was created.
+	Date time.Time
+	// Commit is the SHA of the tagged commit.
+	Commit string
+}
+
+// TagLoader returns a loader of the annotated tag that is the ref of the
+// project. It returns false if the ref of the project is not a tag. The tag
+// is loaded using the Github API when the loader is first called, and a tag
+// that was loaded successfully is not loaded again. The loader fails if the
+// tag is a lightweight tag, which has no tag information.
+func TagLoader(projectName string, c Config) (func(context.Context) (*Tag, error), bool) {
+	p, err := newProject(projectName)
+	if err != nil || !strings.HasPrefix(p.ref, "tags/") || p.hasRevision() || p.isReleases() {
+		return nil, false
+	}
+	_, client := c.clients()
+	var (
+		tag *Tag
+		mu  sync.Mutex
+	)
+	return func(ctx context.Context) (*Tag, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if tag == nil {
+			ref, _, err := client.Git.GetRef(ctx, p.owner, p.repo, "tags/"+p.escapedRefName())
+			if err != nil {
+				return nil, errors.Wrap(rateLimit(err), "get tag ref")
+			}
+			if ref.GetObject().GetType() != "tag" {
+				return nil, errors.Errorf("tag %s is not an annotated tag", p.refName())
+			}
+			sha := ref.GetObject().GetSHA()
+			var loaded *Tag
+			for i := 0; ; i++ {
+				if i == maxTagDepth {
+					return nil, errors.Errorf("tag %s points to too many tags", p.refName())
+				}
+				t, _, err := client.Git.GetTag(ctx, p.owner, p.repo, sha)
+				if err != nil {
+					return nil, errors.Wrap(rateLimit(err), "get tag")
+				}
+				if loaded == nil {
+					loaded = &Tag{
+						Name:        p.refName(),
+						Message:     t.GetMessage(),
+						Tagger:      t.GetTagger().GetName(),
+						TaggerEmail: t.GetTagger().GetEmail(),
+						Date:        t.GetTagger().GetDate(),
+					}
+				}
+				sha = t.GetObject().GetSHA()
+				if t.GetObject().GetType() != "tag" {
+					break
+				}
+			}
+			loaded.Commit = sha
+			tag = loaded
+		}
+		cp := *tag
+		return &cp, nil
+	}, true
+}
@@ -0,0 +1,71 @@
+package githubfs
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagLoader(t *testing.T) {
+	t.Parallel()
+	requests := 0
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		var body string
+		switch req.URL.Path {
+		case "/repos/x/y/git/refs/tags/v1.0.0":
+			body = `{"ref":"refs/tags/v1.0.0","object":{"type":"tag","sha":"t1"}}`
+		case "/repos/x/y/git/tags/t1":
+			body = `{"sha":"t1","message":"Release 1.0.0\n","tagger":{"name":"Gopher","email":"gopher@example.com","date":"2020-01-02T03:04:05Z"},"object":{"type":"tag","sha":"t0"}}`
+		case "/repos/x/y/git/tags/t0":
+			body = `{"sha":"t0","message":"inner","object":{"type":"commit","sha":"c1"}}`
+		case "/repos/x/y/git/refs/tags/light":
+			body = `{"ref":"refs/tags/light","object":{"type":"commit","sha":"c1"}}`
+		default:
+			return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader(`{}`)), Request: req}, nil
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})}
+	c := Config{Client: client}
+	ctx := context.Background()
+
+	load, ok := TagLoader("github.com/x/y/docs@v1.0.0", c)
+	require.True(t, ok)
+	for i := 0; i < 2; i++ {
+		tag, err := load(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &Tag{
+			Name:        "v1.0.0",
+			Message:     "Release 1.0.0\n",
+			Tagger:      "Gopher",
+			TaggerEmail: "gopher@example.com",
+			Date:        time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			Commit:      "c1",
+		}, tag)
+	}
+	// The tag is loaded once.
+	assert.Equal(t, 3, requests)
+
+	load, ok = TagLoader("github.com/x/y@tags/light", c)
+	require.True(t, ok)
+	_, err := load(ctx)
+	assert.EqualError(t, err, "tag light is not an annotated tag")
+
+	_, ok = TagLoader("github.com/x/y@heads/master", c)
+	assert.False(t, ok)
+	_, ok = TagLoader("github.com/x/y", c)
+	assert.False(t, ok)
+	_, ok = TagLoader("github.com/x/y/releases@v1.0.0", c)
+	assert.False(t, ok)
+}
@@ -0,0 +1,52 @@
+package githubfs
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/posener/gitfs/internal/log"
+	"github.com/posener/gitfs/internal/netrc"
+	"golang.org/x/oauth2"
+)
+
+// withTLSConfig returns a copy of the client that uses the given TLS
+// configuration. The configuration is applied on the *http.Transport of the
+// client, also when it is the base transport of an OAuth2 client. Clients
+// with other transports are returned as is.
+func withTLSConfig(client *http.Client, cfg *tls.Config) *http.Client {
+	transport, ok := tlsTransport(client.Transport, cfg)
+	if !ok {
+		log.Warn("TLS configuration is not applied on a client with a custom transport")
+		return client
+	}
+	cp := *client
+	cp.Transport = transport
+	return &cp
+}
+
+// tlsTransport returns a copy of the transport that uses the given TLS
+// configuration, and whether it could be applied.
+func tlsTransport(rt http.RoundTripper, cfg *tls.Config) (http.RoundTripper, bool) {
+	switch t := rt.(type) {
+	case nil:
+		return tlsTransport(http.DefaultTransport, cfg)
+	case *http.Transport:
+		cp := t.Clone()
+		cp.TLSClientConfig = cfg
+		return cp, true
+	case *oauth2.Transport:
+		base, ok := tlsTransport(t.Base, cfg)
+		if !ok {
+			return rt, false
+		}
+		return &oauth2.Transport{Source: t.Source, Base: base}, true
+	case *netrc.Transport:
+		base, ok := tlsTransport(t.Base, cfg)
+		if !ok {
+			return rt, false
+		}
+		return &netrc.Transport{Base: base, Machines: t.Machines}, true
+	default:
+		return rt, false
+	}
+}
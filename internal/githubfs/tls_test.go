@@ -0,0 +1,46 @@
+package githubfs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/posener/gitfs/internal/netrc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestTLSConfig(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	get := func(c Config) error {
+		client, _ := c.clients()
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	assert.Error(t, get(Config{}))
+	assert.NoError(t, get(Config{TLSConfig: &tls.Config{RootCAs: pool}}))
+	assert.NoError(t, get(Config{TLSConfig: &tls.Config{InsecureSkipVerify: true}}))
+
+	// Applied on the base transport of an OAuth2 client.
+	oauthClient := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}))
+	assert.NoError(t, get(Config{Client: oauthClient, TLSConfig: &tls.Config{RootCAs: pool}}))
+	_, ok := oauthClient.Transport.(*oauth2.Transport).Base.(*http.Transport)
+	require.False(t, ok, "the given client should not be modified")
+
+	// Applied on the base transport of a netrc client.
+	netrcClient := &http.Client{Transport: &netrc.Transport{}}
+	assert.NoError(t, get(Config{Client: netrcClient, TLSConfig: &tls.Config{RootCAs: pool}}))
+}
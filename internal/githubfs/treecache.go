@@ -0,0 +1,80 @@
+package githubfs
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/go-github/github"
+)
+
+// TreeCache shares the git trees and the file contents that were fetched
+// from the Github API between filesystems of the same repository. The git
+// tree of a repository at a ref is fetched once for all the filesystems of
+// the different paths in the repository at the same ref. File contents are
+// shared by their git SHA, and are kept as long as the cache is used.
+//
+// A cached tree is never fetched again, such that the cache should be used
+// only as long as the refs of the repository are not expected to change.
+type TreeCache struct {
+	trees map[string]*treeCall
+	blobs map[string][]byte
+	mu    sync.Mutex
+}
+
+// treeCall is a fetch of a git tree that is shared by all the filesystems
+// that need it.
+type treeCall struct {
+	done chan struct{}
+	tree *github.Tree
+	err  error
+}
+
+// NewTreeCache returns an empty cache.
+func NewTreeCache() *TreeCache {
+	return &TreeCache{
+		trees: make(map[string]*treeCall),
+		blobs: make(map[string][]byte),
+	}
+}
+
+// tree returns the git tree of the given key. If it is not cached, it is
+// fetched using get. Concurrent calls with the same key share a single fetch.
+// Failed fetches are not cached.
+func (c *TreeCache) tree(ctx context.Context, key string, get func(context.Context) (*github.Tree, error)) (*github.Tree, error) {
+	c.mu.Lock()
+	if call := c.trees[key]; call != nil {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.tree, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &treeCall{done: make(chan struct{})}
+	c.trees[key] = call
+	c.mu.Unlock()
+
+	call.tree, call.err = get(ctx)
+	if call.err != nil {
+		c.mu.Lock()
+		delete(c.trees, key)
+		c.mu.Unlock()
+	}
+	close(call.done)
+	return call.tree, call.err
+}
+
+// blob returns the cached content of the blob with the given SHA.
+func (c *TreeCache) blob(sha string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	content, ok := c.blobs[sha]
+	return content, ok
+}
+
+func (c *TreeCache) addBlob(sha string, content []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blobs[sha] = content
+}
@@ -0,0 +1,188 @@
+package githubfs
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/diskcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreeCache(t *testing.T) {
+	t.Parallel()
+
+	counter := &countingTransport{base: &mockTransport{}, counts: make(map[string]int)}
+	c := Config{
+		Client: &http.Client{Transport: counter},
+		Trees:  NewTreeCache(),
+	}
+	for _, project := range []string{"github.com/x/y/d1@heads/master", "github.com/x/y/d2@heads/master", "github.com/x/y/d2@heads/master"} {
+		fs, err := New(context.Background(), project, c)
+		require.NoError(t, err)
+		if f, err := fs.Open("f.txt"); err == nil {
+			_, err = ioutil.ReadAll(f)
+			require.NoError(t, err)
+		}
+	}
+	assert.Equal(t, 1, counter.count("/repos/x/y/git/trees/heads/master"))
+	assert.Equal(t, 1, counter.count("/repos/x/y/git/blobs/s1"))
+}
+
+func TestShare(t *testing.T) {
+	t.Parallel()
+
+	counter := &countingTransport{base: &mockTransport{}, counts: make(map[string]int)}
+	c := Share(Config{Client: &http.Client{Transport: counter}})
+	a, err := newGithubFS(context.Background(), "github.com/x/y/d1@heads/master", c)
+	require.NoError(t, err)
+	b, err := newGithubFS(context.Background(), "github.com/x/y/d2@heads/master", c)
+	require.NoError(t, err)
+	assert.True(t, a.httpClient == b.httpClient)
+	assert.True(t, a.client == b.client)
+	// A tree cache is not installed implicitly, since it is not bounded.
+	assert.Nil(t, c.Trees)
+
+	trees := NewTreeCache()
+	c = Share(Config{Client: &http.Client{Transport: counter}, Trees: trees})
+	assert.True(t, c.Trees == trees)
+	for _, project := range []string{"github.com/x/y/d1@heads/master", "github.com/x/y/d2@heads/master"} {
+		_, err := New(context.Background(), project, c)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, counter.count("/repos/x/y/git/trees/heads/master"))
+}
+
+func TestTreeCache_error(t *testing.T) {
+	t.Parallel()
+
+	c := NewTreeCache()
+	calls := 0
+	get := func(context.Context) (*github.Tree, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("failed")
+		}
+		return &github.Tree{}, nil
+	}
+	_, err := c.tree(context.Background(), "key", get)
+	assert.Error(t, err)
+	// Failed fetches are not cached.
+	_, err = c.tree(context.Background(), "key", get)
+	assert.NoError(t, err)
+	_, err = c.tree(context.Background(), "key", get)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestDiskCache(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	counter := &countingTransport{base: &mockTransport{}, counts: make(map[string]int)}
+	// Each filesystem uses a different cache of the same directory, as
+	// different processes do.
+	for i := 0; i < 2; i++ {
+		fs, err := New(context.Background(), "github.com/x/y/d2@heads/master", Config{
+			Client:    &http.Client{Transport: counter},
+			DiskCache: diskcache.New(dir),
+		})
+		require.NoError(t, err)
+		f, err := fs.Open("f.txt")
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, "x", string(content))
+	}
+	assert.Equal(t, 2, counter.count("/repos/x/y/commits/master"))
+	assert.Equal(t, 1, counter.count("/repos/x/y/git/trees/c1"))
+	assert.Equal(t, 1, counter.count("/repos/x/y/git/blobs/s1"))
+}
+
+func TestOffline(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	offline := Config{
+		Client:    &http.Client{Transport: failingTransport{}},
+		DiskCache: diskcache.New(dir),
+		Offline:   true,
+	}
+
+	// Nothing is stored.
+	_, err = New(context.Background(), "github.com/x/y/d2", offline)
+	assert.IsType(t, &OfflineError{}, errors.Cause(err))
+
+	// Store the tree by loading it online.
+	_, err = New(context.Background(), "github.com/x/y/d2", Config{
+		Client:    &http.Client{Transport: &mockTransport{}},
+		DiskCache: diskcache.New(dir),
+	})
+	require.NoError(t, err)
+
+	fs, err := New(context.Background(), "github.com/x/y/d2", offline)
+	require.NoError(t, err)
+	f, err := fs.Open("f.txt")
+	require.NoError(t, err)
+	// The content was not stored.
+	_, err = ioutil.ReadAll(f)
+	assert.IsType(t, &OfflineError{}, errors.Cause(err))
+
+	// Store the content by reading it online.
+	online, err := New(context.Background(), "github.com/x/y/d2@heads/master", Config{
+		Client:    &http.Client{Transport: &mockTransport{}},
+		DiskCache: diskcache.New(dir),
+	})
+	require.NoError(t, err)
+	f, err = online.Open("f.txt")
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(f)
+	require.NoError(t, err)
+
+	f, err = fs.Open("f.txt")
+	require.NoError(t, err)
+	content, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(content))
+
+	// Releases are not supported.
+	_, err = New(context.Background(), "github.com/x/y/releases@v1.0.0", offline)
+	assert.IsType(t, &OfflineError{}, errors.Cause(err))
+}
+
+// failingTransport fails all requests.
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("network is not available")
+}
+
+// countingTransport counts the requests for each URL path.
+type countingTransport struct {
+	base   http.RoundTripper
+	counts map[string]int
+	mu     sync.Mutex
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.counts[req.URL.Path]++
+	t.mu.Unlock()
+	return t.base.RoundTrip(req)
+}
+
+func (t *countingTransport) count(path string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.counts[path]
+}
@@ -0,0 +1,17 @@
+package githubfs
+
+import "net/http"
+
+// userAgentTransport is an http.RoundTripper that sets the User-Agent header
+// of the requests.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A round tripper should not modify the given request.
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,31 @@
+package githubfs
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserAgent(t *testing.T) {
+	t.Parallel()
+	var got []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		got = append(got, req.Header.Get("User-Agent"))
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{}")), Header: make(http.Header), Request: req}, nil
+	})}
+	c := Config{Client: client, UserAgent: "my-app/1.0"}
+	httpClient, githubClient := c.clients()
+
+	_, err := httpClient.Get("https://example.com/")
+	require.NoError(t, err)
+	req, err := githubClient.NewRequest(http.MethodGet, "repos/x/y", nil)
+	require.NoError(t, err)
+	_, err = githubClient.Do(req.Context(), req, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"my-app/1.0", "my-app/1.0"}, got)
+}
@@ -0,0 +1,77 @@
+package githubfs
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// ListVersions returns the tags of the repository of the project that are
+// Semver versions, such as v1.2.3, sorted from the newest version to the
+// oldest. The path and the ref of the project are ignored.
+func ListVersions(ctx context.Context, projectName string, c Config) ([]string, error) {
+	p, err := newProject(projectName)
+	if err != nil {
+		return nil, err
+	}
+	if c.Offline {
+		return nil, &OfflineError{What: "tags of " + p.owner + "/" + p.repo}
+	}
+	if err := c.checkMirrorCredentials(); err != nil {
+		return nil, err
+	}
+	_, client := c.clients()
+	var versions []string
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		tags, resp, err := client.Repositories.ListTags(ctx, p.owner, p.repo, opt)
+		if err != nil {
+			return nil, errors.Wrap(rateLimit(err), "list tags")
+		}
+		for _, tag := range tags {
+			if reSemver.MatchString(tag.GetName()) {
+				versions = append(versions, tag.GetName())
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if c := compareVersions(versions[i], versions[j]); c != 0 {
+			return c > 0
+		}
+		return versions[i] > versions[j]
+	})
+	return versions, nil
+}
+
+// compareVersions compares two Semver versions. Missing minor and patch
+// numbers are zero, such that v1.2 and v1.2.0 are equal.
+func compareVersions(a, b string) int {
+	va, vb := versionNumbers(a), versionNumbers(b)
+	for i := range va {
+		switch {
+		case va[i] > vb[i]:
+			return 1
+		case va[i] < vb[i]:
+			return -1
+		}
+	}
+	return 0
+}
+
+// versionNumbers returns the major, minor and patch numbers of a version
+// that matches reSemver.
+func versionNumbers(v string) [3]int {
+	var n [3]int
+	for i, part := range strings.Split(strings.TrimPrefix(v, "v"), ".") {
+		n[i], _ = strconv.Atoi(part)
+	}
+	return n
+}
@@ -0,0 +1,41 @@
+package githubfs
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListVersions(t *testing.T) {
+	t.Parallel()
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/repos/x/y/tags" {
+			return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}, nil
+		}
+		header := make(http.Header)
+		body := `[{"name":"v1.10.0"},{"name":"latest"},{"name":"v1.2"},{"name":"v1.9.3"}]`
+		if req.URL.Query().Get("page") == "2" {
+			body = `[{"name":"2.0.0"},{"name":"v1.2.0"},{"name":"v2.0.0-rc1"}]`
+		} else {
+			header.Set("Link", `<https://api.github.com/repos/x/y/tags?page=2>; rel="next"`)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     header,
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})}
+
+	versions, err := ListVersions(context.Background(), "github.com/x/y/path@heads/master", Config{Client: client})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2.0.0", "v1.10.0", "v1.9.3", "v1.2.0", "v1.2"}, versions)
+
+	_, err = ListVersions(context.Background(), "github.com/x/z", Config{Client: client})
+	assert.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package glob
 
 import (
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -21,19 +22,25 @@ func New(patterns ...string) (Patterns, error) {
 
 // Match a path to the defined patterns. If it is a file a full match
 // is required. If it is a directory, only matching a prefix of any of
-// the patterns is required.
-func (p Patterns) Match(path string, isDir bool) bool {
+// the patterns is required. Paths and patterns are slash separated on all
+// operating systems, as the paths of the filesystems, and paths are relative
+// to the root of the filesystem, such that a leading slash is ignored. The
+// root directory always matches.
+func (p Patterns) Match(name string, isDir bool) bool {
 	if len(p) == 0 {
 		return true
 	}
-	path = filepath.Clean(path)
-	return (isDir && p.matchPrefix(path)) || (!isDir && p.matchFull(path))
+	name = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+	if isDir && name == "" {
+		return true
+	}
+	return (isDir && p.matchPrefix(name)) || (!isDir && p.matchFull(name))
 }
 
 // matchFull finds a matching of the whole name to any of the patterns.
 func (p Patterns) matchFull(name string) bool {
 	for _, pattern := range p {
-		if ok, _ := filepath.Match(pattern, name); ok {
+		if ok, _ := path.Match(pattern, name); ok {
 			return true
 		}
 	}
@@ -42,15 +49,15 @@ func (p Patterns) matchFull(name string) bool {
 
 // matchPrefix finds a matching of prefix to a prefix of any of the patterns.
 func (p Patterns) matchPrefix(prefix string) bool {
-	parts := strings.Split(prefix, string(filepath.Separator))
+	parts := strings.Split(prefix, "/")
 nextPattern:
 	for _, pattern := range p {
-		patternParts := strings.Split(pattern, string(filepath.Separator))
+		patternParts := strings.Split(pattern, "/")
 		if len(patternParts) < len(parts) {
 			continue
 		}
 		for i := 0; i < len(parts); i++ {
-			if ok, _ := filepath.Match(patternParts[i], parts[i]); !ok {
+			if ok, _ := path.Match(patternParts[i], parts[i]); !ok {
 				continue nextPattern
 			}
 		}
@@ -63,14 +70,14 @@ nextPattern:
 func checkPatterns(patterns []string) error {
 	var badPatterns []string
 	for _, pattern := range patterns {
-		_, err := filepath.Match(pattern, "x")
+		_, err := path.Match(pattern, "x")
 		if err != nil {
 			badPatterns = append(badPatterns, pattern)
 			return errors.Wrap(err, pattern)
 		}
 	}
 	if len(badPatterns) > 0 {
-		return errors.Wrap(filepath.ErrBadPattern, strings.Join(badPatterns, ", "))
+		return errors.Wrap(path.ErrBadPattern, strings.Join(badPatterns, ", "))
 	}
 	return nil
 }
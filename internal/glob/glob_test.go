@@ -19,6 +19,9 @@ func TestMatch(t *testing.T) {
 		{pattern: []string{"foo"}, name: "foo"},
 		{pattern: []string{"*"}, name: "foo"},
 		{pattern: []string{"foo"}, name: "./foo"},
+		{pattern: []string{"foo/*"}, name: "", isDir: true},
+		{pattern: []string{"foo/*"}, name: "/", isDir: true},
+		{pattern: []string{"foo/*"}, name: ".", isDir: true},
 		{pattern: []string{"foo"}, name: "foo/"},
 		{pattern: []string{"foo"}, name: "./foo/"},
 		{pattern: []string{"foo", "bar"}, name: "foo"},
@@ -30,6 +33,10 @@ func TestMatch(t *testing.T) {
 		{pattern: []string{"*/*"}, name: "foo", isDir: true},
 		{pattern: []string{"*"}, name: "foo", isDir: true},
 		{pattern: []string{"foo"}, name: "foo", isDir: true},
+		// Paths are relative to the root.
+		{pattern: []string{"foo"}, name: "/foo"},
+		{pattern: []string{"*/*"}, name: "/foo/bar"},
+		{pattern: []string{"*/*"}, name: "/foo", isDir: true},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,65 @@
+package instrument
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventType is the type of a lifecycle event of a filesystem.
+type EventType int
+
+const (
+	// TreeLoaded is emitted when the filesystem was created and its tree of
+	// files was loaded. It is emitted also when the creation failed.
+	TreeLoaded EventType = iota
+	// FileFetched is emitted when the content of a file was fetched.
+	FileFetched
+	// FetchFailed is emitted when fetching the content of a file failed.
+	FetchFailed
+	// Refreshed is emitted when a watched local filesystem changed, or when
+	// a filesystem was loaded again, and the filesystem serves the changed
+	// files.
+	Refreshed
+	// ServedStale is emitted when loading the current content failed, and a
+	// previous version of the content is served instead.
+	ServedStale
+)
+
+func (t EventType) String() string {
+	switch t {
+	case TreeLoaded:
+		return "TreeLoaded"
+	case FileFetched:
+		return "FileFetched"
+	case FetchFailed:
+		return "FetchFailed"
+	case Refreshed:
+		return "Refreshed"
+	case ServedStale:
+		return "ServedStale"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event is a lifecycle event of a filesystem.
+type Event struct {
+	Type EventType
+	// Project is the project of the filesystem, as given to gitfs.New.
+	Project string
+	// Path is the path of the file in file events.
+	Path string
+	// Duration is the time that the operation took.
+	Duration time.Duration
+	// Err is the error of the operation, if it failed.
+	Err error
+}
+
+// FileEvent returns the event of a file content load.
+func FileEvent(project, path string, d time.Duration, err error) Event {
+	t := FileFetched
+	if err != nil {
+		t = FetchFailed
+	}
+	return Event{Type: t, Project: project, Path: path, Duration: d, Err: err}
+}
@@ -0,0 +1,58 @@
+// Package instrument enables observing the operations of gitfs filesystems,
+// for collecting metrics.
+package instrument
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// Observer observes the operations of a filesystem. Its methods may be called
+// concurrently.
+type Observer interface {
+	// Request is called when a response of a remote request is received. The
+	// status is zero if the request failed without a response.
+	Request(status int, d time.Duration)
+	// Downloaded is called with the number of bytes that were read from the
+	// body of a response.
+	Downloaded(n int)
+	// FileLoaded is called when the content of a file was loaded.
+	FileLoaded(d time.Duration, err error)
+	// Cache is called when content is looked up in the given cache.
+	Cache(name string, hit bool)
+}
+
+// Transport is an http.RoundTripper that reports the requests that are sent
+// using a base round tripper, and the bytes of their responses, to an
+// observer.
+type Transport struct {
+	Base     http.RoundTripper
+	Observer Observer
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		t.Observer.Request(0, time.Since(start))
+		return nil, err
+	}
+	t.Observer.Request(resp.StatusCode, time.Since(start))
+	resp.Body = &countingBody{ReadCloser: resp.Body, observer: t.Observer}
+	return resp, nil
+}
+
+// countingBody reports the number of bytes that are read from a response body.
+type countingBody struct {
+	io.ReadCloser
+	observer Observer
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.observer.Downloaded(n)
+	}
+	return n, err
+}
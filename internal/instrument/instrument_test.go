@@ -0,0 +1,68 @@
+package instrument
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	mu         sync.Mutex
+	statuses   []int
+	downloaded int
+}
+
+func (r *recorder) Request(status int, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses = append(r.statuses, status)
+}
+
+func (r *recorder) Downloaded(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.downloaded += n
+}
+
+func (r *recorder) FileLoaded(time.Duration, error) {}
+
+func (r *recorder) Cache(string, bool) {}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestTransport(t *testing.T) {
+	t.Parallel()
+	var r recorder
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/fail" {
+			return nil, errors.New("failed")
+		}
+		rec := httptest.NewRecorder()
+		rec.WriteString("content")
+		return rec.Result(), nil
+	})
+	client := &http.Client{Transport: &Transport{Base: base, Observer: &r}}
+
+	resp, err := client.Get("http://example.com/ok")
+	require.NoError(t, err)
+	content, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "content", string(content))
+
+	_, err = client.Get("http://example.com/fail")
+	assert.True(t, err != nil && strings.Contains(err.Error(), "failed"))
+
+	assert.Equal(t, []int{200, 0}, r.statuses)
+	assert.Equal(t, 7, r.downloaded)
+}
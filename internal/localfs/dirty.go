@@ -0,0 +1,40 @@
+package localfs
+
+import (
+	"path/filepath"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/pkg/errors"
+)
+
+// dirty returns true if the given sub directory of the working directory of
+// a git repository has changes that were not committed. These are modified,
+// added or removed files, and untracked files that are not ignored.
+func dirty(gitRoot, subDir string) (bool, error) {
+	r, err := git.PlainOpen(gitRoot)
+	if err != nil {
+		return false, errors.Wrap(err, "open repository")
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return false, errors.Wrap(err, "get worktree")
+	}
+	status, err := w.Status()
+	if err != nil {
+		return false, errors.Wrap(err, "get worktree status")
+	}
+	prefix := strings.Trim(filepath.ToSlash(subDir), "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	for path, s := range status {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		if s.Staging != git.Unmodified || s.Worktree != git.Unmodified {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,71 @@
+package localfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirty(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	r, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	write := func(path, content string) {
+		t.Helper()
+		full := filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, ioutil.WriteFile(full, []byte(content), 0644))
+	}
+	write(".gitignore", "*.log\n")
+	write("a/f", "f")
+	write("b/f", "f")
+	_, err = w.Add(".")
+	require.NoError(t, err)
+	_, err = w.Commit("init", &git.CommitOptions{Author: &object.Signature{Name: "Gopher", When: time.Now()}})
+	require.NoError(t, err)
+
+	assertDirty := func(want bool, subDir string) {
+		t.Helper()
+		got, err := dirty(dir, subDir)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, subDir)
+	}
+
+	assertDirty(false, "")
+	assertDirty(false, "a")
+
+	// Ignored files are not changes.
+	write("a/x.log", "x")
+	assertDirty(false, "a")
+
+	// Untracked file.
+	write("a/new", "new")
+	assertDirty(true, "a")
+	assertDirty(true, "")
+	assertDirty(false, "b")
+	require.NoError(t, os.Remove(filepath.Join(dir, "a", "new")))
+
+	// Modified file.
+	write("b/f", "modified")
+	assertDirty(false, "a")
+	assertDirty(true, "b")
+
+	fs, err := New("github.com/x/y/b", dir, false)
+	require.NoError(t, err)
+	assert.True(t, fs.(*ignore).Dirty())
+	fs, err = New("github.com/x/y/a", dir, false)
+	require.NoError(t, err)
+	assert.False(t, fs.(*ignore).Dirty())
+}
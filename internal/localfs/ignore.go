@@ -0,0 +1,117 @@
+package localfs
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/pkg/errors"
+)
+
+// ignore is an object that play the role of an http.FileSystem and an
+// http.File. It wraps a local directory in a git repository, and hides the
+// files that are ignored by the .gitignore files of the repository, and the
+// .git directory.
+type ignore struct {
+	http.FileSystem
+	http.File
+	// path is the path of the opened file, relative to the git root.
+	path    []string
+	matcher gitignore.Matcher
+	// dirty is set in the filesystem if the working directory had changes
+	// that were not committed when it was created.
+	dirty bool
+}
+
+// newIgnore returns a filesystem of the given sub directory of a git
+// repository, without ignored files.
+func newIgnore(gitRoot, subDir string) (*ignore, error) {
+	patterns, err := gitignore.ReadPatterns(osfs.New(gitRoot), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading .gitignore files")
+	}
+	return &ignore{
+		FileSystem: http.Dir(filepath.Join(gitRoot, subDir)),
+		path:       splitPath(subDir),
+		matcher:    gitignore.NewMatcher(patterns),
+	}, nil
+}
+
+// Open a file, relative to the filesystem root. If the file is ignored, an
+// os.ErrNotExist is returned.
+func (i *ignore) Open(name string) (http.File, error) {
+	f, err := i.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	path := join(i.path, splitPath(name)...)
+	if i.ignored(path, info.IsDir()) {
+		f.Close()
+		return nil, os.ErrNotExist
+	}
+	return &ignore{
+		FileSystem: i.FileSystem,
+		File:       f,
+		path:       path,
+		matcher:    i.matcher,
+	}, nil
+}
+
+// Dirty returns true if the working directory had changes that were not
+// committed when the filesystem was created.
+func (i *ignore) Dirty() bool {
+	return i.dirty
+}
+
+// Readdir returns a list of files that are not ignored.
+func (i *ignore) Readdir(count int) ([]os.FileInfo, error) {
+	files, err := i.File.Readdir(count)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]os.FileInfo, 0, len(files))
+	for _, file := range files {
+		path := join(i.path, file.Name())
+		if !i.ignored(path, file.IsDir()) {
+			ret = append(ret, file)
+		}
+	}
+	return ret, nil
+}
+
+// ignored returns true if the given path, or any of its parent directories,
+// is ignored.
+func (i *ignore) ignored(path []string, isDir bool) bool {
+	for j := range path {
+		if path[j] == git.GitDirName {
+			return true
+		}
+		if i.matcher.Match(path[:j+1], isDir || j < len(path)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// join returns a new path of the given path components.
+func join(path []string, elem ...string) []string {
+	return append(path[:len(path):len(path)], elem...)
+}
+
+// splitPath splits a slash separated path to its components.
+func splitPath(path string) []string {
+	path = strings.Trim(filepath.ToSlash(path), "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
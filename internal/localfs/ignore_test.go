@@ -0,0 +1,72 @@
+package localfs
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnore(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	_, err = git.PlainInit(dir, false)
+	require.NoError(t, err)
+	for path, content := range map[string]string{
+		".gitignore":         "node_modules/\n*.log\n",
+		"a.txt":              "a",
+		"b.log":              "b",
+		"node_modules/x.js":  "x",
+		"sub/.gitignore":     "out\n",
+		"sub/in":             "in",
+		"sub/out/y":          "y",
+		"sub/node_modules/z": "z",
+		"other/out":          "out",
+	} {
+		full := filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, ioutil.WriteFile(full, []byte(content), 0644))
+	}
+
+	fs, err := newIgnore(dir, "")
+	require.NoError(t, err)
+	for _, path := range []string{"a.txt", "sub/in", "other/out", ".gitignore"} {
+		_, err := fs.Open(path)
+		assert.NoError(t, err, path)
+	}
+	for _, path := range []string{"b.log", "node_modules", "node_modules/x.js", "sub/out/y", "sub/node_modules/z", ".git", ".git/HEAD"} {
+		_, err := fs.Open(path)
+		assert.True(t, os.IsNotExist(err), path)
+	}
+
+	root, err := fs.Open("/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{".gitignore", "a.txt", "other", "sub"}, names(t, root))
+
+	// A sub directory of the repository.
+	fs, err = newIgnore(dir, "sub")
+	require.NoError(t, err)
+	root, err = fs.Open("/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{".gitignore", "in"}, names(t, root))
+}
+
+func names(t *testing.T, f http.File) []string {
+	t.Helper()
+	files, err := f.Readdir(-1)
+	require.NoError(t, err)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	sort.Strings(names)
+	return names
+}
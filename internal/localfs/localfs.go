@@ -1,37 +1,152 @@
 package localfs
 
 import (
-	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/go-git/go-billy/v5/osfs"
 	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/posener/gitfs/internal/archivefs"
+	"github.com/posener/gitfs/internal/log"
 )
 
-// New returns a Tree for a given github project name.
-func New(projectName string, localPath string) (http.FileSystem, error) {
+// New returns a Tree for a given github project name. If the project has a
+// ref, the content of the ref is returned. Otherwise, the content of the
+// working directory, without the files that are ignored by git, is returned,
+// and it has a Dirty method that reports whether it has uncommitted changes.
+// If matchRemote is set, the git repository should have a remote URL that
+// matches the project. Otherwise, the repository is assumed to be a clone of
+// the project.
+func New(projectName string, localPath string, matchRemote bool) (http.FileSystem, error) {
 	gitRoot, err := lookupGitRoot(localPath)
 	if err != nil {
 		return nil, errors.Wrap(err, "git root not found")
 	}
-	subDir, err := computeSubdir(projectName, gitRoot)
+	subDir := projectSubdir(projectName)
+	if matchRemote {
+		subDir, err = computeSubdir(projectName, gitRoot)
+		if err != nil {
+			return nil, errors.Wrap(err, "git repository does not match project")
+		}
+	}
+	// A bare repository has no working directory, its content is read from
+	// HEAD, unless a ref is given.
+	if ref := revision(projectName); ref != "" || isBare(gitRoot) {
+		return atRef(gitRoot, subDir, ref)
+	}
+	fs, err := newIgnore(gitRoot, subDir)
+	if err != nil {
+		return nil, err
+	}
+	fs.dirty, err = dirty(gitRoot, subDir)
+	if err != nil {
+		log.Warn("Failed checking uncommitted changes", "path", gitRoot, "error", err)
+	} else if fs.dirty {
+		log.Warn("Local directory has uncommitted changes, its content may differ from the remote repository", "path", filepath.Join(gitRoot, subDir))
+	}
+	return fs, nil
+}
+
+// atRef returns a filesystem of the given sub directory of a git repository,
+// with the content of the given ref, or of HEAD if the ref is empty. The content
+// is read from the git object database, and not from the working directory.
+func atRef(gitRoot, subDir, ref string) (http.FileSystem, error) {
+	r, err := gitRepo(gitRoot)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := refCommit(r, ref)
+	if err != nil {
+		return nil, err
+	}
+	gitTree, err := commit.Tree()
+	if err != nil {
+		return nil, errors.Wrap(err, "get commit tree")
+	}
+	if subDir != "" {
+		gitTree, err = gitTree.Tree(subDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get tree of %s", subDir)
+		}
+	}
+	return archivefs.FromGitTree(gitTree, nil)
+}
+
+// refCommit returns the commit of a ref in a local repository. A branch ref
+// of the form `heads/<branch>` is looked up in the local branches, and then
+// in the branches of the remotes. A ref without a `heads/` or `tags/` prefix
+// is a tag, unless it is a full commit SHA. An empty ref is HEAD.
+func refCommit(r *git.Repository, ref string) (*object.Commit, error) {
+	var names []plumbing.ReferenceName
+	switch {
+	case len(ref) == 40 && plumbing.IsHash(ref):
+		commit, err := r.CommitObject(plumbing.NewHash(ref))
+		if err != nil {
+			return nil, errors.Wrapf(err, "get commit %s", ref)
+		}
+		return commit, nil
+	case ref == "":
+		names = append(names, plumbing.HEAD)
+	case strings.HasPrefix(ref, "heads/"):
+		branch := strings.TrimPrefix(ref, "heads/")
+		names = append(names, plumbing.NewBranchReferenceName(branch))
+		remotes, err := r.Remotes()
+		if err != nil {
+			return nil, err
+		}
+		for _, remote := range remotes {
+			names = append(names, plumbing.NewRemoteReferenceName(remote.Config().Name, branch))
+		}
+	default:
+		names = append(names, plumbing.NewTagReferenceName(strings.TrimPrefix(ref, "tags/")))
+	}
+	for _, name := range names {
+		reference, err := r.Reference(name, true)
+		if err == plumbing.ErrReferenceNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "get reference %s", name)
+		}
+		// Annotated tags point to a tag object.
+		if tag, err := r.TagObject(reference.Hash()); err == nil {
+			return tag.Commit()
+		}
+		return r.CommitObject(reference.Hash())
+	}
+	return nil, errors.Errorf("ref %s not found in local repository", ref)
+}
+
+// NewDir returns a filesystem of a local directory. Unlike New, the directory
+// is not required to be in a git repository.
+func NewDir(path string) (http.FileSystem, error) {
+	st, err := os.Stat(path)
 	if err != nil {
-		return nil, errors.Wrap(err, "git repository does not match project")
+		return nil, err
+	}
+	if !st.IsDir() {
+		return nil, errors.Errorf("%s is not a directory", path)
 	}
-	return http.Dir(filepath.Join(gitRoot, subDir)), nil
+	return http.Dir(path), nil
 }
 
-// match validates tha the git repository has a remote URL that matches
-// the given project.
+// computeSubdir validates that the git repository has a remote URL that
+// matches the given project, and returns the path of the project in the
+// repository. Remote URLs are matched in any of the URL forms that git
+// supports, and are not case sensitive.
 func computeSubdir(projectName, gitRoot string) (string, error) {
 	projectName = cleanRevision(projectName)
+	lower := strings.ToLower(projectName)
 	r, err := gitRepo(gitRoot)
 	if err != nil {
 		return "", err
@@ -42,18 +157,50 @@ func computeSubdir(projectName, gitRoot string) (string, error) {
 	}
 	for _, remote := range remotes {
 		for _, url := range remote.Config().URLs {
-			project := urlProjectName(url)
-			if projectName == project {
+			project := strings.ToLower(urlProjectName(url))
+			if project == "" {
+				continue
+			}
+			if lower == project {
 				return "", nil
 			}
-			if strings.HasPrefix(projectName, project+"/") {
-				return strings.TrimPrefix(projectName, project+"/"), nil
+			if strings.HasPrefix(lower, project+"/") {
+				return projectName[len(project)+1:], nil
 			}
 		}
 	}
 	return "", errors.New("non of remote URLs matched")
 }
 
+// projectSubdir returns the path of the project in its repository, according
+// to the project name.
+func projectSubdir(projectName string) string {
+	parts := strings.SplitN(cleanRevision(projectName), "/", 4)
+	if len(parts) < 4 {
+		return ""
+	}
+	return unescape(parts[3])
+}
+
+// revision returns the ref of a project name, or an empty string if it has
+// no ref.
+func revision(projectName string) string {
+	i := strings.Index(projectName, "@")
+	if i < 0 {
+		return ""
+	}
+	return unescape(projectName[i+1:])
+}
+
+// unescape decodes URL percent-encoding in a part of a project name. Invalid
+// encodings are kept as is.
+func unescape(s string) string {
+	if u, err := url.PathUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
+
 func cleanRevision(projectName string) string {
 	i := strings.Index(projectName, "@")
 	if i < 0 {
@@ -85,6 +232,9 @@ func lookupGitRoot(path string) (string, error) {
 		if _, err := os.Stat(filepath.Join(path, git.GitDirName)); err == nil {
 			return path, nil
 		}
+		if isBare(path) {
+			return path, nil
+		}
 		path, _ = filepath.Split(path)
 		if len(path) > 0 && path[len(path)-1] == filepath.Separator {
 			path = path[:len(path)-1]
@@ -93,11 +243,121 @@ func lookupGitRoot(path string) (string, error) {
 	return "", errors.New("not git repository")
 }
 
+// isBare returns true if the given path is a bare git repository.
+func isBare(path string) bool {
+	if _, err := os.Stat(filepath.Join(path, git.GitDirName)); err == nil {
+		return false
+	}
+	head, err := os.Stat(filepath.Join(path, "HEAD"))
+	if err != nil || head.IsDir() {
+		return false
+	}
+	objects, err := os.Stat(filepath.Join(path, "objects"))
+	return err == nil && objects.IsDir()
+}
+
+// urlProjectName returns the project name of a git remote URL, of the form
+// <host>/<owner>/<repo>. The URL can be an scp-like SSH URL, such as
+// git@github.com:owner/repo.git. It returns an empty string for an invalid
+// URL.
 func urlProjectName(urlStr string) string {
-	url, err := url.Parse(urlStr)
+	if !strings.Contains(urlStr, "://") {
+		i := strings.Index(urlStr, ":")
+		if i <= 0 || strings.Contains(urlStr[:i], "/") {
+			return ""
+		}
+		host := urlStr[:i]
+		if j := strings.LastIndex(host, "@"); j >= 0 {
+			host = host[j+1:]
+		}
+		return joinProjectName(host, urlStr[i+1:])
+	}
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return ""
+	}
+	return joinProjectName(u.Hostname(), u.Path)
+}
+
+func joinProjectName(host, path string) string {
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	return host + "/" + path
+}
+
+// DetectProject returns the project name of the Go module that contains the
+// given directory, and the root directory of the module. The project name is
+// derived from the remote URL of the git repository of the module, preferring
+// the "origin" remote, and from the path of the module in the repository. If
+// the repository has no remote, the module path in the go.mod file is used.
+func DetectProject(dir string) (project string, root string, err error) {
+	gitRoot, err := lookupGitRoot(dir)
+	if err != nil {
+		return "", "", errors.Wrap(err, "git root not found")
+	}
+	root, modulePath := lookupModule(dir, gitRoot)
+	if root == "" {
+		root = gitRoot
+	}
+
+	r, err := gitRepo(gitRoot)
 	if err != nil {
-		panic(fmt.Sprintf("failed parsing URL: %s", urlStr))
+		return "", "", err
+	}
+	remotes, err := r.Remotes()
+	if err != nil {
+		return "", "", err
+	}
+	sort.SliceStable(remotes, func(i, j int) bool {
+		return remotes[i].Config().Name == git.DefaultRemoteName
+	})
+	for _, remote := range remotes {
+		for _, url := range remote.Config().URLs {
+			if project = urlProjectName(url); project == "" {
+				continue
+			}
+			rel, err := filepath.Rel(gitRoot, root)
+			if err != nil {
+				return "", "", err
+			}
+			if rel != "." {
+				project += "/" + filepath.ToSlash(rel)
+			}
+			return project, root, nil
+		}
+	}
+	if modulePath != "" {
+		return modulePath, root, nil
+	}
+	return "", "", errors.New("no remote URL or go.mod file found")
+}
+
+// lookupModule returns the root directory and the module path of the Go
+// module that contains the given directory. Only directories under gitRoot
+// are searched. It returns empty strings if no go.mod file was found.
+func lookupModule(dir, gitRoot string) (root string, modulePath string) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", ""
+	}
+	for strings.HasPrefix(dir, gitRoot) {
+		if content, err := ioutil.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, parseModulePath(content)
+		}
+		if dir == gitRoot {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	return "", ""
+}
+
+// parseModulePath returns the module path from the content of a go.mod file.
+func parseModulePath(goMod []byte) string {
+	for _, line := range strings.Split(string(goMod), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], "\"`")
+		}
 	}
-	url.Path = strings.TrimSuffix(url.Path, ".git")
-	return url.Host + url.Path
+	return ""
 }
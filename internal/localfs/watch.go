@@ -0,0 +1,99 @@
+package localfs
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/posener/gitfs/internal/log"
+)
+
+// stamp identifies a version of a file in a snapshot of a filesystem.
+type stamp struct {
+	modTime time.Time
+	size    int64
+	isDir   bool
+}
+
+// Watch starts polling the given filesystem every interval, and calls
+// onChange when a file was added, removed or modified since the previous poll,
+// with the duration of the poll that detected the change.
+// Polling stops when the context is done. Files are compared by their
+// modification time and size, such that only files that are visible in the
+// filesystem are watched, and ignored files do not trigger changes. A poll
+// reads only the listings of the visible directories, such that ignored
+// directories are not walked and files are not opened. Polling is used,
+// instead of file system notifications, since it works the same on all
+// platforms and does not require adding watches for new directories.
+func Watch(ctx context.Context, fs http.FileSystem, interval time.Duration, onChange func(time.Duration)) {
+	prev := snapshot(fs)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			start := time.Now()
+			cur := snapshot(fs)
+			if !changed(prev, cur) {
+				continue
+			}
+			log.Debug("Local filesystem changed")
+			prev = cur
+			onChange(time.Since(start))
+		}
+	}()
+}
+
+// snapshot returns the stamps of all the files in the filesystem, by their
+// paths relative to its root. The stamps are taken from the directory
+// listings, and directories that failed to be read are considered empty.
+func snapshot(fs http.FileSystem) map[string]stamp {
+	s := make(map[string]stamp)
+	var walk func(dir string)
+	walk = func(dir string) {
+		f, err := fs.Open("/" + dir)
+		if err != nil {
+			return
+		}
+		infos, err := f.Readdir(-1)
+		f.Close()
+		if err != nil {
+			return
+		}
+		for _, info := range infos {
+			name := path.Join(dir, info.Name())
+			s[name] = newStamp(info)
+			if info.IsDir() {
+				walk(name)
+			}
+		}
+	}
+	walk("")
+	return s
+}
+
+func newStamp(info os.FileInfo) stamp {
+	st := stamp{modTime: info.ModTime(), isDir: info.IsDir()}
+	if !st.isDir {
+		st.size = info.Size()
+	}
+	return st
+}
+
+func changed(prev, cur map[string]stamp) bool {
+	if len(prev) != len(cur) {
+		return true
+	}
+	for path, st := range cur {
+		if p, ok := prev[path]; !ok || !p.modTime.Equal(st.modTime) || p.size != st.size || p.isDir != st.isDir {
+			return true
+		}
+	}
+	return false
+}
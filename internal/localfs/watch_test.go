@@ -0,0 +1,127 @@
+package localfs
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs-watch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan struct{}, 10)
+	Watch(ctx, http.Dir(dir), 10*time.Millisecond, func(time.Duration) { changes <- struct{}{} })
+
+	// No changes.
+	select {
+	case <-changes:
+		t.Fatal("unexpected change")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Modified file.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("aa"), 0644))
+	waitChange(t, changes)
+
+	// Added file in a new directory.
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "d"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "d", "b"), []byte("b"), 0644))
+	waitChange(t, changes)
+
+	// Removed file.
+	require.NoError(t, os.Remove(filepath.Join(dir, "a")))
+	waitChange(t, changes)
+
+	// No changes are detected after the context is done.
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	for len(changes) > 0 {
+		<-changes
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "c"), []byte("c"), 0644))
+	select {
+	case <-changes:
+		t.Fatal("unexpected change")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs-watch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".gitignore"), []byte("ignored/\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "d"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "d", "b"), []byte("bb"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "ignored"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ignored", "c"), []byte("c"), 0644))
+
+	ignore, err := newIgnore(dir, "")
+	require.NoError(t, err)
+	fs := &openRecorder{FileSystem: ignore}
+	s := snapshot(fs)
+
+	var paths []string
+	for path := range s {
+		paths = append(paths, path)
+	}
+	assert.ElementsMatch(t, []string{".gitignore", "a", "d", "d/b"}, paths)
+	assert.Equal(t, int64(2), s["d/b"].size)
+	// Only the visible directories are read, and files are not opened.
+	assert.Equal(t, []string{"/", "/d"}, fs.opened)
+}
+
+func TestChanged(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	s := map[string]stamp{"a": {modTime: now, size: 1}}
+
+	assert.False(t, changed(s, map[string]stamp{"a": {modTime: now, size: 1}}))
+	assert.True(t, changed(s, map[string]stamp{"a": {modTime: now, size: 2}}))
+	assert.True(t, changed(s, map[string]stamp{"a": {modTime: now.Add(time.Second), size: 1}}))
+	assert.True(t, changed(s, map[string]stamp{"b": {modTime: now, size: 1}}))
+	assert.True(t, changed(s, map[string]stamp{}))
+}
+
+func waitChange(t *testing.T, changes <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("change was not detected")
+	}
+	// Drain changes of the same modification that were detected in
+	// consecutive polls.
+	for {
+		select {
+		case <-changes:
+		case <-time.After(50 * time.Millisecond):
+			return
+		}
+	}
+}
+
+// openRecorder records the names of the opened files.
+type openRecorder struct {
+	http.FileSystem
+	opened []string
+}
+
+func (r *openRecorder) Open(name string) (http.File, error) {
+	r.opened = append(r.opened, name)
+	return r.FileSystem.Open(name)
+}
@@ -0,0 +1,155 @@
+// Package lockfile reads and writes gitfs lock files, which pin projects to
+// the commits that their refs pointed to when they were locked.
+//
+// A lock file has a line for each project, with the project name, the SHA of
+// the locked commit and a hash of the content of the project's filesystem:
+//
+//	github.com/x/y/static@v1.2.3 0123456789abcdef0123456789abcdef01234567 h1:...
+//
+// Lines are sorted by the project names, such that changes to the locked
+// commits are reviewable diffs. Empty lines and lines that start with "#" are
+// ignored.
+package lockfile
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/fsutil"
+)
+
+// header is written at the top of lock files.
+const header = "# Generated by gitfs. Update with `gitfs -lock <file> -update-lock`.\n"
+
+// Entry is the locked state of a project.
+type Entry struct {
+	// Commit is the SHA of the locked commit.
+	Commit string
+	// Hash is the hash of the content of the project, as returned by Hash.
+	// It may be empty.
+	Hash string
+}
+
+// File is the content of a lock file, the entries by the project names.
+type File map[string]Entry
+
+// Read reads a lock file.
+func Read(path string) (File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse parses the content of a lock file.
+func Parse(r io.Reader) (File, error) {
+	lf := make(File)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, errors.Errorf("line %d: expected project, commit and hash", line)
+		}
+		e := Entry{Commit: fields[1]}
+		if len(fields) == 3 {
+			e.Hash = fields[2]
+		}
+		if _, ok := lf[fields[0]]; ok {
+			return nil, errors.Errorf("line %d: project %s locked multiple times", line, fields[0])
+		}
+		lf[fields[0]] = e
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lf, nil
+}
+
+// Write writes the lock file to the given path.
+func (lf File) Write(path string) error {
+	var b bytes.Buffer
+	lf.encode(&b)
+	return ioutil.WriteFile(path, b.Bytes(), 0644)
+}
+
+func (lf File) encode(w io.Writer) {
+	projects := make([]string, 0, len(lf))
+	for project := range lf {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+	io.WriteString(w, header)
+	for _, project := range projects {
+		e := lf[project]
+		line := project + " " + e.Commit
+		if e.Hash != "" {
+			line += " " + e.Hash
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// Pin returns the project name with its ref replaced by the given commit.
+func Pin(project, commit string) string {
+	if i := strings.Index(project, "@"); i >= 0 {
+		project = project[:i]
+	}
+	return project + "@" + commit
+}
+
+// Hash returns a hash of the content of a filesystem. It is computed from the
+// paths and the contents of all its files, such that it does not depend on
+// the order of the files in directories, and it is prefixed by the version
+// of the hash algorithm.
+func Hash(fs http.FileSystem) (string, error) {
+	var lines []string
+	walker := fsutil.Walk(fs, "")
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return "", errors.Wrapf(err, "walking %s", walker.Path())
+		}
+		if walker.Stat().IsDir() {
+			continue
+		}
+		sum, err := fileSum(fs, walker.Path())
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, fmt.Sprintf("%x  %s\n", sum, strings.TrimPrefix(walker.Path(), "/")))
+	}
+	sort.Strings(lines)
+	h := sha256.New()
+	for _, line := range lines {
+		io.WriteString(h, line)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+func fileSum(fs http.FileSystem, path string) ([]byte, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening %s", path)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, errors.Wrapf(err, "reading %s", path)
+	}
+	return h.Sum(nil), nil
+}
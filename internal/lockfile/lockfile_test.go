@@ -0,0 +1,92 @@
+package lockfile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	lf, err := Parse(strings.NewReader(`# Comment
+
+github.com/x/y@v1.0.0 c1 h1:abc
+github.com/x/z c2
+`))
+	require.NoError(t, err)
+	assert.Equal(t, File{
+		"github.com/x/y@v1.0.0": {Commit: "c1", Hash: "h1:abc"},
+		"github.com/x/z":        {Commit: "c2"},
+	}, lf)
+
+	var b bytes.Buffer
+	lf.encode(&b)
+	assert.Equal(t, header+"github.com/x/y@v1.0.0 c1 h1:abc\ngithub.com/x/z c2\n", b.String())
+
+	got, err := Parse(&b)
+	require.NoError(t, err)
+	assert.Equal(t, lf, got)
+}
+
+func TestParse_error(t *testing.T) {
+	t.Parallel()
+	_, err := Parse(strings.NewReader("github.com/x/y\n"))
+	assert.Error(t, err)
+	_, err = Parse(strings.NewReader("github.com/x/y c1 h1 extra\n"))
+	assert.Error(t, err)
+	_, err = Parse(strings.NewReader("github.com/x/y c1\ngithub.com/x/y c2\n"))
+	assert.Error(t, err)
+}
+
+func TestWriteRead(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gitfs.lock")
+
+	lf := File{"github.com/x/y": {Commit: "c1", Hash: "h1:abc"}}
+	require.NoError(t, lf.Write(path))
+	got, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, lf, got)
+}
+
+func TestPin(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "github.com/x/y@c1", Pin("github.com/x/y", "c1"))
+	assert.Equal(t, "github.com/x/y/d@c1", Pin("github.com/x/y/d@heads/release/1.x", "c1"))
+}
+
+func TestHash(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	write := func(path, content string) {
+		t.Helper()
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, path), []byte(content), 0644))
+	}
+	write("a", "a")
+	write("d/b", "b")
+
+	h1, err := Hash(http.Dir(dir))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(h1, "h1:"))
+	h2, err := Hash(http.Dir(dir))
+	require.NoError(t, err)
+	assert.Equal(t, h1, h2)
+
+	write("d/b", "c")
+	h3, err := Hash(http.Dir(dir))
+	require.NoError(t, err)
+	assert.NotEqual(t, h1, h3)
+}
@@ -1,15 +1,185 @@
 // Package log enables controlling gitfs logging.
 package log
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Logger is a logger of formatted messages, such as the standard library
+// *log.Logger.
 type Logger interface {
 	Printf(format string, v ...interface{})
 }
 
-var Log Logger = nil
+// Leveled is a logger with levels. Messages are constant strings, and their
+// variable data is given as alternating keys and values. The standard library
+// *slog.Logger implements this interface.
+type Leveled interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// Sugared is a logger with levels, that its methods have a `w` suffix, such
+// as zap's *SugaredLogger.
+type Sugared interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+}
+
+// Formatted is a logger with levels of formatted messages, such as logrus'
+// *Logger.
+type Formatted interface {
+	Debugf(format string, v ...interface{})
+	Infof(format string, v ...interface{})
+	Warnf(format string, v ...interface{})
+	Errorf(format string, v ...interface{})
+}
+
+// Log is the logger of gitfs. If nil, no logging is done. Secrets, such as
+// tokens and signed URLs, are redacted from the logged messages and values.
+var Log Leveled = nil
+
+// Debug logs a message that is useful for debugging gitfs.
+func Debug(msg string, keysAndValues ...interface{}) {
+	if Log == nil {
+		return
+	}
+	Log.Debug(Redact(msg), redactValues(keysAndValues)...)
+}
+
+// Info logs an informative message.
+func Info(msg string, keysAndValues ...interface{}) {
+	if Log == nil {
+		return
+	}
+	Log.Info(Redact(msg), redactValues(keysAndValues)...)
+}
 
-func Printf(format string, v ...interface{}) {
+// Warn logs a message about an unexpected state that gitfs recovered from.
+func Warn(msg string, keysAndValues ...interface{}) {
 	if Log == nil {
 		return
 	}
-	Log.Printf(format, v...)
+	Log.Warn(Redact(msg), redactValues(keysAndValues)...)
+}
+
+// Error logs a message about a failure.
+func Error(msg string, keysAndValues ...interface{}) {
+	if Log == nil {
+		return
+	}
+	Log.Error(Redact(msg), redactValues(keysAndValues)...)
+}
+
+// FromPrintf returns a leveled logger that logs each message in a single line,
+// with its level and its keys and values. For example:
+//
+//	INFO Loaded project project=github.com/x/y files=3
+func FromPrintf(l Logger) Leveled {
+	return printf{l}
+}
+
+// FromSugared returns a leveled logger that uses the given sugared logger.
+func FromSugared(l Sugared) Leveled {
+	return sugared{l}
+}
+
+// FromFormatted returns a leveled logger that logs each message with its keys
+// and values in a single line, in the matching level of the given logger.
+func FromFormatted(l Formatted) Leveled {
+	return formatted{l}
+}
+
+type printf struct {
+	Logger
+}
+
+func (p printf) Debug(msg string, keysAndValues ...interface{}) {
+	p.print("DEBUG", msg, keysAndValues)
+}
+
+func (p printf) Info(msg string, keysAndValues ...interface{}) {
+	p.print("INFO", msg, keysAndValues)
+}
+
+func (p printf) Warn(msg string, keysAndValues ...interface{}) {
+	p.print("WARN", msg, keysAndValues)
+}
+
+func (p printf) Error(msg string, keysAndValues ...interface{}) {
+	p.print("ERROR", msg, keysAndValues)
+}
+
+func (p printf) print(level, msg string, keysAndValues []interface{}) {
+	p.Printf("%s %s", level, line(msg, keysAndValues))
+}
+
+// line returns a message with its keys and values, in a single line.
+func line(msg string, keysAndValues []interface{}) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		var value interface{} = "!MISSING"
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		fmt.Fprintf(&b, " %v=%s", keysAndValues[i], formatValue(value))
+	}
+	return b.String()
+}
+
+// formatValue formats a value of a key, and quotes it if it is empty or
+// contains spaces, such that the logged line is unambiguous.
+func formatValue(value interface{}) string {
+	s := fmt.Sprint(value)
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+type sugared struct {
+	Sugared
+}
+
+func (s sugared) Debug(msg string, keysAndValues ...interface{}) {
+	s.Debugw(msg, keysAndValues...)
+}
+
+func (s sugared) Info(msg string, keysAndValues ...interface{}) {
+	s.Infow(msg, keysAndValues...)
+}
+
+func (s sugared) Warn(msg string, keysAndValues ...interface{}) {
+	s.Warnw(msg, keysAndValues...)
+}
+
+func (s sugared) Error(msg string, keysAndValues ...interface{}) {
+	s.Errorw(msg, keysAndValues...)
+}
+
+type formatted struct {
+	Formatted
+}
+
+func (f formatted) Debug(msg string, keysAndValues ...interface{}) {
+	f.Debugf("%s", line(msg, keysAndValues))
+}
+
+func (f formatted) Info(msg string, keysAndValues ...interface{}) {
+	f.Infof("%s", line(msg, keysAndValues))
+}
+
+func (f formatted) Warn(msg string, keysAndValues ...interface{}) {
+	f.Warnf("%s", line(msg, keysAndValues))
+}
+
+func (f formatted) Error(msg string, keysAndValues ...interface{}) {
+	f.Errorf("%s", line(msg, keysAndValues))
 }
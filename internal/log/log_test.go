@@ -0,0 +1,94 @@
+package log
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromPrintf(t *testing.T) {
+	t.Parallel()
+	var p printer
+	l := FromPrintf(&p)
+
+	l.Info("Loaded project", "project", "github.com/x/y", "files", 3)
+	l.Warn("Failed", "error", "no such file")
+	l.Debug("Empty", "path", "")
+	l.Error("Odd", "key")
+
+	assert.Equal(t, []string{
+		"INFO Loaded project project=github.com/x/y files=3",
+		`WARN Failed error="no such file"`,
+		`DEBUG Empty path=""`,
+		"ERROR Odd key=!MISSING",
+	}, p.lines)
+}
+
+func TestFromSugared(t *testing.T) {
+	t.Parallel()
+	var s sugaredRecorder
+	l := FromSugared(&s)
+
+	l.Debug("d", "k", 1)
+	l.Info("i")
+	l.Warn("w")
+	l.Error("e")
+
+	assert.Equal(t, []string{"debug d [k 1]", "info i []", "warn w []", "error e []"}, s.lines)
+}
+
+func TestFromFormatted(t *testing.T) {
+	t.Parallel()
+	var f formattedRecorder
+	l := FromFormatted(&f)
+
+	l.Debug("d", "k", 1)
+	l.Info("i")
+	l.Warn("w")
+	l.Error("e", "k", "v")
+
+	assert.Equal(t, []string{"debug d k=1", "info i", "warn w", "error e k=v"}, f.lines)
+}
+
+func TestLevels_noLogger(t *testing.T) {
+	// Logging without a logger does nothing.
+	Debug("d")
+	Info("i")
+	Warn("w")
+	Error("e")
+}
+
+type printer struct {
+	lines []string
+}
+
+func (p *printer) Printf(format string, v ...interface{}) {
+	p.lines = append(p.lines, fmt.Sprintf(format, v...))
+}
+
+type sugaredRecorder struct {
+	lines []string
+}
+
+func (s *sugaredRecorder) record(level, msg string, kv []interface{}) {
+	s.lines = append(s.lines, fmt.Sprintf("%s %s %v", level, msg, kv))
+}
+
+func (s *sugaredRecorder) Debugw(msg string, kv ...interface{}) { s.record("debug", msg, kv) }
+func (s *sugaredRecorder) Infow(msg string, kv ...interface{})  { s.record("info", msg, kv) }
+func (s *sugaredRecorder) Warnw(msg string, kv ...interface{})  { s.record("warn", msg, kv) }
+func (s *sugaredRecorder) Errorw(msg string, kv ...interface{}) { s.record("error", msg, kv) }
+
+type formattedRecorder struct {
+	lines []string
+}
+
+func (f *formattedRecorder) record(level, format string, v []interface{}) {
+	f.lines = append(f.lines, level+" "+fmt.Sprintf(format, v...))
+}
+
+func (f *formattedRecorder) Debugf(format string, v ...interface{}) { f.record("debug", format, v) }
+func (f *formattedRecorder) Infof(format string, v ...interface{})  { f.record("info", format, v) }
+func (f *formattedRecorder) Warnf(format string, v ...interface{})  { f.record("warn", format, v) }
+func (f *formattedRecorder) Errorf(format string, v ...interface{}) { f.record("error", format, v) }
@@ -0,0 +1,9 @@
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import "log/slog"
+
+// The standard library structured logger is a leveled logger.
+var _ Leveled = (*slog.Logger)(nil)
@@ -0,0 +1,163 @@
+// Package netrc reads credentials from a netrc file, as go get and curl do,
+// and authorizes HTTP requests with them.
+package netrc
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// githubHost is the machine of the credentials that are used for the hosts
+// of github.com, such as api.github.com and raw.githubusercontent.com, when
+// there are no credentials for the host itself.
+const githubHost = "github.com"
+
+// Machine is an entry in a netrc file.
+type Machine struct {
+	// Name of the machine. It is empty for the default entry.
+	Name     string
+	Login    string
+	Password string
+}
+
+// Machines are the entries of a netrc file.
+type Machines []Machine
+
+// Parse parses the content of a netrc file. Tokens that are not supported,
+// and macro definitions, are ignored.
+func Parse(data string) Machines {
+	var (
+		ms     Machines
+		cur    *Machine
+		fields = strings.Fields(data)
+	)
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			if i+1 < len(fields) {
+				i++
+				ms = append(ms, Machine{Name: fields[i]})
+				cur = &ms[len(ms)-1]
+			}
+		case "default":
+			ms = append(ms, Machine{})
+			cur = &ms[len(ms)-1]
+		case "login", "password", "account":
+			if i+1 >= len(fields) {
+				break
+			}
+			i++
+			if cur == nil {
+				continue
+			}
+			switch fields[i-1] {
+			case "login":
+				cur.Login = fields[i]
+			case "password":
+				cur.Password = fields[i]
+			}
+		case "macdef":
+			// A macro definition ends with an empty line, which is lost in
+			// the fields, so the rest of the file is skipped.
+			return ms
+		}
+	}
+	return ms
+}
+
+// Load loads the netrc file of the current user. The path of the file is
+// taken from the NETRC environment variable, or it is .netrc in the home
+// directory (_netrc on Windows). A missing file results in no machines.
+func Load(getenv func(string) string) (Machines, error) {
+	path := Path(getenv)
+	if path == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(data)), nil
+}
+
+// Path returns the path of the netrc file of the current user, or an empty
+// string if it can not be determined.
+func Path(getenv func(string) string) string {
+	if path := getenv("NETRC"); path != "" {
+		return path
+	}
+	home := getenv("HOME")
+	name := ".netrc"
+	if runtime.GOOS == "windows" {
+		home = getenv("USERPROFILE")
+		name = "_netrc"
+	}
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, name)
+}
+
+// Lookup returns the credentials for the given host. Hosts of github.com
+// fall back to the credentials of github.com, and all hosts fall back to the
+// default entry.
+func (ms Machines) Lookup(host string) (Machine, bool) {
+	if m, ok := ms.find(host); ok {
+		return m, true
+	}
+	if isGithub(host) {
+		if m, ok := ms.find(githubHost); ok {
+			return m, true
+		}
+	}
+	return ms.find("")
+}
+
+func (ms Machines) find(name string) (Machine, bool) {
+	for _, m := range ms {
+		if m.Name == name && m.Password != "" {
+			return m, true
+		}
+	}
+	return Machine{}, false
+}
+
+// isGithub returns true for hosts that serve github.com content.
+func isGithub(host string) bool {
+	return host == githubHost || strings.HasSuffix(host, "."+githubHost) ||
+		host == "githubusercontent.com" || strings.HasSuffix(host, ".githubusercontent.com")
+}
+
+// Transport authorizes requests with basic authentication, using the
+// credentials of their host. Requests that are already authorized, and
+// requests to hosts without credentials, are sent as is.
+type Transport struct {
+	Base     http.RoundTripper
+	Machines Machines
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Header.Get("Authorization") != "" {
+		return base.RoundTrip(req)
+	}
+	m, ok := t.Machines.Lookup(req.URL.Hostname())
+	if !ok {
+		return base.RoundTrip(req)
+	}
+	// The request should not be modified by the transport.
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(m.Login, m.Password)
+	return base.RoundTrip(req)
+}
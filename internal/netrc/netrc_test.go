@@ -0,0 +1,112 @@
+package netrc
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const content = `
+machine github.com
+	login user
+	password token
+
+machine ghe.example.com login ghe-user password ghe-token account acc
+
+default login anon password anon-token
+
+macdef init
+	machine ignored.com login x password y
+`
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	want := Machines{
+		{Name: "github.com", Login: "user", Password: "token"},
+		{Name: "ghe.example.com", Login: "ghe-user", Password: "ghe-token"},
+		{Login: "anon", Password: "anon-token"},
+	}
+	assert.Equal(t, want, Parse(content))
+	assert.Empty(t, Parse(""))
+	assert.Empty(t, Parse("login x password y"))
+}
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+	ms := Parse(content)
+
+	tests := []struct {
+		host      string
+		wantLogin string
+	}{
+		{host: "github.com", wantLogin: "user"},
+		{host: "api.github.com", wantLogin: "user"},
+		{host: "raw.githubusercontent.com", wantLogin: "user"},
+		{host: "ghe.example.com", wantLogin: "ghe-user"},
+		{host: "other.com", wantLogin: "anon"},
+	}
+	for _, tt := range tests {
+		m, ok := ms.Lookup(tt.host)
+		assert.True(t, ok, tt.host)
+		assert.Equal(t, tt.wantLogin, m.Login, tt.host)
+	}
+
+	_, ok := Parse("machine github.com login user password token").Lookup("other.com")
+	assert.False(t, ok)
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "gitfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "netrc")
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+
+	ms, err := Load(env(map[string]string{"NETRC": path}))
+	require.NoError(t, err)
+	assert.Len(t, ms, 3)
+
+	ms, err = Load(env(map[string]string{"NETRC": filepath.Join(dir, "missing")}))
+	require.NoError(t, err)
+	assert.Empty(t, ms)
+
+	ms, err = Load(env(nil))
+	require.NoError(t, err)
+	assert.Empty(t, ms)
+}
+
+func TestTransport(t *testing.T) {
+	t.Parallel()
+	var gotUser, gotPassword string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, gotPassword, _ = r.BasicAuth()
+	}))
+	defer s.Close()
+
+	client := &http.Client{Transport: &Transport{
+		Machines: Parse("machine 127.0.0.1 login user password token"),
+	}}
+	_, err := client.Get(s.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "user", gotUser)
+	assert.Equal(t, "token", gotPassword)
+
+	// Authorized requests are not modified.
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("other", "other-token")
+	_, err = client.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, "other", gotUser)
+}
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
@@ -0,0 +1,88 @@
+// Package normfs normalizes the Unicode forms of file names in a filesystem.
+//
+// The same file name can be encoded in different Unicode forms: macOS stores
+// composed characters, such as "é", decomposed (NFD), while most other
+// systems and the Github API keep them as they were given, which is usually
+// composed (NFC). A name that was typed in one form does not find a file that
+// was stored in the other.
+package normfs
+
+import (
+	"net/http"
+	"os"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// New returns a filesystem in which the names of the files in directory
+// listings are in the given form, and files can be opened by names in any
+// form.
+func New(fs http.FileSystem, form norm.Form) http.FileSystem {
+	return &normFS{fs: fs, form: form}
+}
+
+type normFS struct {
+	fs   http.FileSystem
+	form norm.Form
+}
+
+// Open opens the file of the given name. If it does not exist, the name is
+// tried in its NFC and NFD forms.
+func (n *normFS) Open(name string) (http.File, error) {
+	f, err := n.fs.Open(name)
+	if os.IsNotExist(err) {
+		for _, form := range []norm.Form{norm.NFC, norm.NFD} {
+			if alt := form.String(name); alt != name {
+				if altF, altErr := n.fs.Open(alt); altErr == nil {
+					f, err = altF, nil
+					break
+				}
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Only directories are wrapped, such that files keep the methods of the
+	// underlying filesystem.
+	st, err := f.Stat()
+	if err != nil || !st.IsDir() {
+		return f, nil
+	}
+	return &dir{File: f, form: n.form}, nil
+}
+
+// dir is a directory that lists its files with normalized names.
+type dir struct {
+	http.File
+	form norm.Form
+}
+
+func (d *dir) Readdir(count int) ([]os.FileInfo, error) {
+	infos, err := d.File.Readdir(count)
+	for i, info := range infos {
+		if name := d.form.String(info.Name()); name != info.Name() {
+			infos[i] = renamedInfo{FileInfo: info, name: name}
+		}
+	}
+	return infos, err
+}
+
+func (d *dir) Stat() (os.FileInfo, error) {
+	info, err := d.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if name := d.form.String(info.Name()); name != info.Name() {
+		return renamedInfo{FileInfo: info, name: name}, nil
+	}
+	return info, nil
+}
+
+// renamedInfo is a file info with a different name.
+type renamedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (r renamedInfo) Name() string { return r.name }
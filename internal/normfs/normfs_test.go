@@ -0,0 +1,53 @@
+package normfs
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/posener/gitfs/internal/tree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	nfc = "caf\u00e9"
+	nfd = "cafe\u0301"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	tr := make(tree.Tree)
+	require.NoError(t, tr.AddFileContent(nfd+"/"+nfd+".txt", []byte("content")))
+	fs := New(tr, norm.NFC)
+
+	// Files can be opened in both forms.
+	for _, name := range []string{nfc + "/" + nfc + ".txt", nfd + "/" + nfd + ".txt"} {
+		f, err := fs.Open(name)
+		require.NoError(t, err, name)
+		content, err := ioutil.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, "content", string(content))
+	}
+
+	// Listings are in the requested form.
+	root, err := fs.Open("/")
+	require.NoError(t, err)
+	infos, err := root.Readdir(-1)
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, nfc, infos[0].Name())
+
+	d, err := fs.Open(nfc)
+	require.NoError(t, err)
+	st, err := d.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, nfc, st.Name())
+	infos, err = d.Readdir(-1)
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, nfc+".txt", infos[0].Name())
+
+	_, err = fs.Open("nosuchfile")
+	assert.Error(t, err)
+}
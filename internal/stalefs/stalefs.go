@@ -0,0 +1,217 @@
+// Package stalefs serves the content of a previous version of a filesystem,
+// when loading the content of a file of the current version fails.
+package stalefs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+)
+
+// New returns a filesystem that serves the files of fs, and serves the file
+// of the same path in prev when reading a file of fs fails before any of its
+// content was read, such as when its content can't be loaded. onStale is
+// called with the path of the file and the error of fs whenever prev is used.
+// Files that do not exist in fs are not served from prev, since they were
+// removed. If prev is itself a filesystem of New, its current version is used,
+// such that only a single previous version is kept.
+func New(fs, prev http.FileSystem, onStale func(name string, err error)) http.FileSystem {
+	if s, ok := prev.(*staleFS); ok {
+		prev = s.fs
+	}
+	return &staleFS{fs: fs, prev: prev, onStale: onStale}
+}
+
+type staleFS struct {
+	fs      http.FileSystem
+	prev    http.FileSystem
+	onStale func(name string, err error)
+}
+
+// Open opens the file of the given name. Only regular files are wrapped, such
+// that directories keep the methods of the underlying filesystem.
+func (s *staleFS) Open(name string) (http.File, error) {
+	f, err := s.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil || st.IsDir() {
+		return f, nil
+	}
+	return &file{File: f, name: name, fs: s}, nil
+}
+
+// file is a regular file that is read from the previous version of the
+// filesystem after reading it failed. It keeps the optional methods of the
+// files of gitfs filesystems.
+type file struct {
+	http.File
+	name string
+	fs   *staleFS
+	// read is set when content was read from File, after which it can't
+	// be replaced.
+	read bool
+	// stale, if set, is the file of the previous version that is read
+	// instead of File.
+	stale http.File
+}
+
+// fallback opens the file of the previous version, if reading File failed
+// with the given error and the file can be replaced, and returns whether it
+// was opened.
+func (f *file) fallback(err error) bool {
+	if err == nil || err == io.EOF || f.read || f.fs.prev == nil {
+		return false
+	}
+	stale, serr := f.fs.prev.Open(f.name)
+	if serr != nil {
+		return false
+	}
+	if st, serr := stale.Stat(); serr != nil || st.IsDir() {
+		stale.Close()
+		return false
+	}
+	// Continue from the offset that File was seeked to.
+	if off, serr := f.File.Seek(0, io.SeekCurrent); serr == nil && off > 0 {
+		if _, serr := stale.Seek(off, io.SeekStart); serr != nil {
+			stale.Close()
+			return false
+		}
+	}
+	f.stale = stale
+	if f.fs.onStale != nil {
+		f.fs.onStale(f.name, err)
+	}
+	return true
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.stale != nil {
+		return f.stale.Read(p)
+	}
+	n, err := f.File.Read(p)
+	if n == 0 && f.fallback(err) {
+		return f.stale.Read(p)
+	}
+	if n > 0 {
+		f.read = true
+	}
+	return n, err
+}
+
+// probe reads from File before it is seeked or its info is returned, such
+// that a failure to load its content is detected before its size is used, and
+// the file of the previous version, with its own size, is used instead.
+func (f *file) probe() {
+	if f.read || f.stale != nil || f.fs.prev == nil {
+		return
+	}
+	off, err := f.File.Seek(0, io.SeekCurrent)
+	if err != nil {
+		f.fallback(err)
+		return
+	}
+	var b [1]byte
+	n, err := f.File.Read(b[:])
+	if n == 0 {
+		f.fallback(err)
+		return
+	}
+	f.read = true
+	f.File.Seek(off, io.SeekStart)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	f.probe()
+	if f.stale != nil {
+		return f.stale.Seek(offset, whence)
+	}
+	n, err := f.File.Seek(offset, whence)
+	if f.fallback(err) {
+		return f.stale.Seek(offset, whence)
+	}
+	return n, err
+}
+
+// Stat returns the info of the served file. It loads the content of the file,
+// since the size of the previous version is returned if loading fails.
+func (f *file) Stat() (os.FileInfo, error) {
+	f.probe()
+	if f.stale != nil {
+		return f.stale.Stat()
+	}
+	return f.File.Stat()
+}
+
+func (f *file) Close() error {
+	if f.stale != nil {
+		f.stale.Close()
+	}
+	return f.File.Close()
+}
+
+// WithContext returns the file that reads its content with the given
+// context, if the underlying file supports it.
+func (f *file) WithContext(ctx context.Context) http.File {
+	c, ok := f.File.(interface {
+		WithContext(context.Context) http.File
+	})
+	if !ok || f.stale != nil {
+		return f
+	}
+	return &file{File: c.WithContext(ctx), name: f.name, fs: f.fs, read: f.read}
+}
+
+// Hash returns the git object SHA of the served content, or an empty string
+// if it is not known. The hash of the current version is known only after its
+// content was loaded, since the content of the previous version may be served
+// instead.
+func (f *file) Hash() string {
+	h, ok := f.current().(interface{ Hash() string })
+	if !ok || !f.Loaded() {
+		return ""
+	}
+	return h.Hash()
+}
+
+// WriteTo implements io.WriterTo with the underlying file, if it supports it.
+func (f *file) WriteTo(w io.Writer) (int64, error) {
+	if wt, ok := f.File.(io.WriterTo); ok && f.stale == nil {
+		n, err := wt.WriteTo(w)
+		if n > 0 {
+			f.read = true
+		}
+		if n > 0 || !f.fallback(err) {
+			return n, err
+		}
+	}
+	return io.Copy(w, struct{ io.Reader }{f})
+}
+
+// Gzipped returns a gzip compressed copy of the served content, if the
+// underlying file has one.
+func (f *file) Gzipped() []byte {
+	if g, ok := f.current().(interface{ Gzipped() []byte }); ok {
+		return g.Gzipped()
+	}
+	return nil
+}
+
+// Loaded returns whether the served content is loaded, if the underlying file
+// knows it.
+func (f *file) Loaded() bool {
+	if l, ok := f.current().(interface{ Loaded() bool }); ok {
+		return l.Loaded()
+	}
+	return true
+}
+
+// current returns the file that its content is served.
+func (f *file) current() http.File {
+	if f.stale != nil {
+		return f.stale
+	}
+	return f.File
+}
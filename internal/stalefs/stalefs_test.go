@@ -0,0 +1,88 @@
+package stalefs
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/posener/gitfs/internal/tree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func failLoad(context.Context) ([]byte, error) {
+	return nil, errors.New("failed")
+}
+
+func TestStaleFS(t *testing.T) {
+	t.Parallel()
+	prev := make(tree.Tree)
+	prev.AddFileContent("ok", []byte("old"))
+	prev.AddFileContent("failed", []byte("old content"))
+	prev.AddFileContent("removed", []byte("old"))
+
+	cur := make(tree.Tree)
+	require.NoError(t, cur.AddFile("ok", 3, func(context.Context) ([]byte, error) { return []byte("new"), nil }))
+	require.NoError(t, cur.AddFile("failed", 3, failLoad))
+	require.NoError(t, cur.AddFile("new", 3, failLoad))
+
+	var stale []string
+	fs := New(cur, prev, func(name string, err error) {
+		assert.EqualError(t, err, "failed")
+		stale = append(stale, name)
+	})
+
+	assert.Equal(t, "new", readFile(t, fs, "ok"))
+	assert.Equal(t, "old content", readFile(t, fs, "failed"))
+
+	// Files that do not exist in the previous version fail.
+	f, err := fs.Open("new")
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(f)
+	assert.Error(t, err)
+
+	// Removed files are not served.
+	_, err = fs.Open("removed")
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Equal(t, []string{"failed"}, stale)
+}
+
+func TestStaleFS_serve(t *testing.T) {
+	t.Parallel()
+	prev := make(tree.Tree)
+	prev.AddFileContent("a.txt", []byte("old content"))
+	cur := make(tree.Tree)
+	require.NoError(t, cur.AddFile("a.txt", 3, failLoad))
+
+	// The served size is the size of the previous version.
+	h := http.FileServer(New(cur, prev, nil))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a.txt", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "old content", rec.Body.String())
+	assert.Equal(t, "11", rec.Header().Get("Content-Length"))
+}
+
+func TestNew_singlePrevious(t *testing.T) {
+	t.Parallel()
+	v1 := make(tree.Tree)
+	v2 := make(tree.Tree)
+	fs := New(v2, v1, nil)
+	fs = New(make(tree.Tree), fs, nil)
+	assert.Equal(t, v2, fs.(*staleFS).prev)
+}
+
+func readFile(t *testing.T, fs http.FileSystem, name string) string {
+	t.Helper()
+	f, err := fs.Open(name)
+	require.NoError(t, err)
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	return string(b)
+}
@@ -0,0 +1,93 @@
+// Package strictfs makes Readdir of regular files fail, as it does for files
+// of the os package, instead of returning no files and no error.
+package strictfs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"syscall"
+)
+
+// New returns a filesystem in which Readdir of regular files returns an
+// *os.PathError with syscall.ENOTDIR.
+func New(fs http.FileSystem) http.FileSystem {
+	return strictFS{fs}
+}
+
+type strictFS struct {
+	http.FileSystem
+}
+
+// Open opens the file of the given name. Only regular files are wrapped, such
+// that directories keep the methods of the underlying filesystem.
+func (s strictFS) Open(name string) (http.File, error) {
+	f, err := s.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil || st.IsDir() {
+		return f, nil
+	}
+	return &file{File: f, name: name}, nil
+}
+
+// file is a regular file that fails Readdir. It keeps the optional methods
+// of the files of gitfs filesystems.
+type file struct {
+	http.File
+	name string
+}
+
+func (f *file) Readdir(int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.name, Err: syscall.ENOTDIR}
+}
+
+// WithContext returns the file that reads its content with the given
+// context, if the underlying file supports it.
+func (f *file) WithContext(ctx context.Context) http.File {
+	c, ok := f.File.(interface {
+		WithContext(context.Context) http.File
+	})
+	if !ok {
+		return f
+	}
+	return &file{File: c.WithContext(ctx), name: f.name}
+}
+
+// Hash returns the git object SHA of the content of the file, or an empty
+// string if it is not known.
+func (f *file) Hash() string {
+	if h, ok := f.File.(interface{ Hash() string }); ok {
+		return h.Hash()
+	}
+	return ""
+}
+
+// WriteTo implements io.WriterTo with the underlying file, if it supports it.
+func (f *file) WriteTo(w io.Writer) (int64, error) {
+	if wt, ok := f.File.(io.WriterTo); ok {
+		return wt.WriteTo(w)
+	}
+	return io.Copy(w, struct{ io.Reader }{f.File})
+}
+
+// Gzipped returns a gzip compressed copy of the content of the file, if the
+// underlying file has one.
+func (f *file) Gzipped() []byte {
+	if g, ok := f.File.(interface{ Gzipped() []byte }); ok {
+		return g.Gzipped()
+	}
+	return nil
+}
+
+// Loaded returns whether the content of the file is loaded, if the underlying
+// file knows it.
+func (f *file) Loaded() bool {
+	if l, ok := f.File.(interface{ Loaded() bool }); ok {
+		return l.Loaded()
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package strictfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/posener/gitfs/internal/tree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictFS(t *testing.T) {
+	t.Parallel()
+	tr := make(tree.Tree)
+	require.NoError(t, tr.AddFileContent("d/a.txt", []byte("a")))
+	require.NoError(t, tr.SetHash("d/a.txt", "abc"))
+	require.NoError(t, tr.SetGzipped("d/a.txt", []byte("gz")))
+	fs := New(tr)
+
+	d, err := fs.Open("d")
+	require.NoError(t, err)
+	infos, err := d.Readdir(-1)
+	require.NoError(t, err)
+	assert.Len(t, infos, 1)
+
+	f, err := fs.Open("d/a.txt")
+	require.NoError(t, err)
+	_, err = f.Readdir(-1)
+	require.Error(t, err)
+	pathErr, ok := err.(*os.PathError)
+	require.True(t, ok)
+	assert.Equal(t, syscall.ENOTDIR, pathErr.Err)
+	assert.Equal(t, "d/a.txt", pathErr.Path)
+
+	assert.Equal(t, "abc", f.(interface{ Hash() string }).Hash())
+	assert.Equal(t, []byte("gz"), f.(interface{ Gzipped() []byte }).Gzipped())
+	var content bytes.Buffer
+	_, err = f.(io.WriterTo).WriteTo(&content)
+	require.NoError(t, err)
+	assert.Equal(t, "a", content.String())
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	fCtx := f.(interface {
+		WithContext(context.Context) http.File
+	}).WithContext(context.Background())
+	_, err = fCtx.Readdir(-1)
+	assert.Error(t, err)
+	buf := make([]byte, 1)
+	_, err = fCtx.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(buf))
+
+	_, err = fs.Open("nosuchfile")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,82 @@
+package tree
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache is a bounded cache for file contents. When the total size of the
+// cached contents exceeds the maximal size, the least recently used contents
+// are evicted. Evicted contents are loaded again when they are read.
+type Cache struct {
+	maxSize int64
+	size    int64
+	// recent is a list of *cacheEntry, ordered from the most recently used.
+	recent  *list.List
+	entries map[*file]*list.Element
+	mu      sync.Mutex
+}
+
+type cacheEntry struct {
+	file    *file
+	content []byte
+}
+
+// NewCache returns a cache that holds up to maxSize bytes of file contents.
+func NewCache(maxSize int64) *Cache {
+	return &Cache{
+		maxSize: maxSize,
+		recent:  list.New(),
+		entries: make(map[*file]*list.Element),
+	}
+}
+
+// Size returns the total size of the cached contents.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+func (c *Cache) get(f *file) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[f]
+	if !ok {
+		return nil, false
+	}
+	c.recent.MoveToFront(e)
+	return e.Value.(*cacheEntry).content, true
+}
+
+// has returns whether the content of a file is cached, without marking it as
+// recently used.
+func (c *Cache) has(f *file) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[f]
+	return ok
+}
+
+// add content of a file to the cache. Contents that are larger than
+// the cache size are not cached.
+func (c *Cache) add(f *file, content []byte) {
+	size := int64(len(content))
+	if size > c.maxSize {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[f]; ok {
+		return
+	}
+	c.entries[f] = c.recent.PushFront(&cacheEntry{file: f, content: content})
+	c.size += size
+	for c.size > c.maxSize {
+		e := c.recent.Back()
+		entry := e.Value.(*cacheEntry)
+		c.recent.Remove(e)
+		delete(c.entries, entry.file)
+		c.size -= int64(len(entry.content))
+	}
+}
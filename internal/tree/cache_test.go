@@ -0,0 +1,50 @@
+package tree
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+	loads := make(map[string]int)
+	tr := make(Tree)
+	for _, name := range []string{"a", "b", "c", "big"} {
+		name := name
+		content := []byte(name + name)
+		if name == "big" {
+			content = []byte("0123456789")
+		}
+		require.NoError(t, tr.AddFile(name, len(content), func(context.Context) ([]byte, error) {
+			loads[name]++
+			return content, nil
+		}))
+	}
+	c := NewCache(5)
+	tr.SetCache(c)
+
+	assertContent(t, tr["a"].Open(), "aa")
+	assertContent(t, tr["b"].Open(), "bb")
+	assert.Equal(t, int64(4), c.Size())
+
+	// Reading a cached file does not load it again.
+	assertContent(t, tr["a"].Open(), "aa")
+	assert.Equal(t, 1, loads["a"])
+
+	// Adding c evicts b, which is the least recently used.
+	assertContent(t, tr["c"].Open(), "cc")
+	assert.Equal(t, int64(4), c.Size())
+	assertContent(t, tr["a"].Open(), "aa")
+	assert.Equal(t, 1, loads["a"])
+	assertContent(t, tr["b"].Open(), "bb")
+	assert.Equal(t, 2, loads["b"])
+
+	// Content that is bigger than the cache is not cached.
+	assertContent(t, tr["big"].Open(), "0123456789")
+	assertContent(t, tr["big"].Open(), "0123456789")
+	assert.Equal(t, 2, loads["big"])
+	assert.Equal(t, int64(4), c.Size())
+}
@@ -0,0 +1,63 @@
+package tree
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/log"
+)
+
+// Commit is information about a git commit.
+type Commit struct {
+	// SHA of the commit.
+	SHA string
+	// Author is the name of the commit author.
+	Author string
+	// Date is the time in which the commit was committed.
+	Date time.Time
+	// Message is the commit message.
+	Message string
+}
+
+// CommitLoader is a function that loads the last commit that modified a file
+// or a directory. If the context is done this function should return an error.
+type CommitLoader func(context.Context) (*Commit, error)
+
+// errNoCommit is returned when no commit information is available.
+var errNoCommit = errors.New("no commit information")
+
+// SetCommit sets a loader for the last commit that modified the file or
+// directory in the given path. The loader is called whenever the commit is
+// requested, using the Commit method of the opened file or directory.
+func (t Tree) SetCommit(path string, load CommitLoader) error {
+	path = cleanPath(path)
+	switch o := t[path].(type) {
+	case *dir:
+		o.commit = load
+	case *file:
+		o.commit = load
+	default:
+		return fmt.Errorf("path %s not found", path)
+	}
+	return nil
+}
+
+// Commit returns the last commit that modified the file.
+func (r *lazyReader) Commit() (*Commit, error) {
+	return loadCommit(r.ctx, r.commit)
+}
+
+// Commit returns the last commit that modified the directory.
+func (r *dirReader) Commit() (*Commit, error) {
+	return loadCommit(context.Background(), r.commit)
+}
+
+func loadCommit(ctx context.Context, load CommitLoader) (*Commit, error) {
+	if load == nil {
+		return nil, errNoCommit
+	}
+	c, err := load(ctx)
+	return c, log.RedactError(err)
+}
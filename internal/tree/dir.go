@@ -1,9 +1,10 @@
 package tree
 
 import (
+	"io"
 	"net/http"
 	"os"
-	"time"
+	"sync"
 )
 
 func newDir(name string) *dir {
@@ -13,20 +14,32 @@ func newDir(name string) *dir {
 	return &dir{name: name}
 }
 
-// dir is an Opener for a directory. It is also the http.File.
+// dir is an Opener for a directory.
 type dir struct {
-	name  string
-	files []os.FileInfo
+	modTime
+	name   string
+	files  []os.FileInfo
+	commit CommitLoader
 }
 
 func (d *dir) Open() http.File {
-	return d
+	return &dirReader{dir: d}
 }
 
 func (d *dir) add(f os.FileInfo) {
 	d.files = append(d.files, f)
 }
 
+// remove a file from the directory listing.
+func (d *dir) remove(name string) {
+	for i, f := range d.files {
+		if f.Name() == name {
+			d.files = append(d.files[:i:i], d.files[i+1:]...)
+			return
+		}
+	}
+}
+
 func (d *dir) Close() error {
 	return nil
 }
@@ -37,6 +50,7 @@ func (d *dir) Seek(int64, int) (int64, error) {
 	return 0, nil
 }
 
+// Readdir of the Opener always lists the directory from its beginning.
 func (d *dir) Readdir(n int) ([]os.FileInfo, error) {
 	if n <= 0 || n >= len(d.files) {
 		return d.files, nil
@@ -57,10 +71,6 @@ func (d *dir) Size() int64 {
 func (d *dir) Mode() os.FileMode {
 	return os.ModeDir
 }
-func (d *dir) ModTime() time.Time {
-	return time.Time{}
-}
-
 func (d *dir) IsDir() bool {
 	return true
 }
@@ -68,3 +78,47 @@ func (d *dir) IsDir() bool {
 func (d *dir) Sys() interface{} {
 	return nil
 }
+
+// dirReader is the http.File of a directory. It keeps the state of Readdir
+// calls, such that, as with os.File, successive calls page through the
+// directory entries.
+type dirReader struct {
+	*dir
+	offset int
+	mu     sync.Mutex
+}
+
+// Readdir returns the next n entries of the directory, and io.EOF when there
+// are no more entries. If n <= 0, it returns all the remaining entries. If
+// entries were removed from the directory between calls, the offset is
+// clamped to the current entries.
+func (r *dirReader) Readdir(n int) ([]os.FileInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.offset > len(r.files) {
+		r.offset = len(r.files)
+	}
+	files := r.files[r.offset:]
+	if n <= 0 {
+		r.offset = len(r.files)
+		return files, nil
+	}
+	if len(files) == 0 {
+		return nil, io.EOF
+	}
+	if n < len(files) {
+		files = files[:n]
+	}
+	r.offset += len(files)
+	return files, nil
+}
+
+// Seek to the beginning of the directory resets the Readdir calls.
+func (r *dirReader) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekStart {
+		r.mu.Lock()
+		r.offset = 0
+		r.mu.Unlock()
+	}
+	return 0, nil
+}
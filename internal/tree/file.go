@@ -1,13 +1,18 @@
 package tree
 
 import (
-	"bytes"
 	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/pkg/errors"
+
 	"github.com/posener/gitfs/internal/log"
 )
 
@@ -17,12 +22,68 @@ func newFile(name string, size int64, load Loader) *file {
 
 // file is an Opener for a file object.
 type file struct {
+	modTime
 	name string
 	size int64
+	mode os.FileMode
 	load Loader
+	// streamer, if set, is used to read the file instead of load.
+	streamer Streamer
+	commit   CommitLoader
+	// hash is the git object SHA of the content, if it is known.
+	hash string
+	// gzipped is a gzip compressed copy of the content, if it is known.
+	gzipped []byte
+
+	// content is the loaded content, as a []byte. It is set once, when the
+	// content is loaded, and is never modified, such that readers of loaded
+	// content do not lock the file. It is not used if cache is set.
+	content atomic.Value
+	cache   *Cache
+	// call is the in-flight load of the content, if any.
+	call *loadCall
+	// onLoad, if set, is called after the content was loaded.
+	onLoad func(time.Duration, error)
+	mu     sync.Mutex
+}
 
+// SizeError is returned when the loaded content of a file does not match the
+// size that was declared when the file was added to the tree. This may happen
+// if the remote repository changed after the tree was fetched.
+type SizeError struct {
+	Name   string
+	Size   int64
+	Loaded int64
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("file %s has size %d, but %d bytes were loaded", e.Name, e.Size, e.Loaded)
+}
+
+// loadCall is a load of file content that is shared by all concurrent readers
+// of the file.
+type loadCall struct {
+	done    chan struct{}
 	content []byte
-	mu      sync.Mutex
+	err     error
+	// canceled is set if the context of the reader that started the load
+	// was done when the load finished, such that its error may be caused by
+	// that reader, and not by the loading itself.
+	canceled bool
+}
+
+// copy returns a new file with the given name and the same content as f.
+func (f *file) copy(name string) *file {
+	c := newFile(name, f.size, f.load)
+	c.mode = f.mode
+	c.modTime.load = f.modTime.load
+	c.cache = f.cache
+	c.streamer = f.streamer
+	c.commit = f.commit
+	c.hash = f.hash
+	c.gzipped = f.gzipped
+	c.onLoad = f.onLoad
+	return c
 }
 
 func (f *file) Open() http.File {
@@ -41,18 +102,45 @@ func (f *file) Size() int64 {
 	return f.size
 }
 
-func (*file) Mode() os.FileMode {
-	return 0
-}
-
-func (*file) ModTime() time.Time {
-	return time.Time{}
+func (f *file) Mode() os.FileMode {
+	return f.mode
 }
 
 func (*file) IsDir() bool {
 	return false
 }
 
+// Hash returns the git object SHA of the content of the file, or an empty
+// string if it is not known.
+func (f *file) Hash() string {
+	return f.hash
+}
+
+// Gzipped returns a gzip compressed copy of the content of the file, or nil
+// if it is not known.
+func (f *file) Gzipped() []byte {
+	return f.gzipped
+}
+
+// Load loads the content of the file, such that reading it does not load it
+// again. Files that are streamed are not loaded.
+func (f *file) Load(ctx context.Context) error {
+	if f.streamer != nil {
+		return nil
+	}
+	_, err := f.loadContent(ctx)
+	return err
+}
+
+// Loaded returns whether the content of the file is loaded, such that reading
+// it does not load it again.
+func (f *file) Loaded() bool {
+	if _, ok := f.content.Load().([]byte); ok {
+		return true
+	}
+	return f.cache != nil && f.cache.has(f)
+}
+
 func (*file) Sys() interface{} {
 	return nil
 }
@@ -61,40 +149,110 @@ func (*file) Readdir(count int) ([]os.FileInfo, error) {
 	return nil, nil
 }
 
-func (f *file) loadContent(ctx context.Context) error {
+// loadContent returns the content of the file. The content is loaded on the
+// first call and kept in memory. If a cache is set, the content is kept only
+// in the cache, and loaded again after it was evicted.
+//
+// Concurrent calls share a single load of the content, and its result. A
+// failed load is not kept, and the content is loaded again in the next call.
+// If the load failed after the context of the call that started it was done,
+// the other calls load the content again with their own contexts, such that
+// a reader that is canceled does not fail the other readers.
+// If the loaded content does not match the file size, a *SizeError is
+// returned, such that the served content always matches its declared size.
+//
+// Once the content is loaded without a cache, it is returned without locking
+// the file, such that concurrent readers of the same file do not contend.
+func (f *file) loadContent(ctx context.Context) ([]byte, error) {
+	if content, ok := f.content.Load().([]byte); ok {
+		return content, nil
+	}
 	f.mu.Lock()
-	defer f.mu.Unlock()
-	if f.content != nil {
-		return nil
+	if content, ok := f.content.Load().([]byte); ok {
+		// Loaded while the lock was acquired.
+		f.mu.Unlock()
+		return content, nil
 	}
+	if f.cache != nil {
+		if content, ok := f.cache.get(f); ok {
+			f.mu.Unlock()
+			return content, nil
+		}
+	}
+	if c := f.call; c != nil {
+		// Wait for the in-flight load.
+		f.mu.Unlock()
+		select {
+		case <-c.done:
+			if c.err != nil && c.canceled && ctx.Err() == nil {
+				// The load failed since the reader that started it was
+				// canceled, load again with the context of this reader.
+				return f.loadContent(ctx)
+			}
+			return c.content, c.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	c := &loadCall{done: make(chan struct{})}
+	f.call = c
+	f.mu.Unlock()
+
 	start := time.Now()
-	buf, err := f.load(ctx)
-	if err != nil {
-		return err
+	c.content, c.err = f.load(ctx)
+	c.canceled = ctx.Err() != nil
+	// Load errors are returned to the readers of the file, and may contain
+	// signed download URLs.
+	c.err = log.RedactError(c.err)
+	if c.err == nil && int64(len(c.content)) != f.size {
+		c.err = &SizeError{Name: f.name, Size: f.size, Loaded: int64(len(c.content))}
+		c.content = nil
 	}
-	f.content = buf
-	log.Printf("Loaded file %s in %.1fs", f.name, time.Now().Sub(start).Seconds())
-	return nil
+	if f.onLoad != nil {
+		f.onLoad(time.Now().Sub(start), c.err)
+	}
+
+	f.mu.Lock()
+	f.call = nil
+	if c.err == nil {
+		log.Debug("Loaded file", "path", f.name, "duration", time.Now().Sub(start))
+		if f.cache != nil {
+			f.cache.add(f, c.content)
+		} else {
+			f.content.Store(c.content)
+		}
+	}
+	f.mu.Unlock()
+	close(c.done)
+	return c.content, c.err
 }
 
 // lazyReader is the http.File for a file. It loads lazily file content
-// only when Read or Seek operations are performed.
+// only when Read or ReadAt operations are performed. Seek does not load the
+// content, such that serving ranges of a file only loads it when it is read.
+// If the file has a streamer, the content is read from a stream, which is
+// opened again only when reading from an offset that was already passed.
 type lazyReader struct {
 	*file
-	reader *bytes.Reader
-	ctx    context.Context
-	mu     sync.Mutex
+	content []byte
+	offset  int64
+	ctx     context.Context
+	mu      sync.Mutex
+
+	stream       io.ReadCloser
+	streamOffset int64
 }
 
+// lazy loads the content of the reader. It should be called when r.mu is held.
 func (r *lazyReader) lazy() error {
-	if err := r.loadContent(r.ctx); err != nil {
-		return err
+	if r.content != nil {
+		return nil
 	}
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	if r.reader == nil {
-		r.reader = bytes.NewReader(r.content)
+	content, err := r.loadContent(r.ctx)
+	if err != nil {
+		return err
 	}
+	r.content = content
 	return nil
 }
 
@@ -104,30 +262,174 @@ func (r *lazyReader) WithContext(ctx context.Context) http.File {
 
 func (r lazyReader) withContext(ctx context.Context) *lazyReader {
 	r.ctx = ctx
+	// The stream is not shared with the new reader.
+	r.stream = nil
 	return &r
 }
 
 func (r *lazyReader) Close() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.reader = nil
+	r.content = nil
+	r.offset = 0
 	r.ctx = context.Background()
-	return nil
+	return r.closeStream()
 }
 
 func (r *lazyReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, err := r.readAt(p, r.offset)
+	r.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		// Read returns io.EOF only when no bytes were read.
+		err = nil
+	}
+	return n, err
+}
+
+// WriteTo implements io.WriterTo. It writes the content from the current
+// offset to w with a single write, such that io.Copy does not copy loaded
+// content through an intermediate buffer. Streamed files are copied from
+// their stream.
+func (r *lazyReader) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	if r.streamer != nil {
+		r.mu.Unlock()
+		// Hide the WriteTo method, such that io.Copy reads from the stream.
+		return io.Copy(w, struct{ io.Reader }{r})
+	}
+	defer r.mu.Unlock()
 	if err := r.lazy(); err != nil {
 		return 0, err
 	}
 	if err := r.ctx.Err(); err != nil {
 		return 0, err
 	}
-	return r.reader.Read(p)
+	if r.offset >= int64(len(r.content)) {
+		return 0, nil
+	}
+	n, err := w.Write(r.content[r.offset:])
+	r.offset += int64(n)
+	return int64(n), err
 }
 
-func (r *lazyReader) Seek(offset int64, whence int) (int64, error) {
+// ReadAt implements io.ReaderAt.
+func (r *lazyReader) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.readAt(p, off)
+}
+
+func (r *lazyReader) readAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if r.streamer != nil {
+		return r.readStream(p, off)
+	}
 	if err := r.lazy(); err != nil {
 		return 0, err
 	}
-	return r.reader.Seek(offset, whence)
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if off >= int64(len(r.content)) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.content[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readStream reads from the stream of the file, at the given offset. It should
+// be called when r.mu is held.
+func (r *lazyReader) readStream(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	if err := r.seekStream(off); err != nil {
+		return 0, err
+	}
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.stream, p)
+	r.streamOffset += int64(n)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	if err == io.EOF && r.streamOffset != r.size {
+		err = &SizeError{Name: r.name, Size: r.size, Loaded: r.streamOffset}
+	}
+	return n, err
+}
+
+// seekStream sets the stream to the given offset. Seeking forward discards
+// the content until the offset, and seeking backward opens a new stream.
+func (r *lazyReader) seekStream(off int64) error {
+	if r.stream != nil && off < r.streamOffset {
+		if err := r.closeStream(); err != nil {
+			return err
+		}
+	}
+	if r.stream == nil {
+		stream, err := r.streamer(r.ctx)
+		if err != nil {
+			return log.RedactError(err)
+		}
+		r.stream = stream
+		r.streamOffset = 0
+	}
+	if off == r.streamOffset {
+		return nil
+	}
+	n, err := io.CopyN(ioutil.Discard, r.stream, off-r.streamOffset)
+	r.streamOffset += n
+	if err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func (r *lazyReader) closeStream() error {
+	if r.stream == nil {
+		return nil
+	}
+	err := r.stream.Close()
+	r.stream = nil
+	r.streamOffset = 0
+	return err
+}
+
+// Seek implements io.Seeker. Seeking relative to the end of the file uses the
+// file size, and does not load the content.
+func (r *lazyReader) Seek(offset int64, whence int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.contentSize()
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// contentSize returns the size of the loaded content, or the file size if the
+// content was not loaded yet.
+func (r *lazyReader) contentSize() int64 {
+	if r.content != nil {
+		return int64(len(r.content))
+	}
+	return r.size
 }
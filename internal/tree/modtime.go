@@ -0,0 +1,67 @@
+package tree
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/posener/gitfs/internal/log"
+)
+
+// TimeLoader is a function that loads a modification time. If the context
+// is done this function should return an error.
+type TimeLoader func(context.Context) (time.Time, error)
+
+// modTime lazily loads a modification time of a file or a directory.
+type modTime struct {
+	load   TimeLoader
+	time   time.Time
+	loaded bool
+	mu     sync.Mutex
+}
+
+// ModTime returns the modification time. It is loaded on the first call,
+// without a deadline. See loadModTime.
+func (m *modTime) ModTime() time.Time {
+	return m.loadModTime(context.Background())
+}
+
+// loadModTime returns the modification time, and loads it with the given
+// context if it was not loaded yet. Only a successful load is kept, such that
+// a transient failure, such as a rate limit, is retried in the next call. If
+// no loader was set or the loading failed, the zero time is returned.
+func (m *modTime) loadModTime(ctx context.Context) time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.loaded || m.load == nil {
+		return m.time
+	}
+	t, err := m.load(ctx)
+	if err != nil {
+		log.Warn("Failed loading modification time", "error", err)
+		return time.Time{}
+	}
+	m.time, m.loaded = t, true
+	return t
+}
+
+// fileInfo is the info of an opened file, that loads its modification time
+// with the context of the reader that it was returned from.
+type fileInfo struct {
+	*file
+	ctx context.Context
+}
+
+func (i fileInfo) ModTime() time.Time {
+	return i.file.loadModTime(i.ctx)
+}
+
+// Stat returns the info of the file. Its modification time is loaded with
+// the context of the reader, such that it is canceled with it.
+func (r *lazyReader) Stat() (os.FileInfo, error) {
+	r.mu.Lock()
+	ctx := r.ctx
+	r.mu.Unlock()
+	return fileInfo{file: r.file, ctx: ctx}, nil
+}
@@ -0,0 +1,133 @@
+package tree
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/posener/gitfs/internal/log"
+)
+
+// ResolveSymlinks replaces symlinks in the tree with the files or directories
+// that they point to. Symlinks are files with the os.ModeSymlink mode bit,
+// that their content is the path of their target, relative to the directory
+// that contains them. Symlinks that point outside of the tree, or to paths
+// that do not exist in the tree, are left as is. Symlinks in loops, such as a
+// symlink to a directory that contains it, symlinks that point to each other,
+// and symlinks that point to them, can't be resolved and are removed from the
+// tree.
+func (t Tree) ResolveSymlinks(ctx context.Context) error {
+	targets := make(map[string]string)
+	var loops []string
+	for p, o := range t {
+		f, ok := o.(*file)
+		if !ok || f.mode&os.ModeSymlink == 0 {
+			continue
+		}
+		content, err := f.loadContent(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "loading symlink %s", p)
+		}
+		target := path.Join(path.Dir(p), string(content))
+		if target == ".." || strings.HasPrefix(target, "../") || path.IsAbs(target) {
+			log.Warn("Symlink points outside of the tree", "path", p)
+			continue
+		}
+		target = cleanPath(target)
+		if target == "." {
+			target = ""
+		}
+		if target == "" || strings.HasPrefix(p, target+"/") {
+			log.Warn("Symlink points to a directory that contains it", "path", p)
+			loops = append(loops, p)
+			continue
+		}
+		targets[p] = target
+	}
+	for _, link := range loops {
+		if err := t.Remove(link); err != nil {
+			return errors.Wrapf(err, "removing symlink %s", link)
+		}
+	}
+
+	// Resolve symlinks until no more progress can be made. Symlinks that point
+	// to other symlinks, or to directories that contain symlinks, are resolved
+	// after those were resolved.
+	for len(targets) > 0 {
+		resolved := 0
+		for link, target := range targets {
+			if pending(targets, target) {
+				continue
+			}
+			delete(targets, link)
+			if t[target] == nil {
+				log.Warn("Symlink target not found", "path", link, "target", target)
+				continue
+			}
+			if err := t.link(link, target); err != nil {
+				return errors.Wrapf(err, "linking %s to %s", link, target)
+			}
+			resolved++
+		}
+		if resolved == 0 {
+			log.Warn("Symlinks loop", "paths", targets)
+			for link := range targets {
+				if err := t.Remove(link); err != nil {
+					return errors.Wrapf(err, "removing symlink %s", link)
+				}
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// pending returns true if the target, or any path under it, is a symlink
+// that was not resolved yet.
+func pending(links map[string]string, target string) bool {
+	for link := range links {
+		if link == target || strings.HasPrefix(link, target+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// link replaces the opener in path p with a copy of the opener of target.
+// If target is a directory, its entire subtree is copied.
+func (t Tree) link(p, target string) error {
+	name := path.Base(p)
+	switch o := t[target].(type) {
+	case *file:
+		t.replace(p, o.copy(name))
+	case *dir:
+		d := newDir(name)
+		d.modTime.load = o.modTime.load
+		t.replace(p, d)
+
+		// Collect the subtree before modifying the tree.
+		prefix := target + "/"
+		var subPaths []string
+		for subPath := range t {
+			if strings.HasPrefix(subPath, prefix) {
+				subPaths = append(subPaths, subPath)
+			}
+		}
+		for _, subPath := range subPaths {
+			linked := path.Join(p, strings.TrimPrefix(subPath, prefix))
+			var err error
+			switch o := t[subPath].(type) {
+			case *file:
+				err = t.addCopy(linked, o)
+			case *dir:
+				err = t.AddDir(linked)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package tree
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSymlinks(t *testing.T) {
+	t.Parallel()
+	tr := make(Tree)
+	require.NoError(t, tr.AddFileContent("d/f", []byte("content")))
+	addLink(t, tr, "d/file-link", "f")
+	addLink(t, tr, "dir-link", "d")
+	addLink(t, tr, "chain-link", "d/file-link")
+	addLink(t, tr, "outside-link", "../f")
+	addLink(t, tr, "missing-link", "nosuchfile")
+
+	require.NoError(t, tr.ResolveSymlinks(context.Background()))
+
+	assertContent(t, tr["d/file-link"].Open(), "content")
+	assertContent(t, tr["chain-link"].Open(), "content")
+	assertContent(t, tr["dir-link/f"].Open(), "content")
+	assertContent(t, tr["dir-link/file-link"].Open(), "content")
+	assertDir(t, tr, "dir-link")
+	assertDirContains(t, tr, "", "dir-link")
+	assertDirContains(t, tr, "dir-link", "f")
+	assertDirContains(t, tr, "dir-link", "file-link")
+	assertFile(t, tr, "chain-link", 7)
+
+	// Unresolved links stay as they are.
+	assertContent(t, tr["outside-link"].Open(), "../f")
+	assertContent(t, tr["missing-link"].Open(), "nosuchfile")
+	st, err := tr["outside-link"].Stat()
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSymlink, st.Mode())
+}
+
+func TestResolveSymlinks_loop(t *testing.T) {
+	t.Parallel()
+	tr := make(Tree)
+	addLink(t, tr, "a", "b")
+	addLink(t, tr, "b", "a")
+	addLink(t, tr, "c", "a")
+	addLink(t, tr, "self", "self")
+	require.NoError(t, tr.ResolveSymlinks(context.Background()))
+	// Links in a loop, and links to them, are removed.
+	assert.Nil(t, tr["a"])
+	assert.Nil(t, tr["b"])
+	assert.Nil(t, tr["c"])
+	assert.Nil(t, tr["self"])
+}
+
+func TestResolveSymlinks_ancestorLoop(t *testing.T) {
+	t.Parallel()
+	tr := make(Tree)
+	require.NoError(t, tr.AddFileContent("d/f", []byte("content")))
+	addLink(t, tr, "d/parent", ".")
+	addLink(t, tr, "d/e/grandparent", "../..")
+	addLink(t, tr, "root", ".")
+	addLink(t, tr, "dir-link", "d")
+	require.NoError(t, tr.ResolveSymlinks(context.Background()))
+
+	// Links to directories that contain them are removed, and links to
+	// these directories are resolved without them.
+	assert.Nil(t, tr["d/parent"])
+	assert.Nil(t, tr["d/e/grandparent"])
+	assert.Nil(t, tr["root"])
+	assertContent(t, tr["dir-link/f"].Open(), "content")
+	assertDir(t, tr, "dir-link/e")
+	assert.Nil(t, tr["dir-link/parent"])
+	assertDirContains(t, tr, "", "d")
+	assertDirContains(t, tr, "", "dir-link")
+}
+
+func addLink(t *testing.T, tr Tree, path, target string) {
+	t.Helper()
+	require.NoError(t, tr.AddFileContent(path, []byte(target)))
+	require.NoError(t, tr.SetMode(path, os.ModeSymlink))
+}
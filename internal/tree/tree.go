@@ -3,10 +3,15 @@ package tree
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/pkg/errors"
 
 	"github.com/posener/gitfs/internal/log"
 )
@@ -30,6 +35,10 @@ type Tree map[string]Opener
 // this function should return an error.
 type Loader func(context.Context) ([]byte, error)
 
+// Streamer is a function that opens a stream of file content. If the context
+// is done this function, or reading from the stream, should return an error.
+type Streamer func(context.Context) (io.ReadCloser, error)
+
 // Open is the implementation of http.FileSystem.
 func (t Tree) Open(name string) (http.File, error) {
 	path := strings.Trim(name, "/")
@@ -40,11 +49,11 @@ func (t Tree) Open(name string) (http.File, error) {
 			// No files were added yet, return empty root directory.
 			return newDir("/"), nil
 		}
-		log.Printf("File %s not found", name)
+		log.Debug("File not found", "path", name)
 		return nil, os.ErrNotExist
 	}
 	if !valid(name, opener.Stat) {
-		log.Printf("File %s is invalid", name)
+		log.Debug("File is invalid", "path", name)
 		return nil, os.ErrInvalid
 
 	}
@@ -124,6 +133,300 @@ func (t Tree) AddFileContent(path string, content []byte) error {
 	})
 }
 
+// SetModTime sets a loader for the modification time of the file or directory
+// in the given path. The loader is called lazily, only once, when the
+// modification time is first requested. If it fails, the zero time is used.
+func (t Tree) SetModTime(path string, load TimeLoader) error {
+	path = cleanPath(path)
+	switch o := t[path].(type) {
+	case *dir:
+		o.modTime.load = load
+	case *file:
+		o.modTime.load = load
+	default:
+		return fmt.Errorf("path %s not found", path)
+	}
+	return nil
+}
+
+// SetMode sets the mode of the file in the given path. Only the permission
+// bits and the os.ModeSymlink bit are used.
+func (t Tree) SetMode(path string, mode os.FileMode) error {
+	path = cleanPath(path)
+	f, ok := t[path].(*file)
+	if !ok {
+		return fmt.Errorf("file %s not found", path)
+	}
+	f.mode = mode & (os.ModePerm | os.ModeSymlink)
+	return nil
+}
+
+// SetHash sets the git object SHA of the content of the file in the given
+// path, which identifies its content, for example for HTTP caching.
+func (t Tree) SetHash(path string, hash string) error {
+	path = cleanPath(path)
+	f, ok := t[path].(*file)
+	if !ok {
+		return fmt.Errorf("file %s not found", path)
+	}
+	f.hash = hash
+	return nil
+}
+
+// SetContent sets the content of the file in the given path, that is
+// already available, such that it is not loaded. The content should be of
+// the size of the file.
+func (t Tree) SetContent(path string, content []byte) error {
+	path = cleanPath(path)
+	f, ok := t[path].(*file)
+	if !ok {
+		return fmt.Errorf("file %s not found", path)
+	}
+	f.load = func(ctx context.Context) ([]byte, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return content, nil
+	}
+	f.streamer = nil
+	return nil
+}
+
+// SetGzipped sets a gzip compressed copy of the content of the file in the
+// given path, such that it can be served to clients that accept gzip without
+// compressing it.
+func (t Tree) SetGzipped(path string, gzipped []byte) error {
+	path = cleanPath(path)
+	f, ok := t[path].(*file)
+	if !ok {
+		return fmt.Errorf("file %s not found", path)
+	}
+	f.gzipped = gzipped
+	return nil
+}
+
+// SetStreamer sets a streamer for the file in the given path. Reading a file
+// that has a streamer reads its content from a stream, instead of loading
+// the whole content to memory. It should be used for large files.
+func (t Tree) SetStreamer(path string, streamer Streamer) error {
+	path = cleanPath(path)
+	f, ok := t[path].(*file)
+	if !ok {
+		return fmt.Errorf("file %s not found", path)
+	}
+	f.streamer = streamer
+	return nil
+}
+
+// SetCache sets a cache for the contents of all the files in the tree.
+// Instead of keeping the content of every file that was loaded in memory,
+// the contents are kept only in the cache, which bounds the total memory.
+func (t Tree) SetCache(c *Cache) {
+	for _, o := range t {
+		if f, ok := o.(*file); ok {
+			f.cache = c
+		}
+	}
+}
+
+// SetLoadObserver sets a function that is called whenever the content of a
+// file in the tree is loaded, with the path of the file, the duration of the
+// load and its error.
+func (t Tree) SetLoadObserver(observe func(path string, d time.Duration, err error)) {
+	for path, o := range t {
+		if f, ok := o.(*file); ok {
+			path := path
+			f.onLoad = func(d time.Duration, err error) { observe(path, d, err) }
+		}
+	}
+}
+
+// Remove removes the file or directory in the given path from the tree.
+// Removing a directory removes all its content.
+func (t Tree) Remove(path string) error {
+	path = cleanPath(path)
+	if path == "" {
+		return fmt.Errorf("can't remove root directory")
+	}
+	if t[path] == nil {
+		return fmt.Errorf("path %s not found", path)
+	}
+	t.parent(path).remove(filepath.Base(path))
+	for p := range t.subtree(path) {
+		delete(t, p)
+	}
+	return nil
+}
+
+// Filter removes from the tree the files and directories for which keep
+// returns false. The paths that are given to keep are absolute paths in the
+// tree, such as "/dir/file". Removing a directory removes all its content,
+// without calling keep for it.
+func (t Tree) Filter(keep func(path string, info os.FileInfo) bool) error {
+	paths := make([]string, 0, len(t))
+	for p := range t {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	// Sorting makes sure that directories are filtered before their content.
+	sort.Strings(paths)
+	for _, p := range paths {
+		o := t[p]
+		if o == nil {
+			// The path was removed with its parent directory.
+			continue
+		}
+		info, err := o.Stat()
+		if err != nil {
+			return errors.Wrapf(err, "stat %s", p)
+		}
+		if keep("/"+p, info) {
+			continue
+		}
+		if err := t.Remove(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rename moves the file or directory in oldPath to newPath. Renaming
+// a directory moves all its content. The parent directories of newPath are
+// created if needed. newPath must not exist in the tree.
+func (t Tree) Rename(oldPath, newPath string) error {
+	oldPath, newPath = cleanPath(oldPath), cleanPath(newPath)
+	o := t[oldPath]
+	switch {
+	case oldPath == "":
+		return fmt.Errorf("can't rename root directory")
+	case o == nil:
+		return fmt.Errorf("path %s not found", oldPath)
+	case t[newPath] != nil:
+		return fmt.Errorf("path %s already exists", newPath)
+	case strings.HasPrefix(newPath, oldPath+"/"):
+		return fmt.Errorf("can't move %s into itself", oldPath)
+	}
+	// Check that the new parent directories can be created.
+	for p := filepath.Dir(newPath); p != "."; p = filepath.Dir(p) {
+		if _, ok := t[p].(*file); ok {
+			return fmt.Errorf("path %s is a file", p)
+		}
+	}
+
+	subtree := t.subtree(oldPath)
+	t.parent(oldPath).remove(filepath.Base(oldPath))
+	for p := range subtree {
+		delete(t, p)
+	}
+
+	name := filepath.Base(newPath)
+	switch o := o.(type) {
+	case *file:
+		o.name = name
+	case *dir:
+		o.name = name
+	}
+	if err := t.AddDir(filepath.Dir(newPath)); err != nil {
+		return err
+	}
+	t.parent(newPath).add(o.(os.FileInfo))
+	for p, o := range subtree {
+		t[newPath+strings.TrimPrefix(p, oldPath)] = o
+	}
+	return nil
+}
+
+// Merge adds all the files and directories of other to t. Directories that
+// exist in both trees are merged. Any other path that exists in both trees is
+// a conflict: if overwrite is set, the path in t is replaced with the path from
+// other, otherwise an error is returned and t is not modified.
+func (t Tree) Merge(other Tree, overwrite bool) error {
+	paths := make([]string, 0, len(other))
+	for p := range other {
+		paths = append(paths, p)
+	}
+	// Sorting makes sure that directories are merged before their content.
+	sort.Strings(paths)
+
+	if !overwrite {
+		for _, p := range paths {
+			if conflict(t[p], other[p]) {
+				return fmt.Errorf("conflict in path %s", p)
+			}
+		}
+	}
+
+	for _, p := range paths {
+		if conflict(t[p], other[p]) {
+			if err := t.Remove(p); err != nil {
+				return err
+			}
+		}
+		var err error
+		switch o := other[p].(type) {
+		case *dir:
+			err = t.AddDir(p)
+		case *file:
+			err = t.addCopy(p, o)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "merging %s", p)
+		}
+	}
+	return nil
+}
+
+// conflict returns true if two openers of the same path can't be merged.
+func conflict(a, b Opener) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	_, aDir := a.(*dir)
+	_, bDir := b.(*dir)
+	return !aDir || !bDir
+}
+
+// addCopy adds a copy of file f in path p.
+func (t Tree) addCopy(p string, f *file) error {
+	if err := t.AddFile(p, int(f.size), f.load); err != nil {
+		return err
+	}
+	t.replace(p, f.copy(filepath.Base(p)))
+	return nil
+}
+
+// subtree returns the openers of the given path and all the paths under it.
+func (t Tree) subtree(path string) map[string]Opener {
+	sub := make(map[string]Opener)
+	for p, o := range t {
+		if p == path || strings.HasPrefix(p, path+"/") {
+			sub[p] = o
+		}
+	}
+	return sub
+}
+
+// parent returns the parent directory of a path. The parent must exist.
+func (t Tree) parent(path string) *dir {
+	dirPath, _ := filepath.Split(path)
+	return t[cleanPath(dirPath)].(*dir)
+}
+
+// replace the opener in the given path, also in the listing of its parent
+// directory. The path must exist in the tree.
+func (t Tree) replace(path string, o Opener) {
+	t[path] = o
+	parent := t.parent(path)
+	name := filepath.Base(path)
+	for i, f := range parent.files {
+		if f.Name() == name {
+			parent.files[i] = o.(os.FileInfo)
+			return
+		}
+	}
+}
+
 func valid(name string, info func() (os.FileInfo, error)) bool {
 	expectingDir := len(name) > 0 && name[len(name)-1] == '/'
 	if expectingDir {
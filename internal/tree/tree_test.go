@@ -1,13 +1,19 @@
 package tree
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -124,6 +130,116 @@ func TestOpen_concurrent(t *testing.T) {
 	wg.Wait()
 }
 
+func TestOpen_concurrentLoad(t *testing.T) {
+	t.Parallel()
+	const goroutines = 10
+
+	var (
+		loads   int32
+		fail    = true
+		release = make(chan struct{})
+	)
+	tr := make(Tree)
+	require.NoError(t, tr.AddFile("a", 6, func(context.Context) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		if fail {
+			return nil, fmt.Errorf("failed")
+		}
+		return []byte("file a"), nil
+	}))
+
+	// All concurrent reads share a single failed load.
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+			_, err := ioutil.ReadAll(tr["a"].Open())
+			errs <- err
+		}()
+	}
+	// Wait for the load to start before releasing it.
+	for atomic.LoadInt32(&loads) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	// A failed load is retried on the next read.
+	fail = false
+	assertContent(t, tr["a"].Open(), "file a")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loads))
+}
+
+func TestOpen_concurrentLoadCanceled(t *testing.T) {
+	t.Parallel()
+
+	var loads int32
+	tr := make(Tree)
+	require.NoError(t, tr.AddFile("a", 6, func(ctx context.Context) ([]byte, error) {
+		if atomic.AddInt32(&loads, 1) == 1 {
+			// The first load waits until its reader is canceled.
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return []byte("file a"), nil
+	}))
+	withContext := func(ctx context.Context) http.File {
+		return tr["a"].Open().(interface {
+			WithContext(context.Context) http.File
+		}).WithContext(ctx)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := ioutil.ReadAll(withContext(ctx))
+		canceled <- err
+	}()
+	// Wait for the load of the canceled reader to start.
+	for atomic.LoadInt32(&loads) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	live := make(chan error, 1)
+	go func() {
+		content, err := ioutil.ReadAll(withContext(context.Background()))
+		if err == nil && string(content) != "file a" {
+			err = fmt.Errorf("got content %q", content)
+		}
+		live <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	assert.Equal(t, context.Canceled, <-canceled)
+	assert.NoError(t, <-live)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loads))
+}
+func TestFile_loadedOnce(t *testing.T) {
+	t.Parallel()
+
+	var loads int32
+	tr := make(Tree)
+	require.NoError(t, tr.AddFile("empty", 0, func(context.Context) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		return nil, nil
+	}))
+	for i := 0; i < 3; i++ {
+		f, err := tr.Open("empty")
+		require.NoError(t, err)
+		assertContent(t, f, "")
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+}
+
 func TestDir_readDir(t *testing.T) {
 	t.Parallel()
 
@@ -153,6 +269,70 @@ func TestDir_readDir(t *testing.T) {
 	}
 }
 
+func TestDir_readDirPagination(t *testing.T) {
+	t.Parallel()
+
+	tr := make(Tree)
+	require.NoError(t, tr.AddFile("a/1", 0, nil))
+	require.NoError(t, tr.AddFile("a/2", 0, nil))
+	require.NoError(t, tr.AddFile("a/3", 0, nil))
+
+	d, err := tr.Open("a")
+	require.NoError(t, err)
+
+	files, err := d.Readdir(2)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+	files, err = d.Readdir(2)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+	_, err = d.Readdir(2)
+	assert.Equal(t, io.EOF, err)
+
+	// All remaining entries, after all entries were read.
+	files, err = d.Readdir(0)
+	require.NoError(t, err)
+	assert.Len(t, files, 0)
+
+	// Seeking to start of directory resets the reading.
+	_, err = d.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	files, err = d.Readdir(-1)
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+
+	// A different handle has its own state.
+	d2, err := tr.Open("a")
+	require.NoError(t, err)
+	files, err = d2.Readdir(3)
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+}
+
+func TestDir_readDirRemoved(t *testing.T) {
+	t.Parallel()
+
+	tr := make(Tree)
+	require.NoError(t, tr.AddFile("a/1", 0, nil))
+	require.NoError(t, tr.AddFile("a/2", 0, nil))
+	require.NoError(t, tr.AddFile("a/3", 0, nil))
+
+	d, err := tr.Open("a")
+	require.NoError(t, err)
+	files, err := d.Readdir(3)
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+
+	// Removing entries after they were read does not fail the next calls.
+	require.NoError(t, tr.Remove("a/3"))
+	require.NoError(t, tr.Remove("a/2"))
+	_, err = d.Readdir(1)
+	assert.Equal(t, io.EOF, err)
+	files, err = d.Readdir(0)
+	require.NoError(t, err)
+	assert.Len(t, files, 0)
+}
+
 func TestFile_read(t *testing.T) {
 	t.Parallel()
 
@@ -164,6 +344,170 @@ func TestFile_read(t *testing.T) {
 	assertContent(t, tr["a"].Open(), content)
 }
 
+func TestFile_readAt(t *testing.T) {
+	t.Parallel()
+
+	tr := make(Tree)
+	require.NoError(t, tr.AddFileContent("a", []byte("0123456789")))
+	f := tr["a"].Open()
+	r, ok := f.(io.ReaderAt)
+	require.True(t, ok)
+
+	buf := make([]byte, 3)
+	n, err := r.ReadAt(buf, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "234", string(buf[:n]))
+
+	n, err = r.ReadAt(buf, 8)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "89", string(buf[:n]))
+
+	// ReadAt does not change the offset of Read.
+	assertContent(t, f, "0123456789")
+}
+
+func TestFile_writeTo(t *testing.T) {
+	t.Parallel()
+
+	tr := make(Tree)
+	require.NoError(t, tr.AddFileContent("a", []byte("0123456789")))
+	require.NoError(t, tr.AddFile("s", 10, nil))
+	require.NoError(t, tr.SetStreamer("s", func(context.Context) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("0123456789")), nil
+	}))
+
+	for _, name := range []string{"a", "s"} {
+		f := tr[name].Open()
+		_, err := f.Seek(3, io.SeekStart)
+		require.NoError(t, err)
+		w := &countingWriter{}
+		n, err := f.(io.WriterTo).WriteTo(w)
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(7), n, name)
+		assert.Equal(t, "3456789", w.String(), name)
+		if name == "a" {
+			assert.Equal(t, 1, w.writes)
+		}
+
+		// The offset is at the end of the file.
+		n, err = f.(io.WriterTo).WriteTo(w)
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(0), n, name)
+	}
+}
+
+// countingWriter is a buffer that counts the calls to Write.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestFile_seek(t *testing.T) {
+	t.Parallel()
+
+	loads := 0
+	tr := make(Tree)
+	require.NoError(t, tr.AddFile("a", 10, func(context.Context) ([]byte, error) {
+		loads++
+		return []byte("0123456789"), nil
+	}))
+	f := tr["a"].Open()
+
+	// Seeking does not load the content.
+	pos, err := f.Seek(0, io.SeekEnd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), pos)
+	pos, err = f.Seek(-3, io.SeekCurrent)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), pos)
+	assert.Equal(t, 0, loads)
+	_, err = f.Seek(-1, io.SeekStart)
+	assert.Error(t, err)
+
+	got, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "789", string(got))
+	assert.Equal(t, 1, loads)
+}
+
+func TestFile_serveRange(t *testing.T) {
+	t.Parallel()
+
+	tr := make(Tree)
+	require.NoError(t, tr.AddFileContent("a", []byte("0123456789")))
+
+	req := httptest.NewRequest(http.MethodGet, "/a", nil)
+	req.Header.Set("Range", "bytes=3-5")
+	rec := httptest.NewRecorder()
+	http.FileServer(tr).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "345", rec.Body.String())
+}
+
+func TestFile_stream(t *testing.T) {
+	t.Parallel()
+
+	const content = "0123456789"
+	opens := 0
+	tr := make(Tree)
+	require.NoError(t, tr.AddFile("a", len(content), func(context.Context) ([]byte, error) {
+		t.Fatal("content should be streamed")
+		return nil, nil
+	}))
+	require.NoError(t, tr.SetStreamer("a", func(context.Context) (io.ReadCloser, error) {
+		opens++
+		return ioutil.NopCloser(strings.NewReader(content)), nil
+	}))
+	f := tr["a"].Open()
+
+	buf := make([]byte, 4)
+	n, err := f.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "0123", string(buf[:n]))
+
+	// Reading forward continues the same stream.
+	_, err = f.Seek(2, io.SeekCurrent)
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "6789", string(got))
+	assert.Equal(t, 1, opens)
+
+	// Reading backward opens a new stream.
+	n, err = f.(io.ReaderAt).ReadAt(buf, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "1234", string(buf[:n]))
+	assert.Equal(t, 2, opens)
+
+	// Reading after the end does not open a stream.
+	_, err = f.(io.ReaderAt).ReadAt(buf, 10)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 2, opens)
+
+	require.NoError(t, f.Close())
+}
+
+func TestFile_streamSizeMismatch(t *testing.T) {
+	t.Parallel()
+
+	tr := make(Tree)
+	require.NoError(t, tr.AddFile("a", 10, nil))
+	require.NoError(t, tr.SetStreamer("a", func(context.Context) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("short")), nil
+	}))
+
+	_, err := ioutil.ReadAll(tr["a"].Open())
+	var sizeErr *SizeError
+	require.True(t, errors.As(err, &sizeErr))
+	assert.Equal(t, int64(5), sizeErr.Loaded)
+}
+
 func TestFile_readFailure(t *testing.T) {
 	t.Parallel()
 
@@ -176,6 +520,60 @@ func TestFile_readFailure(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestFile_readFailureRedacted(t *testing.T) {
+	t.Parallel()
+
+	tr := make(Tree)
+	require.NoError(t, tr.AddFile("a", 10, func(context.Context) ([]byte, error) {
+		return nil, fmt.Errorf("get https://example.com/a?token=secret: failed")
+	}))
+
+	_, err := ioutil.ReadAll(tr["a"].Open())
+	require.Error(t, err)
+	assert.Equal(t, "get https://example.com/a?token=REDACTED: failed", err.Error())
+}
+
+func TestFile_sizeMismatch(t *testing.T) {
+	t.Parallel()
+
+	tr := make(Tree)
+	require.NoError(t, tr.AddFile("a", 10, func(context.Context) ([]byte, error) { return []byte("short"), nil }))
+
+	_, err := ioutil.ReadAll(tr["a"].Open())
+	var sizeErr *SizeError
+	require.True(t, errors.As(err, &sizeErr))
+	assert.Equal(t, &SizeError{Name: "a", Size: 10, Loaded: 5}, sizeErr)
+}
+
+func TestSetLoadObserver(t *testing.T) {
+	t.Parallel()
+
+	tr := make(Tree)
+	require.NoError(t, tr.AddFileContent("a", []byte("a")))
+	require.NoError(t, tr.AddFile("b", 10, func(context.Context) ([]byte, error) { return nil, fmt.Errorf("failed") }))
+	var (
+		paths []string
+		errs  []error
+	)
+	tr.SetLoadObserver(func(path string, d time.Duration, err error) {
+		paths = append(paths, path)
+		errs = append(errs, err)
+	})
+
+	_, err := ioutil.ReadAll(tr["a"].Open())
+	require.NoError(t, err)
+	// Loaded content is not loaded again.
+	_, err = ioutil.ReadAll(tr["a"].Open())
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(tr["b"].Open())
+	require.Error(t, err)
+
+	assert.Equal(t, []string{"a", "b"}, paths)
+	require.Len(t, errs, 2)
+	assert.NoError(t, errs[0])
+	assert.Error(t, errs[1])
+}
+
 func TestFile_overrideFailure(t *testing.T) {
 	t.Parallel()
 
@@ -187,6 +585,136 @@ func TestFile_overrideFailure(t *testing.T) {
 	assert.Error(t, tr.AddFile("b", 10, nil))
 }
 
+func TestSetModTime(t *testing.T) {
+	t.Parallel()
+	tr := make(Tree)
+	require.NoError(t, tr.AddFileContent("a/b", []byte("content")))
+
+	fileTime := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	dirTime := time.Date(2019, 2, 3, 4, 5, 6, 0, time.UTC)
+	calls := 0
+	require.NoError(t, tr.SetModTime("a/b", func(context.Context) (time.Time, error) {
+		calls++
+		return fileTime, nil
+	}))
+	require.NoError(t, tr.SetModTime("a", func(context.Context) (time.Time, error) { return dirTime, nil }))
+	assert.Error(t, tr.SetModTime("nosuchfile", nil))
+
+	f, err := tr.Open("a/b")
+	require.NoError(t, err)
+	st, err := f.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, fileTime, st.ModTime())
+	assert.Equal(t, fileTime, st.ModTime())
+	assert.Equal(t, 1, calls)
+
+	// Directory listing contains the same modification time.
+	files, err := tr["a"].Readdir(-1)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, fileTime, files[0].ModTime())
+
+	st, err = tr["a"].Stat()
+	require.NoError(t, err)
+	assert.Equal(t, dirTime, st.ModTime())
+}
+
+func TestSetModTime_failure(t *testing.T) {
+	t.Parallel()
+	tr := make(Tree)
+	require.NoError(t, tr.AddFileContent("a", []byte("content")))
+	modTime := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	calls := 0
+	require.NoError(t, tr.SetModTime("a", func(context.Context) (time.Time, error) {
+		calls++
+		if calls == 1 {
+			return time.Now(), fmt.Errorf("failed")
+		}
+		return modTime, nil
+	}))
+	st, err := tr["a"].Stat()
+	require.NoError(t, err)
+	assert.Equal(t, time.Time{}, st.ModTime())
+
+	// The failure is not kept, and the modification time is loaded again.
+	assert.Equal(t, modTime, st.ModTime())
+	assert.Equal(t, modTime, st.ModTime())
+	assert.Equal(t, 2, calls)
+}
+
+func TestSetModTime_context(t *testing.T) {
+	t.Parallel()
+	tr := make(Tree)
+	require.NoError(t, tr.AddFileContent("a", []byte("content")))
+	require.NoError(t, tr.SetModTime("a", func(ctx context.Context) (time.Time, error) {
+		if err := ctx.Err(); err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(1, 0), nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f, err := tr.Open("a")
+	require.NoError(t, err)
+	st, err := f.(interface {
+		WithContext(context.Context) http.File
+	}).WithContext(ctx).Stat()
+	require.NoError(t, err)
+	assert.Equal(t, time.Time{}, st.ModTime())
+
+	st, err = f.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(1, 0), st.ModTime())
+}
+
+func TestSetCommit(t *testing.T) {
+	t.Parallel()
+
+	commit := &Commit{SHA: "abc", Author: "Gopher", Message: "message"}
+	load := func(context.Context) (*Commit, error) { return commit, nil }
+
+	tr := make(Tree)
+	require.NoError(t, tr.AddFileContent("a/b", []byte("b")))
+	require.NoError(t, tr.AddFileContent("a/c", []byte("c")))
+	require.NoError(t, tr.SetCommit("a/b", load))
+	require.NoError(t, tr.SetCommit("a", load))
+	assert.Error(t, tr.SetCommit("nosuchfile", load))
+
+	type committer interface {
+		Commit() (*Commit, error)
+	}
+	for _, path := range []string{"a", "a/b"} {
+		f, err := tr.Open(path)
+		require.NoError(t, err)
+		got, err := f.(committer).Commit()
+		require.NoError(t, err)
+		assert.Equal(t, commit, got)
+	}
+
+	// File without commit.
+	f, err := tr.Open("a/c")
+	require.NoError(t, err)
+	_, err = f.(committer).Commit()
+	assert.Error(t, err)
+}
+
+func TestSetMode(t *testing.T) {
+	t.Parallel()
+	tr := make(Tree)
+	require.NoError(t, tr.AddFileContent("a/b", []byte("#!/bin/sh")))
+	require.NoError(t, tr.SetMode("a/b", 0755))
+	assert.Error(t, tr.SetMode("a", 0755))
+	assert.Error(t, tr.SetMode("nosuchfile", 0755))
+
+	f, err := tr.Open("a/b")
+	require.NoError(t, err)
+	st, err := f.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), st.Mode())
+	assert.False(t, st.IsDir())
+}
+
 func assertDir(t *testing.T, tr Tree, path string) {
 	t.Helper()
 	d := tr[path]
@@ -244,3 +772,123 @@ func assertContent(t *testing.T, r io.Reader, content string) {
 	require.NoError(t, err)
 	assert.Equal(t, content, string(b))
 }
+
+func TestRemove(t *testing.T) {
+	t.Parallel()
+	tr := make(Tree)
+	require.NoError(t, tr.AddFileContent("a/b/c", []byte("c")))
+	require.NoError(t, tr.AddFileContent("a/d", []byte("d")))
+	require.NoError(t, tr.AddFileContent("ab", []byte("ab")))
+
+	require.NoError(t, tr.Remove("a/d"))
+	assert.Nil(t, tr["a/d"])
+	assertDirNotContains(t, tr, "a", "d")
+
+	require.NoError(t, tr.Remove("/a/"))
+	assert.Nil(t, tr["a"])
+	assert.Nil(t, tr["a/b"])
+	assert.Nil(t, tr["a/b/c"])
+	assertDirNotContains(t, tr, "", "a")
+	assertFile(t, tr, "ab", 2)
+
+	assert.Error(t, tr.Remove("a"))
+	assert.Error(t, tr.Remove(""))
+}
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+	tr := make(Tree)
+	require.NoError(t, tr.AddFileContent("a/b/c", []byte("c")))
+	require.NoError(t, tr.AddFileContent("a/large", []byte("large")))
+	require.NoError(t, tr.AddFileContent("d/e", []byte("e")))
+
+	var called []string
+	require.NoError(t, tr.Filter(func(path string, info os.FileInfo) bool {
+		called = append(called, path)
+		return path != "/a/b" && info.Size() < 5
+	}))
+	// The content of a removed directory is not filtered.
+	assert.NotContains(t, called, "/a/b/c")
+	assert.Nil(t, tr["a/b"])
+	assert.Nil(t, tr["a/b/c"])
+	assert.Nil(t, tr["a/large"])
+	assertDirNotContains(t, tr, "a", "b")
+	assertDirNotContains(t, tr, "a", "large")
+	assertFile(t, tr, "d/e", 1)
+}
+
+func TestRename(t *testing.T) {
+	t.Parallel()
+	tr := make(Tree)
+	require.NoError(t, tr.AddFileContent("a/b/c", []byte("c")))
+	require.NoError(t, tr.AddFileContent("a/d", []byte("d")))
+
+	// Rename a file.
+	require.NoError(t, tr.Rename("a/d", "e/f"))
+	assert.Nil(t, tr["a/d"])
+	assertDirNotContains(t, tr, "a", "d")
+	assertFile(t, tr, "e/f", 1)
+	assertDirContains(t, tr, "e", "f")
+	assertContent(t, tr["e/f"].Open(), "d")
+
+	// Rename a directory.
+	require.NoError(t, tr.Rename("a", "g"))
+	assert.Nil(t, tr["a"])
+	assert.Nil(t, tr["a/b/c"])
+	assertDirNotContains(t, tr, "", "a")
+	assertDir(t, tr, "g")
+	assertDir(t, tr, "g/b")
+	assertDirContains(t, tr, "", "g")
+	assertDirContains(t, tr, "g/b", "c")
+	assertContent(t, tr["g/b/c"].Open(), "c")
+
+	// Failures.
+	assert.Error(t, tr.Rename("nosuchfile", "x"))
+	assert.Error(t, tr.Rename("g", "e"))
+	assert.Error(t, tr.Rename("g", "g/x"))
+	assert.Error(t, tr.Rename("g", "e/f/x"))
+	assert.Error(t, tr.Rename("", "x"))
+}
+
+func assertDirNotContains(t *testing.T, tr Tree, path string, notContains string) {
+	t.Helper()
+	require.NotNil(t, tr[path])
+	files, err := tr[path].Readdir(-1)
+	require.NoError(t, err)
+	for _, f := range files {
+		if f.Name() == notContains {
+			t.Errorf("Dir %q contains file %q", path, notContains)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+	a := make(Tree)
+	require.NoError(t, a.AddFileContent("common/a", []byte("a")))
+	require.NoError(t, a.AddFileContent("conflict", []byte("a")))
+
+	b := make(Tree)
+	require.NoError(t, b.AddFileContent("common/b", []byte("b")))
+	require.NoError(t, b.AddFileContent("only-b/b", []byte("b")))
+	require.NoError(t, b.AddFileContent("conflict/b", []byte("b")))
+
+	// Without overwrite, the conflict fails the merge and a is not changed.
+	assert.Error(t, a.Merge(b, false))
+	assert.Nil(t, a["common/b"])
+	assertContent(t, a["conflict"].Open(), "a")
+
+	require.NoError(t, a.Merge(b, true))
+	assertContent(t, a["common/a"].Open(), "a")
+	assertContent(t, a["common/b"].Open(), "b")
+	assertContent(t, a["only-b/b"].Open(), "b")
+	assertContent(t, a["conflict/b"].Open(), "b")
+	assertDir(t, a, "conflict")
+	assertDirContains(t, a, "", "only-b")
+	assertDirContains(t, a, "common", "a")
+	assertDirContains(t, a, "common", "b")
+
+	// The merged tree is not affected by changes in the other tree.
+	require.NoError(t, b.Rename("common/b", "common/c"))
+	assertFile(t, a, "common/b", 1)
+}
@@ -0,0 +1,85 @@
+// Package webhook handles Github push webhooks.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/posener/gitfs/internal/log"
+)
+
+// maxPayloadSize is the maximal size of a webhook payload. Github caps the
+// payloads at 25MB.
+const maxPayloadSize = 25 << 20
+
+// Push is a push event of a Github repository.
+type Push struct {
+	// Ref is the full name of the pushed ref, such as refs/heads/master.
+	Ref string `json:"ref"`
+	// After is the SHA of the commit that the ref points to after the push.
+	After      string `json:"after"`
+	Repository struct {
+		// FullName is the name of the repository, of the form owner/repo.
+		FullName      string `json:"full_name"`
+		DefaultBranch string `json:"default_branch"`
+	} `json:"repository"`
+}
+
+// Handler returns a handler of Github webhooks that calls onPush for each
+// push event. If the secret is not empty, requests must be signed with it, as
+// Github does when the webhook has a secret. Ping events are acknowledged,
+// and other events are ignored.
+func Handler(secret string, onPush func(Push)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+		if err != nil {
+			http.Error(w, "failed reading payload", http.StatusBadRequest)
+			return
+		}
+		if secret != "" && !Verify(secret, r.Header.Get("X-Hub-Signature-256"), body) {
+			log.Warn("Webhook with invalid signature", "remote", r.RemoteAddr)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		switch event := r.Header.Get("X-GitHub-Event"); event {
+		case "ping":
+			w.WriteHeader(http.StatusOK)
+		case "push":
+			var p Push
+			if err := json.Unmarshal(body, &p); err != nil {
+				http.Error(w, "invalid push payload", http.StatusBadRequest)
+				return
+			}
+			log.Debug("Webhook push", "repository", p.Repository.FullName, "ref", p.Ref, "after", p.After)
+			onPush(p)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			log.Debug("Ignoring webhook event", "event", event)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+// Verify returns true if the signature, of the form sha256=<hex>, is the
+// HMAC-SHA256 of the payload with the given secret.
+func Verify(secret, signature string, payload []byte) bool {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const payload = `{"ref":"refs/heads/master","after":"abc","repository":{"full_name":"x/y","default_branch":"master"}}`
+
+func sign(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		method    string
+		event     string
+		signature string
+		wantCode  int
+		wantPush  bool
+	}{
+		{name: "push", event: "push", signature: sign("secret", payload), wantCode: http.StatusAccepted, wantPush: true},
+		{name: "ping", event: "ping", signature: sign("secret", payload), wantCode: http.StatusOK},
+		{name: "other event", event: "issues", signature: sign("secret", payload), wantCode: http.StatusNoContent},
+		{name: "bad signature", event: "push", signature: sign("other", payload), wantCode: http.StatusUnauthorized},
+		{name: "no signature", event: "push", wantCode: http.StatusUnauthorized},
+		{name: "get", method: http.MethodGet, event: "push", signature: sign("secret", payload), wantCode: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pushes []Push
+			h := Handler("secret", func(p Push) { pushes = append(pushes, p) })
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			r := httptest.NewRequest(method, "/", strings.NewReader(payload))
+			r.Header.Set("X-GitHub-Event", tt.event)
+			r.Header.Set("X-Hub-Signature-256", tt.signature)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if !tt.wantPush {
+				assert.Empty(t, pushes)
+				return
+			}
+			if assert.Len(t, pushes, 1) {
+				assert.Equal(t, "refs/heads/master", pushes[0].Ref)
+				assert.Equal(t, "abc", pushes[0].After)
+				assert.Equal(t, "x/y", pushes[0].Repository.FullName)
+				assert.Equal(t, "master", pushes[0].Repository.DefaultBranch)
+			}
+		})
+	}
+}
+
+func TestHandler_noSecret(t *testing.T) {
+	t.Parallel()
+	pushed := false
+	h := Handler("", func(Push) { pushed = true })
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+	r.Header.Set("X-GitHub-Event", "push")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.True(t, pushed)
+}
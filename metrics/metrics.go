@@ -0,0 +1,123 @@
+// Package metrics exposes Prometheus metrics of gitfs filesystems.
+//
+// The metrics are collected by an observer that is given to the filesystems
+// with gitfs.OptMetrics, and are exposed by registering it in a Prometheus
+// registry:
+//
+//	m := metrics.New()
+//	prometheus.MustRegister(m)
+//	fs, err := gitfs.New(ctx, "github.com/x/y", gitfs.OptMetrics(m))
+//
+// The same observer can be used for many filesystems.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics is an observer of gitfs filesystems, that is also a Prometheus
+// collector of the observed metrics:
+//
+//   - gitfs_requests_total: counter of remote requests, by the response status
+//     code. The code is "error" for requests that failed without a response.
+//
+//   - gitfs_request_duration_seconds: histogram of the latency of remote
+//     requests.
+//
+// * gitfs_downloaded_bytes_total: counter of the bytes of responses bodies.
+//
+//   - gitfs_file_load_duration_seconds: histogram of the latency of loading
+//     file contents, by the result, which is "ok" or "error".
+//
+//   - gitfs_cache_lookups_total: counter of cache lookups, by the cache and the
+//     result, which is "hit" or "miss". The caches are "etag" for OptETagCache,
+//     "tree" for OptTreeCache and "disk" for OptDiskCache.
+type Metrics struct {
+	requests        *prometheus.CounterVec
+	requestDuration prometheus.Histogram
+	downloaded      prometheus.Counter
+	fileLoad        *prometheus.HistogramVec
+	cacheLookups    *prometheus.CounterVec
+}
+
+// New returns new metrics.
+func New() *Metrics {
+	return &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gitfs_requests_total",
+			Help: "Number of remote requests, by response status code.",
+		}, []string{"code"}),
+		requestDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "gitfs_request_duration_seconds",
+			Help:    "Latency of remote requests.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		downloaded: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "gitfs_downloaded_bytes_total",
+			Help: "Number of bytes that were downloaded in responses of remote requests.",
+		}),
+		fileLoad: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "gitfs_file_load_duration_seconds",
+			Help:    "Latency of loading file contents, by result.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"result"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gitfs_cache_lookups_total",
+			Help: "Number of cache lookups, by cache and result.",
+		}, []string{"cache", "result"}),
+	}
+}
+
+// Request implements the gitfs.Observer interface.
+func (m *Metrics) Request(status int, d time.Duration) {
+	code := "error"
+	if status != 0 {
+		code = strconv.Itoa(status)
+	}
+	m.requests.WithLabelValues(code).Inc()
+	m.requestDuration.Observe(d.Seconds())
+}
+
+// Downloaded implements the gitfs.Observer interface.
+func (m *Metrics) Downloaded(n int) {
+	m.downloaded.Add(float64(n))
+}
+
+// FileLoaded implements the gitfs.Observer interface.
+func (m *Metrics) FileLoaded(d time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.fileLoad.WithLabelValues(result).Observe(d.Seconds())
+}
+
+// Cache implements the gitfs.Observer interface.
+func (m *Metrics) Cache(name string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cacheLookups.WithLabelValues(name, result).Inc()
+}
+
+// Describe implements the prometheus.Collector interface.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements the prometheus.Collector interface.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.requests, m.requestDuration, m.downloaded, m.fileLoad, m.cacheLookups}
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+	m := New()
+	r := prometheus.NewRegistry()
+	require.NoError(t, r.Register(m))
+
+	m.Request(200, time.Second)
+	m.Request(200, time.Second)
+	m.Request(0, time.Second)
+	m.Downloaded(10)
+	m.Downloaded(5)
+	m.FileLoaded(time.Second, nil)
+	m.FileLoaded(time.Second, errors.New("failed"))
+	m.Cache("etag", true)
+	m.Cache("etag", false)
+	m.Cache("etag", true)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues("200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("error")))
+	assert.Equal(t, 15.0, testutil.ToFloat64(m.downloaded))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.cacheLookups.WithLabelValues("etag", "hit")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.cacheLookups.WithLabelValues("etag", "miss")))
+
+	families, err := r.Gather()
+	require.NoError(t, err)
+	var names []string
+	for _, f := range families {
+		names = append(names, f.GetName())
+	}
+	assert.Equal(t, []string{
+		"gitfs_cache_lookups_total",
+		"gitfs_downloaded_bytes_total",
+		"gitfs_file_load_duration_seconds",
+		"gitfs_request_duration_seconds",
+		"gitfs_requests_total",
+	}, names)
+}